@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// watchReconnectDelay is how long wallet watch-live waits before dialing a
+// dropped event stream again
+const watchReconnectDelay = 3 * time.Second
+
+// addressWatch is the state of a wallet watch-live session
+type addressWatch struct {
+	address  string
+	apiHost  string // host:port of the local API
+	balance  int64
+	nodeDown bool // A balance fetch failed; reported once until it works again
+}
+
+// runWatchLive follows an address through the local node's event streams
+// (/ws/mempool, /ws/blocks) and prints a line for every pending or confirmed
+// transaction touching it and every balance change. The balance is also
+// polled every --interval, so changes the streams don't describe (block
+// rewards, reorgs, events missed while disconnected) still show up.
+// Runs until Ctrl-C.
+func runWatchLive(cmd *cobra.Command, args []string) {
+	if !ValidateAddress(addressFlag) {
+		fmt.Println("⛔ ERROR: Invalid address provided.")
+		os.Exit(1)
+	}
+	if intervalFlag < time.Second {
+		fmt.Println("⛔ ERROR: --interval must be at least 1s.")
+		os.Exit(1)
+	}
+	apiPort := viper.GetInt("api.port")
+	if apiPort == 0 {
+		apiPort = 8080
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	w := &addressWatch{address: addressFlag, apiHost: fmt.Sprintf("localhost:%d", apiPort)}
+	balance, err := w.fetchBalance(ctx)
+	if err != nil {
+		fmt.Printf("⛔ ERROR: Failed to connect to API: %v\n", err)
+		os.Exit(1)
+	}
+	w.balance = balance
+	fmt.Printf("👀 Watching %s — balance %.8f SOLE. Press Ctrl-C to stop.\n", w.address, float64(balance)/100000000.0)
+
+	events := make(chan []byte, 64)
+	go w.follow(ctx, "/ws/mempool", events)
+	go w.follow(ctx, "/ws/blocks", events)
+
+	ticker := time.NewTicker(intervalFlag)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("\n👋 Stopped watching.")
+			return
+		case msg := <-events:
+			w.handleEvent(ctx, msg)
+		case <-ticker.C:
+			w.checkBalance(ctx)
+		}
+	}
+}
+
+// follow relays the messages of one event stream to events, dialing it again
+// whenever the connection drops, until ctx is done
+func (w *addressWatch) follow(ctx context.Context, path string, events chan<- []byte) {
+	url := "ws://" + w.apiHost + path
+	lost := false
+	for ctx.Err() == nil {
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+		if err == nil {
+			if lost {
+				w.printf("🔌 Reconnected to %s", path)
+				lost = false
+			}
+			closed := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+				case <-closed:
+				}
+				conn.Close()
+			}()
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					break
+				}
+				select {
+				case events <- msg:
+				case <-ctx.Done():
+				}
+			}
+			close(closed)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if !lost {
+			w.printf("⚠️  Lost %s, retrying every %s...", path, watchReconnectDelay)
+			lost = true
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(watchReconnectDelay):
+		}
+	}
+}
+
+// handleEvent prints the transactions of a stream event that touch the
+// address. Blocks and reorgs are followed by a balance check.
+func (w *addressWatch) handleEvent(ctx context.Context, msg []byte) {
+	var head struct {
+		Event string `json:"event"`
+	}
+	if json.Unmarshal(msg, &head) != nil {
+		return
+	}
+
+	switch head.Event {
+	case "new_tx":
+		var evt WsMempoolEvent
+		if json.Unmarshal(msg, &evt) != nil {
+			return
+		}
+		spends, received := w.involvement(evt.Inputs, evt.Outputs)
+		switch {
+		case spends && received > 0:
+			w.printf("⏳ [Pending] TX %.16s... spends from this address, %.8f SOLE back to it", evt.TxID, float64(received)/100000000.0)
+		case spends:
+			w.printf("⏳ [Pending] TX %.16s... spends from this address", evt.TxID)
+		case received > 0:
+			w.printf("⏳ [Pending] TX %.16s... +%.8f SOLE incoming", evt.TxID, float64(received)/100000000.0)
+		}
+
+	case "new_block":
+		var evt WsBlockEvent
+		if json.Unmarshal(msg, &evt) != nil {
+			return
+		}
+		for _, tx := range evt.Transactions {
+			if spends, received := w.involvement(tx.Inputs, tx.Outputs); spends || received > 0 {
+				w.printf("✅ [Confirmed] TX %.16s... in block %d", tx.TxID, evt.Height)
+			}
+		}
+		w.checkBalance(ctx)
+
+	case "reorg":
+		var evt WsReorgEvent
+		if json.Unmarshal(msg, &evt) == nil {
+			w.printf("🔀 [Reorg] Chain switched branch (depth %d), re-checking balance", evt.Depth)
+		}
+		w.checkBalance(ctx)
+	}
+}
+
+// involvement reports whether a transaction spends from the address and how
+// many Photons it pays to it
+func (w *addressWatch) involvement(inputs []WsInput, outputs []WsOutput) (spends bool, received int64) {
+	for _, in := range inputs {
+		if in.Address == w.address {
+			spends = true
+		}
+	}
+	for _, out := range outputs {
+		if out.Address == w.address {
+			received += out.Value
+		}
+	}
+	return spends, received
+}
+
+// checkBalance prints the change since the last known balance, if any
+func (w *addressWatch) checkBalance(ctx context.Context) {
+	balance, err := w.fetchBalance(ctx)
+	if err != nil {
+		if !w.nodeDown && ctx.Err() == nil {
+			w.printf("⚠️  Balance unavailable: %v", err)
+			w.nodeDown = true
+		}
+		return
+	}
+	w.nodeDown = false
+	if balance == w.balance {
+		return
+	}
+	w.printf("💰 Balance %+.8f SOLE → %.8f SOLE", float64(balance-w.balance)/100000000.0, float64(balance)/100000000.0)
+	w.balance = balance
+}
+
+func (w *addressWatch) fetchBalance(ctx context.Context) (int64, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resp, err := apiGet(reqCtx, "http://"+w.apiHost+"/balance/"+w.address)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("API returned HTTP %d", resp.StatusCode)
+	}
+
+	var balResp BalanceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&balResp); err != nil {
+		return 0, fmt.Errorf("failed to parse API response: %w", err)
+	}
+	return balResp.Balance, nil
+}
+
+func (w *addressWatch) printf(format string, a ...any) {
+	fmt.Printf("[%s] %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, a...))
+}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,8 +18,16 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// limiterIdleTTL is the least time a bucket is kept after its last use
+const limiterIdleTTL = 10 * time.Minute
+
+type rateBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
 type IPRateLimiter struct {
-	ips map[string]*rate.Limiter
+	ips map[string]*rateBucket
 	mu  sync.Mutex
 	r   rate.Limit
 	b   int
@@ -22,20 +35,16 @@ type IPRateLimiter struct {
 
 func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
 	i := &IPRateLimiter{
-		ips: make(map[string]*rate.Limiter),
+		ips: make(map[string]*rateBucket),
 		r:   r,
 		b:   b,
 	}
 
-	// Clean up old entries periodically (Proof Of concept)
+	// Drop idle buckets periodically to bound memory
 	go func() {
 		for {
 			time.Sleep(1 * time.Minute)
-			i.mu.Lock()
-			// In production you'd track last access time and delete old ones
-			// For now, simple clear to prevent memory leaks in long run
-			i.ips = make(map[string]*rate.Limiter)
-			i.mu.Unlock()
+			i.prune(time.Now())
 		}
 	}()
 
@@ -46,53 +55,213 @@ func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	limiter, exists := i.ips[ip]
+	bucket, exists := i.ips[ip]
 	if !exists {
-		limiter = rate.NewLimiter(i.r, i.b)
-		i.ips[ip] = limiter
+		bucket = &rateBucket{limiter: rate.NewLimiter(i.r, i.b)}
+		i.ips[ip] = bucket
+	}
+	bucket.lastSeen = time.Now()
+
+	return bucket.limiter
+}
+
+// idleTTL is how long a bucket is kept after its last use. It is at least the
+// time an empty bucket takes to refill, so a dropped bucket was full anyway:
+// a throttled client (or a peer that reconnects) never gets a burst back early.
+func (i *IPRateLimiter) idleTTL() time.Duration {
+	ttl := limiterIdleTTL
+	if i.r > 0 && i.r != rate.Inf {
+		if refill := time.Duration(float64(i.b) / float64(i.r) * float64(time.Second)); refill > ttl {
+			ttl = refill
+		}
+	}
+	return ttl
+}
+
+// prune drops the buckets idle for longer than idleTTL at now
+func (i *IPRateLimiter) prune(now time.Time) {
+	ttl := i.idleTTL()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for key, bucket := range i.ips {
+		if now.Sub(bucket.lastSeen) > ttl {
+			delete(i.ips, key)
+		}
+	}
+}
+
+// setHeaders reports the caller's bucket: X-RateLimit-Limit is the burst size,
+// X-RateLimit-Remaining the whole tokens left and X-RateLimit-Reset the
+// seconds until the bucket is full again.
+func (i *IPRateLimiter) setHeaders(w http.ResponseWriter, l *rate.Limiter, now time.Time) {
+	tokens := l.TokensAt(now)
+	remaining := int(math.Floor(tokens))
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	reset := 0
+	if i.r > 0 && tokens < float64(i.b) {
+		reset = ceilSeconds(time.Duration((float64(i.b) - tokens) / float64(i.r) * float64(time.Second)))
 	}
 
-	return limiter
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(i.b))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(reset))
+}
+
+// ceilSeconds rounds d up to whole seconds, as Retry-After requires
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
 }
 
 func RateLimitMiddleware(limiter *IPRateLimiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-			// 1. Safe Header Parsing for IP (Handles proxies like Nginx)
-			ip := ""
-			forwarded := r.Header.Get("X-Forwarded-For")
-			if forwarded != "" {
-				parts := strings.Split(forwarded, ",")
-				if len(parts) > 0 && parts[0] != "" {
-					ip = strings.TrimSpace(parts[0])
-				}
+			// 1. Check Limit safely
+			l := limiter.GetLimiter(clientIP(r))
+			now := time.Now()
+			res := l.ReserveN(now, 1)
+			if delay := res.DelayFrom(now); !res.OK() || delay > 0 {
+				res.CancelAt(now) // Rejected requests don't consume a token
+				limiter.setHeaders(w, l, now)
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(delay)))
+				writeJSONError(w, http.StatusTooManyRequests, CodeRateLimited, "Too many requests, retry later")
+				return
 			}
+			limiter.setHeaders(w, l, now)
+
+			// Security Header
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-			// Fallback to RemoteAddr if no proxy header exists
-			if ip == "" {
-				var err error
-				ip, _, err = net.SplitHostPort(r.RemoteAddr)
-				if err != nil {
-					ip = r.RemoteAddr
-				}
+// clientIP identifies the caller for rate limiting: the first
+// X-Forwarded-For entry (proxies like Nginx), else the remote address
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		if first := strings.TrimSpace(strings.Split(forwarded, ",")[0]); first != "" {
+			return first
+		}
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// HeldRequests counts the long-poll requests each client holds open. Held
+// requests are bounded by these caps instead of by the steady request rate.
+type HeldRequests struct {
+	perIP    map[string]int
+	total    int
+	maxPerIP int
+	max      int
+	mu       sync.Mutex
+}
+
+func NewHeldRequests(maxPerIP, max int) *HeldRequests {
+	return &HeldRequests{perIP: make(map[string]int), maxPerIP: maxPerIP, max: max}
+}
+
+// Acquire takes a slot for ip, false if the client or the node is at its cap
+func (h *HeldRequests) Acquire(ip string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.perIP[ip] >= h.maxPerIP || h.total >= h.max {
+		return false
+	}
+	h.perIP[ip]++
+	h.total++
+	return true
+}
+
+func (h *HeldRequests) Release(ip string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.perIP[ip]--; h.perIP[ip] <= 0 {
+		delete(h.perIP, ip)
+	}
+	h.total--
+}
+
+// longPollKey carries a *longPoll in the request context
+type longPollKey struct{}
+
+type longPoll struct {
+	held bool // The handler waited instead of answering at once
+}
+
+// markHeld tells LongPollMiddleware the request is being held open
+func markHeld(r *http.Request) {
+	if p, ok := r.Context().Value(longPollKey{}).(*longPoll); ok {
+		p.held = true
+	}
+}
+
+// LongPollMiddleware rate limits an endpoint that holds requests open when
+// they carry a wait query parameter. Other requests go through
+// RateLimitMiddleware. A waiting request needs a token in the caller's
+// bucket, so the burst still applies, but only spends it if it was answered
+// at once: requests the handler holds (see markHeld) count against held
+// instead, so a client polling once per block is never throttled.
+func LongPollMiddleware(limiter *IPRateLimiter, held *HeldRequests) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limited := RateLimitMiddleware(limiter)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("wait") == "" {
+				limited.ServeHTTP(w, r)
+				return
 			}
 
-			// 2. Check Limit safely
+			ip := clientIP(r)
 			l := limiter.GetLimiter(ip)
-			if !l.Allow() {
-				http.Error(w, "429 Too Many Requests", http.StatusTooManyRequests)
+			now := time.Now()
+			res := l.ReserveN(now, 1)
+			delay := res.DelayFrom(now)
+			res.CancelAt(now) // Only checking: the token is spent below if the request isn't held
+			if !res.OK() || delay > 0 {
+				limiter.setHeaders(w, l, now)
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(delay)))
+				writeJSONError(w, http.StatusTooManyRequests, CodeRateLimited, "Too many requests, retry later")
 				return
 			}
-
-			// Security Header
+			if !held.Acquire(ip) {
+				w.Header().Set("Retry-After", "1")
+				writeJSONError(w, http.StatusTooManyRequests, CodeRateLimited,
+					fmt.Sprintf("Too many waiting requests (max %d per client), retry later", held.maxPerIP))
+				return
+			}
+			defer held.Release(ip)
+			limiter.setHeaders(w, l, now)
 			w.Header().Set("X-Content-Type-Options", "nosniff")
 
-			next.ServeHTTP(w, r)
+			poll := &longPoll{}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), longPollKey{}, poll)))
+			if !poll.held {
+				l.Allow()
+			}
 		})
 	}
 }
 
+// writeJSONError answers from a middleware, which may run before
+// commonMiddleware has set the JSON content type
+func writeJSONError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: msg, Code: code})
+}
+
 // CORSMiddleware handles Cross-Origin Resource Sharing & Panic Recovery & Safe Body reading
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -101,7 +270,7 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		defer func() {
 			if err := recover(); err != nil {
 				log.Printf("⚠️  [REST API] Recovered from panic in handler: %v", err)
-				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+				writeJSONError(w, http.StatusInternalServerError, CodeInternal, "Internal server error")
 			}
 		}()
 
@@ -124,6 +293,7 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Origin", origin)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Origin, Accept, Authorization, X-CSRF-Token")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset")
 
 		// Handle Preflight
 		if r.Method == "OPTIONS" {
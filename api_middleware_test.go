@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	handler := RateLimitMiddleware(NewIPRateLimiter(1, 2))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	call := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blocks/tip", nil))
+		return rec
+	}
+
+	first := call()
+	if first.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", first.Code)
+	}
+	for header, want := range map[string]string{"X-RateLimit-Limit": "2", "X-RateLimit-Remaining": "1", "X-RateLimit-Reset": "1"} {
+		if got := first.Header().Get(header); got != want {
+			t.Errorf("first request: %s = %q, want %q", header, got, want)
+		}
+	}
+	if got := first.Header().Get("Retry-After"); got != "" {
+		t.Errorf("allowed request carries Retry-After %q", got)
+	}
+
+	call()
+	limited := call()
+	if limited.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status %d, want %d", limited.Code, http.StatusTooManyRequests)
+	}
+	for header, want := range map[string]string{"Retry-After": "1", "X-RateLimit-Limit": "2", "X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "2"} {
+		if got := limited.Header().Get(header); got != want {
+			t.Errorf("rejected request: %s = %q, want %q", header, got, want)
+		}
+	}
+	if code := decodeError(t, limited); code != CodeRateLimited {
+		t.Errorf("error code %q, want %q", code, CodeRateLimited)
+	}
+}
+
+func TestIPRateLimiterKeepsBucketsUntilIdle(t *testing.T) {
+	limiter := NewIPRateLimiter(1, 2)
+	bucket := limiter.GetLimiter("peer")
+	used := time.Now()
+
+	limiter.prune(used.Add(limiterIdleTTL - time.Second))
+	if limiter.GetLimiter("peer") != bucket {
+		t.Fatal("bucket dropped before its idle TTL")
+	}
+	used = time.Now()
+	limiter.prune(used.Add(limiterIdleTTL + time.Second))
+	if limiter.GetLimiter("peer") == bucket {
+		t.Fatal("idle bucket kept")
+	}
+
+	// A bucket is kept at least until it has refilled
+	slow := NewIPRateLimiter(0.01, 100)
+	bucket = slow.GetLimiter("peer")
+	slow.prune(time.Now().Add(limiterIdleTTL + time.Second))
+	if slow.GetLimiter("peer") != bucket {
+		t.Fatal("bucket dropped before it refilled")
+	}
+}
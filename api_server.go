@@ -1,11 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
@@ -13,11 +23,70 @@ import (
 )
 
 type RestServer struct {
-	P2P *Server
+	P2P     *Server
+	closing chan struct{} // Closed on Shutdown, releases held long polls
+
+	economicsMux sync.Mutex
+	economics    economicsSnapshot
+}
+
+// economicsSnapshot caches the chain-derived part of /stats economics, which
+// needs a full UTXO scan. It is reused while the tip is unchanged, for at
+// most economicsCacheTTL.
+type economicsSnapshot struct {
+	tip     string
+	at      time.Time
+	utxos   int
+	supply  int64
+	avgTxs  float64
+	sampled int
+}
+
+const (
+	economicsCacheTTL = 10 * time.Second
+	// Blocks averaged for avg_txs_per_block
+	economicsWindowBlocks = 100
+)
+
+// unixSocketPrefix selects a Unix domain socket as API listen address (unix:/path/sole.sock)
+const unixSocketPrefix = "unix:"
+
+// APIEndpoint describes where the API listens, for logs and the node summary
+func APIEndpoint(listenHost string, port int) string {
+	if strings.HasPrefix(listenHost, unixSocketPrefix) {
+		return listenHost
+	}
+	return fmt.Sprintf("http://%s:%d", listenHost, port)
+}
+
+// listenUnixSocket binds the API socket. A socket left behind by a node that
+// didn't shut down cleanly is replaced; any other file at path is an error.
+func listenUnixSocket(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Access to the socket is the API's access control: owner and group only
+	if err := os.Chmod(path, 0660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
 }
 
-func StartRestServer(server *Server, listenHost string, port int) {
-	rs := RestServer{P2P: server}
+// StartRestServer serves the REST API in the background and returns the
+// http.Server, for Shutdown. listenHost is an IP, or unix:<path> to serve on
+// a Unix domain socket (port is then unused). A listen failure is fatal.
+func StartRestServer(server *Server, listenHost string, port int) *http.Server {
+	rs := RestServer{P2P: server, closing: make(chan struct{})}
 
 	router := mux.NewRouter()
 	router.Use(commonMiddleware)
@@ -29,41 +98,82 @@ func StartRestServer(server *Server, listenHost string, port int) {
 	// Middleware Wrappers
 	readMW := RateLimitMiddleware(readLimiter)
 	writeMW := RateLimitMiddleware(writeLimiter)
+	tipMW := LongPollMiddleware(readLimiter, NewHeldRequests(maxTipWaitsPerClient, maxTipWaits))
 
 	// Endpoints (Applied specific rate limits)
-	router.Handle("/balance/{address}", readMW(http.HandlerFunc(rs.getBalance))).Methods("GET")
-	router.Handle("/utxos/{address}", readMW(http.HandlerFunc(rs.getUTXOs))).Methods("GET")
-	router.Handle("/blocks/tip", readMW(http.HandlerFunc(rs.getTip))).Methods("GET")
+	router.Handle("/balance/{address}", readMW(rs.fullNodeOnly(rs.getBalance))).Methods("GET")
+	router.Handle("/address/{address}/validate", readMW(http.HandlerFunc(rs.validateAddress))).Methods("GET")
+	router.Handle("/utxos/{address}", readMW(rs.fullNodeOnly(rs.getUTXOs))).Methods("GET")
+	router.Handle("/utxo/{txid}/{vout}", readMW(rs.fullNodeOnly(rs.getUTXO))).Methods("GET")
+	router.Handle("/blocks/tip", tipMW(http.HandlerFunc(rs.getTip))).Methods("GET")
+	router.Handle("/blocks/height/{height}", readMW(http.HandlerFunc(rs.getBlockByHeight))).Methods("GET")
+	router.Handle("/blocks/{hash}/raw", readMW(http.HandlerFunc(rs.getRawBlock))).Methods("GET")
 	router.Handle("/blocks/{hash}", readMW(http.HandlerFunc(rs.getBlock))).Methods("GET")
 	router.Handle("/rawtx/{id}", readMW(http.HandlerFunc(rs.getRawTx))).Methods("GET")
-	router.Handle("/transactions/{address}", readMW(http.HandlerFunc(rs.getTransactions))).Methods("GET")
+	router.Handle("/transactions/{address}", readMW(rs.fullNodeOnly(rs.getTransactions))).Methods("GET")
 	router.Handle("/transaction/{id}", readMW(http.HandlerFunc(rs.getTransaction))).Methods("GET")
+	router.Handle("/transaction/{id}/status", readMW(rs.fullNodeOnly(rs.getTxStatus))).Methods("GET")
+	router.Handle("/transaction/{id}/confirmations", readMW(rs.fullNodeOnly(rs.getTxConfirmations))).Methods("GET")
+	router.Handle("/tx/{id}/acks", readMW(rs.fullNodeOnly(rs.getTxAcks))).Methods("GET")
 	router.Handle("/proof/{id}", readMW(http.HandlerFunc(rs.getMerkleProof))).Methods("GET")
 	router.Handle("/network/peers", readMW(http.HandlerFunc(rs.getPeers))).Methods("GET")
 	router.Handle("/consensus/validators", readMW(http.HandlerFunc(rs.getValidators))).Methods("GET")
+	router.Handle("/consensus/schedule", readMW(http.HandlerFunc(rs.getSchedule))).Methods("GET")
+	router.Handle("/consensus/coverage", readMW(http.HandlerFunc(rs.getCoverage))).Methods("GET")
+	router.Handle("/mempool", readMW(rs.fullNodeOnly(rs.getMempool))).Methods("GET")
+	router.Handle("/mempool/{id}/ancestors", readMW(rs.fullNodeOnly(rs.getMempoolAncestors))).Methods("GET")
+	router.Handle("/mempool/{id}/descendants", readMW(rs.fullNodeOnly(rs.getMempoolDescendants))).Methods("GET")
+	router.Handle("/fee/estimate", readMW(rs.fullNodeOnly(rs.getFeeEstimate))).Methods("GET")
+	router.Handle("/stats", readMW(rs.fullNodeOnly(rs.getStats))).Methods("GET")
+	router.Handle("/orphans", readMW(http.HandlerFunc(rs.getOrphans))).Methods("GET")
+	router.Handle("/node/info", readMW(http.HandlerFunc(rs.getNodeInfo))).Methods("GET")
+	router.Handle("/validator/status", readMW(http.HandlerFunc(rs.getValidatorStatus))).Methods("GET")
+	router.Handle("/search/{query}", readMW(rs.fullNodeOnly(rs.search))).Methods("GET")
 
 	// Stricter limit for Sending Transactions
-	router.Handle("/tx/send", writeMW(http.HandlerFunc(rs.sendTx))).Methods("POST")
+	router.Handle("/tx/send", writeMW(rs.fullNodeOnly(rs.sendTx))).Methods("POST")
+	router.Handle("/blocks/submit", writeMW(rs.fullNodeOnly(rs.submitBlock))).Methods("POST")
+	router.Handle("/tx/{id}/rebroadcast", writeMW(rs.fullNodeOnly(rs.rebroadcastTx))).Methods("POST")
+	router.Handle("/consensus/revocations", writeMW(rs.fullNodeOnly(rs.submitRevocation))).Methods("POST")
 
-	// WebSocket Endpoints (no rate limiting — long-lived connections)
-	router.HandleFunc("/ws/mempool", func(w http.ResponseWriter, r *http.Request) {
+	// WebSocket Endpoints: the handshake is a read request, the long-lived connection isn't limited
+	router.Handle("/ws/mempool", readMW(rs.fullNodeOnly(func(w http.ResponseWriter, r *http.Request) {
 		handleWs(rs.P2P.MempoolHub, w, r)
-	})
-	router.HandleFunc("/ws/blocks", func(w http.ResponseWriter, r *http.Request) {
+	})))
+	router.Handle("/ws/blocks", readMW(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		handleWs(rs.P2P.BlockHub, w, r)
-	})
-
-	addr := fmt.Sprintf("%s:%d", listenHost, port)
-	fmt.Printf("🚀 API Server started on http://%s\n", addr)
+	})))
+	router.Handle("/ws/peers", readMW(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleWs(rs.P2P.PeerHub, w, r)
+	})))
+	router.Handle("/ws/tx/{id}", readMW(rs.fullNodeOnly(rs.watchTx)))
 
 	srv := &http.Server{
 		Handler:      CORSMiddleware(router),
-		Addr:         addr,
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
+	srv.RegisterOnShutdown(func() { close(rs.closing) })
+
+	var ln net.Listener
+	var err error
+	if path, ok := strings.CutPrefix(listenHost, unixSocketPrefix); ok {
+		ln, err = listenUnixSocket(path)
+	} else {
+		srv.Addr = fmt.Sprintf("%s:%d", listenHost, port)
+		ln, err = net.Listen("tcp", srv.Addr)
+	}
+	if err != nil {
+		log.Fatalf("Fatal: API listen on %s: %v", APIEndpoint(listenHost, port), err)
+	}
+	fmt.Printf("🚀 API Server started on %s\n", APIEndpoint(listenHost, port))
 
-	log.Fatal(srv.ListenAndServe())
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	return srv
 }
 
 func commonMiddleware(next http.Handler) http.Handler {
@@ -84,9 +194,10 @@ type TipResponse struct {
 }
 
 type TxSendRequest struct {
-	Hex  string  `json:"hex"`
-	Fee  float64 `json:"fee"`
-	Memo string  `json:"memo"`
+	Hex          string  `json:"hex"`
+	Fee          float64 `json:"fee"`
+	Memo         string  `json:"memo"`
+	AllowHighFee bool    `json:"allow_high_fee,omitempty"`
 }
 
 type SuccessResponse struct {
@@ -94,8 +205,88 @@ type SuccessResponse struct {
 	TxID   string `json:"txid,omitempty"`
 }
 
+type RebroadcastResponse struct {
+	TxID  string `json:"txid"`
+	Peers int    `json:"peers"` // Peers the inv was sent to
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code"` // Stable machine-readable code (see errors.go)
+}
+
+// writeTxError reports a transaction pipeline error with its mapped HTTP status and code
+func writeTxError(w http.ResponseWriter, err error) {
+	w.WriteHeader(TxErrorHTTPStatus(err))
+	code := TxErrorCode(err)
+	if code == "" {
+		code = CodeTxInvalid
+	}
+	json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction rejected: " + err.Error(), Code: code})
+}
+
+// writeTxLookupError answers a failed FindTransaction: 410 if the transaction
+// was mined in a block whose body this node pruned, else 404
+func writeTxLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrBlockPruned) {
+		w.WriteHeader(http.StatusGone)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction is in a pruned block", Code: CodePruned})
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction not found", Code: CodeNotFound})
+}
+
+// fullNodeOnly answers 503 on a light node, which keeps no UTXO set, tx
+// index or mempool
+func (rs *RestServer) fullNodeOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rs.P2P.Light {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Not available on a light node (node start --light)", Code: CodeLightNode})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// writeBlockUnavailable answers 503 when a light node got no block from its peers
+func writeBlockUnavailable(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: "Light node: " + err.Error(), Code: CodeBlockUnavailable})
+}
+
+// withBody fills in the transactions of a block a light node only has the
+// header of (see FetchBlock). On failure the error is written and false
+// returned.
+func (rs *RestServer) withBody(w http.ResponseWriter, r *http.Request, block *Block) bool {
+	if !rs.P2P.Light || !block.Pruned {
+		return true
+	}
+	full, err := rs.P2P.FetchBlock(r.Context(), block.Hash)
+	if err != nil {
+		writeBlockUnavailable(w, err)
+		return false
+	}
+	*block = *full
+	return true
+}
+
+// lightTransaction finds a mined transaction on a light node, in the block
+// its peers supply (see FetchTxBlock). On failure the error is written.
+func (rs *RestServer) lightTransaction(w http.ResponseWriter, r *http.Request, txID []byte) (*Transaction, *Block, bool) {
+	block, err := rs.P2P.FetchTxBlock(r.Context(), txID)
+	if err != nil {
+		writeBlockUnavailable(w, err)
+		return nil, nil, false
+	}
+	for _, tx := range block.Transactions {
+		if bytes.Equal(tx.ID, txID) {
+			return tx, block, true
+		}
+	}
+	writeTxLookupError(w, errors.New("Transaction does not exist"))
+	return nil, nil, false
 }
 
 type MerkleProofResponse struct {
@@ -110,6 +301,19 @@ type RawTxResponse struct {
 	Hex string `json:"hex"`
 }
 
+type RawBlockResponse struct {
+	Hex string `json:"hex"`
+}
+
+type BlockSubmitRequest struct {
+	Hex string `json:"hex"`
+}
+
+// RevocationSubmitRequest carries a certificate printed by chain revoke-validator
+type RevocationSubmitRequest struct {
+	Certificate string `json:"certificate"`
+}
+
 func (rs *RestServer) getMerkleProof(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	txIDHex := vars["id"]
@@ -117,40 +321,48 @@ func (rs *RestServer) getMerkleProof(w http.ResponseWriter, r *http.Request) {
 	txID, err := hex.DecodeString(txIDHex)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format", Code: CodeInvalidRequest})
 		return
 	}
 
-	// Verify the transaction exists
-	_, err = rs.P2P.Blockchain.FindTransaction(txID)
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction not found"})
-		return
-	}
+	var block Block
+	if rs.P2P.Light {
+		_, fetched, ok := rs.lightTransaction(w, r, txID)
+		if !ok {
+			return
+		}
+		block = *fetched
+	} else {
+		// Verify the transaction exists
+		_, err = rs.P2P.Blockchain.FindTransaction(txID)
+		if err != nil {
+			writeTxLookupError(w, err)
+			return
+		}
 
-	// Look up the block using the O(1) BadgerDB index
-	var blockHash []byte
-	err = rs.P2P.Blockchain.Database.View(func(txn *badger.Txn) error {
-		item, badgerErr := txn.Get(append([]byte("tx-"), txID...))
-		if badgerErr != nil {
+		// Look up the block using the O(1) BadgerDB index
+		var blockHash []byte
+		err = rs.P2P.Blockchain.Database.View(func(txn *badger.Txn) error {
+			item, badgerErr := txn.Get(append([]byte("tx-"), txID...))
+			if badgerErr != nil {
+				return badgerErr
+			}
+			blockHash, badgerErr = item.ValueCopy(nil)
 			return badgerErr
-		}
-		blockHash, badgerErr = item.ValueCopy(nil)
-		return badgerErr
-	})
+		})
 
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Block containing the transaction not found"})
-		return
-	}
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Block containing the transaction not found", Code: CodeNotFound})
+			return
+		}
 
-	block, err := rs.P2P.Blockchain.GetBlock(blockHash)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Failed to retrieve block data"})
-		return
+		block, err = rs.P2P.Blockchain.GetBlock(blockHash)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Failed to retrieve block data", Code: CodeInternal})
+			return
+		}
 	}
 
 	var txHashes [][]byte
@@ -162,9 +374,12 @@ func (rs *RestServer) getMerkleProof(w http.ResponseWriter, r *http.Request) {
 	proof, err := mTree.GetMerklePath(txID)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error(), Code: CodeInternal})
 		return
 	}
+	if proof == nil {
+		proof = make([]MerkleStep, 0)
+	}
 
 	response := MerkleProofResponse{
 		TxID:        txIDHex,
@@ -179,16 +394,24 @@ func (rs *RestServer) getMerkleProof(w http.ResponseWriter, r *http.Request) {
 }
 
 type JSONTransactionResponse struct {
-	ID        string       `json:"id"`
-	Inputs    []JSONInput  `json:"inputs"`
-	Outputs   []JSONOutput `json:"outputs"`
-	Timestamp int64        `json:"timestamp"`
-	Memo      string       `json:"memo,omitempty"`
+	ID         string       `json:"id"`
+	Inputs     []JSONInput  `json:"inputs"`
+	Outputs    []JSONOutput `json:"outputs"`
+	Timestamp  int64        `json:"timestamp"`                 // Set by the sender when signing
+	BlockTime  int64        `json:"block_timestamp,omitempty"` // Timestamp of the block that mined it (absent while unconfirmed)
+	LockHeight int          `json:"lock_height,omitempty"`     // Can't be mined below this height
+	Memo       string       `json:"memo,omitempty"`
+	Fee        *int64       `json:"fee,omitempty"` // Set when declared or every input value is known
 }
 
 type JSONInput struct {
-	SenderAddress string `json:"sender_address"`
-	Signature     string `json:"signature"`
+	SenderAddress string   `json:"sender_address"`
+	Signature     string   `json:"signature"`
+	CoinbaseData  string   `json:"coinbase_data,omitempty"`
+	PrevTxID      string   `json:"prev_txid,omitempty"`
+	Vout          int      `json:"vout"`
+	Value         *int64   `json:"value,omitempty"` // Omitted when the spent output can't be resolved
+	ValueSole     *float64 `json:"value_sole,omitempty"`
 }
 
 type JSONOutput struct {
@@ -203,26 +426,252 @@ type PeerResponse struct {
 }
 
 type ValidatorResponse struct {
-	TotalValidators int      `json:"total_validators"`
-	Validators      []string `json:"validators"`
+	TotalValidators int                `json:"total_validators"`
+	Validators      []string           `json:"validators"`
+	Details         []ValidatorStat    `json:"details"`
+	Revoked         []RevokedValidator `json:"revoked"` // Keys rejected from the given height on
+}
+
+type ValidatorStatusResponse struct {
+	IsValidator      bool   `json:"is_validator"`
+	Authorized       bool   `json:"authorized"`
+	PubKey           string `json:"pubkey,omitempty"`
+	Address          string `json:"address,omitempty"`
+	RewardAddress    string `json:"reward_address,omitempty"` // Coinbase recipient of the next block
+	NextHeight       int    `json:"next_height"`
+	ScheduledPubKey  string `json:"scheduled_pubkey"`
+	IsMyTurn         bool   `json:"is_my_turn"`
+	BlocksForged     int    `json:"blocks_forged"`
+	LastForgedHeight int    `json:"last_forged_height"`
+}
+
+// Default and maximum number of heights returned by /consensus/schedule
+const (
+	defaultScheduleCount = 10
+	maxScheduleCount     = 100
+)
+
+// Default and maximum number of blocks covered by /consensus/coverage
+const (
+	defaultCoverageBlocks = 100
+	maxCoverageBlocks     = 1000
+)
+
+// Default and maximum page size of /utxos/{address}
+const (
+	defaultUTXOPageSize = 100
+	maxUTXOPageSize     = 1000
+)
+
+// Long polls on /blocks/tip: longest wait, and how many may be held at once
+const (
+	maxTipWaitSeconds    = 60
+	maxTipWaitsPerClient = 4
+	maxTipWaits          = 1000
+)
+
+type ScheduleEntry struct {
+	Height     int    `json:"height"`
+	PubKey     string `json:"pubkey"`
+	Address    string `json:"address"`
+	EtaSeconds int64  `json:"eta_seconds"` // Earliest expected time from now, if blocks keep coming
+}
+
+type ScheduleResponse struct {
+	Height           int             `json:"height"` // Current tip height
+	BlockTimeSeconds int64           `json:"block_time_seconds"`
+	Schedule         []ScheduleEntry `json:"schedule"`
 }
 
-func ToJSONResponse(tx *Transaction) JSONTransactionResponse {
-	var inputs []JSONInput
-	var outputs []JSONOutput
+type StatsResponse struct {
+	Height                 int             `json:"height"`
+	TipHash                string          `json:"tip_hash"`
+	MempoolSize            int             `json:"mempool_size"`
+	Peers                  int             `json:"peers"`
+	IsSyncing              bool            `json:"is_syncing"`
+	LastTipAdvance         int64           `json:"last_tip_advance"`
+	SecondsSinceTipAdvance int64           `json:"seconds_since_tip_advance"`
+	BlocksByValidator      map[string]int  `json:"blocks_by_validator"` // Address -> main-chain blocks forged
+	InvFilter              InvFilterStats  `json:"inv_filter"`
+	BlockValidation        ValidationStats `json:"block_validation"`
+	Economics              EconomicStats   `json:"economics"`
+	P2PTraffic             P2PTrafficStats `json:"p2p_traffic"`
+}
+
+// P2PTrafficStats counts P2P payload bytes since startup. The _wire fields
+// are what actually crossed the network, after compression.
+type P2PTrafficStats struct {
+	Compression       bool  `json:"compression"` // This node offers compressed payloads
+	SentBytes         int64 `json:"sent_bytes"`
+	SentWireBytes     int64 `json:"sent_wire_bytes"`
+	ReceivedBytes     int64 `json:"received_bytes"`
+	ReceivedWireBytes int64 `json:"received_wire_bytes"`
+}
+
+// EconomicStats summarizes supply and activity. Amounts are in Photons, with
+// the _sole fields giving the same value in SOLE.
+type EconomicStats struct {
+	UTXOCount             int     `json:"utxo_count"`
+	CirculatingSupply     int64   `json:"circulating_supply"` // Value locked in the UTXO set
+	CirculatingSupplySole float64 `json:"circulating_supply_sole"`
+	AvgTxsPerBlock        float64 `json:"avg_txs_per_block"` // Excluding coinbase
+	AvgWindowBlocks       int     `json:"avg_window_blocks"` // Blocks averaged, back from the tip (pruned blocks excluded)
+	MempoolValue          int64   `json:"mempool_value"`     // Outputs of pending transactions, change included
+	MempoolValueSole      float64 `json:"mempool_value_sole"`
+	MempoolFees           int64   `json:"mempool_fees"`
+	MempoolFeesSole       float64 `json:"mempool_fees_sole"`
+}
+
+// OrphanEntry is a received block whose parent this node doesn't have
+type OrphanEntry struct {
+	Hash           string `json:"hash"`
+	PrevHash       string `json:"prev_hash"` // The missing parent
+	Height         int    `json:"height"`
+	Peer           string `json:"peer"`
+	FirstSeen      int64  `json:"first_seen"`
+	WaitingSeconds int64  `json:"waiting_seconds"`
+	Seen           int    `json:"seen"` // Times received
+}
+
+type OrphansResponse struct {
+	Count   int           `json:"count"`
+	Missing []string      `json:"missing_parents"` // Distinct parent hashes the orphans wait on
+	Orphans []OrphanEntry `json:"orphans"`
+}
+
+// ValidationStats reports how long checking block transactions takes
+type ValidationStats struct {
+	Blocks       int64   `json:"blocks"` // Blocks checked since startup
+	AvgMillis    float64 `json:"avg_ms"`
+	MaxMillis    float64 `json:"max_ms"`
+	LastMillis   float64 `json:"last_ms"`
+	MaxInputs    int     `json:"max_inputs"`    // Blocks spending more inputs are rejected
+	OverLimit    int64   `json:"over_limit"`    // Blocks rejected for exceeding max_inputs
+	ParentLoads  int64   `json:"parent_loads"`  // Spent transactions read from the database
+	ParentMisses int64   `json:"parent_misses"` // Inputs spending an unknown transaction
+}
+
+// InvFilterStats counts the work saved by the in-memory inventory filters
+type InvFilterStats struct {
+	KnownBlocks       int   `json:"known_blocks"`        // Hashes loaded in the block filter (0 = disabled)
+	BlockReadsSkipped int64 `json:"block_reads_skipped"` // Block existence checks answered without the database
+	TxFetchesSkipped  int64 `json:"tx_fetches_skipped"`  // Announced txs not fetched because already mined
+}
+
+type NodeInfoResponse struct {
+	Version         string   `json:"version"`
+	Commit          string   `json:"commit"`
+	BuildDate       string   `json:"build_date"`
+	NetworkID       string   `json:"network_id"`
+	ProtocolID      string   `json:"protocol_id"`
+	ProtocolVersion int      `json:"protocol_version"` // Highest P2P protocol version spoken
+	PeerID          string   `json:"peer_id,omitempty"`
+	GenesisHash     string   `json:"genesis_hash"`
+	ListenAddrs     []string `json:"listen_addrs"`
+	APIOnly         bool     `json:"api_only"`
+	Light           bool     `json:"light"`      // Headers only, blocks fetched from peers (node start --light)
+	DustLimit       int64    `json:"dust_limit"` // Smallest non-memo output relayed, in Photons
+	UptimeSeconds   int64    `json:"uptime_seconds"`
+	StartedAt       int64    `json:"started_at"`
+}
+
+type MempoolResponse struct {
+	Size       int     `json:"size"`
+	Bytes      int     `json:"bytes"`
+	MaxBytes   int     `json:"max_bytes"`
+	MinFeeRate float64 `json:"min_fee_rate"` // Photons/byte needed to get in (0 = any)
+	Waiting    int     `json:"waiting"`      // Time-locked txs held until their lock height
+
+	Transactions []MempoolEntry `json:"transactions,omitempty"` // With ?verbose=true, in block order
+}
+
+// MempoolRelativesResponse lists the mempool transactions a transaction
+// depends on (ancestors) or that depend on it (descendants), parents first
+type MempoolRelativesResponse struct {
+	TxID         string         `json:"txid"`
+	Count        int            `json:"count"`
+	Transactions []MempoolEntry `json:"transactions"`
+}
+
+type MempoolEntry struct {
+	TxID       string   `json:"txid"`
+	Fee        int64    `json:"fee"`
+	Size       int      `json:"size"`
+	FeeRate    float64  `json:"fee_rate"`    // Photons/byte
+	ReceivedAt int64    `json:"received_at"` // Unix time this node first accepted it
+	Parents    []string `json:"parents"`     // Direct unconfirmed parents
+}
+
+type FeeEstimateResponse struct {
+	MinFeeRate    float64 `json:"min_fee_rate"`
+	MedianFeeRate float64 `json:"median_fee_rate"`
+	TypicalTxSize int     `json:"typical_tx_size"`
+	SuggestedFee  int64   `json:"suggested_fee"` // Photons for a typical transaction
+}
+
+// typicalTxSize approximates a 1-input, 2-output transaction
+const typicalTxSize = 400
+
+// ToJSONResponse renders a transaction for the API. When chain is non-nil,
+// each input is resolved against the tx index to report the value it spends;
+// inputs whose previous transaction isn't indexed are left without a value.
+// Transactions declaring their Fee report it as is and skip the lookups.
+func ToJSONResponse(tx *Transaction, chain *Blockchain) JSONTransactionResponse {
+	inputs := make([]JSONInput, 0, len(tx.Vin))
+	outputs := make([]JSONOutput, 0, len(tx.Vout))
+	var fee *int64
+
+	var totalOut int64
+	for _, vout := range tx.Vout {
+		totalOut += vout.Value
+	}
 
 	// Inputs
 	if tx.IsCoinbase() {
+		// A coinbase input carries the block reward (subsidy + fees) it pays out
+		reward := totalOut
+		rewardSole := float64(reward) / 100000000.0
 		inputs = append(inputs, JSONInput{
 			SenderAddress: "COINBASE",
 			Signature:     "",
+			CoinbaseData:  string(tx.Vin[0].PubKey),
+			Vout:          tx.Vin[0].Vout,
+			Value:         &reward,
+			ValueSole:     &rewardSole,
 		})
 	} else {
+		// A declared fee was checked against the inputs when the tx was
+		// accepted: no need to look up what each input spends
+		declared := tx.Fee > 0
+		var totalIn int64
+		allKnown := chain != nil
 		for _, vin := range tx.Vin {
-			inputs = append(inputs, JSONInput{
+			input := JSONInput{
 				SenderAddress: AddressFromPubKeyHash(HashPubKey(vin.PubKey)),
 				Signature:     hex.EncodeToString(vin.Signature),
-			})
+				PrevTxID:      hex.EncodeToString(vin.Txid),
+				Vout:          vin.Vout,
+			}
+			if declared {
+				inputs = append(inputs, input)
+				continue
+			}
+			if value, ok := resolveInputValue(chain, vin); ok {
+				valueSole := float64(value) / 100000000.0
+				input.Value = &value
+				input.ValueSole = &valueSole
+				totalIn += value
+			} else {
+				allKnown = false
+			}
+			inputs = append(inputs, input)
+		}
+		if declared {
+			paid := tx.Fee
+			fee = &paid
+		} else if allKnown {
+			paid := totalIn - totalOut
+			fee = &paid
 		}
 	}
 
@@ -252,12 +701,34 @@ func ToJSONResponse(tx *Transaction) JSONTransactionResponse {
 	}
 
 	return JSONTransactionResponse{
-		ID:        hex.EncodeToString(tx.ID),
-		Inputs:    inputs,
-		Outputs:   outputs,
-		Timestamp: tx.Timestamp,
-		Memo:      memo,
+		ID:         hex.EncodeToString(tx.ID),
+		Inputs:     inputs,
+		Outputs:    outputs,
+		Timestamp:  tx.Timestamp,
+		LockHeight: tx.LockHeight,
+		Memo:       memo,
+		Fee:        fee,
+	}
+}
+
+// resolveInputValue looks up the output an input spends via the tx index
+func resolveInputValue(chain *Blockchain, vin TxInput) (int64, bool) {
+	if chain == nil {
+		return 0, false
+	}
+	block, err := chain.FindTransactionBlock(vin.Txid)
+	if err != nil {
+		return 0, false
+	}
+	for _, prevTx := range block.Transactions {
+		if bytes.Equal(prevTx.ID, vin.Txid) {
+			if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+				return 0, false
+			}
+			return prevTx.Vout[vin.Vout].Value, true
+		}
 	}
+	return 0, false
 }
 
 type JSONBlock struct {
@@ -268,12 +739,29 @@ type JSONBlock struct {
 	Transactions  []JSONTransactionResponse `json:"transactions"`
 	Validator     string                    `json:"validator"`
 	Signature     string                    `json:"signature"`
+	Attestations  []JSONAttestation         `json:"attestations,omitempty"`
+	Pruned        bool                      `json:"pruned,omitempty"` // Transactions discarded by a pruning node
+}
+
+type JSONAttestation struct {
+	Validator string `json:"validator"`
+	Signature string `json:"signature"`
 }
 
-func ToJSONBlock(block *Block) JSONBlock {
-	var jsonTxs []JSONTransactionResponse
+func ToJSONBlock(block *Block, chain *Blockchain) JSONBlock {
+	jsonTxs := make([]JSONTransactionResponse, 0, len(block.Transactions))
 	for _, tx := range block.Transactions {
-		jsonTxs = append(jsonTxs, ToJSONResponse(tx))
+		jsonTx := ToJSONResponse(tx, chain)
+		jsonTx.BlockTime = block.Timestamp
+		jsonTxs = append(jsonTxs, jsonTx)
+	}
+
+	var attestations []JSONAttestation
+	for _, a := range block.Attestations {
+		attestations = append(attestations, JSONAttestation{
+			Validator: hex.EncodeToString(a.Validator),
+			Signature: hex.EncodeToString(a.Signature),
+		})
 	}
 
 	return JSONBlock{
@@ -284,6 +772,8 @@ func ToJSONBlock(block *Block) JSONBlock {
 		Transactions:  jsonTxs,
 		Validator:     hex.EncodeToString(block.Validator),
 		Signature:     hex.EncodeToString(block.Signature),
+		Attestations:  attestations,
+		Pruned:        block.Pruned,
 	}
 }
 
@@ -292,13 +782,13 @@ func (rs *RestServer) getBalance(w http.ResponseWriter, r *http.Request) {
 	addr := vars["address"]
 
 	if !ValidateAddress(addr) {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid address"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid address", Code: CodeInvalidAddress})
 		return
 	}
 
 	pubKeyHash, err := ExtractPubKeyHash(addr)
 	if err != nil {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid address encoding"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid address encoding", Code: CodeInvalidAddress})
 		return
 	}
 
@@ -312,47 +802,200 @@ func (rs *RestServer) getBalance(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(BalanceResponse{Address: addr, Balance: balance})
 }
 
+type AddressValidationResponse struct {
+	Address       string `json:"address"`
+	Valid         bool   `json:"valid"`
+	Version       *int   `json:"version,omitempty"` // Only set for valid addresses
+	PubKeyHashHex string `json:"pubkey_hash_hex,omitempty"`
+}
+
+// validateAddress decodes an address for form validation. Malformed input is
+// a normal answer (valid: false, HTTP 200), not an error.
+func (rs *RestServer) validateAddress(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["address"]
+	resp := AddressValidationResponse{Address: addr}
+
+	if ValidateAddress(addr) {
+		decoded, err := Base58Decode([]byte(addr))
+		if err == nil {
+			version := int(decoded[0])
+			resp.Valid = true
+			resp.Version = &version
+			resp.PubKeyHashHex = hex.EncodeToString(decoded[1 : len(decoded)-4])
+		}
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
 type UTXOResponse struct {
 	TxID   string `json:"txid"`
 	Vout   int    `json:"vout"`
 	Amount int64  `json:"amount"`
 }
 
+// UTXOPageResponse is one page of /utxos/{address}. Total and TotalValue
+// cover every output matching the filter, not just this page. Next is the
+// ?after= cursor of the following page, empty on the last one.
+type UTXOPageResponse struct {
+	Address    string         `json:"address"`
+	Total      int            `json:"total"`
+	TotalValue int64          `json:"total_value"`
+	Offset     int            `json:"offset"`
+	Limit      int            `json:"limit"`
+	UTXOs      []UTXOResponse `json:"utxos"`
+	Next       string         `json:"next,omitempty"`
+}
+
+type UTXOStatusResponse struct {
+	TxID           string  `json:"txid"`
+	Vout           int     `json:"vout"`
+	Unspent        bool    `json:"unspent"`
+	Value          int64   `json:"value"`
+	ValueSole      float64 `json:"value_sole"`
+	Address        string  `json:"address"`
+	SpentInMempool bool    `json:"spent_in_mempool"` // An unconfirmed transaction already spends it
+}
+
+func (rs *RestServer) getUTXO(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	txID, err := hex.DecodeString(vars["txid"])
+	if err != nil || len(txID) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format", Code: CodeInvalidRequest})
+		return
+	}
+	vout, err := strconv.Atoi(vars["vout"])
+	if err != nil || vout < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid output index", Code: CodeInvalidRequest})
+		return
+	}
+
+	out, err := rs.P2P.UTXOSet.GetUTXO(txID, vout)
+	if err == badger.ErrKeyNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Output is spent or does not exist", Code: CodeNotFound})
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Failed to read UTXO set", Code: CodeInternal})
+		return
+	}
+
+	response := UTXOStatusResponse{
+		TxID:      hex.EncodeToString(txID),
+		Vout:      vout,
+		Unspent:   true,
+		Value:     out.Value,
+		ValueSole: float64(out.Value) / 100000000.0,
+		Address:   AddressFromPubKeyHash(out.PubKeyHash),
+	}
+
+	rs.P2P.MempoolMux.Lock()
+	for _, item := range rs.P2P.Mempool {
+		for _, vin := range item.Tx.Vin {
+			if vin.Vout == vout && bytes.Equal(vin.Txid, txID) {
+				response.SpentInMempool = true
+			}
+		}
+	}
+	rs.P2P.MempoolMux.Unlock()
+
+	json.NewEncoder(w).Encode(response)
+}
+
 func (rs *RestServer) getRawTx(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	txIDHex := vars["id"]
 
 	txID, err := hex.DecodeString(txIDHex)
 	if err != nil {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format", Code: CodeInvalidRequest})
+		return
+	}
+
+	if rs.P2P.Light {
+		if tx, _, ok := rs.lightTransaction(w, r, txID); ok {
+			json.NewEncoder(w).Encode(RawTxResponse{Hex: hex.EncodeToString(tx.Serialize())})
+		}
 		return
 	}
 
 	tx, err := rs.P2P.Blockchain.FindTransaction(txID)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction not found"})
+		writeTxLookupError(w, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(RawTxResponse{Hex: hex.EncodeToString(tx.Serialize())})
 }
 
+// getUTXOs lists the spendable outputs of an address, read from the UTXO
+// set's address index in outpoint order. Without paging parameters it
+// answers the bare array older clients expect. With ?limit=, ?offset=,
+// ?after= or ?min_value= it answers one page: ?after=<txid>:<vout> resumes
+// after that output, so pages don't shift when the mempool changes, and
+// ?min_value= skips outputs below that many Photons.
 func (rs *RestServer) getUTXOs(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	addr := vars["address"]
 
 	if !ValidateAddress(addr) {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid address"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid address", Code: CodeInvalidAddress})
 		return
 	}
 
 	pubKeyHash, err := ExtractPubKeyHash(addr)
 	if err != nil {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid address encoding"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid address encoding", Code: CodeInvalidAddress})
 		return
 	}
 
+	badRequest := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: msg, Code: CodeInvalidRequest})
+	}
+	query := r.URL.Query()
+	paged := query.Has("limit") || query.Has("offset") || query.Has("after") || query.Has("min_value")
+	limit := defaultUTXOPageSize
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxUTXOPageSize {
+			badRequest(fmt.Sprintf("Invalid limit (1-%d)", maxUTXOPageSize))
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			badRequest("Invalid offset (0 or more)")
+			return
+		}
+		offset = n
+	}
+	after := ""
+	if v := query.Get("after"); v != "" {
+		txID, vout, ok := parseOutpoint(v)
+		if !ok {
+			badRequest("Invalid after (<txid>:<vout>)")
+			return
+		}
+		after = fmt.Sprintf("%x-%d", txID, vout)
+	}
+	var minValue int64
+	if v := query.Get("min_value"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			badRequest("Invalid min_value (Photons, 0 or more)")
+			return
+		}
+		minValue = n
+	}
+
 	// 1. Identify Mempool Spends
 	mempoolSpends := make(map[string]bool)
 	rs.P2P.MempoolMux.Lock()
@@ -364,31 +1007,115 @@ func (rs *RestServer) getUTXOs(w http.ResponseWriter, r *http.Request) {
 	}
 	rs.P2P.MempoolMux.Unlock()
 
-	utxos := rs.P2P.UTXOSet.FindAllUTXOs(pubKeyHash)
-	var response []UTXOResponse
-
-	for _, u := range utxos {
-		// 2. Filter out Mempool-locked UTXOs
+	response := UTXOPageResponse{
+		Address: addr,
+		Offset:  offset,
+		Limit:   limit,
+		UTXOs:   make([]UTXOResponse, 0),
+	}
+	skipped := 0
+	for _, u := range rs.P2P.UTXOSet.FindAllUTXOs(pubKeyHash) {
+		// 2. Filter out Mempool-locked and too small UTXOs
 		key := fmt.Sprintf("%s-%d", u.TxID, u.Vout)
-		if mempoolSpends[key] {
+		if mempoolSpends[key] || u.Output.Value < minValue {
 			continue
 		}
-
-		response = append(response, UTXOResponse{
-			TxID:   u.TxID,
-			Vout:   u.Vout,
-			Amount: u.Output.Value,
-		})
+		response.Total++
+		response.TotalValue += u.Output.Value
+
+		// 3. Count everything, return only the requested page
+		switch {
+		case paged && key <= after:
+		case paged && skipped < offset:
+			skipped++
+		case paged && len(response.UTXOs) == limit:
+			// More follow: the page ends with a cursor to resume from
+			last := response.UTXOs[limit-1]
+			response.Next = fmt.Sprintf("%s:%d", last.TxID, last.Vout)
+		default:
+			response.UTXOs = append(response.UTXOs, UTXOResponse{
+				TxID:   u.TxID,
+				Vout:   u.Vout,
+				Amount: u.Output.Value,
+			})
+		}
 	}
 
-	if response == nil {
-		response = make([]UTXOResponse, 0)
+	if !paged {
+		json.NewEncoder(w).Encode(response.UTXOs)
+		return
 	}
-
 	json.NewEncoder(w).Encode(response)
 }
 
+// parseOutpoint reads an outpoint written as OutpointKey writes it
+func parseOutpoint(s string) (txID []byte, vout int, ok bool) {
+	id, idx, found := strings.Cut(s, ":")
+	if !found {
+		return nil, 0, false
+	}
+	txID, err := hex.DecodeString(id)
+	if err != nil || len(txID) == 0 {
+		return nil, 0, false
+	}
+	vout, err = strconv.Atoi(idx)
+	if err != nil || vout < 0 {
+		return nil, 0, false
+	}
+	return txID, vout, true
+}
+
+// getTip returns the tip. With ?wait=<seconds> it long-polls: the answer
+// comes as soon as the tip height is above since (default: the current
+// height), or with the unchanged tip once wait runs out.
 func (rs *RestServer) getTip(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("wait") == "" {
+		rs.writeTip(w)
+		return
+	}
+
+	wait, err := strconv.Atoi(query.Get("wait"))
+	if err != nil || wait < 0 || wait > maxTipWaitSeconds {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid wait (0-%d seconds)", maxTipWaitSeconds), Code: CodeInvalidRequest})
+		return
+	}
+	since := rs.P2P.Blockchain.GetBestHeight()
+	if v := query.Get("since"); v != "" {
+		if since, err = strconv.Atoi(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid since (a block height)", Code: CodeInvalidRequest})
+			return
+		}
+	}
+
+	// The server-wide WriteTimeout is shorter than the longest wait
+	deadline := time.Now().Add(time.Duration(wait) * time.Second)
+	http.NewResponseController(w).SetWriteDeadline(deadline.Add(5 * time.Second))
+	timeout := time.NewTimer(time.Until(deadline))
+	defer timeout.Stop()
+
+	for {
+		changed := rs.P2P.TipNotifier.Changed()
+		if rs.P2P.Blockchain.GetBestHeight() > since {
+			break
+		}
+		markHeld(r)
+		select {
+		case <-changed:
+			continue
+		case <-timeout.C:
+		case <-rs.closing:
+		case <-r.Context().Done():
+			return
+		}
+		break
+	}
+	rs.writeTip(w)
+}
+
+func (rs *RestServer) writeTip(w http.ResponseWriter) {
 	height := rs.P2P.Blockchain.GetBestHeight()
 	hash := rs.P2P.Blockchain.LastHash
 	json.NewEncoder(w).Encode(TipResponse{Height: height, Hash: hex.EncodeToString(hash)})
@@ -400,66 +1127,544 @@ func (rs *RestServer) getBlock(w http.ResponseWriter, r *http.Request) {
 
 	hash, err := hex.DecodeString(hashHex)
 	if err != nil {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid hash format"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid hash format", Code: CodeInvalidRequest})
 		return
 	}
 
 	block, err := rs.P2P.Blockchain.GetBlock(hash)
 	if err != nil {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Block not found"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Block not found", Code: CodeNotFound})
+		return
+	}
+	if !rs.withBody(w, r, &block) {
 		return
 	}
 
 	// Convert to JSONBlock to have enriched transaction data
-	jsonBlock := ToJSONBlock(&block)
+	jsonBlock := ToJSONBlock(&block, rs.P2P.Blockchain)
 	json.NewEncoder(w).Encode(jsonBlock)
 }
 
-func (rs *RestServer) getTransactions(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	addr := vars["address"]
-
-	if !ValidateAddress(addr) {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid address"})
+func (rs *RestServer) getRawBlock(w http.ResponseWriter, r *http.Request) {
+	hash, err := hex.DecodeString(mux.Vars(r)["hash"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid hash format", Code: CodeInvalidRequest})
 		return
 	}
 
-	txs := rs.P2P.Blockchain.FindTransactions(addr)
-
-	var jsonTxs []JSONTransactionResponse
-	for _, tx := range txs {
-		jsonTxs = append(jsonTxs, ToJSONResponse(&tx))
+	block, err := rs.P2P.Blockchain.GetBlock(hash)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Block not found", Code: CodeNotFound})
+		return
+	}
+	if !rs.withBody(w, r, &block) {
+		return
+	}
+	if block.Pruned {
+		w.WriteHeader(http.StatusGone)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Block body has been pruned", Code: CodePruned})
+		return
 	}
 
-	json.NewEncoder(w).Encode(jsonTxs)
+	json.NewEncoder(w).Encode(RawBlockResponse{Hex: hex.EncodeToString(block.SerializeCanonical())})
 }
 
-func (rs *RestServer) getTransaction(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	txIDHex := vars["id"]
-
-	txID, err := hex.DecodeString(txIDHex)
-	if err != nil {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format"})
+// submitBlock accepts a canonically serialized block, validates it like a
+// block received from a peer and relays it on success.
+func (rs *RestServer) submitBlock(w http.ResponseWriter, r *http.Request) {
+	if rs.P2P.IsAPIOnly() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Node is running in read-only API mode: blocks cannot be submitted", Code: CodeReadOnly})
 		return
 	}
 
-	tx, err := rs.P2P.Blockchain.FindTransaction(txID)
+	var req BlockSubmitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid request body", Code: CodeInvalidRequest})
+		return
+	}
+
+	data, err := hex.DecodeString(req.Hex)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid hex", Code: CodeInvalidRequest})
+		return
+	}
+
+	block, err := DeserializeBlockCanonical(data)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid block: " + err.Error(), Code: CodeBlockInvalid})
+		return
+	}
+
+	if _, err := rs.P2P.Blockchain.GetBlock(block.Hash); err == nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Block already known", Code: CodeAlreadyKnown})
+		return
+	}
+
+	if err := rs.P2P.AcceptBlock(block); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Block " + err.Error(), Code: CodeBlockInvalid})
+		return
+	}
+
+	for _, p := range rs.P2P.Host.Network().Peers() {
+		rs.P2P.SendInv(p, "block", [][]byte{block.Hash})
+	}
+
+	json.NewEncoder(w).Encode(SuccessResponse{Status: "success"})
+}
+
+// submitRevocation applies a validator revocation certificate signed by a
+// majority of the other validators (see chain revoke-validator)
+func (rs *RestServer) submitRevocation(w http.ResponseWriter, r *http.Request) {
+	if rs.P2P.IsAPIOnly() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Node is running in read-only API mode: revocations cannot be applied", Code: CodeReadOnly})
+		return
+	}
+
+	var req RevocationSubmitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid request body", Code: CodeInvalidRequest})
+		return
+	}
+	data, err := hex.DecodeString(req.Certificate)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid hex", Code: CodeInvalidRequest})
+		return
+	}
+
+	revocation, err := DeserializeRevocation(data)
+	added := false
+	if err == nil {
+		rs.P2P.MempoolMux.Lock()
+		added, err = rs.P2P.AddRevocation(revocation)
+		rs.P2P.MempoolMux.Unlock()
+	}
 	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Revocation rejected: " + err.Error(), Code: CodeRevocationBad})
+		return
+	}
+
+	if added {
+		fmt.Printf("⛔ API: Revocation of validator %.16s... queued for the next block\n", revocation.PubKey)
+		rs.P2P.RelayRevocation(revocation, "")
+	}
+	json.NewEncoder(w).Encode(SuccessResponse{Status: "success"})
+}
+
+// rebroadcastTx re-announces a mempool transaction to every connected peer,
+// for peers that missed the first inv (e.g. it was submitted while offline)
+func (rs *RestServer) rebroadcastTx(w http.ResponseWriter, r *http.Request) {
+	if rs.P2P.IsAPIOnly() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Node is running in read-only API mode: transactions cannot be broadcast", Code: CodeReadOnly})
+		return
+	}
+
+	txID := mux.Vars(r)["id"]
+	rs.P2P.MempoolMux.Lock()
+	item, ok := rs.P2P.Mempool[txID]
+	rs.P2P.MempoolMux.Unlock()
+	if !ok {
 		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction not found"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction not in mempool (already mined or unknown)", Code: CodeNotFound})
+		return
+	}
+
+	peers := rs.P2P.Host.Network().Peers()
+	for _, p := range peers {
+		rs.P2P.SendInv(p, "tx", [][]byte{item.Tx.ID})
+	}
+	fmt.Printf("API: Rebroadcast transaction %s to %d peer(s)\n", txID, len(peers))
+
+	json.NewEncoder(w).Encode(RebroadcastResponse{TxID: txID, Peers: len(peers)})
+}
+
+func (rs *RestServer) getBlockByHeight(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	height, err := strconv.Atoi(vars["height"])
+	if err != nil || height < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid height", Code: CodeInvalidRequest})
+		return
+	}
+
+	block, err := rs.P2P.Blockchain.GetBlockByHeight(height)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Block not found", Code: CodeNotFound})
+		return
+	}
+	if !rs.withBody(w, r, &block) {
+		return
+	}
+
+	jsonBlock := ToJSONBlock(&block, rs.P2P.Blockchain)
+	json.NewEncoder(w).Encode(jsonBlock)
+}
+
+func (rs *RestServer) getTransactions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	addr := vars["address"]
+
+	if !ValidateAddress(addr) {
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid address", Code: CodeInvalidAddress})
+		return
+	}
+
+	txs := rs.P2P.Blockchain.FindTransactions(addr)
+
+	jsonTxs := make([]JSONTransactionResponse, 0, len(txs))
+	for _, tx := range txs {
+		jsonTx := ToJSONResponse(&tx, rs.P2P.Blockchain)
+		jsonTx.BlockTime, _ = rs.P2P.Blockchain.TxBlockTime(tx.ID)
+		jsonTxs = append(jsonTxs, jsonTx)
+	}
+
+	json.NewEncoder(w).Encode(jsonTxs)
+}
+
+func (rs *RestServer) getTransaction(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	txIDHex := vars["id"]
+
+	txID, err := hex.DecodeString(txIDHex)
+	if err != nil {
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format", Code: CodeInvalidRequest})
+		return
+	}
+
+	if rs.P2P.Light {
+		tx, block, ok := rs.lightTransaction(w, r, txID)
+		if ok {
+			jsonTx := ToJSONResponse(tx, rs.P2P.Blockchain)
+			jsonTx.BlockTime = block.Timestamp
+			json.NewEncoder(w).Encode(jsonTx)
+		}
+		return
+	}
+
+	tx, err := rs.P2P.Blockchain.FindTransaction(txID)
+	if err != nil {
+		if !errors.Is(err, ErrBlockPruned) && rs.writeTxReplaced(w, txID) {
+			return
+		}
+		writeTxLookupError(w, err)
 		return
 	}
 
-	jsonTx := ToJSONResponse(&tx)
+	jsonTx := ToJSONResponse(&tx, rs.P2P.Blockchain)
+	jsonTx.BlockTime, _ = rs.P2P.Blockchain.TxBlockTime(tx.ID)
 	json.NewEncoder(w).Encode(jsonTx)
 }
 
+// SearchResponse is what GET /search found. Type says which field is set.
+type SearchResponse struct {
+	Type        string                   `json:"type"` // "block", "transaction" or "address"
+	Block       *JSONBlock               `json:"block,omitempty"`
+	Transaction *JSONTransactionResponse `json:"transaction,omitempty"`
+	TxStatus    *TxStatusResponse        `json:"tx_status,omitempty"`
+	Address     *BalanceResponse         `json:"address,omitempty"`
+}
+
+type SearchMissResponse struct {
+	Error string   `json:"error"`
+	Tried []string `json:"tried"` // Resource types the query was looked up as
+}
+
+// search backs an explorer search box: it guesses what the query is from its
+// format and confirms the guess with a lookup. Digits are a height, 64 hex
+// characters a block hash or txid, shorter hex a txid, and a Base58 string with a valid checksum
+// an address.
+func (rs *RestServer) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(mux.Vars(r)["query"])
+	chain := rs.P2P.Blockchain
+	tried := make([]string, 0, 2)
+
+	if height, err := strconv.Atoi(query); err == nil && height >= 0 {
+		tried = append(tried, "height")
+		if block, err := chain.GetBlockByHeight(height); err == nil {
+			jsonBlock := ToJSONBlock(&block, chain)
+			json.NewEncoder(w).Encode(SearchResponse{Type: "block", Block: &jsonBlock})
+			return
+		}
+	}
+
+	// The genesis coinbase has a short fixed ID, so transactions don't need 32 bytes
+	if id, err := hex.DecodeString(query); err == nil && len(id) >= 16 {
+		if len(id) == 32 {
+			tried = append(tried, "block")
+			if block, err := chain.GetBlock(id); err == nil {
+				jsonBlock := ToJSONBlock(&block, chain)
+				json.NewEncoder(w).Encode(SearchResponse{Type: "block", Block: &jsonBlock})
+				return
+			}
+		}
+		tried = append(tried, "transaction")
+
+		status := rs.txStatus(id)
+		var tx Transaction
+		found := false
+		switch status.Status {
+		case "confirmed":
+			if tx, err = chain.FindTransaction(id); err != nil {
+				writeTxLookupError(w, err)
+				return
+			}
+			found = true
+		case "pending", "waiting":
+			rs.P2P.MempoolMux.Lock()
+			item, ok := rs.P2P.Mempool[status.TxID]
+			if !ok {
+				item, ok = rs.P2P.WaitingTxs[status.TxID]
+			}
+			rs.P2P.MempoolMux.Unlock()
+			tx, found = item.Tx, ok
+		}
+		if found {
+			jsonTx := ToJSONResponse(&tx, chain)
+			jsonTx.BlockTime, _ = chain.TxBlockTime(tx.ID)
+			json.NewEncoder(w).Encode(SearchResponse{Type: "transaction", Transaction: &jsonTx, TxStatus: &status})
+			return
+		}
+	}
+
+	if ValidateAddress(query) {
+		tried = append(tried, "address")
+		if pubKeyHash, err := ExtractPubKeyHash(query); err == nil {
+			balance := int64(0)
+			for _, out := range rs.P2P.UTXOSet.FindUnspentOutputs(pubKeyHash) {
+				balance += out.Value
+			}
+			json.NewEncoder(w).Encode(SearchResponse{Type: "address", Address: &BalanceResponse{Address: query, Balance: balance}})
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(SearchMissResponse{Error: "No block, transaction or address matches the query", Tried: tried})
+}
+
+type TxStatusResponse struct {
+	TxID          string `json:"txid"`
+	Status        string `json:"status"` // "confirmed", "pending", "waiting" (time-locked), "replaced" or "unknown"
+	BlockHash     string `json:"block_hash,omitempty"`
+	BlockHeight   int    `json:"block_height"`
+	Confirmations int    `json:"confirmations"`
+	ReplacedBy    string `json:"replaced_by,omitempty"` // Conflicting tx that evicted it from the mempool
+}
+
+// TxReplacedResponse is the 404 of /transaction/{id} and its confirmations
+// for a transaction evicted from the mempool by a conflicting one
+type TxReplacedResponse struct {
+	ErrorResponse
+	ReplacedBy string `json:"replaced_by"`
+}
+
+// writeTxReplaced answers for a replaced transaction and reports whether txID
+// was one
+func (rs *RestServer) writeTxReplaced(w http.ResponseWriter, txID []byte) bool {
+	byID := rs.P2P.ReplacedBy(hex.EncodeToString(txID))
+	if byID == "" {
+		return false
+	}
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(TxReplacedResponse{
+		ErrorResponse: ErrorResponse{Error: "Transaction was replaced by a conflicting transaction", Code: CodeNotFound},
+		ReplacedBy:    byID,
+	})
+	return true
+}
+
+// TxConfirmationsResponse is the minimal confirmation count payment
+// processors poll. A pending transaction has no block fields and in_mempool set.
+type TxConfirmationsResponse struct {
+	Confirmations int    `json:"confirmations"`
+	BlockHeight   *int   `json:"block_height,omitempty"` // Pointer: genesis transactions are at height 0
+	BlockHash     string `json:"block_hash,omitempty"`
+	InMempool     bool   `json:"in_mempool,omitempty"`
+}
+
+// TxAcksResponse lists the peers that confirmed accepting a transaction
+type TxAcksResponse struct {
+	TxID  string     `json:"txid"`
+	Acks  int        `json:"acks"`
+	Peers []TxAckRow `json:"peers"`
+}
+
+type TxAckRow struct {
+	Peer string `json:"peer"`
+	At   int64  `json:"at"` // Unix time of the acknowledgment
+}
+
+// txStatus reports where a transaction currently is: mined, in the mempool, or neither
+func (rs *RestServer) txStatus(txID []byte) TxStatusResponse {
+	response := TxStatusResponse{TxID: hex.EncodeToString(txID), Status: "unknown"}
+
+	if blockHash, height, tipHeight, err := rs.P2P.Blockchain.TxConfirmation(txID); err == nil {
+		response.Status = "confirmed"
+		response.BlockHash = hex.EncodeToString(blockHash)
+		response.BlockHeight = height
+		response.Confirmations = tipHeight - height + 1
+		return response
+	}
+
+	rs.P2P.MempoolMux.Lock()
+	if _, ok := rs.P2P.Mempool[response.TxID]; ok {
+		response.Status = "pending"
+	} else if _, ok := rs.P2P.WaitingTxs[response.TxID]; ok {
+		response.Status = "waiting"
+	}
+	rs.P2P.MempoolMux.Unlock()
+
+	if response.Status == "unknown" {
+		if byID := rs.P2P.ReplacedBy(response.TxID); byID != "" {
+			response.Status = "replaced"
+			response.ReplacedBy = byID
+		}
+	}
+	return response
+}
+
+func (rs *RestServer) getTxStatus(w http.ResponseWriter, r *http.Request) {
+	txID, err := hex.DecodeString(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format", Code: CodeInvalidRequest})
+		return
+	}
+
+	response := rs.txStatus(txID)
+	if response.Status == "unknown" {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// getTxConfirmations answers from the tx index and the mempool maps only:
+// unlike /transaction/{id} it never scans the chain or decodes a block body
+func (rs *RestServer) getTxConfirmations(w http.ResponseWriter, r *http.Request) {
+	txID, err := hex.DecodeString(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format", Code: CodeInvalidRequest})
+		return
+	}
+
+	if blockHash, height, tipHeight, err := rs.P2P.Blockchain.TxConfirmation(txID); err == nil {
+		json.NewEncoder(w).Encode(TxConfirmationsResponse{
+			Confirmations: tipHeight - height + 1,
+			BlockHeight:   &height,
+			BlockHash:     hex.EncodeToString(blockHash),
+		})
+		return
+	}
+
+	txIDHex := hex.EncodeToString(txID)
+	rs.P2P.MempoolMux.Lock()
+	_, pending := rs.P2P.Mempool[txIDHex]
+	if !pending {
+		_, pending = rs.P2P.WaitingTxs[txIDHex]
+	}
+	rs.P2P.MempoolMux.Unlock()
+
+	if !pending {
+		if rs.writeTxReplaced(w, txID) {
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction is neither confirmed nor pending", Code: CodeNotFound})
+		return
+	}
+	json.NewEncoder(w).Encode(TxConfirmationsResponse{InMempool: true})
+}
+
+func (rs *RestServer) getTxAcks(w http.ResponseWriter, r *http.Request) {
+	txID, err := hex.DecodeString(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format", Code: CodeInvalidRequest})
+		return
+	}
+
+	response := TxAcksResponse{TxID: hex.EncodeToString(txID), Peers: []TxAckRow{}}
+	for _, p := range rs.P2P.TxAcks.Peers(response.TxID) {
+		response.Peers = append(response.Peers, TxAckRow{Peer: p.Peer, At: p.At.Unix()})
+	}
+	response.Acks = len(response.Peers)
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// watchTx holds a WebSocket open until the transaction is mined, sends a
+// single tx_confirmed event and closes. Already-mined transactions are
+// reported immediately.
+func (rs *RestServer) watchTx(w http.ResponseWriter, r *http.Request) {
+	txID, err := hex.DecodeString(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid transaction ID format", Code: CodeInvalidRequest})
+		return
+	}
+	txIDHex := hex.EncodeToString(txID)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("⚠️  [WS] Upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Waiting can outlast the server's read/write timeouts
+	conn.SetReadDeadline(time.Time{})
+	conn.SetWriteDeadline(time.Time{})
+
+	// Register before checking the chain so a block landing in between is not missed
+	confirmed := rs.P2P.TxWatcher.Watch(txIDHex)
+	defer rs.P2P.TxWatcher.Cancel(txIDHex, confirmed)
+
+	if status := rs.txStatus(txID); status.Status == "confirmed" {
+		conn.WriteJSON(NewWsTxConfirmedEvent(TxConfirmation{
+			TxID: txIDHex, BlockHash: status.BlockHash, BlockHeight: status.BlockHeight,
+		}))
+		return
+	}
+
+	// Reader loop only detects the client going away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case c := <-confirmed:
+		conn.WriteJSON(NewWsTxConfirmedEvent(c))
+	case <-closed:
+	}
+}
+
 func (rs *RestServer) getPeers(w http.ResponseWriter, r *http.Request) {
-	peers := rs.P2P.Host.Network().Peers()
-	var peerList []string
-	for _, p := range peers {
-		peerList = append(peerList, p.String())
+	peerList := make([]string, 0)
+	if !rs.P2P.IsAPIOnly() {
+		for _, p := range rs.P2P.Host.Network().Peers() {
+			peerList = append(peerList, p.String())
+		}
 	}
 
 	response := PeerResponse{
@@ -470,31 +1675,451 @@ func (rs *RestServer) getPeers(w http.ResponseWriter, r *http.Request) {
 }
 
 func (rs *RestServer) getValidators(w http.ResponseWriter, r *http.Request) {
-	validators := AuthorizedValidators
+	validators := append(make([]string, 0, len(AuthorizedValidators)), AuthorizedValidators...)
 	response := ValidatorResponse{
 		TotalValidators: len(validators),
 		Validators:      validators,
+		Details:         rs.P2P.ValidatorStats.Snapshot(),
+		Revoked:         rs.P2P.Blockchain.RevokedValidators(),
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (rs *RestServer) getSchedule(w http.ResponseWriter, r *http.Request) {
+	count := defaultScheduleCount
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxScheduleCount {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Invalid count (1-%d)", maxScheduleCount), Code: CodeInvalidRequest})
+			return
+		}
+		count = n
+	}
+
+	height := rs.P2P.Blockchain.GetBestHeight()
+	blockTime := int64(MiningInterval.Seconds())
+	response := ScheduleResponse{
+		Height:           height,
+		BlockTimeSeconds: blockTime,
+		Schedule:         []ScheduleEntry{},
 	}
+
+	for i, pubKey := range ValidatorSchedule(height+1, count) {
+		entry := ScheduleEntry{
+			Height:     height + 1 + i,
+			PubKey:     pubKey,
+			EtaSeconds: int64(i+1) * blockTime,
+		}
+		if raw, err := hex.DecodeString(pubKey); err == nil {
+			entry.Address = ValidatorAddress(raw)
+		}
+		response.Schedule = append(response.Schedule, entry)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// getCoverage reports which validator signed each block of a height range.
+// to defaults to the tip and from to the defaultCoverageBlocks before it.
+func (rs *RestServer) getCoverage(w http.ResponseWriter, r *http.Request) {
+	badRequest := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: msg, Code: CodeInvalidRequest})
+	}
+
+	tip := rs.P2P.Blockchain.GetBestHeight()
+	to := tip
+	if v := r.URL.Query().Get("to"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			badRequest("Invalid to height")
+			return
+		}
+		to = min(n, tip)
+	}
+	from := max(to-defaultCoverageBlocks+1, 0)
+	if v := r.URL.Query().Get("from"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			badRequest("Invalid from height")
+			return
+		}
+		from = n
+	}
+	if from > to {
+		badRequest(fmt.Sprintf("from (%d) is above to (%d); the tip is at %d", from, to, tip))
+		return
+	}
+	if to-from+1 > maxCoverageBlocks {
+		badRequest(fmt.Sprintf("Range too large (max %d blocks)", maxCoverageBlocks))
+		return
+	}
+
+	json.NewEncoder(w).Encode(SigningCoverage(rs.P2P.Blockchain, from, to))
+}
+
+func (rs *RestServer) getStats(w http.ResponseWriter, r *http.Request) {
+	response := StatsResponse{
+		Height:  rs.P2P.Blockchain.GetBestHeight(),
+		TipHash: hex.EncodeToString(rs.P2P.Blockchain.LastHash),
+	}
+
+	rs.P2P.MempoolMux.Lock()
+	response.MempoolSize = len(rs.P2P.Mempool)
+	for _, item := range rs.P2P.Mempool {
+		for _, out := range item.Tx.Vout {
+			response.Economics.MempoolValue += out.Value
+		}
+		response.Economics.MempoolFees += item.Fee
+	}
+	rs.P2P.MempoolMux.Unlock()
+
+	if !rs.P2P.IsAPIOnly() {
+		response.Peers = len(rs.P2P.Host.Network().Peers())
+	}
+
+	rs.P2P.BlockBufferMux.Lock()
+	response.IsSyncing = rs.P2P.IsSyncing
+	rs.P2P.BlockBufferMux.Unlock()
+
+	response.BlocksByValidator = make(map[string]int)
+	for _, st := range rs.P2P.ValidatorStats.Snapshot() {
+		response.BlocksByValidator[st.Address] = st.BlocksForged
+	}
+
+	if rs.P2P.Blockchain.KnownBlocks != nil {
+		response.InvFilter.KnownBlocks = rs.P2P.Blockchain.KnownBlocks.Count()
+	}
+	response.InvFilter.BlockReadsSkipped = rs.P2P.Blockchain.SkippedBlockReads.Load()
+	response.InvFilter.TxFetchesSkipped = rs.P2P.SkippedTxFetches.Load()
+
+	response.P2PTraffic = P2PTrafficStats{
+		Compression:       rs.P2P.Compression,
+		SentBytes:         rs.P2P.Wire.SentRaw.Load(),
+		SentWireBytes:     rs.P2P.Wire.SentWire.Load(),
+		ReceivedBytes:     rs.P2P.Wire.RecvRaw.Load(),
+		ReceivedWireBytes: rs.P2P.Wire.RecvWire.Load(),
+	}
+
+	v := &rs.P2P.Blockchain.Validation
+	response.BlockValidation = ValidationStats{
+		Blocks:       v.Blocks.Load(),
+		MaxMillis:    float64(v.MaxNanos.Load()) / 1e6,
+		LastMillis:   float64(v.LastNanos.Load()) / 1e6,
+		MaxInputs:    MaxBlockInputs,
+		OverLimit:    v.OverLimit.Load(),
+		ParentLoads:  v.ParentLoads.Load(),
+		ParentMisses: v.ParentMisses.Load(),
+	}
+	if response.BlockValidation.Blocks > 0 {
+		response.BlockValidation.AvgMillis = float64(v.TotalNanos.Load()) / 1e6 / float64(response.BlockValidation.Blocks)
+	}
+
+	rs.P2P.TipWatchMux.Lock()
+	response.LastTipAdvance = rs.P2P.LastTipAdvance.Unix()
+	response.SecondsSinceTipAdvance = int64(time.Since(rs.P2P.LastTipAdvance).Seconds())
+	rs.P2P.TipWatchMux.Unlock()
+
+	snap := rs.economicsFor(response.TipHash)
+	e := &response.Economics
+	e.UTXOCount = snap.utxos
+	e.CirculatingSupply = snap.supply
+	e.CirculatingSupplySole = float64(snap.supply) / 100000000.0
+	e.AvgTxsPerBlock = snap.avgTxs
+	e.AvgWindowBlocks = snap.sampled
+	e.MempoolValueSole = float64(e.MempoolValue) / 100000000.0
+	e.MempoolFeesSole = float64(e.MempoolFees) / 100000000.0
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// economicsFor returns the cached UTXO totals and block activity for
+// tip, recomputing them when the tip moved or the cache expired
+func (rs *RestServer) economicsFor(tip string) economicsSnapshot {
+	rs.economicsMux.Lock()
+	defer rs.economicsMux.Unlock()
+
+	if rs.economics.tip == tip && time.Since(rs.economics.at) < economicsCacheTTL {
+		return rs.economics
+	}
+
+	snap := economicsSnapshot{tip: tip, at: time.Now()}
+	snap.utxos, snap.supply = rs.P2P.UTXOSet.Totals()
+
+	txs := 0
+	iter := rs.P2P.Blockchain.Iterator()
+	for snap.sampled < economicsWindowBlocks {
+		block := iter.Next()
+		if block.Pruned {
+			break // Transactions no longer stored
+		}
+		snap.sampled++
+		for _, tx := range block.Transactions {
+			if !tx.IsCoinbase() {
+				txs++
+			}
+		}
+		if block.IsGenesis() {
+			break
+		}
+	}
+	if snap.sampled > 0 {
+		snap.avgTxs = float64(txs) / float64(snap.sampled)
+	}
+
+	rs.economics = snap
+	return snap
+}
+
+func (rs *RestServer) getOrphans(w http.ResponseWriter, r *http.Request) {
+	response := OrphansResponse{Missing: []string{}, Orphans: []OrphanEntry{}}
+	seen := make(map[string]bool)
+	for _, o := range rs.P2P.Orphans.Snapshot(rs.P2P.Blockchain) {
+		response.Orphans = append(response.Orphans, OrphanEntry{
+			Hash:           o.Hash,
+			PrevHash:       o.PrevHash,
+			Height:         o.Height,
+			Peer:           o.Peer,
+			FirstSeen:      o.FirstSeen.Unix(),
+			WaitingSeconds: int64(time.Since(o.FirstSeen).Seconds()),
+			Seen:           o.Seen,
+		})
+		if !seen[o.PrevHash] {
+			seen[o.PrevHash] = true
+			response.Missing = append(response.Missing, o.PrevHash)
+		}
+	}
+	response.Count = len(response.Orphans)
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (rs *RestServer) getNodeInfo(w http.ResponseWriter, r *http.Request) {
+	response := NodeInfoResponse{
+		Version:         BuildVersion,
+		Commit:          BuildCommit,
+		BuildDate:       BuildDate,
+		NetworkID:       NetworkID,
+		ProtocolID:      protocolID,
+		ProtocolVersion: ProtocolVersion,
+		ListenAddrs:     []string{},
+		APIOnly:         rs.P2P.IsAPIOnly(),
+		Light:           rs.P2P.Light,
+		DustLimit:       rs.P2P.DustLimit,
+		UptimeSeconds:   int64(time.Since(rs.P2P.StartedAt).Seconds()),
+		StartedAt:       rs.P2P.StartedAt.Unix(),
+	}
+
+	if genesis, err := rs.P2P.Blockchain.GetBlockByHeight(0); err == nil {
+		response.GenesisHash = hex.EncodeToString(genesis.Hash)
+	}
+
+	if !rs.P2P.IsAPIOnly() {
+		response.PeerID = rs.P2P.Host.ID().String()
+		for _, addr := range rs.P2P.Host.Addrs() {
+			response.ListenAddrs = append(response.ListenAddrs, fmt.Sprintf("%s/p2p/%s", addr, response.PeerID))
+		}
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (rs *RestServer) getMempool(w http.ResponseWriter, r *http.Request) {
+	verbose := false
+	if v := r.URL.Query().Get("verbose"); v != "" {
+		var err error
+		if verbose, err = strconv.ParseBool(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid verbose (true or false)", Code: CodeInvalidRequest})
+			return
+		}
+	}
+
+	rs.P2P.MempoolMux.Lock()
+	count, bytes, floor, _ := rs.P2P.MempoolStats()
+	response := MempoolResponse{
+		Size:       count,
+		Bytes:      bytes,
+		MaxBytes:   rs.P2P.MaxMempoolBytes,
+		MinFeeRate: floor,
+		Waiting:    len(rs.P2P.WaitingTxs),
+	}
+	if verbose {
+		// The order the next block would list them in
+		var txs []*Transaction
+		entries := make(map[string]MempoolEntry)
+		for _, id := range rs.P2P.mempoolByPriority() {
+			item := rs.P2P.Mempool[id]
+			txs = append(txs, &item.Tx)
+			entries[id] = rs.mempoolEntry(id, item)
+		}
+		response.Transactions = []MempoolEntry{}
+		for _, tx := range orderForBlock(txs) {
+			response.Transactions = append(response.Transactions, entries[hex.EncodeToString(tx.ID)])
+		}
+	}
+	rs.P2P.MempoolMux.Unlock()
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (rs *RestServer) getMempoolAncestors(w http.ResponseWriter, r *http.Request) {
+	rs.writeMempoolRelatives(w, mux.Vars(r)["id"], func(item MempoolItem) map[string]bool {
+		ancestors, _ := mempoolAncestors(&item.Tx, rs.P2P.Mempool)
+		return ancestors
+	})
+}
+
+func (rs *RestServer) getMempoolDescendants(w http.ResponseWriter, r *http.Request) {
+	txID := mux.Vars(r)["id"]
+	rs.writeMempoolRelatives(w, txID, func(MempoolItem) map[string]bool {
+		return mempoolDescendants(txID, rs.P2P.Mempool)
+	})
+}
+
+// writeMempoolRelatives answers the ancestors/descendants endpoints. related
+// runs with MempoolMux held.
+func (rs *RestServer) writeMempoolRelatives(w http.ResponseWriter, txID string, related func(MempoolItem) map[string]bool) {
+	rs.P2P.MempoolMux.Lock()
+	item, ok := rs.P2P.Mempool[txID]
+	if !ok {
+		rs.P2P.MempoolMux.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction not in mempool (already mined or unknown)", Code: CodeNotFound})
+		return
+	}
+
+	var txs []*Transaction
+	entries := make(map[string]MempoolEntry)
+	for id := range related(item) {
+		rel := rs.P2P.Mempool[id]
+		txs = append(txs, &rel.Tx)
+		entries[id] = rs.mempoolEntry(id, rel)
+	}
+	rs.P2P.MempoolMux.Unlock()
+
+	// Oldest first, then moved so that parents come before their children
+	sort.Slice(txs, func(i, j int) bool {
+		return entries[hex.EncodeToString(txs[i].ID)].ReceivedAt < entries[hex.EncodeToString(txs[j].ID)].ReceivedAt
+	})
+	response := MempoolRelativesResponse{TxID: txID, Transactions: []MempoolEntry{}}
+	for _, tx := range orderForBlock(txs) {
+		response.Transactions = append(response.Transactions, entries[hex.EncodeToString(tx.ID)])
+	}
+	response.Count = len(response.Transactions)
+	json.NewEncoder(w).Encode(response)
+}
+
+// mempoolEntry describes a mempool transaction for the API. Caller must hold
+// MempoolMux.
+func (rs *RestServer) mempoolEntry(txID string, item MempoolItem) MempoolEntry {
+	parents := []string{}
+	for _, vin := range item.Tx.Vin {
+		parentID := hex.EncodeToString(vin.Txid)
+		if _, ok := rs.P2P.Mempool[parentID]; ok && !slices.Contains(parents, parentID) {
+			parents = append(parents, parentID)
+		}
+	}
+	return MempoolEntry{
+		TxID:       txID,
+		Fee:        item.Fee,
+		Size:       item.Size,
+		FeeRate:    item.FeeRate(),
+		ReceivedAt: item.ReceivedAt,
+		Parents:    parents,
+	}
+}
+
+func (rs *RestServer) getFeeEstimate(w http.ResponseWriter, r *http.Request) {
+	rs.P2P.MempoolMux.Lock()
+	_, _, floor, median := rs.P2P.MempoolStats()
+	rs.P2P.MempoolMux.Unlock()
+
+	// Pay the median, but always clear the eviction floor
+	rate := median
+	if rate <= floor {
+		rate = floor + 1
+	}
+
+	json.NewEncoder(w).Encode(FeeEstimateResponse{
+		MinFeeRate:    floor,
+		MedianFeeRate: median,
+		TypicalTxSize: typicalTxSize,
+		SuggestedFee:  int64(math.Ceil(rate * typicalTxSize)),
+	})
+}
+
+func (rs *RestServer) getValidatorStatus(w http.ResponseWriter, r *http.Request) {
+	nextHeight := rs.P2P.Blockchain.GetBestHeight() + 1
+	response := ValidatorStatusResponse{
+		IsValidator:     len(rs.P2P.ValidatorKeys) > 0,
+		NextHeight:      nextHeight,
+		ScheduledPubKey: ScheduledValidator(nextHeight),
+	}
+
+	revoked, err := rs.P2P.Blockchain.Revoked()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Validator revocations can't be read", Code: CodeInternal})
+		return
+	}
+	key := rs.P2P.ForgingKey(nextHeight, revoked)
+	response.IsMyTurn = key != nil
+	if key == nil && len(rs.P2P.ValidatorKeys) > 0 {
+		key = &rs.P2P.ValidatorKeys[0] // Not our turn: report the primary key
+	}
+	if key != nil {
+		_, gone := revoked[key.PubKeyHex]
+		response.PubKey = key.PubKeyHex
+		response.Address = key.Address
+		response.RewardAddress = rs.P2P.RewardAddressFor(key)
+		response.Authorized = IsAuthorizedValidator(response.PubKey) && !gone
+
+		rs.P2P.ForgeStatsMux.Lock()
+		response.BlocksForged = rs.P2P.BlocksForged
+		response.LastForgedHeight = rs.P2P.LastForgedHeight
+		rs.P2P.ForgeStatsMux.Unlock()
+	}
+
 	json.NewEncoder(w).Encode(response)
 }
 
 func (rs *RestServer) sendTx(w http.ResponseWriter, r *http.Request) {
+	if rs.P2P.IsAPIOnly() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Node is running in read-only API mode: transactions cannot be broadcast", Code: CodeReadOnly})
+		return
+	}
+
 	var req TxSendRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error(), Code: CodeInvalidRequest})
 		return
 	}
 
 	txBytes, err := hex.DecodeString(req.Hex)
 	if err != nil {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid hex"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid hex", Code: CodeInvalidRequest})
+		return
+	}
+	if len(txBytes) > MaxTxSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf("Transaction too large (%d bytes, max %d)", len(txBytes), MaxTxSize), Code: CodeTxTooLarge})
 		return
 	}
 
 	// Deserialize
 	tx := DeserializeTransaction(txBytes)
 
+	if err := tx.ValidateSize(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction rejected: " + err.Error(), Code: CodeTxInvalid})
+		return
+	}
+
 	// Validate with mempool context for chained transactions
 	rs.P2P.MempoolMux.Lock()
 	mempoolSnapshot := make(map[string]MempoolItem, len(rs.P2P.Mempool))
@@ -503,8 +2128,28 @@ func (rs *RestServer) sendTx(w http.ResponseWriter, r *http.Request) {
 	}
 	rs.P2P.MempoolMux.Unlock()
 
-	if rs.P2P.Blockchain.VerifyTransactionWithMempool(&tx, mempoolSnapshot) == false {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction invalid"})
+	if err := rs.P2P.Blockchain.ValidateTransaction(&tx, mempoolSnapshot); err != nil {
+		writeTxError(w, err)
+		return
+	}
+
+	// Relay policy: no dust outputs, no fat-finger fees
+	if err := tx.CheckDust(rs.P2P.DustLimit); err != nil {
+		writeTxError(w, err)
+		return
+	}
+	fee, err := rs.P2P.UTXOSet.CalculateFee(&tx, mempoolSnapshot)
+	if err == nil {
+		err = tx.CheckDeclaredFee(fee)
+	}
+	if err != nil {
+		writeTxError(w, err)
+		return
+	}
+	if rs.P2P.MaxTxFee > 0 && fee > rs.P2P.MaxTxFee && !req.AllowHighFee {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: fmt.Sprintf(
+			"Transaction rejected: fee %d Photons exceeds the node ceiling of %d (resend with allow_high_fee if intended)", fee, rs.P2P.MaxTxFee), Code: CodeFeeTooHigh})
 		return
 	}
 
@@ -514,24 +2159,37 @@ func (rs *RestServer) sendTx(w http.ResponseWriter, r *http.Request) {
 	rs.P2P.MempoolMux.Lock()
 	defer rs.P2P.MempoolMux.Unlock()
 
+	if !tx.IsFinal(rs.P2P.Blockchain.GetBestHeight() + 1) {
+		if err := rs.P2P.AddWaitingTx(tx, fee, time.Now().Unix()); err != nil {
+			if errors.Is(err, ErrDoubleSpend) {
+				writeTxError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction rejected: " + err.Error(), Code: CodeMempoolRejected})
+			return
+		}
+		fmt.Printf("API: Time-locked transaction %s waits for height %d\n", txID, tx.LockHeight)
+		json.NewEncoder(w).Encode(SuccessResponse{Status: "waiting", TxID: txID})
+		return
+	}
+
 	if rs.P2P.Mempool[txID].Tx.ID == nil {
 		// Check for mempool double-spend
-		for _, vin := range tx.Vin {
-			inputKey := hex.EncodeToString(vin.Txid) + ":" + fmt.Sprintf("%d", vin.Vout)
-			for existingID, existing := range rs.P2P.Mempool {
-				for _, evin := range existing.Tx.Vin {
-					existingKey := hex.EncodeToString(evin.Txid) + ":" + fmt.Sprintf("%d", evin.Vout)
-					if inputKey == existingKey {
-						json.NewEncoder(w).Encode(ErrorResponse{
-							Error: fmt.Sprintf("Double-spend: input %s already used by mempool TX %s", inputKey, existingID),
-						})
-						return
-					}
-				}
-			}
+		if err := CheckMempoolConflict(&tx, rs.P2P.Mempool); err != nil {
+			writeTxError(w, err)
+			return
+		}
+		if err := CheckMempoolChain(&tx, rs.P2P.Mempool); err != nil {
+			writeTxError(w, err)
+			return
 		}
 
-		rs.P2P.Mempool[txID] = MempoolItem{Tx: tx, AddedAt: time.Now().Unix()}
+		if err := rs.P2P.AddToMempool(tx, fee, time.Now().Unix()); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction rejected: " + err.Error(), Code: CodeMempoolRejected})
+			return
+		}
 		fmt.Printf("API: Transaction added to Mempool: %s\n", txID)
 		BroadcastMempoolTx(rs.P2P.MempoolHub, &tx)
 
@@ -543,6 +2201,6 @@ func (rs *RestServer) sendTx(w http.ResponseWriter, r *http.Request) {
 
 		json.NewEncoder(w).Encode(SuccessResponse{Status: "success", TxID: txID})
 	} else {
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction already in mempool or exists"})
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Transaction already in mempool or exists", Code: CodeAlreadyKnown})
 	}
 }
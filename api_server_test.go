@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// callAPI runs handler on a request with the given mux path variables and
+// returns the recorded response
+func callAPI(handler http.HandlerFunc, method, target string, body []byte, vars map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// decodeError reads the error code of an ErrorResponse
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("undecodable error response %q: %v", rec.Body.String(), err)
+	}
+	return resp.Code
+}
+
+func TestSendTxRejectsOversizedTransaction(t *testing.T) {
+	rs := &RestServer{P2P: newTestServer(t, newTestChain(t))}
+	w, _ := NewWallet()
+
+	body, _ := json.Marshal(TxSendRequest{Hex: hex.EncodeToString(oversizedTx(w.GetAddress()).Serialize())})
+	rec := callAPI(rs.sendTx, "POST", "/tx/send", body, nil)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusRequestEntityTooLarge, rec.Body)
+	}
+	if code := decodeError(t, rec); code != CodeTxTooLarge {
+		t.Fatalf("code %q, want %q", code, CodeTxTooLarge)
+	}
+}
+
+// postTx submits tx to /tx/send
+func postTx(rs *RestServer, tx *Transaction, allowHighFee bool) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(TxSendRequest{Hex: hex.EncodeToString(tx.Serialize()), AllowHighFee: allowHighFee})
+	return callAPI(rs.sendTx, "POST", "/tx/send", body, nil)
+}
+
+func TestSendTxRelayPolicy(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	funding := addTestBlock(t, chain, w).Transactions[0]
+
+	s := newTestServer(t, chain)
+	s.DustLimit = DefaultDustLimit
+	s.MaxTxFee = DefaultMaxTxFee
+	rs := &RestServer{P2P: s}
+	to, _ := NewWallet()
+
+	dust := spendTx(t, w, funding, 0, *NewTxOutput(DefaultDustLimit-1, to.GetAddress()), *NewTxOutput(InitialSubsidy-DefaultDustLimit-1000, w.GetAddress()))
+	if rec := postTx(rs, dust, false); decodeError(t, rec) != "dust_output" {
+		t.Fatalf("dust output: %d %s", rec.Code, rec.Body)
+	}
+
+	highFee := spendTx(t, w, funding, 0, *NewTxOutput(DefaultDustLimit, to.GetAddress()))
+	if rec := postTx(rs, highFee, false); rec.Code != http.StatusBadRequest || decodeError(t, rec) != CodeFeeTooHigh {
+		t.Fatalf("excessive fee: %d %s", rec.Code, rec.Body)
+	}
+	if rec := postTx(rs, highFee, true); rec.Code != http.StatusOK {
+		t.Fatalf("excessive fee with allow_high_fee: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestNodeInfoReportsDustLimit(t *testing.T) {
+	s := NewAPIOnlyServer(newTestChain(t))
+	s.DustLimit = 1000
+	rs := &RestServer{P2P: s}
+
+	srv := httptest.NewServer(http.HandlerFunc(rs.getNodeInfo))
+	defer srv.Close()
+	port, _ := strconv.Atoi(srv.URL[strings.LastIndex(srv.URL, ":")+1:])
+
+	if got := nodeDustLimit(context.Background(), port); got != 1000 {
+		t.Fatalf("dust limit %d, want 1000", got)
+	}
+	srv.Close()
+	if got := nodeDustLimit(context.Background(), port); got != DefaultDustLimit {
+		t.Fatalf("unreachable node: dust limit %d, want the default %d", got, DefaultDustLimit)
+	}
+}
+
+func TestTransactionsListEncodesAsArray(t *testing.T) {
+	rs := &RestServer{P2P: NewAPIOnlyServer(newTestChain(t))}
+	w, _ := NewWallet()
+
+	rec := callAPI(rs.getTransactions, "GET", "/transactions/"+w.GetAddress(), nil, map[string]string{"address": w.GetAddress()})
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Fatalf("no history: got %s, want []", body)
+	}
+}
+
+func TestJSONKeepsSignatureKeys(t *testing.T) {
+	chain := newTestChain(t)
+	genesis, err := chain.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Genesis is unsigned and its coinbase input has no signature: the keys stay
+	data, _ := json.Marshal(ToJSONBlock(&genesis, chain))
+	var block map[string]any
+	if err := json.Unmarshal(data, &block); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"validator", "signature", "transactions"} {
+		if _, ok := block[key]; !ok {
+			t.Errorf("block JSON lacks %q: %s", key, data)
+		}
+	}
+	input := block["transactions"].([]any)[0].(map[string]any)["inputs"].([]any)[0].(map[string]any)
+	if _, ok := input["signature"]; !ok {
+		t.Errorf("coinbase input JSON lacks \"signature\": %v", input)
+	}
+}
+
+func TestTransactionBlockTimestamp(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	other, _ := newValidator(t)
+	withValidators(t, 1, w, other)
+	rs := &RestServer{P2P: NewAPIOnlyServer(chain)}
+
+	funding := addTestBlock(t, chain, w).Transactions[0]
+	spend := spendTx(t, w, funding, 0, *NewTxOutput(InitialSubsidy/2, w.GetAddress()))
+	mined := addTestBlock(t, chain, w, spend)
+	addTestBlock(t, chain, w)
+
+	txID := hex.EncodeToString(spend.ID)
+	rec := callAPI(rs.getTransaction, "GET", "/transaction/"+txID, nil, map[string]string{"id": txID})
+	var resp JSONTransactionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if resp.BlockTime != mined.Timestamp {
+		t.Fatalf("block_timestamp %d, want %d", resp.BlockTime, mined.Timestamp)
+	}
+
+	// A shorter side branch is stored and indexed, but not confirmed
+	side := forkFromGenesis(t, chain, other, 1)[0]
+	chain.AddBlock(side)
+	if !chain.HasTransaction(side.Transactions[0].ID) {
+		t.Fatal("side-branch transaction not indexed")
+	}
+	if ts, ok := chain.TxBlockTime(side.Transactions[0].ID); ok {
+		t.Fatalf("side-branch transaction reported as mined at %d", ts)
+	}
+	if _, ok := chain.TxBlockTime([]byte("unknown")); ok {
+		t.Fatal("unknown transaction reported as mined")
+	}
+}
+
+// searchFor runs /search/{query} and decodes a hit, or returns the miss
+func searchFor(t *testing.T, rs *RestServer, query string) (SearchResponse, *SearchMissResponse) {
+	t.Helper()
+
+	rec := callAPI(rs.search, "GET", "/search/"+query, nil, map[string]string{"query": query})
+	if rec.Code == http.StatusNotFound {
+		var miss SearchMissResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &miss); err != nil {
+			t.Fatalf("%q: %v: %s", query, err, rec.Body)
+		}
+		return SearchResponse{}, &miss
+	}
+	var hit SearchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &hit); err != nil {
+		t.Fatalf("%q: %v: %s", query, err, rec.Body)
+	}
+	return hit, nil
+}
+
+func TestSearch(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	s := NewAPIOnlyServer(chain)
+	rs := &RestServer{P2P: s}
+
+	funding := addTestBlock(t, chain, w)
+	coinbase := funding.Transactions[0]
+	pending := spendTx(t, w, coinbase, 0, *NewTxOutput(InitialSubsidy/2, w.GetAddress()))
+	if err := s.AddToMempool(*pending, pending.Fee, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	genesis, err := chain.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits := []struct {
+		query, typ, want string
+	}{
+		{"1", "block", hex.EncodeToString(funding.Hash)},
+		{hex.EncodeToString(funding.Hash), "block", hex.EncodeToString(funding.Hash)},
+		{hex.EncodeToString(coinbase.ID), "transaction", hex.EncodeToString(coinbase.ID)},
+		{hex.EncodeToString(genesis.Transactions[0].ID), "transaction", hex.EncodeToString(genesis.Transactions[0].ID)}, // Short fixed ID
+		{hex.EncodeToString(pending.ID), "transaction", hex.EncodeToString(pending.ID)},
+		{w.GetAddress(), "address", w.GetAddress()},
+	}
+	for _, h := range hits {
+		hit, miss := searchFor(t, rs, h.query)
+		if miss != nil || hit.Type != h.typ {
+			t.Errorf("%q: got type %q (miss %v), want %q", h.query, hit.Type, miss, h.typ)
+			continue
+		}
+		var got string
+		switch h.typ {
+		case "block":
+			got = hit.Block.Hash
+		case "transaction":
+			got = hit.Transaction.ID
+		case "address":
+			got = hit.Address.Address
+		}
+		if got != h.want {
+			t.Errorf("%q: found %s, want %s", h.query, got, h.want)
+		}
+	}
+	if hit, _ := searchFor(t, rs, hex.EncodeToString(pending.ID)); hit.TxStatus == nil || hit.TxStatus.Status != "pending" {
+		t.Errorf("mempool transaction: status %+v, want pending", hit.TxStatus)
+	}
+
+	misses := map[string][]string{
+		"99":                          {"height"},
+		strings.Repeat("ab", 32):      {"block", "transaction"},
+		strings.Repeat("ab", 20):      {"transaction"},
+		"not-a-block-tx-or-address!!": {},
+	}
+	for query, tried := range misses {
+		_, miss := searchFor(t, rs, query)
+		if miss == nil {
+			t.Errorf("%q: found something", query)
+			continue
+		}
+		if strings.Join(miss.Tried, ",") != strings.Join(tried, ",") {
+			t.Errorf("%q: tried %v, want %v", query, miss.Tried, tried)
+		}
+	}
+}
+
+func TestJSONDeclaredFee(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	funding := addTestBlock(t, chain, w).Transactions[0]
+
+	// The implicit fee is worked out from the spent output
+	implicit := spendTx(t, w, funding, 0, *NewTxOutput(InitialSubsidy-500, w.GetAddress()))
+	implicit.Fee = 0 // Only rendered, so the stale signature doesn't matter
+	resp := ToJSONResponse(implicit, chain)
+	if resp.Fee == nil || *resp.Fee != 500 || resp.Inputs[0].Value == nil {
+		t.Fatalf("implicit fee: fee %v, input value %v", resp.Fee, resp.Inputs[0].Value)
+	}
+
+	// A declared fee is read as is, even when the spent output is unknown
+	unknown := buildBlock(t, tipBlock(t, chain), w).Transactions[0] // Never stored
+	declared := spendTx(t, w, unknown, 0, *NewTxOutput(InitialSubsidy-700, w.GetAddress()))
+	resp = ToJSONResponse(declared, chain)
+	if resp.Fee == nil || *resp.Fee != 700 || resp.Inputs[0].Value != nil {
+		t.Fatalf("declared fee: fee %v, input value %v", resp.Fee, resp.Inputs[0].Value)
+	}
+}
+
+func TestMempoolRelatives(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	funding := addTestBlock(t, chain, w).Transactions[0]
+
+	s := NewAPIOnlyServer(chain)
+	rs := &RestServer{P2P: s}
+	parent := spendTx(t, w, funding, 0, *NewTxOutput(InitialSubsidy-1000, w.GetAddress()))
+	child := spendTx(t, w, parent, 0, *NewTxOutput(InitialSubsidy-2000, w.GetAddress()))
+	for i, tx := range []*Transaction{parent, child} {
+		if err := s.AddToMempool(*tx, tx.Fee, 1708816000+int64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parentID, childID := hex.EncodeToString(parent.ID), hex.EncodeToString(child.ID)
+
+	relatives := func(handler http.HandlerFunc, txID string) []map[string]any {
+		t.Helper()
+		rec := callAPI(handler, "GET", "/mempool/"+txID, nil, map[string]string{"id": txID})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%d %s", rec.Code, rec.Body)
+		}
+		var resp struct {
+			Count        int              `json:"count"`
+			Transactions []map[string]any `json:"transactions"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Count != len(resp.Transactions) {
+			t.Fatalf("count %d for %d transactions", resp.Count, len(resp.Transactions))
+		}
+		return resp.Transactions
+	}
+
+	ancestors := relatives(rs.getMempoolAncestors, childID)
+	if len(ancestors) != 1 || ancestors[0]["txid"] != parentID {
+		t.Fatalf("ancestors of the child: %v, want the parent", ancestors)
+	}
+	// received_at is when this node accepted the transaction
+	if ancestors[0]["received_at"] != float64(1708816000) {
+		t.Fatalf("received_at %v, want 1708816000", ancestors[0]["received_at"])
+	}
+	descendants := relatives(rs.getMempoolDescendants, parentID)
+	if len(descendants) != 1 || descendants[0]["txid"] != childID {
+		t.Fatalf("descendants of the parent: %v, want the child", descendants)
+	}
+	if rec := callAPI(rs.getMempoolAncestors, "GET", "/mempool/00", nil, map[string]string{"id": "00"}); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown transaction: %d, want 404", rec.Code)
+	}
+}
+
+func TestUTXOsPaging(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	var coinbases []*Transaction
+	for i := 0; i < 4; i++ {
+		coinbases = append(coinbases, addTestBlock(t, chain, w).Transactions[0])
+	}
+	s := NewAPIOnlyServer(chain)
+	rs := &RestServer{P2P: s}
+	addr := w.GetAddress()
+	utxos := func(query string) *httptest.ResponseRecorder {
+		return callAPI(rs.getUTXOs, "GET", "/utxos/"+addr+query, nil, map[string]string{"address": addr})
+	}
+
+	// No paging parameters: the bare array older clients read
+	var all []UTXOResponse
+	if err := json.Unmarshal(utxos("").Body.Bytes(), &all); err != nil || len(all) != 4 {
+		t.Fatalf("unpaged response: %d outputs (%v), want an array of 4", len(all), err)
+	}
+
+	var first UTXOPageResponse
+	if err := json.Unmarshal(utxos("?limit=2").Body.Bytes(), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Total != 4 || len(first.UTXOs) != 2 || first.Next == "" {
+		t.Fatalf("first page: total %d, %d outputs, next %q", first.Total, len(first.UTXOs), first.Next)
+	}
+
+	// A spend entering the mempool between pages doesn't shift the next one
+	var spent *Transaction
+	for _, cb := range coinbases {
+		if hex.EncodeToString(cb.ID) == first.UTXOs[0].TxID {
+			spent = cb
+		}
+	}
+	tx := spendTx(t, w, spent, 0, *NewTxOutput(InitialSubsidy-1000, addr))
+	if err := s.AddToMempool(*tx, tx.Fee, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	var second UTXOPageResponse
+	if err := json.Unmarshal(utxos("?limit=2&after="+first.Next).Body.Bytes(), &second); err != nil {
+		t.Fatal(err)
+	}
+	if len(second.UTXOs) != 2 || second.Next != "" {
+		t.Fatalf("second page: %d outputs, next %q, want the last 2", len(second.UTXOs), second.Next)
+	}
+	seen := map[string]bool{}
+	for _, u := range append(first.UTXOs, second.UTXOs...) {
+		seen[u.TxID] = true
+	}
+	if len(seen) != 4 {
+		t.Fatalf("pages cover %d distinct outputs, want 4", len(seen))
+	}
+
+	for _, query := range []string{"?limit=0", "?offset=-1", "?after=nope", "?min_value=x"} {
+		if rec := utxos(query); rec.Code != http.StatusBadRequest || decodeError(t, rec) != CodeInvalidRequest {
+			t.Fatalf("%s: status %d %s, want 400 %s", query, rec.Code, rec.Body, CodeInvalidRequest)
+		}
+	}
+}
+
+func TestSendTxAdmitsUnconfirmedChain(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	funding := addTestBlock(t, chain, w).Transactions[0]
+	s := newTestServer(t, chain)
+	s.DustLimit = DefaultDustLimit
+	s.MaxTxFee = DefaultMaxTxFee
+	rs := &RestServer{P2P: s}
+
+	txs := unconfirmedChain(t, w, funding, MaxMempoolChainDepth+2)
+	// Parent, child, grandchild and on up to the depth limit
+	for i, tx := range txs[:MaxMempoolChainDepth+1] {
+		if rec := postTx(rs, tx, false); rec.Code != http.StatusOK {
+			t.Fatalf("generation %d: %d %s", i, rec.Code, rec.Body)
+		}
+	}
+
+	rec := postTx(rs, txs[MaxMempoolChainDepth+1], false)
+	if rec.Code != http.StatusConflict || decodeError(t, rec) != "unconfirmed_input" {
+		t.Fatalf("%d generations deep: %d %s, want 409 unconfirmed_input", MaxMempoolChainDepth+1, rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// BanDuration is how long a peer reaching banScoreThreshold stays banned
+const BanDuration = time.Hour
+
+// BanList remembers peers banned for misbehaviour. It is the host's
+// connection gater, so a banned peer can neither reconnect nor be dialed
+// (bootnode and mDNS retries included) until its ban expires.
+type BanList struct {
+	banned map[peer.ID]time.Time // PeerID -> ban expiry
+	mux    sync.Mutex
+}
+
+var _ connmgr.ConnectionGater = (*BanList)(nil)
+
+func NewBanList() *BanList {
+	return &BanList{banned: make(map[peer.ID]time.Time)}
+}
+
+// Ban refuses connections with id until now+d. Expired bans are dropped.
+func (bl *BanList) Ban(id peer.ID, now time.Time, d time.Duration) {
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+
+	for p, until := range bl.banned {
+		if now.After(until) {
+			delete(bl.banned, p)
+		}
+	}
+	bl.banned[id] = now.Add(d)
+}
+
+// IsBanned reports whether id is banned at now
+func (bl *BanList) IsBanned(id peer.ID, now time.Time) bool {
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+
+	until, ok := bl.banned[id]
+	if ok && now.After(until) {
+		delete(bl.banned, id)
+		return false
+	}
+	return ok
+}
+
+func (bl *BanList) InterceptPeerDial(p peer.ID) bool {
+	return !bl.IsBanned(p, time.Now())
+}
+
+func (bl *BanList) InterceptAddrDial(p peer.ID, _ multiaddr.Multiaddr) bool {
+	return !bl.IsBanned(p, time.Now())
+}
+
+// InterceptAccept allows every inbound connection: the remote PeerID is only
+// known once the connection is secured
+func (bl *BanList) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (bl *BanList) InterceptSecured(_ network.Direction, p peer.ID, _ network.ConnMultiaddrs) bool {
+	return !bl.IsBanned(p, time.Now())
+}
+
+func (bl *BanList) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
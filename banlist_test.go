@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/time/rate"
+)
+
+func TestBanListExpires(t *testing.T) {
+	bl := NewBanList()
+	id := peer.ID("banned-peer")
+	now := time.Now()
+
+	bl.Ban(id, now, time.Minute)
+	if !bl.IsBanned(id, now.Add(30*time.Second)) {
+		t.Fatal("peer should be banned before the ban expires")
+	}
+	if bl.IsBanned(id, now.Add(2*time.Minute)) {
+		t.Fatal("peer should no longer be banned after the ban expires")
+	}
+	if bl.IsBanned(peer.ID("other-peer"), now) {
+		t.Fatal("unrelated peer reported as banned")
+	}
+}
+
+func TestGetBlocksFloodBansPeer(t *testing.T) {
+	s := newTestServer(t, newTestChain(t))
+	flooder := newTestHost(t)
+	connectHosts(t, flooder, s.Host)
+
+	// The burst is served, every throttled request costs throttlePenalty
+	frames := getBlocksBurst + banScoreThreshold/throttlePenalty + 5
+	for i := 0; i < frames; i++ {
+		if sendP2PFrame(context.Background(), flooder, s.Host.ID(), CommandToBytes("getblocks")) != nil {
+			break // Already disconnected
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.Bans.IsBanned(flooder.ID(), time.Now()) {
+		if time.Now().After(deadline) {
+			t.Fatal("flooding peer was not banned")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for s.Host.Network().Connectedness(flooder.ID()) == network.Connected {
+		if time.Now().After(deadline) {
+			t.Fatal("banned peer is still connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The gater refuses the reconnect
+	flooder.Peerstore().RemovePeer(s.Host.ID())
+	if err := flooder.Connect(context.Background(), peer.AddrInfo{ID: s.Host.ID(), Addrs: s.Host.Addrs()}); err == nil {
+		time.Sleep(100 * time.Millisecond)
+		if s.Host.Network().Connectedness(flooder.ID()) == network.Connected {
+			t.Fatal("banned peer was able to reconnect")
+		}
+	}
+}
+
+func TestMessageFloodDisconnectsAndBansOnReconnect(t *testing.T) {
+	s := newTestServer(t, newTestChain(t))
+	s.MessageLimiter = NewIPRateLimiter(rate.Limit(0.01), 3) // No refill during the test
+	flooder := newTestHost(t)
+	connectHosts(t, flooder, s.Host)
+
+	// Past the burst, the flooder is disconnected and penalized
+	for i := 0; i < 10; i++ {
+		if sendP2PFrame(context.Background(), flooder, s.Host.ID(), CommandToBytes("flood")) != nil {
+			break // Already disconnected
+		}
+	}
+	waitFor(t, "flooder disconnected", func() bool {
+		return s.Host.Network().Connectedness(flooder.ID()) != network.Connected
+	})
+	s.PeerScoresMux.Lock()
+	score := s.PeerScores[flooder.ID().String()]
+	s.PeerScoresMux.Unlock()
+	if score == 0 && !s.Bans.IsBanned(flooder.ID(), time.Now()) {
+		t.Fatal("flood not penalized")
+	}
+
+	// The disconnect doesn't refill the bucket: a reconnect gets no new burst
+	if tokens := s.MessageLimiter.GetLimiter(flooder.ID().String()).Tokens(); tokens >= 1 {
+		t.Fatalf("bucket refilled to %.1f tokens by the disconnect", tokens)
+	}
+	flooder.Peerstore().RemovePeer(s.Host.ID())
+	if err := flooder.Connect(context.Background(), peer.AddrInfo{ID: s.Host.ID(), Addrs: s.Host.Addrs()}); err == nil {
+		sendP2PFrame(context.Background(), flooder, s.Host.ID(), CommandToBytes("flood"))
+	}
+	waitFor(t, "flooder banned", func() bool { return s.Bans.IsBanned(flooder.ID(), time.Now()) })
+}
@@ -9,9 +9,16 @@ import (
 const b58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
 
 func Base58Encode(input []byte) []byte {
+	// Each leading 0x00 byte is encoded as a single '1'. Count them up front so
+	// the mapping mirrors Base58Decode exactly.
+	zeroBytes := 0
+	for zeroBytes < len(input) && input[zeroBytes] == 0x00 {
+		zeroBytes++
+	}
+
 	var result []byte
 
-	x := new(big.Int).SetBytes(input)
+	x := new(big.Int).SetBytes(input[zeroBytes:])
 
 	base := big.NewInt(int64(len(b58Alphabet)))
 	zero := big.NewInt(0)
@@ -22,16 +29,12 @@ func Base58Encode(input []byte) []byte {
 		result = append(result, b58Alphabet[mod.Int64()])
 	}
 
-	ReverseBytes(result)
-
-	for _, b := range input {
-		if b == 0x00 {
-			result = append([]byte{b58Alphabet[0]}, result...)
-		} else {
-			break
-		}
+	for i := 0; i < zeroBytes; i++ {
+		result = append(result, b58Alphabet[0])
 	}
 
+	ReverseBytes(result)
+
 	return result
 }
 
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestBase58RoundTripAddresses(t *testing.T) {
+	// Random hashes hit a leading 0x00 byte (after the version byte) about
+	// once every 256 addresses
+	for i := 0; i < 2000; i++ {
+		pubKeyHash := make([]byte, 20)
+		if _, err := rand.Read(pubKeyHash); err != nil {
+			t.Fatal(err)
+		}
+		if i%10 == 0 {
+			pubKeyHash[0] = 0x00
+		}
+		address := AddressFromPubKeyHash(pubKeyHash)
+
+		decoded, err := Base58Decode([]byte(address))
+		if err != nil {
+			t.Fatalf("%s: %v", address, err)
+		}
+		if got := string(Base58Encode(decoded)); got != address {
+			t.Fatalf("round trip changed %s into %s", address, got)
+		}
+		if !ValidateAddress(address) {
+			t.Fatalf("generated address %s is invalid", address)
+		}
+	}
+}
+
+func TestBase58LeadingZeros(t *testing.T) {
+	for _, input := range [][]byte{
+		{},
+		{0x00},
+		{0x00, 0x00, 0x00},
+		{0x00, 0x00, 0x01},
+		{0x00, 0xff, 0x00},
+		{0x01, 0x00},
+	} {
+		encoded := Base58Encode(input)
+		decoded, err := Base58Decode(encoded)
+		if err != nil {
+			t.Fatalf("%x: %v", input, err)
+		}
+		if !bytes.Equal(decoded, input) {
+			t.Fatalf("%x encoded as %q decodes to %x", input, encoded, decoded)
+		}
+	}
+	if got := string(Base58Encode([]byte{0x00, 0x00})); got != "11" {
+		t.Fatalf("two zero bytes encoded as %q, want \"11\"", got)
+	}
+}
+
+func TestValidateAddressRejectsNonCanonical(t *testing.T) {
+	w, _ := NewWallet()
+	address := w.GetAddress()
+
+	for _, bad := range []string{"1" + address, address[1:], address + "1"} {
+		if ValidateAddress(bad) {
+			t.Fatalf("non-canonical %q accepted", bad)
+		}
+	}
+	if !ValidateAddress("1HSYNy8yXUuUZrkBCnzSc34Lqr8soPAKQL") {
+		t.Fatal("genesis admin address rejected")
+	}
+}
@@ -3,11 +3,29 @@ package main
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"time"
 )
 
+const (
+	// Canonical (gob-free) block encoding, see SerializeCanonical
+	blockFormatVersion   = byte(0x01)
+	blockFormatPruned    = byte(0x02) // Header-only record left by --prune
+	MaxBlockTransactions = 10000
+	MaxBlockAttestations = 100
+
+	// MaxBlockInputs bounds the inputs a block's transactions spend, and with
+	// them the index lookups and signature checks validating it costs. Unlike
+	// a time budget it gives every node the same verdict, however fast or
+	// busy its machine.
+	MaxBlockInputs = 10000
+)
+
 type Block struct {
 	Timestamp     int64
 	Transactions  []*Transaction
@@ -17,9 +35,64 @@ type Block struct {
 	Nonce         int    // PoA Anti-Spam
 	Validator     []byte // Public key of the block validator (64 bytes)
 	Signature     []byte // ECDSA signature of the block hash (64 bytes)
+
+	// Co-signatures from other validators, needed when BlockQuorum > 1.
+	// Not covered by the hash: each one signs it.
+	Attestations []BlockAttestation
+
+	// Validator keys revoked from the next height on (see CheckRevocations).
+	// Covered by the hash when present.
+	Revocations []ValidatorRevocation
+
+	// Set on stored blocks whose transactions were discarded by --prune.
+	// PrunedRoot keeps their merkle root so the header hash still verifies.
+	Pruned     bool
+	PrunedRoot []byte
+}
+
+// BlockAttestation is a validator's signature over a block hash, in the same
+// raw formats as Block.Validator and Block.Signature
+type BlockAttestation struct {
+	Validator []byte
+	Signature []byte
 }
 
+// IsGenesis reports whether this is a genesis block: height 0 and no parent.
+// Genesis carries no PoA signature (its Validator is the literal "Genesis"),
+// so it is checked against the locally built genesis hash instead, and chain
+// walks stop at it.
+func (b *Block) IsGenesis() bool {
+	return b.Height == 0 && len(b.PrevBlockHash) == 0
+}
+
+// HeaderOnly returns a pruned copy of the block: same header, no transactions
+func (b *Block) HeaderOnly() *Block {
+	return &Block{
+		Timestamp:     b.Timestamp,
+		PrevBlockHash: b.PrevBlockHash,
+		Hash:          b.Hash,
+		Height:        b.Height,
+		Nonce:         b.Nonce,
+		Validator:     b.Validator,
+		Signature:     b.Signature,
+		Attestations:  b.Attestations,
+		Revocations:   b.Revocations,
+		Pruned:        true,
+		PrunedRoot:    b.HashTransactions(),
+	}
+}
+
+// Serialize encodes the block for storage in the canonical format
+// (see SerializeCanonical). DeserializeBlock still reads gob-encoded blocks
+// written by older versions.
 func (b *Block) Serialize() []byte {
+	return b.SerializeCanonical()
+}
+
+// SerializeGob encodes the block with encoding/gob, the format used before
+// SerializeCanonical. P2P block messages keep using it so that nodes which
+// only understand gob can still follow the chain during the migration.
+func (b *Block) SerializeGob() []byte {
 	var result bytes.Buffer
 	encoder := gob.NewEncoder(&result)
 
@@ -31,22 +104,223 @@ func (b *Block) Serialize() []byte {
 	return result.Bytes()
 }
 
-// SetHash calculates and sets the deterministic SHA-256 hash of the block header.
-// It explicitly excludes the Signature field to prevent malleability.
-func (b *Block) SetHash() {
-	var txHashes [][]byte
-	for _, tx := range b.Transactions {
-		txHashes = append(txHashes, tx.ID)
+// SerializeCanonical encodes the block in the stable binary format used for
+// interop (GET /blocks/{hash}/raw, POST /blocks/submit). Integers are
+// big-endian int64 and byte fields are int64 length-prefixed, as in
+// Transaction.Serialize:
+//
+//	version(1 byte) | timestamp | height | nonce
+//	| prevHash | hash | validator | signature
+//	| txCount | txCount × (txID | txBytes)
+//
+// Pruned blocks use version 0x02 and end with the merkle root instead of
+// the transaction section. They are only ever stored, never relayed.
+//
+// Attestations, if any, follow as a trailer in either version:
+// count | count × (validator | signature). Single-signer blocks omit it and
+// encode exactly as before. Revocations, if any, follow the attestation
+// trailer (written with a zero count when there are no attestations) in the
+// encodeRevocations format.
+func (b *Block) SerializeCanonical() []byte {
+	var encoded bytes.Buffer
+	writeBytes := func(data []byte) {
+		binary.Write(&encoded, binary.BigEndian, int64(len(data)))
+		encoded.Write(data)
+	}
+
+	if b.Pruned {
+		encoded.WriteByte(blockFormatPruned)
+	} else {
+		encoded.WriteByte(blockFormatVersion)
+	}
+	binary.Write(&encoded, binary.BigEndian, b.Timestamp)
+	binary.Write(&encoded, binary.BigEndian, int64(b.Height))
+	binary.Write(&encoded, binary.BigEndian, int64(b.Nonce))
+	writeBytes(b.PrevBlockHash)
+	writeBytes(b.Hash)
+	writeBytes(b.Validator)
+	writeBytes(b.Signature)
+
+	if b.Pruned {
+		writeBytes(b.PrunedRoot)
+	} else {
+		binary.Write(&encoded, binary.BigEndian, int64(len(b.Transactions)))
+		for _, tx := range b.Transactions {
+			writeBytes(tx.ID)
+			writeBytes(tx.Serialize())
+		}
+	}
+
+	if len(b.Attestations) > 0 || len(b.Revocations) > 0 {
+		binary.Write(&encoded, binary.BigEndian, int64(len(b.Attestations)))
+		for _, a := range b.Attestations {
+			writeBytes(a.Validator)
+			writeBytes(a.Signature)
+		}
+	}
+	if len(b.Revocations) > 0 {
+		encoded.Write(encodeRevocations(b.Revocations))
+	}
+
+	return encoded.Bytes()
+}
+
+// DeserializeBlockCanonical decodes a block written by SerializeCanonical.
+// Transaction IDs must match their content, except in the genesis block
+// whose coinbase carries a fixed ID.
+func DeserializeBlockCanonical(data []byte) (*Block, error) {
+	reader := bytes.NewReader(data)
+
+	readInt := func() (int64, error) {
+		var v int64
+		err := binary.Read(reader, binary.BigEndian, &v)
+		return v, err
+	}
+	readBytes := func() ([]byte, error) {
+		l, err := readInt()
+		if err != nil {
+			return nil, err
+		}
+		if l < 0 || l > MaxFieldLen {
+			return nil, fmt.Errorf("invalid field length %d", l)
+		}
+		field := make([]byte, l)
+		_, err = io.ReadFull(reader, field)
+		return field, err
+	}
+
+	version, err := reader.ReadByte()
+	if err != nil {
+		return nil, errors.New("empty block data")
+	}
+	if version != blockFormatVersion && version != blockFormatPruned {
+		return nil, fmt.Errorf("unsupported block format version %d", version)
 	}
 
-	var merkleRoot []byte
-	if len(txHashes) > 0 {
-		mTree := NewMerkleTree(txHashes)
-		merkleRoot = mTree.RootNode.Data
+	var block Block
+	var height, nonce int64
+	if block.Timestamp, err = readInt(); err != nil {
+		return nil, fmt.Errorf("reading timestamp: %w", err)
+	}
+	if height, err = readInt(); err != nil {
+		return nil, fmt.Errorf("reading height: %w", err)
+	}
+	if nonce, err = readInt(); err != nil {
+		return nil, fmt.Errorf("reading nonce: %w", err)
+	}
+	block.Height = int(height)
+	block.Nonce = int(nonce)
+
+	for _, field := range []*[]byte{&block.PrevBlockHash, &block.Hash, &block.Validator, &block.Signature} {
+		if *field, err = readBytes(); err != nil {
+			return nil, fmt.Errorf("reading header: %w", err)
+		}
+	}
+
+	if version == blockFormatPruned {
+		block.Pruned = true
+		if block.PrunedRoot, err = readBytes(); err != nil {
+			return nil, fmt.Errorf("reading merkle root: %w", err)
+		}
 	} else {
-		merkleRoot = []byte{}
+		txCount, err := readInt()
+		if err != nil {
+			return nil, fmt.Errorf("reading transaction count: %w", err)
+		}
+		if txCount < 0 || txCount > MaxBlockTransactions {
+			return nil, fmt.Errorf("invalid transaction count %d", txCount)
+		}
+
+		for i := 0; i < int(txCount); i++ {
+			id, err := readBytes()
+			if err != nil {
+				return nil, fmt.Errorf("reading transaction %d id: %w", i, err)
+			}
+			raw, err := readBytes()
+			if err != nil {
+				return nil, fmt.Errorf("reading transaction %d: %w", i, err)
+			}
+
+			tx := DeserializeTransaction(raw)
+			// DeserializeTransaction is lenient; a clean round trip proves the bytes were well-formed
+			if !bytes.Equal(tx.Serialize(), raw) {
+				return nil, fmt.Errorf("transaction %d is malformed", i)
+			}
+			if !bytes.Equal(tx.ID, id) && block.Height != 0 {
+				return nil, fmt.Errorf("transaction %d id %x does not match its content", i, id)
+			}
+			tx.ID = id
+			block.Transactions = append(block.Transactions, &tx)
+		}
 	}
 
+	// Optional attestation trailer, then optional revocations
+	if reader.Len() > 0 {
+		count, err := readInt()
+		if err != nil {
+			return nil, fmt.Errorf("reading attestation count: %w", err)
+		}
+		// Zero only ever comes before revocations
+		if count < 0 || count > MaxBlockAttestations || (count == 0 && reader.Len() == 0) {
+			return nil, fmt.Errorf("invalid attestation count %d", count)
+		}
+		for i := 0; i < int(count); i++ {
+			var a BlockAttestation
+			if a.Validator, err = readBytes(); err != nil {
+				return nil, fmt.Errorf("reading attestation %d: %w", i, err)
+			}
+			if a.Signature, err = readBytes(); err != nil {
+				return nil, fmt.Errorf("reading attestation %d: %w", i, err)
+			}
+			block.Attestations = append(block.Attestations, a)
+		}
+	}
+	if reader.Len() > 0 {
+		count, err := readInt()
+		if err != nil {
+			return nil, fmt.Errorf("reading revocation count: %w", err)
+		}
+		if count < 1 || count > MaxBlockRevocations {
+			return nil, fmt.Errorf("invalid revocation count %d", count)
+		}
+		for i := 0; i < int(count); i++ {
+			pubKey, err := readBytes()
+			if err != nil {
+				return nil, fmt.Errorf("reading revocation %d: %w", i, err)
+			}
+			sigCount, err := readInt()
+			if err != nil {
+				return nil, fmt.Errorf("reading revocation %d: %w", i, err)
+			}
+			if sigCount < 0 || sigCount > MaxBlockAttestations {
+				return nil, fmt.Errorf("revocation %d: invalid signature count %d", i, sigCount)
+			}
+			r := ValidatorRevocation{PubKey: string(pubKey)}
+			for j := 0; j < int(sigCount); j++ {
+				var sig BlockAttestation
+				if sig.Validator, err = readBytes(); err != nil {
+					return nil, fmt.Errorf("reading revocation %d: %w", i, err)
+				}
+				if sig.Signature, err = readBytes(); err != nil {
+					return nil, fmt.Errorf("reading revocation %d: %w", i, err)
+				}
+				r.Signatures = append(r.Signatures, sig)
+			}
+			block.Revocations = append(block.Revocations, r)
+		}
+	}
+
+	if reader.Len() > 0 {
+		return nil, fmt.Errorf("%d trailing bytes after block", reader.Len())
+	}
+	return &block, nil
+}
+
+// SetHash calculates and sets the deterministic SHA-256 hash of the block header.
+// It explicitly excludes the Signature field to prevent malleability.
+func (b *Block) SetHash() {
+	merkleRoot := b.HashTransactions()
+
 	timestampBytes := IntToHex(b.Timestamp)
 	heightBytes := IntToHex(int64(b.Height))
 	nonceBytes := IntToHex(int64(b.Nonce))
@@ -62,21 +336,38 @@ func (b *Block) SetHash() {
 		},
 		[]byte{},
 	)
+	if len(b.Revocations) > 0 {
+		// Blocks without revocations hash exactly as before
+		revocationsHash := sha256.Sum256(encodeRevocations(b.Revocations))
+		headers = append(headers, revocationsHash[:]...)
+	}
 
 	hash := sha256.Sum256(headers)
 	b.Hash = hash[:]
 }
 
+// HeaderHash recomputes the hash the block's header commits to. Forged
+// blocks are mined before SignBlock fills in Validator, so their hash covers
+// an empty validator; only genesis hashes its own.
+func (b *Block) HeaderHash() []byte {
+	recomputed := *b
+	if !b.IsGenesis() {
+		recomputed.Validator = nil
+	}
+	recomputed.SetHash()
+	return recomputed.Hash
+}
+
+// HashTransactions returns the merkle root committed to by the block header
 func (b *Block) HashTransactions() []byte {
+	if b.Pruned {
+		return b.PrunedRoot
+	}
 	var txHashes [][]byte
 	for _, tx := range b.Transactions {
 		txHashes = append(txHashes, tx.ID)
 	}
-	if len(txHashes) == 0 {
-		return []byte{}
-	}
-	mTree := NewMerkleTree(txHashes)
-	return mTree.RootNode.Data
+	return MerkleRoot(txHashes)
 }
 
 func NewBlock(transactions []*Transaction, prevBlockHash []byte, height int, validator []byte) *Block {
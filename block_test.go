@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// testBlock builds a signed, attested child of the genesis block paying w
+func testBlock(t *testing.T, w, attester *Wallet) *Block {
+	t.Helper()
+
+	genesis := NewGenesisBlock()
+	to, _ := NewWallet()
+	block := buildBlock(t, genesis, w, spendTx(t, w, genesis.Transactions[0], 0, *NewTxOutput(DefaultDustLimit, to.GetAddress())))
+	key, err := attester.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AttestBlock(block, key); err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+func TestBlockCanonicalRoundTrip(t *testing.T) {
+	w, _ := NewWallet()
+	attester, _ := NewWallet()
+	block := testBlock(t, w, attester)
+
+	decoded, err := DeserializeBlockCanonical(block.SerializeCanonical())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Timestamp != block.Timestamp || decoded.Height != block.Height || decoded.Nonce != block.Nonce ||
+		!bytes.Equal(decoded.PrevBlockHash, block.PrevBlockHash) || !bytes.Equal(decoded.Hash, block.Hash) ||
+		!bytes.Equal(decoded.Validator, block.Validator) || !bytes.Equal(decoded.Signature, block.Signature) {
+		t.Fatalf("round trip changed the header:\n got %+v\nwant %+v", decoded, block)
+	}
+	if !reflect.DeepEqual(decoded.Attestations, block.Attestations) {
+		t.Fatalf("attestations %+v, want %+v", decoded.Attestations, block.Attestations)
+	}
+	if len(decoded.Transactions) != len(block.Transactions) {
+		t.Fatalf("%d transactions, want %d", len(decoded.Transactions), len(block.Transactions))
+	}
+	for i, tx := range decoded.Transactions {
+		if !bytes.Equal(tx.ID, block.Transactions[i].ID) || !bytes.Equal(tx.Serialize(), block.Transactions[i].Serialize()) {
+			t.Fatalf("transaction %d changed", i)
+		}
+	}
+	if !bytes.Equal(decoded.HashTransactions(), block.HashTransactions()) {
+		t.Fatal("merkle root changed")
+	}
+
+	pruned := block.HeaderOnly()
+	decoded, err = DeserializeBlockCanonical(pruned.SerializeCanonical())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Pruned || !bytes.Equal(decoded.PrunedRoot, block.HashTransactions()) || len(decoded.Attestations) != 1 {
+		t.Fatalf("pruned round trip: %+v", decoded)
+	}
+}
+
+func TestDeserializeBlockReadsGob(t *testing.T) {
+	w, _ := NewWallet()
+	attester, _ := NewWallet()
+	block := testBlock(t, w, attester)
+
+	decoded := DeserializeBlock(block.SerializeGob())
+	if decoded == nil || !bytes.Equal(decoded.Hash, block.Hash) || len(decoded.Transactions) != len(block.Transactions) {
+		t.Fatalf("gob block decoded as %+v", decoded)
+	}
+	if !bytes.Equal(decoded.Serialize(), block.Serialize()) {
+		t.Fatal("gob-decoded block re-encodes differently")
+	}
+}
+
+func TestDeserializeBlockRejectsForeignTxID(t *testing.T) {
+	w, _ := NewWallet()
+	attester, _ := NewWallet()
+	block := testBlock(t, w, attester)
+	block.Transactions[1].ID = bytes.Repeat([]byte{0xab}, 32)
+
+	if _, err := DeserializeBlockCanonical(block.SerializeCanonical()); err == nil {
+		t.Fatal("block with a transaction ID not matching its content decoded")
+	}
+	if _, err := DeserializeBlockCanonical(append(block.SerializeCanonical()[:40], 0)); err == nil {
+		t.Fatal("truncated block decoded")
+	}
+}
+
+func TestGenesisBoundary(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	chain := newTestChain(t)
+	genesis := NewGenesisBlock()
+	addTestBlock(t, chain, w)
+	addTestBlock(t, chain, w)
+
+	// Height 0 alone, or no parent alone, doesn't make a genesis block
+	if (&Block{Height: 0, PrevBlockHash: genesis.Hash}).IsGenesis() || (&Block{Height: 1}).IsGenesis() {
+		t.Fatal("block with a parent or above height 0 taken for genesis")
+	}
+
+	// A walk from the tip ends at genesis
+	iter := chain.Iterator()
+	var walked []*Block
+	for {
+		block := iter.Next()
+		walked = append(walked, block)
+		if block.IsGenesis() {
+			break
+		}
+		if len(walked) > 3 {
+			t.Fatal("iterator walked past genesis")
+		}
+	}
+	if len(walked) != 3 || !bytes.Equal(walked[2].Hash, genesis.Hash) {
+		t.Fatalf("walk stopped after %d blocks at %x, want 3 ending at genesis %x", len(walked), walked[len(walked)-1].Hash, genesis.Hash)
+	}
+
+	// Even signed by an authorized validator, genesis isn't accepted by signature
+	key, err := w.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := *genesis
+	if err := SignBlock(&signed, key); err != nil {
+		t.Fatal(err)
+	}
+	if VerifyBlockSignature(&signed) {
+		t.Fatal("signed genesis block accepted by VerifyBlockSignature")
+	}
+
+	// Rollback goes down to genesis and no further
+	for range 2 {
+		if _, err := chain.RollbackTip(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := chain.RollbackTip(); err == nil {
+		t.Fatal("genesis block rolled back")
+	}
+	if !bytes.Equal(chain.LastHash, genesis.Hash) || chain.GetBestHeight() != 0 {
+		t.Fatalf("tip %x at height %d after refusing to roll back genesis", chain.LastHash, chain.GetBestHeight())
+	}
+}
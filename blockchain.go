@@ -3,31 +3,93 @@ package main
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"encoding/binary"
 	"encoding/gob"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 )
 
 const (
 	dbPath = "./data/blocks"
+
+	// Block hash filter sizing: ~1.2MB covers a million blocks at 1% false
+	// positives. Bigger chains only see more positives, which fall back to Badger.
+	blockFilterCapacity = 1 << 20
+	blockFilterFPRate   = 0.01
 )
 
-func getBadgerOptions(path string) badger.Options {
+// ErrBlockPruned is returned (wrapped) when a lookup needs the transactions
+// of a block that --prune reduced to its header
+var ErrBlockPruned = errors.New("block pruned")
+
+// ErrQuorumNotMet is returned (wrapped) by ForgeBlock when the loaded keys
+// can't give a block the BlockQuorum signatures every node requires
+var ErrQuorumNotMet = errors.New("block signing quorum not met")
+
+// ErrTxNotIndexed is returned (wrapped) by FindIndexedTransaction for IDs the tx index can't resolve
+var ErrTxNotIndexed = errors.New("transaction not indexed")
+
+// prunedKey marks a database in which PruneBlocks has discarded block bodies
+var prunedKey = []byte("pruned")
+
+// heightPrefix indexes the main chain: "height-" + 8-byte big-endian height -> block hash
+const heightPrefix = "height-"
+
+// BadgerTuning sizes Badger's files and caches (--db-* flags, "db" config
+// section). The defaults suit a small educational node.
+type BadgerTuning struct {
+	ValueLogFileMB    int // Max size of one value log file
+	MemTableMB        int
+	BlockCacheMB      int // Must stay > 0: Badger needs it with compression on
+	NumVersionsToKeep int
+}
+
+var DBTuning = BadgerTuning{
+	ValueLogFileMB:    16,
+	MemTableMB:        8,
+	BlockCacheMB:      1,
+	NumVersionsToKeep: 1,
+}
+
+// Validate checks the tuning against the ranges Badger accepts
+func (t BadgerTuning) Validate() error {
+	if t.ValueLogFileMB < 1 || t.ValueLogFileMB >= 2048 {
+		return fmt.Errorf("value log file size must be between 1 and 2047 MB, got %d", t.ValueLogFileMB)
+	}
+	if t.MemTableMB < 1 {
+		return fmt.Errorf("memtable size must be at least 1 MB, got %d", t.MemTableMB)
+	}
+	if t.BlockCacheMB < 1 {
+		return fmt.Errorf("block cache size must be at least 1 MB, got %d", t.BlockCacheMB)
+	}
+	if t.NumVersionsToKeep < 1 {
+		return fmt.Errorf("versions to keep must be at least 1, got %d", t.NumVersionsToKeep)
+	}
+	return nil
+}
+
+// getBadgerOptions returns the options every database open goes through, so a
+// reopened DB keeps the tuning and integrity checks it was created with
+func getBadgerOptions(path string, readOnly bool) badger.Options {
 	opts := badger.DefaultOptions(path)
 	opts.Logger = nil
+	opts.ReadOnly = readOnly
 	// opts.Truncate = true (Removed in v3)
 
-	opts.ValueLogFileSize = 16 << 20 // 16 MB max value log file size
-	opts.MemTableSize = 8 << 20      // 8 MB memtable
-	opts.BlockCacheSize = 1 << 20    // 1 MB cache
-	opts.NumVersionsToKeep = 1
+	opts.ValueLogFileSize = int64(DBTuning.ValueLogFileMB) << 20
+	opts.MemTableSize = int64(DBTuning.MemTableMB) << 20
+	opts.BlockCacheSize = int64(DBTuning.BlockCacheMB) << 20
+	opts.NumVersionsToKeep = DBTuning.NumVersionsToKeep
 
 	// Robustness
 	opts.VerifyValueChecksum = true
@@ -46,6 +108,53 @@ type Blockchain struct {
 	LastHash []byte
 	Database *badger.DB
 	Mux      sync.Mutex
+
+	// OnReorg is invoked after AddBlock switches the tip to a different branch
+	OnReorg func(ReorgInfo)
+	// OnTipExtended is invoked when a new block directly extends the tip
+	// (forged locally or received), but not for reorgs
+	OnTipExtended func(*Block)
+
+	// KnownBlocks holds every stored block hash once EnableBlockFilter ran
+	KnownBlocks       *BloomFilter
+	SkippedBlockReads atomic.Int64 // Existence checks answered by KnownBlocks alone
+
+	Validation BlockValidationStats
+}
+
+// BlockValidationStats times VerifyBlockTransactions, the cost of checking a
+// received or synced block's transactions
+type BlockValidationStats struct {
+	Blocks       atomic.Int64 // Blocks whose transactions were checked
+	TotalNanos   atomic.Int64
+	MaxNanos     atomic.Int64
+	LastNanos    atomic.Int64
+	OverLimit    atomic.Int64 // Blocks rejected for spending more than MaxBlockInputs inputs
+	ParentLoads  atomic.Int64 // Spent transactions read from the database (blocks and single txs)
+	ParentMisses atomic.Int64 // Inputs whose spent transaction isn't indexed
+}
+
+// record accounts for one VerifyBlockTransactions run
+func (s *BlockValidationStats) record(elapsed time.Duration) {
+	nanos := elapsed.Nanoseconds()
+	s.Blocks.Add(1)
+	s.TotalNanos.Add(nanos)
+	s.LastNanos.Store(nanos)
+	for {
+		prev := s.MaxNanos.Load()
+		if nanos <= prev || s.MaxNanos.CompareAndSwap(prev, nanos) {
+			return
+		}
+	}
+}
+
+// ReorgInfo describes a chain reorganization performed by AddBlock
+type ReorgInfo struct {
+	OldTip               []byte
+	NewTip               []byte
+	CommonAncestorHeight int
+	Depth                int      // Number of blocks disconnected from the old branch
+	Disconnected         [][]byte // Old branch hashes, tip first
 }
 
 type BlockchainIterator struct {
@@ -65,7 +174,7 @@ func InitBlockchain() (*Blockchain, error) {
 		return nil, fmt.Errorf("failed to create db directory: %s", err)
 	}
 
-	opts := getBadgerOptions(dbPath)
+	opts := getBadgerOptions(dbPath, false)
 
 	db, err := badger.Open(opts)
 	if err != nil {
@@ -89,6 +198,16 @@ func InitBlockchain() (*Blockchain, error) {
 			}
 		}
 
+		if err := txn.Set(heightKey(0), genesis.Hash); err != nil {
+			return fmt.Errorf("failed to index the genesis block: %w", err)
+		}
+
+		if ActivePreset != "" {
+			if err := txn.Set(presetKey, []byte(ActivePreset)); err != nil {
+				return fmt.Errorf("failed to record the genesis preset: %w", err)
+			}
+		}
+
 		err = txn.Set([]byte("lh"), genesis.Hash)
 		lastHash = genesis.Hash
 		return err
@@ -97,7 +216,7 @@ func InitBlockchain() (*Blockchain, error) {
 		return nil, fmt.Errorf("InitBlockchain database update failed: %w", err)
 	}
 
-	blockchain := Blockchain{lastHash, db, sync.Mutex{}}
+	blockchain := Blockchain{LastHash: lastHash, Database: db}
 	return &blockchain, nil
 }
 
@@ -108,8 +227,7 @@ func ContinueBlockchain(address string) *Blockchain {
 	}
 
 	var lastHash []byte
-	opts := badger.DefaultOptions(dbPath)
-	opts.Logger = nil
+	opts := getBadgerOptions(dbPath, false)
 
 	db, err := badger.Open(opts)
 	if err != nil {
@@ -128,7 +246,16 @@ func ContinueBlockchain(address string) *Blockchain {
 		log.Fatalf("Fatal: Failed to retrieve last block hash: %v\n", err)
 	}
 
-	chain := Blockchain{lastHash, db, sync.Mutex{}}
+	chain := Blockchain{LastHash: lastHash, Database: db}
+	if err := chain.LoadPreset(); err != nil {
+		log.Fatalf("Fatal: Failed to load the genesis preset: %v\n", err)
+	}
+	if err := chain.ensureHeightIndex(); err != nil {
+		log.Fatalf("Fatal: Failed to build the block height index: %v\n", err)
+	}
+	if err := (UTXOSet{&chain}).ensureAddressIndex(); err != nil {
+		log.Fatalf("Fatal: Failed to index the UTXO set by address: %v\n", err)
+	}
 	return &chain
 }
 
@@ -139,9 +266,7 @@ func ContinueBlockchainReadOnly(address string) *Blockchain {
 	}
 
 	var lastHash []byte
-	opts := badger.DefaultOptions(dbPath)
-	opts.Logger = nil
-	opts.ReadOnly = true
+	opts := getBadgerOptions(dbPath, true)
 
 	db, err := badger.Open(opts)
 	if err != nil {
@@ -160,7 +285,10 @@ func ContinueBlockchainReadOnly(address string) *Blockchain {
 		log.Fatalf("Fatal: Failed to retrieve last block hash (Read-Only): %v\n", err)
 	}
 
-	chain := Blockchain{lastHash, db, sync.Mutex{}}
+	chain := Blockchain{LastHash: lastHash, Database: db}
+	if err := chain.LoadPreset(); err != nil {
+		log.Fatalf("Fatal: Failed to load the genesis preset (Read-Only): %v\n", err)
+	}
 	return &chain
 }
 
@@ -170,13 +298,7 @@ func ContinueBlockchainSnapshot(customPath string) *Blockchain {
 	}
 
 	var lastHash []byte
-	opts := badger.DefaultOptions(customPath)
-	opts.Logger = nil
-	// Memory optimizations
-	opts.ValueLogFileSize = 16 << 20
-	opts.MemTableSize = 8 << 20
-	opts.BlockCacheSize = 1 << 20
-	opts.NumVersionsToKeep = 1
+	opts := getBadgerOptions(customPath, false)
 
 	db, err := badger.Open(opts)
 	if err != nil {
@@ -195,10 +317,66 @@ func ContinueBlockchainSnapshot(customPath string) *Blockchain {
 		log.Panic(err)
 	}
 
-	chain := Blockchain{lastHash, db, sync.Mutex{}}
+	chain := Blockchain{LastHash: lastHash, Database: db}
 	return &chain
 }
 
+// EnableBlockFilter loads every stored block hash into KnownBlocks, so that
+// lookups for unknown blocks (the common case during sync) skip Badger.
+func (chain *Blockchain) EnableBlockFilter() error {
+	filter := NewBloomFilter(blockFilterCapacity, blockFilterFPRate)
+
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			// Blocks are the only records keyed by a bare 32-byte hash
+			if key := it.Item().Key(); len(key) == 32 {
+				filter.Add(key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	chain.KnownBlocks = filter
+	return nil
+}
+
+// rememberBlock records a newly stored block in KnownBlocks
+func (chain *Blockchain) rememberBlock(hash []byte) {
+	if chain.KnownBlocks != nil {
+		chain.KnownBlocks.Add(hash)
+	}
+}
+
+// MayHaveBlock answers from KnownBlocks only: false means the block is
+// definitely not stored. Without a filter it always returns true.
+func (chain *Blockchain) MayHaveBlock(hash []byte) bool {
+	if chain.KnownBlocks != nil && !chain.KnownBlocks.Test(hash) {
+		chain.SkippedBlockReads.Add(1)
+		return false
+	}
+	return true
+}
+
+// HasBlock reports whether a block is stored, without decoding it
+func (chain *Blockchain) HasBlock(hash []byte) bool {
+	if !chain.MayHaveBlock(hash) {
+		return false
+	}
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(hash)
+		return err
+	})
+	return err == nil
+}
+
 func (chain *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 	var block Block
 
@@ -214,6 +392,148 @@ func (chain *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 	return block, err
 }
 
+// LoadBlock is GetBlock for possibly damaged databases: undecodable block
+// data comes back as an error instead of a panic
+func (chain *Blockchain) LoadBlock(blockHash []byte) (*Block, error) {
+	var block *Block
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(blockHash)
+		if err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		block = DeserializeBlock(data)
+		return nil
+	})
+	if err == nil && block == nil {
+		err = errors.New("undecodable block data")
+	}
+	return block, err
+}
+
+// GetBlockByHeight returns the main-chain block at the given height
+func (chain *Blockchain) GetBlockByHeight(height int) (Block, error) {
+	if height < 0 {
+		return Block{}, fmt.Errorf("invalid height %d", height)
+	}
+
+	hash, err := chain.HashAtHeight(height)
+	if err == nil {
+		return chain.GetBlock(hash)
+	}
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return Block{}, err
+	}
+
+	// Not indexed: past the tip, or a database opened read-only before
+	// ensureHeightIndex could build the index
+	iter := chain.Iterator()
+	for {
+		block := iter.Next()
+		if block.Height == height {
+			return *block, nil
+		}
+		if block.Height < height || block.IsGenesis() {
+			break
+		}
+	}
+
+	return Block{}, fmt.Errorf("block at height %d not found", height)
+}
+
+// HashAtHeight returns the hash of the main-chain block at height, from the
+// height index. Heights past the tip yield badger.ErrKeyNotFound.
+func (chain *Blockchain) HashAtHeight(height int) ([]byte, error) {
+	var hash []byte
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(heightKey(height))
+		if err != nil {
+			return err
+		}
+		hash, err = item.ValueCopy(nil)
+		return err
+	})
+	return hash, err
+}
+
+func heightKey(height int) []byte {
+	key := make([]byte, len(heightPrefix)+8)
+	copy(key, heightPrefix)
+	binary.BigEndian.PutUint64(key[len(heightPrefix):], uint64(height))
+	return key
+}
+
+// indexMainChain points the height index at block, the new tip, and at its
+// ancestors down to the first one already indexed: the fork point after a
+// reorg, the parent otherwise. Old-branch entries are all overwritten, since
+// the new tip is higher than the old one.
+func indexMainChain(txn *badger.Txn, block *Block) error {
+	for {
+		if err := txn.Set(heightKey(block.Height), block.Hash); err != nil {
+			return err
+		}
+		if block.IsGenesis() {
+			return nil
+		}
+
+		item, err := txn.Get(heightKey(block.Height - 1))
+		if err == nil {
+			indexed, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(indexed, block.PrevBlockHash) {
+				return nil
+			}
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		item, err = txn.Get(block.PrevBlockHash)
+		if err != nil {
+			return fmt.Errorf("parent of block %x: %w", block.Hash, err)
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if block = DeserializeBlock(data); block == nil {
+			return errors.New("undecodable block data")
+		}
+	}
+}
+
+// ensureHeightIndex builds the height index of databases created before it
+// existed. Indexed databases are recognized by their tip's entry.
+func (chain *Blockchain) ensureHeightIndex() error {
+	tip, err := chain.GetBlock(chain.LastHash)
+	if err != nil {
+		return err
+	}
+	if hash, err := chain.HashAtHeight(tip.Height); err == nil && bytes.Equal(hash, tip.Hash) {
+		return nil
+	}
+
+	fmt.Printf("🔄 Indexing %d blocks by height...\n", tip.Height+1)
+	batch := chain.Database.NewWriteBatch()
+	defer batch.Cancel()
+
+	iter := chain.Iterator()
+	for {
+		block := iter.Next()
+		if err := batch.Set(heightKey(block.Height), block.Hash); err != nil {
+			return err
+		}
+		if block.IsGenesis() {
+			break
+		}
+	}
+	return batch.Flush()
+}
+
 // GetBlockHashes returns a list of hashes of all the blocks in the chain
 // Returns hashes in chronological order: Genesis → Tip
 func (chain *Blockchain) GetBlockHashes() [][]byte {
@@ -225,7 +545,7 @@ func (chain *Blockchain) GetBlockHashes() [][]byte {
 		block := iter.Next()
 		blocks = append(blocks, block.Hash)
 
-		if len(block.PrevBlockHash) == 0 {
+		if block.IsGenesis() {
 			break
 		}
 	}
@@ -267,7 +587,9 @@ func (chain *Blockchain) GetBestHeight() int {
 	return lastBlock.Height
 }
 
-func (chain *Blockchain) ForgeBlock(transactions []*Transaction, privKey ecdsa.PrivateKey) *Block {
+// ForgeBlock builds, signs and stores the next block. Each of attesters adds
+// a co-signature, as required when BlockQuorum > 1.
+func (chain *Blockchain) ForgeBlock(transactions []*Transaction, revocations []ValidatorRevocation, privKey ecdsa.PrivateKey, attesters ...ecdsa.PrivateKey) (*Block, error) {
 	chain.Mux.Lock()
 	defer chain.Mux.Unlock()
 
@@ -303,6 +625,12 @@ func (chain *Blockchain) ForgeBlock(transactions []*Transaction, privKey ecdsa.P
 
 	// Create block without signature first
 	newBlock := NewBlock(transactions, lastHash, newHeight, nil)
+	newBlock.Revocations = revocations
+	if newBlock.Timestamp <= lastBlock.Timestamp {
+		// Forged within the parent's second (back-to-back empty blocks, a
+		// reactive forge right after a tick): timestamps must still increase
+		newBlock.Timestamp = lastBlock.Timestamp + 1
+	}
 
 	// PoA Hardening: Mine the block (Find valid Nonce)
 	MineBlock(newBlock)
@@ -312,6 +640,23 @@ func (chain *Blockchain) ForgeBlock(transactions []*Transaction, privKey ecdsa.P
 	if err != nil {
 		log.Panic("Failed to sign block:", err)
 	}
+	for _, attester := range attesters {
+		if err := AttestBlock(newBlock, attester); err != nil {
+			log.Panic("Failed to attest block:", err)
+		}
+	}
+
+	// Never store a block every other node would reject
+	if !VerifyBlockSignature(newBlock) {
+		return nil, fmt.Errorf("%w: block %d carries %d signature(s), %d required", ErrQuorumNotMet, newHeight, 1+len(newBlock.Attestations), BlockQuorum)
+	}
+	revoked, err := chain.RevokedBy(lastBlock)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckRevocations(newBlock, revoked); err != nil {
+		return nil, err
+	}
 
 	err = chain.Database.Update(func(txn *badger.Txn) error {
 		err := txn.Set(newBlock.Hash, newBlock.Serialize())
@@ -326,6 +671,13 @@ func (chain *Blockchain) ForgeBlock(transactions []*Transaction, privKey ecdsa.P
 				log.Panic(err)
 			}
 		}
+		if err := indexRevocations(txn, newBlock); err != nil {
+			log.Panic(err)
+		}
+
+		if err := txn.Set(heightKey(newBlock.Height), newBlock.Hash); err != nil {
+			log.Panic(err)
+		}
 
 		err = txn.Set([]byte("lh"), newBlock.Hash)
 		chain.LastHash = newBlock.Hash
@@ -334,23 +686,34 @@ func (chain *Blockchain) ForgeBlock(transactions []*Transaction, privKey ecdsa.P
 	if err != nil {
 		log.Panic(err)
 	}
+	chain.rememberBlock(newBlock.Hash)
 
-	return newBlock
+	if chain.OnTipExtended != nil {
+		chain.OnTipExtended(newBlock)
+	}
+	return newBlock, nil
 }
 
 func (chain *Blockchain) AddBlock(block *Block, txCache ...map[string]Transaction) bool {
 	// 0. Exist Check: Verify duplicates BEFORE expensive crypto validation
-	_, err := chain.GetBlock(block.Hash)
-	if err == nil {
+	if chain.HasBlock(block.Hash) {
 		return false // Already processed
 	}
+	if block.Pruned {
+		fmt.Println("⛔ AddBlock: Block rejected - pruned blocks carry no transactions")
+		return false
+	}
 
 	// 1. PoA Hardening: Validate block linkage and header
 	chain.Mux.Lock()
 	defer chain.Mux.Unlock()
 
+	var err error
+	var oldTip []byte // Set when this block causes a reorganization
+	extendedTip := false
+
+	var prevBlock Block
 	if len(block.PrevBlockHash) > 0 {
-		var prevBlock Block
 		err = chain.Database.View(func(txn *badger.Txn) error {
 			item, err := txn.Get(block.PrevBlockHash)
 			if err != nil {
@@ -365,15 +728,15 @@ func (chain *Blockchain) AddBlock(block *Block, txCache ...map[string]Transactio
 			return false
 		}
 
-		if block.Height != prevBlock.Height+1 {
-			fmt.Printf("⛔ AddBlock: Height mismatch. Expected %d, got %d\n", prevBlock.Height+1, block.Height)
-			return false
-		}
-
 		if err := ValidateBlockHeader(block, &prevBlock); err != nil {
 			fmt.Printf("⛔ AddBlock: Header Validation Failed: %s\n", err)
 			return false
 		}
+	} else {
+		// Only chain init creates a genesis block; a parentless block from
+		// anywhere else would skip the linkage checks above
+		fmt.Println("⛔ AddBlock: Block rejected - no parent (foreign genesis block)")
+		return false
 	}
 
 	// 2. Verify PoA signature
@@ -381,6 +744,14 @@ func (chain *Blockchain) AddBlock(block *Block, txCache ...map[string]Transactio
 		fmt.Println("AddBlock: Block rejected - invalid PoA signature")
 		return false
 	}
+	revoked, err := chain.RevokedBy(&prevBlock)
+	if err == nil {
+		err = CheckRevocations(block, revoked)
+	}
+	if err != nil {
+		fmt.Printf("⛔ AddBlock: Block rejected - %v\n", err)
+		return false
+	}
 
 	// 3. Verify all internal transaction signatures (including intra-block + cross-block cache)
 	if !chain.VerifyBlockTransactions(block, txCache...) {
@@ -406,25 +777,11 @@ func (chain *Blockchain) AddBlock(block *Block, txCache ...map[string]Transactio
 				return err
 			}
 		}
-
-		item, err := txn.Get([]byte("lh"))
-		if err != nil {
+		if err := indexRevocations(txn, block); err != nil {
 			return err
 		}
-		lastHash, _ := item.ValueCopy(nil)
-
-		item, err = txn.Get(lastHash)
-		if err != nil {
-			return err
-		}
-		lastBlockData, _ := item.ValueCopy(nil)
-		lastBlock := DeserializeBlock(lastBlockData)
-
-		if block.Height > lastBlock.Height {
-			err = txn.Set([]byte("lh"), block.Hash)
-			chain.LastHash = block.Hash
-		}
 
+		oldTip, extendedTip, err = chain.connectTip(txn, block)
 		return err
 	})
 
@@ -432,15 +789,170 @@ func (chain *Blockchain) AddBlock(block *Block, txCache ...map[string]Transactio
 		fmt.Printf("⛔ AddBlock: Failed to save block to database: %v\n", err)
 		return false
 	}
+	chain.rememberBlock(block.Hash)
+	chain.announceTip(block, oldTip, extendedTip)
 	return true
 }
 
+// connectTip makes block the tip, within txn, if it is higher than the
+// current one. It returns the replaced tip when block doesn't extend it (a
+// reorg), and whether it extended it directly.
+func (chain *Blockchain) connectTip(txn *badger.Txn, block *Block) (oldTip []byte, extended bool, err error) {
+	item, err := txn.Get([]byte("lh"))
+	if err != nil {
+		return nil, false, err
+	}
+	lastHash, _ := item.ValueCopy(nil)
+
+	item, err = txn.Get(lastHash)
+	if err != nil {
+		return nil, false, err
+	}
+	lastBlockData, _ := item.ValueCopy(nil)
+	lastBlock := DeserializeBlock(lastBlockData)
+
+	if block.Height <= lastBlock.Height {
+		return nil, false, nil
+	}
+	if err := indexMainChain(txn, block); err != nil {
+		return nil, false, err
+	}
+	if err := txn.Set([]byte("lh"), block.Hash); err != nil {
+		return nil, false, err
+	}
+	chain.LastHash = block.Hash
+	if !bytes.Equal(block.PrevBlockHash, lastHash) {
+		return lastHash, false, nil
+	}
+	return nil, true, nil
+}
+
+// announceTip runs the OnReorg or OnTipExtended hook for a block stored by
+// connectTip
+func (chain *Blockchain) announceTip(block *Block, oldTip []byte, extended bool) {
+	// The new tip does not extend the old one: a side branch overtook it
+	if oldTip != nil {
+		reorg, err := chain.FindReorg(oldTip, block.Hash)
+		if err != nil {
+			fmt.Printf("⚠️  Reorg detected but fork point lookup failed: %v\n", err)
+		} else {
+			fmt.Printf("🔀 [Reorg] Tip switched %x → %x (depth %d, common ancestor at height %d)\n",
+				reorg.OldTip[:4], reorg.NewTip[:4], reorg.Depth, reorg.CommonAncestorHeight)
+			if chain.OnReorg != nil {
+				chain.OnReorg(reorg)
+			}
+		}
+	}
+	if extended && chain.OnTipExtended != nil {
+		chain.OnTipExtended(block)
+	}
+}
+
+// RollbackTip disconnects the tip block: its UTXO changes are reversed, its
+// record and tx index entries are deleted and its parent becomes the tip.
+// The genesis block cannot be rolled back. Meant for local development.
+func (chain *Blockchain) RollbackTip() (*Block, error) {
+	chain.Mux.Lock()
+	defer chain.Mux.Unlock()
+
+	tip, err := chain.GetBlock(chain.LastHash)
+	if err != nil {
+		return nil, fmt.Errorf("reading tip: %w", err)
+	}
+	if tip.IsGenesis() {
+		return nil, errors.New("refusing to roll back the genesis block")
+	}
+	if tip.Pruned {
+		return nil, fmt.Errorf("%w: cannot roll back block %x without its transactions", ErrBlockPruned, tip.Hash)
+	}
+
+	// UTXO first: without an undo record, restoring spent outputs needs this
+	// block's ancestors indexed
+	if err := (UTXOSet{chain}).Rollback(&tip); err != nil {
+		return nil, fmt.Errorf("rolling back UTXO set: %w", err)
+	}
+
+	err = chain.Database.Update(func(txn *badger.Txn) error {
+		for _, tx := range tip.Transactions {
+			key := append([]byte("tx-"), tx.ID...)
+			// A side-branch copy of the tx may own the index entry; leave it alone
+			if item, err := txn.Get(key); err == nil {
+				if owner, _ := item.ValueCopy(nil); !bytes.Equal(owner, tip.Hash) {
+					continue
+				}
+			}
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		if err := txn.Delete(tip.Hash); err != nil {
+			return err
+		}
+		if err := txn.Delete(heightKey(tip.Height)); err != nil {
+			return err
+		}
+		return txn.Set([]byte("lh"), tip.PrevBlockHash)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("removing block %x: %w", tip.Hash, err)
+	}
+
+	chain.LastHash = tip.PrevBlockHash
+	return &tip, nil
+}
+
+// FindReorg walks both branches back to their common ancestor and reports
+// the blocks disconnected from the old branch.
+func (chain *Blockchain) FindReorg(oldTip, newTip []byte) (ReorgInfo, error) {
+	info := ReorgInfo{OldTip: oldTip, NewTip: newTip}
+
+	oldBlock, err := chain.GetBlock(oldTip)
+	if err != nil {
+		return info, err
+	}
+	newBlock, err := chain.GetBlock(newTip)
+	if err != nil {
+		return info, err
+	}
+
+	for !bytes.Equal(oldBlock.Hash, newBlock.Hash) {
+		if oldBlock.IsGenesis() && newBlock.IsGenesis() {
+			return info, errors.New("branches do not share a common ancestor")
+		}
+
+		if oldBlock.Height >= newBlock.Height {
+			info.Disconnected = append(info.Disconnected, oldBlock.Hash)
+			if oldBlock, err = chain.GetBlock(oldBlock.PrevBlockHash); err != nil {
+				return info, err
+			}
+		} else {
+			if newBlock, err = chain.GetBlock(newBlock.PrevBlockHash); err != nil {
+				return info, err
+			}
+		}
+	}
+
+	info.CommonAncestorHeight = oldBlock.Height
+	info.Depth = len(info.Disconnected)
+	return info, nil
+}
+
 const (
 	MaxSupply       = 8910000 * 100000000 // 8.91M * 10^8
 	InitialSubsidy  = 10 * 100000000      // 10 SOLE
 	HalvingInterval = 195500              // Blocks
 )
 
+// MaxBlockReward bounds a configured BlockReward (1000 SOLE): the emission
+// over every halving era stays far below the int64 range
+const MaxBlockReward = 1000 * 100000000
+
+// BlockReward is the subsidy of the first halving era in Photons. Like the
+// validator list it is a consensus rule: every node must use the same value
+// (config: consensus.block_reward, flag --block-reward). MaxSupply only holds
+// for the default.
+var BlockReward int64 = InitialSubsidy
+
 // GetBlockSubsidy calculates the mining reward based on block height (Halving)
 func (chain *Blockchain) GetBlockSubsidy(height int) int64 {
 	halvings := height / HalvingInterval
@@ -450,7 +962,7 @@ func (chain *Blockchain) GetBlockSubsidy(height int) int64 {
 		return 0
 	}
 
-	subsidy := int64(InitialSubsidy) >> halvings
+	subsidy := BlockReward >> halvings
 
 	if subsidy <= 0 {
 		return 0
@@ -459,6 +971,33 @@ func (chain *Blockchain) GetBlockSubsidy(height int) int64 {
 	return subsidy
 }
 
+// CheckCoinbaseReward rejects a block whose coinbase pays out more than the
+// subsidy for its height plus fees. Genesis carries the premine instead.
+func (chain *Blockchain) CheckCoinbaseReward(block *Block, fees int64) error {
+	if block.Height == 0 {
+		return nil
+	}
+
+	var reward int64
+	for i, tx := range block.Transactions {
+		if !tx.IsCoinbase() {
+			continue
+		}
+		if i > 0 {
+			return fmt.Errorf("coinbase transaction at position %d (must be first)", i)
+		}
+		for _, out := range tx.Vout {
+			reward += out.Value
+		}
+	}
+
+	if allowed := chain.GetBlockSubsidy(block.Height) + fees; reward > allowed {
+		return fmt.Errorf("coinbase pays %d Photons, more than the %d allowed at height %d (subsidy %d + fees %d)",
+			reward, allowed, block.Height, chain.GetBlockSubsidy(block.Height), fees)
+	}
+	return nil
+}
+
 // FindUnspentTransactions returns a list of transactions containing unspent outputs
 func (bc *Blockchain) FindUnspentTransactions(pubKeyHash []byte) []Transaction {
 	var unspentTXs []Transaction
@@ -494,7 +1033,7 @@ func (bc *Blockchain) FindUnspentTransactions(pubKeyHash []byte) []Transaction {
 			}
 		}
 
-		if len(block.PrevBlockHash) == 0 {
+		if block.IsGenesis() {
 			break
 		}
 	}
@@ -545,7 +1084,7 @@ func (bc *Blockchain) FindTransactions(address string) []Transaction {
 			}
 		}
 
-		if len(block.PrevBlockHash) == 0 {
+		if block.IsGenesis() {
 			break
 		}
 	}
@@ -553,50 +1092,6 @@ func (bc *Blockchain) FindTransactions(address string) []Transaction {
 	return transactions
 }
 
-// FindUTXO finds all unspent transaction outputs and returns them
-func (chain *Blockchain) FindUTXO() map[string]TxOutputs {
-	UTXO := make(map[string]TxOutputs)
-	spentTXOs := make(map[string][]int)
-	iter := chain.Iterator()
-
-	for {
-		block := iter.Next()
-
-		for _, tx := range block.Transactions {
-			txID := hex.EncodeToString(tx.ID)
-
-		Outputs:
-			for outIdx, out := range tx.Vout {
-				// Was the output spent?
-				if spentTXOs[txID] != nil {
-					for _, spentOut := range spentTXOs[txID] {
-						if spentOut == outIdx {
-							continue Outputs
-						}
-					}
-				}
-
-				outs := UTXO[txID]
-				outs.Outputs = append(outs.Outputs, out)
-				UTXO[txID] = outs
-			}
-
-			if !tx.IsCoinbase() {
-				for _, in := range tx.Vin {
-					inTxID := hex.EncodeToString(in.Txid)
-					spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Vout)
-				}
-			}
-		}
-
-		if len(block.PrevBlockHash) == 0 {
-			break
-		}
-	}
-
-	return UTXO
-}
-
 // FindSpendableOutputs finds and returns unspent outputs to reference in inputs
 func (chain *Blockchain) FindSpendableOutputs(pubKeyHash []byte, amount int64) (int64, map[string][]int) {
 	unspentOutputs := make(map[string][]int)
@@ -622,9 +1117,17 @@ Work:
 	return accumulated, unspentOutputs
 }
 
-// FindTransaction finds a transaction by ID (Optimized with O(1) Index)
-func (chain *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
-	// 1. Try to find using the O(1) Transaction Index
+// HasTransaction reports whether a transaction is in the tx index
+func (chain *Blockchain) HasTransaction(ID []byte) bool {
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(append([]byte("tx-"), ID...))
+		return err
+	})
+	return err == nil
+}
+
+// FindTransactionBlock returns the block a transaction was mined in, via the tx index
+func (chain *Blockchain) FindTransactionBlock(ID []byte) (Block, error) {
 	var blockHash []byte
 	err := chain.Database.View(func(txn *badger.Txn) error {
 		item, err := txn.Get(append([]byte("tx-"), ID...))
@@ -634,18 +1137,155 @@ func (chain *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 		blockHash, err = item.ValueCopy(nil)
 		return err
 	})
+	if err != nil {
+		return Block{}, err
+	}
+	return chain.GetBlock(blockHash)
+}
 
-	if err == nil {
-		// Index hit! Retrieve the specific block
-		block, err := chain.GetBlock(blockHash)
-		if err == nil {
-			for _, tx := range block.Transactions {
-				if bytes.Equal(tx.ID, ID) {
-					return *tx, nil
-				}
+// TxConfirmation looks a transaction up in the tx index and returns the hash
+// and height of its block along with the tip height, all from one snapshot.
+// No transactions are decoded (see storedBlockHeight), so it stays cheap for
+// clients polling confirmations. A transaction that isn't indexed returns
+// badger.ErrKeyNotFound.
+func (chain *Blockchain) TxConfirmation(ID []byte) (blockHash []byte, height, tipHeight int, err error) {
+	err = chain.Database.View(func(txn *badger.Txn) error {
+		heightOf := func(hash []byte) (int, error) {
+			item, err := txn.Get(hash)
+			if err != nil {
+				return 0, err
+			}
+			data, err := item.ValueCopy(nil)
+			if err != nil {
+				return 0, err
+			}
+			return storedBlockHeight(data)
+		}
+
+		item, err := txn.Get(append([]byte("tx-"), ID...))
+		if err != nil {
+			return err
+		}
+		if blockHash, err = item.ValueCopy(nil); err != nil {
+			return err
+		}
+		if height, err = heightOf(blockHash); err != nil {
+			return err
+		}
+
+		item, err = txn.Get([]byte("lh"))
+		if err != nil {
+			return err
+		}
+		lastHash, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		tipHeight, err = heightOf(lastHash)
+		return err
+	})
+	return blockHash, height, tipHeight, err
+}
+
+// storedBlockHeight reads the height of a stored block. Canonical records
+// keep it at a fixed offset (after the format byte and the timestamp); only
+// gob records from older versions are decoded in full.
+func storedBlockHeight(data []byte) (int, error) {
+	_, height, err := storedBlockStamp(data)
+	return height, err
+}
+
+// storedBlockStamp reads the timestamp and height of a stored block, in
+// place like storedBlockHeight
+func storedBlockStamp(data []byte) (timestamp int64, height int, err error) {
+	if len(data) >= 17 && (data[0] == blockFormatVersion || data[0] == blockFormatPruned) {
+		return int64(binary.BigEndian.Uint64(data[1:9])), int(int64(binary.BigEndian.Uint64(data[9:17]))), nil
+	}
+	block := DeserializeBlock(data)
+	if block == nil {
+		return 0, 0, errors.New("undecodable block data")
+	}
+	return block.Timestamp, block.Height, nil
+}
+
+// TxBlockTime returns the timestamp of the main-chain block a transaction
+// was mined in. Like TxConfirmation it goes through the tx index and reads
+// the block's header fields in place; the height index then tells whether
+// that block is still on the main chain. ok is false for transactions that
+// aren't indexed or only sit on a side branch.
+func (chain *Blockchain) TxBlockTime(ID []byte) (timestamp int64, ok bool) {
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		value := func(key []byte) ([]byte, error) {
+			item, err := txn.Get(key)
+			if err != nil {
+				return nil, err
 			}
+			return item.ValueCopy(nil)
+		}
+
+		blockHash, err := value(append([]byte("tx-"), ID...))
+		if err != nil {
+			return err
+		}
+		data, err := value(blockHash)
+		if err != nil {
+			return err
+		}
+		ts, height, err := storedBlockStamp(data)
+		if err != nil {
+			return err
+		}
+		mainHash, err := value(heightKey(height))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(mainHash, blockHash) {
+			return badger.ErrKeyNotFound
+		}
+		timestamp = ts
+		return nil
+	})
+	return timestamp, err == nil
+}
+
+// FindIndexedTransaction finds a transaction through the O(1) tx index only.
+// Returns ErrTxNotIndexed (wrapped) when the index has no usable entry.
+func (chain *Blockchain) FindIndexedTransaction(ID []byte) (Transaction, error) {
+	var blockHash []byte
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(append([]byte("tx-"), ID...))
+		if err != nil {
+			return err
+		}
+		blockHash, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return Transaction{}, fmt.Errorf("%w: %x", ErrTxNotIndexed, ID)
+	}
+
+	block, err := chain.GetBlock(blockHash)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("%w: %x (block %x: %v)", ErrTxNotIndexed, ID, blockHash, err)
+	}
+	if block.Pruned {
+		return Transaction{}, fmt.Errorf("%w: transaction %x was in block %x", ErrBlockPruned, ID, blockHash)
+	}
+	for _, tx := range block.Transactions {
+		if bytes.Equal(tx.ID, ID) {
+			return *tx, nil
 		}
 	}
+	return Transaction{}, fmt.Errorf("%w: %x (not in block %x)", ErrTxNotIndexed, ID, blockHash)
+}
+
+// FindTransaction finds a transaction by ID (Optimized with O(1) Index)
+func (chain *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
+	// 1. Try to find using the O(1) Transaction Index
+	tx, err := chain.FindIndexedTransaction(ID)
+	if !errors.Is(err, ErrTxNotIndexed) {
+		return tx, err
+	}
 
 	// 2. Fallback to O(N) iteration (for legacy compatibility if DB not reset)
 	iter := chain.Iterator()
@@ -658,7 +1298,7 @@ func (chain *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 			}
 		}
 
-		if len(block.PrevBlockHash) == 0 {
+		if block.IsGenesis() {
 			break
 		}
 	}
@@ -666,11 +1306,108 @@ func (chain *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 	return Transaction{}, errors.New("Transaction does not exist")
 }
 
+// FindParentTransaction resolves a transaction spent by an input. When its
+// block was pruned, a stand-in is built from the UTXO set: it carries the
+// unspent outputs at their original indexes, which is all that signature
+// checks and fee calculation read. Only the tx index is consulted: an input
+// pointing at an unknown transaction must not cost a scan of the whole chain.
+func (chain *Blockchain) FindParentTransaction(ID []byte) (Transaction, error) {
+	tx, err := chain.FindIndexedTransaction(ID)
+	if !errors.Is(err, ErrBlockPruned) {
+		return tx, err
+	}
+
+	outputs := (UTXOSet{chain}).FindTxOutputs(ID)
+	if len(outputs) == 0 {
+		return Transaction{}, err
+	}
+
+	stub := Transaction{ID: ID}
+	for idx, out := range outputs {
+		for len(stub.Vout) <= idx {
+			stub.Vout = append(stub.Vout, TxOutput{})
+		}
+		stub.Vout[idx] = out
+	}
+	return stub, nil
+}
+
+// PruneBlocks replaces main-chain blocks buried under more than keep blocks
+// with header-only records. It walks down from the tip and stops at the first
+// block that is already pruned. Genesis is never pruned.
+// Returns the number of blocks pruned.
+func (chain *Blockchain) PruneBlocks(keep int) (int, error) {
+	pruned := 0
+	iter := chain.Iterator()
+	for depth := 0; ; depth++ {
+		block := iter.Next()
+		if block == nil || block.Pruned || block.IsGenesis() {
+			break
+		}
+		if depth < keep {
+			continue
+		}
+
+		header := block.HeaderOnly()
+		err := chain.Database.Update(func(txn *badger.Txn) error {
+			if err := txn.Set(prunedKey, []byte{1}); err != nil {
+				return err
+			}
+			if err := txn.Delete(undoKey(block.Hash)); err != nil {
+				return err
+			}
+			return txn.Set(block.Hash, header.Serialize())
+		})
+		if err != nil {
+			return pruned, fmt.Errorf("pruning block %x: %w", block.Hash, err)
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// IsPruned reports whether any block body has been discarded. Such a chain
+// can no longer rebuild its UTXO set from scratch.
+func (chain *Blockchain) IsPruned() bool {
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(prunedKey)
+		return err
+	})
+	return err == nil
+}
+
+// PrevTxCache holds transactions already read from the database, keyed by
+// hex ID, so inputs spending outputs of the same transaction load it once.
+// One cache lives for one block validation or forging round: it is not
+// invalidated, and isn't safe for concurrent use.
+type PrevTxCache map[string]Transaction
+
+// findParentCached is FindParentTransaction through cache (nil disables it)
+func (chain *Blockchain) findParentCached(ID []byte, cache PrevTxCache) (Transaction, error) {
+	key := hex.EncodeToString(ID)
+	if tx, ok := cache[key]; ok {
+		return tx, nil
+	}
+	tx, err := chain.FindParentTransaction(ID)
+	if err != nil {
+		chain.Validation.ParentMisses.Add(1)
+		return tx, err
+	}
+	chain.Validation.ParentLoads.Add(1)
+	if cache != nil {
+		cache[key] = tx
+	}
+	return tx, nil
+}
+
 // SignTransaction signs inputs of a Transaction
 func (chain *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
 	prevTXs := make(map[string]Transaction)
 
 	for _, vin := range tx.Vin {
+		if _, loaded := prevTXs[hex.EncodeToString(vin.Txid)]; loaded {
+			continue // Another output of a transaction already read
+		}
 		prevTX, err := chain.FindTransaction(vin.Txid)
 		if err != nil {
 			// [SECURITY FIX] Do not panic on invalid TxID, prevent DoS.
@@ -685,58 +1422,74 @@ func (chain *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateK
 
 // VerifyTransaction verifies transaction input signatures (DB-only lookup)
 func (chain *Blockchain) VerifyTransaction(tx *Transaction) bool {
-	if tx.IsCoinbase() {
-		return true
-	}
-
-	prevTXs := make(map[string]Transaction)
-
-	for _, vin := range tx.Vin {
-		prevTX, err := chain.FindTransaction(vin.Txid)
-		if err != nil {
-			fmt.Printf("⛔ [VerifyTransaction] Rejected: Parent transaction %x does not exist.\n", vin.Txid)
-			return false
-		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+	if err := chain.ValidateTransaction(tx, nil); err != nil {
+		fmt.Printf("⛔ [VerifyTransaction] Rejected: %s\n", err)
+		return false
 	}
-
-	return tx.Verify(prevTXs)
+	return true
 }
 
 // FindTransactionWithMempool checks the mempool first, then falls back to the blockchain DB.
 func (chain *Blockchain) FindTransactionWithMempool(ID []byte, mempool map[string]MempoolItem) (Transaction, error) {
+	return chain.findWithMempool(ID, mempool, nil)
+}
+
+func (chain *Blockchain) findWithMempool(ID []byte, mempool map[string]MempoolItem, cache PrevTxCache) (Transaction, error) {
 	txID := hex.EncodeToString(ID)
 	if item, exists := mempool[txID]; exists {
 		return item.Tx, nil
 	}
-	return chain.FindTransaction(ID)
+	return chain.findParentCached(ID, cache)
 }
 
 // VerifyTransactionWithMempool verifies transaction input signatures,
 // checking the mempool for unconfirmed parent transactions before the DB.
-func (chain *Blockchain) VerifyTransactionWithMempool(tx *Transaction, mempool map[string]MempoolItem) bool {
+// cache (may be nil) is shared by the transactions of one forging round.
+func (chain *Blockchain) VerifyTransactionWithMempool(tx *Transaction, mempool map[string]MempoolItem, cache PrevTxCache) bool {
+	if err := chain.ValidateTransactionCached(tx, mempool, cache); err != nil {
+		fmt.Printf("⛔ [VerifyTransaction] Rejected: %s\n", err)
+		return false
+	}
+	return true
+}
+
+// ValidateTransaction resolves the parents of tx (mempool first when given, then DB)
+// and checks its signatures. Returns ErrUnknownInput or ErrInvalidSignature (wrapped).
+func (chain *Blockchain) ValidateTransaction(tx *Transaction, mempool map[string]MempoolItem) error {
+	return chain.ValidateTransactionCached(tx, mempool, nil)
+}
+
+// ValidateTransactionCached is ValidateTransaction with database reads going
+// through cache (nil: each parent is still read once per transaction)
+func (chain *Blockchain) ValidateTransactionCached(tx *Transaction, mempool map[string]MempoolItem, cache PrevTxCache) error {
 	if tx.IsCoinbase() {
-		return true
+		return nil
 	}
 
 	prevTXs := make(map[string]Transaction)
 
 	for _, vin := range tx.Vin {
-		prevTX, err := chain.FindTransactionWithMempool(vin.Txid, mempool)
+		key := hex.EncodeToString(vin.Txid)
+		if _, loaded := prevTXs[key]; loaded {
+			continue
+		}
+		prevTX, err := chain.findWithMempool(vin.Txid, mempool, cache)
 		if err != nil {
-			fmt.Printf("⛔ [VerifyTransaction] Rejected: Parent transaction %x not found in DB or Mempool.\n", vin.Txid)
-			return false
+			return fmt.Errorf("%w: parent transaction %x not found", ErrUnknownInput, vin.Txid)
 		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+		prevTXs[key] = prevTX
 	}
 
-	return tx.Verify(prevTXs)
+	return tx.CheckSignatures(prevTXs)
 }
 
 // VerifyBlockTransactions validates all transaction signatures in a block
 // using a two-pass approach to handle arbitrary intra-block TX ordering.
 // The optional externalCache accumulates TXs across blocks during IBD.
 func (chain *Blockchain) VerifyBlockTransactions(block *Block, externalCache ...map[string]Transaction) bool {
+	start := time.Now()
+	defer func() { chain.Validation.record(time.Since(start)) }()
+
 	// Extract optional external (cross-block IBD) cache
 	var crossBlockCache map[string]Transaction
 	if len(externalCache) > 0 && externalCache[0] != nil {
@@ -752,17 +1505,51 @@ func (chain *Blockchain) VerifyBlockTransactions(block *Block, externalCache ...
 	}
 
 	// Then overlay this block's own TXs (higher priority)
+	inputs := 0
 	for _, tx := range block.Transactions {
 		if tx == nil {
 			log.Println("⚠️ [VerifyBlockTransactions] Nil transaction found in block, rejecting...")
 			return false
 		}
+		if !tx.IsCoinbase() {
+			inputs += len(tx.Vin)
+		}
+		if inputs > MaxBlockInputs {
+			chain.Validation.OverLimit.Add(1)
+			fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: Block %x spends more than %d inputs\n", block.Hash, MaxBlockInputs)
+			return false
+		}
+		if err := tx.ValidateSize(); err != nil {
+			fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: Transaction %x exceeds limits: %s\n", tx.ID, err)
+			return false
+		}
+		// Consensus rule for every block, whatever encoding it came in: a
+		// transaction ID is the hash of its content. The tx index and the
+		// canonical format store IDs as given, so they must not be chosen by
+		// the forger. Nodes have always derived IDs from the received bytes,
+		// so blocks forged before the rule was checked satisfy it too.
+		if !bytes.Equal(tx.ID, tx.Hash()) {
+			fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: Transaction ID %x does not match its content\n", tx.ID)
+			return false
+		}
+		if !tx.IsFinal(block.Height) {
+			fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: Transaction %x is locked until height %d (block %d)\n", tx.ID, tx.LockHeight, block.Height)
+			return false
+		}
 		blockTxCache[hex.EncodeToString(tx.ID)] = *tx
 	}
 
 	// ── Pass 2: Validate each transaction with the pre-populated cache ──
+	// Parents read from the database are kept for the rest of the block, so
+	// spending many outputs of one transaction costs a single block read.
+	dbParents := make(PrevTxCache)
+	var totalFees int64
 	for _, tx := range block.Transactions {
 		if tx.IsCoinbase() {
+			if tx.Fee != 0 {
+				fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: Coinbase %x declares a fee\n", tx.ID)
+				return false
+			}
 			continue
 		}
 
@@ -774,7 +1561,7 @@ func (chain *Blockchain) VerifyBlockTransactions(block *Block, externalCache ...
 				prevTXs[parentTxID] = cachedTx
 			} else {
 				// Fallback to blockchain database
-				prevTX, err := chain.FindTransaction(vin.Txid)
+				prevTX, err := chain.findParentCached(vin.Txid, dbParents)
 				if err != nil {
 					fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: Parent transaction %x not found.\n", vin.Txid)
 					return false
@@ -787,6 +1574,31 @@ func (chain *Blockchain) VerifyBlockTransactions(block *Block, externalCache ...
 			fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: Invalid signature in transaction %x\n", tx.ID)
 			return false
 		}
+
+		inputTotal, err := tx.InputTotal(prevTXs)
+		if err != nil {
+			fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: Transaction %x: %s\n", tx.ID, err)
+			return false
+		}
+		var outputTotal int64
+		for _, out := range tx.Vout {
+			outputTotal += out.Value
+		}
+		if inputTotal < outputTotal {
+			fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: Transaction %x spends more than its inputs\n", tx.ID)
+			return false
+		}
+		if err := tx.CheckDeclaredFee(inputTotal - outputTotal); err != nil {
+			fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: Transaction %x: %s\n", tx.ID, err)
+			return false
+		}
+		totalFees += inputTotal - outputTotal
+	}
+
+	// ── Coinbase: at most the subsidy for this height plus the fees ────
+	if err := chain.CheckCoinbaseReward(block, totalFees); err != nil {
+		fmt.Printf("⛔ [VerifyBlockTransactions] Rejected: %s\n", err)
+		return false
 	}
 
 	// ── Post-verification: feed this block's TXs into the IBD cache ─────
@@ -828,8 +1640,19 @@ func (i *BlockchainIterator) Next() *Block {
 	return block
 }
 
-// DeserializeBlock deserializes a block
+// DeserializeBlock decodes a block in the canonical format, or in the legacy
+// gob format. A gob stream never starts with blockFormatVersion (its first
+// message is a multi-byte type definition), so the leading byte tells them apart.
 func DeserializeBlock(d []byte) *Block {
+	if len(d) > 0 && (d[0] == blockFormatVersion || d[0] == blockFormatPruned) {
+		block, err := DeserializeBlockCanonical(d)
+		if err != nil {
+			log.Printf("⚠️ DeserializeBlock failed (%d bytes): %v", len(d), err)
+			return nil
+		}
+		return block
+	}
+
 	var block Block
 	decoder := gob.NewDecoder(bytes.NewReader(d))
 	err := decoder.Decode(&block)
@@ -840,6 +1663,28 @@ func DeserializeBlock(d []byte) *Block {
 	return &block
 }
 
+// CleanSnapshots removes the "<dbPath>_snapshot_<nanos>" copies that older
+// versions of 'tx send' made and leaked when killed mid-send. It returns
+// the removed directories.
+func CleanSnapshots() ([]string, error) {
+	dirs, err := filepath.Glob(dbPath + "_snapshot_*")
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return removed, fmt.Errorf("removing %s: %w", dir, err)
+		}
+		removed = append(removed, dir)
+	}
+	return removed, nil
+}
+
 func DBExists() bool {
 	if _, err := os.Stat(dbPath + "/MANIFEST"); os.IsNotExist(err) {
 		return false
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func TestGetBlockByHeightFollowsMainChain(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+
+	genesis := tipBlock(t, chain)
+	var mainBranch []*Block
+	for prev := genesis; len(mainBranch) < 3; prev = mainBranch[len(mainBranch)-1] {
+		block := buildBlock(t, prev, w)
+		if !chain.AddBlock(block) {
+			t.Fatalf("block %d rejected", block.Height)
+		}
+		mainBranch = append(mainBranch, block)
+	}
+	for _, want := range append([]*Block{genesis}, mainBranch...) {
+		got, err := chain.GetBlockByHeight(want.Height)
+		if err != nil {
+			t.Fatalf("height %d: %v", want.Height, err)
+		}
+		if !bytes.Equal(got.Hash, want.Hash) {
+			t.Fatalf("height %d: got block %x, want %x", want.Height, got.Hash, want.Hash)
+		}
+	}
+	if _, err := chain.GetBlockByHeight(4); err == nil {
+		t.Fatal("found a block past the tip")
+	}
+
+	// A longer branch forking after height 1 takes over heights 2..4
+	side := []*Block{buildBlock(t, mainBranch[0], w)}
+	for len(side) < 3 {
+		side = append(side, buildBlock(t, side[len(side)-1], w))
+	}
+	for _, block := range side {
+		chain.AddBlock(block)
+	}
+	if !bytes.Equal(chain.LastHash, side[2].Hash) {
+		t.Fatal("the longer branch did not become the main chain")
+	}
+	for _, want := range append([]*Block{mainBranch[0]}, side...) {
+		got, err := chain.GetBlockByHeight(want.Height)
+		if err != nil || !bytes.Equal(got.Hash, want.Hash) {
+			t.Fatalf("after the reorg, height %d is %x (%v), want %x", want.Height, got.Hash, err, want.Hash)
+		}
+	}
+
+	if _, err := chain.RollbackTip(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.GetBlockByHeight(4); err == nil {
+		t.Fatal("rolled back block is still indexed")
+	}
+}
+
+func TestEnsureHeightIndexBackfills(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+
+	prev := tipBlock(t, chain)
+	for i := 0; i < 3; i++ {
+		prev = buildBlock(t, prev, w)
+		if !chain.AddBlock(prev) {
+			t.Fatalf("block %d rejected", prev.Height)
+		}
+	}
+
+	// A database from before the index
+	if err := chain.Database.DropPrefix([]byte(heightPrefix)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.HashAtHeight(2); err != badger.ErrKeyNotFound {
+		t.Fatalf("index not dropped: %v", err)
+	}
+	if got, err := chain.GetBlockByHeight(2); err != nil || got.Height != 2 {
+		t.Fatalf("unindexed lookup: height %d, %v", got.Height, err)
+	}
+
+	if err := chain.ensureHeightIndex(); err != nil {
+		t.Fatal(err)
+	}
+	for height := 0; height <= 3; height++ {
+		if _, err := chain.HashAtHeight(height); err != nil {
+			t.Fatalf("height %d not indexed: %v", height, err)
+		}
+	}
+	hash, _ := chain.HashAtHeight(3)
+	if !bytes.Equal(hash, prev.Hash) {
+		t.Fatalf("tip indexed as %x, want %x", hash, prev.Hash)
+	}
+}
+
+func TestAddBlockRejectsOversizedTransaction(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+
+	block := buildBlock(t, tipBlock(t, chain), w, oversizedTx(w.GetAddress()))
+	if chain.AddBlock(block) {
+		t.Fatal("block with an oversized transaction accepted")
+	}
+}
+
+func TestAddBlockRejectsForeignTxID(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	funding := addTestBlock(t, chain, w).Transactions[0]
+
+	to, _ := NewWallet()
+	tx := spendTx(t, w, funding, 0, *NewTxOutput(InitialSubsidy-1000, to.GetAddress()))
+	tx.ID = bytes.Repeat([]byte{0xab}, 32)
+
+	// In memory, as a gob P2P block message would deliver it
+	if chain.AddBlock(buildBlock(t, tipBlock(t, chain), w, tx)) {
+		t.Fatal("block with a transaction ID not matching its content accepted")
+	}
+}
+
+func TestVerifyBlockTransactionsInputLimit(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+
+	// Inputs are counted before any is resolved: they needn't exist
+	var txs []*Transaction
+	for i := 0; i*MaxTxInputCount <= MaxBlockInputs; i++ {
+		tx := &Transaction{Vout: []TxOutput{*NewTxOutput(DefaultDustLimit, w.GetAddress())}}
+		for j := 0; j < MaxTxInputCount; j++ {
+			tx.Vin = append(tx.Vin, TxInput{Txid: bytes.Repeat([]byte{byte(i + 1)}, 32), Vout: j})
+		}
+		tx.ID = tx.Hash()
+		txs = append(txs, tx)
+	}
+
+	before := chain.Validation.OverLimit.Load()
+	if chain.VerifyBlockTransactions(buildBlock(t, tipBlock(t, chain), w, txs...)) {
+		t.Fatalf("block spending more than %d inputs accepted", MaxBlockInputs)
+	}
+	if got := chain.Validation.OverLimit.Load() - before; got != 1 {
+		t.Fatalf("over_limit grew by %d, want 1", got)
+	}
+}
+
+// BenchmarkVerifyBlockTransactions measures validating a block spending an
+// output of block 1 against chain length. "scan" resolves the parent by
+// walking down from the tip, as inputs were resolved before the tx index;
+// "indexed" is VerifyBlockTransactions. The signature check is the same in
+// both, so the gap is the lookup alone.
+func BenchmarkVerifyBlockTransactions(b *testing.B) {
+	for _, length := range []int{10, 100, 1000} {
+		chain := newTestChain(b)
+		w, _ := newValidator(b)
+		withValidators(b, 1, w)
+		funding := addTestBlock(b, chain, w).Transactions[0]
+		for chain.GetBestHeight() < length {
+			addTestBlock(b, chain, w)
+		}
+		to, _ := NewWallet()
+		block := buildBlock(b, tipBlock(b, chain), w, spendTx(b, w, funding, 0, *NewTxOutput(InitialSubsidy/2, to.GetAddress())))
+		spend := block.Transactions[1]
+
+		b.Run(fmt.Sprintf("scan/blocks=%d", length), func(b *testing.B) {
+			for b.Loop() {
+				prevTXs := make(map[string]Transaction)
+				for _, in := range spend.Vin {
+					iter := chain.Iterator()
+					for parent := iter.Next(); parent != nil; parent = iter.Next() {
+						for _, tx := range parent.Transactions {
+							if bytes.Equal(tx.ID, in.Txid) {
+								prevTXs[hex.EncodeToString(tx.ID)] = *tx
+							}
+						}
+						if len(parent.PrevBlockHash) == 0 || prevTXs[hex.EncodeToString(in.Txid)].ID != nil {
+							break
+						}
+					}
+				}
+				if !spend.Verify(prevTXs) {
+					b.Fatal("spend rejected")
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("indexed/blocks=%d", length), func(b *testing.B) {
+			for b.Loop() {
+				if !chain.VerifyBlockTransactions(block) {
+					b.Fatal("block rejected")
+				}
+			}
+		})
+	}
+}
+
+func TestRollbackTipDisconnectsBlocks(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	to, _ := NewWallet()
+
+	funding := addTestBlock(t, chain, w)
+	parent := tipBlock(t, chain)
+	before := utxoSnapshot(t, chain)
+
+	spend := spendTx(t, w, funding.Transactions[0], 0, *NewTxOutput(DefaultDustLimit, to.GetAddress()))
+	tip := addTestBlock(t, chain, w, spend)
+	addTestBlock(t, chain, w)
+
+	// Roll back two blocks, as chain rollback --blocks 2 does
+	for range 2 {
+		if _, err := chain.RollbackTip(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !bytes.Equal(chain.LastHash, parent.Hash) || chain.GetBestHeight() != parent.Height {
+		t.Fatalf("tip is %x at height %d, want %x at %d", chain.LastHash, chain.GetBestHeight(), parent.Hash, parent.Height)
+	}
+	if _, err := chain.GetBlock(tip.Hash); err == nil {
+		t.Fatal("rolled back block is still stored")
+	}
+	if _, err := chain.FindTransaction(spend.ID); err == nil {
+		t.Fatal("rolled back transaction is still indexed")
+	}
+	if after := utxoSnapshot(t, chain); !maps.Equal(after, before) {
+		t.Fatalf("UTXO set after rollback:\n got %v\nwant %v", after, before)
+	}
+
+	// The chain keeps growing from the new tip
+	addTestBlock(t, chain, w)
+}
+
+func TestForgeBlockQuorum(t *testing.T) {
+	chain := newTestChain(t)
+	a, keyA := newValidator(t)
+	b, keyB := newValidator(t)
+	withValidators(t, 2, a, b)
+	genesis := chain.LastHash
+
+	coinbase := func() []*Transaction {
+		return []*Transaction{NewCoinbaseTX(a.GetAddress(), "", InitialSubsidy)}
+	}
+
+	// Quorum not met: nothing is stored
+	if _, err := chain.ForgeBlock(coinbase(), nil, keyA); !errors.Is(err, ErrQuorumNotMet) {
+		t.Fatalf("got %v, want %v", err, ErrQuorumNotMet)
+	}
+	if !bytes.Equal(chain.LastHash, genesis) || chain.GetBestHeight() != 0 {
+		t.Fatal("a block missing the quorum was stored")
+	}
+
+	// Quorum met: the block is stored and every node accepts its signatures
+	block, err := chain.ForgeBlock(coinbase(), nil, keyA, keyB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(chain.LastHash, block.Hash) {
+		t.Fatal("forged block is not the tip")
+	}
+	if !VerifyBlockSignature(block) {
+		t.Fatal("forged block fails the quorum check")
+	}
+}
+
+func TestOpenPathsShareBadgerOptions(t *testing.T) {
+	chdirTemp(t)
+	defer func(saved BadgerTuning) { DBTuning = saved }(DBTuning)
+	// Off the defaults, so a path falling back to badger.DefaultOptions shows
+	DBTuning = BadgerTuning{ValueLogFileMB: 32, MemTableMB: 16, BlockCacheMB: 4, NumVersionsToKeep: 2}
+
+	chain, err := InitBlockchain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := chain.Database.Opts()
+	chain.Database.Close()
+
+	opens := []struct {
+		name     string
+		open     func() *Blockchain
+		readOnly bool
+	}{
+		{"ContinueBlockchain", func() *Blockchain { return ContinueBlockchain("") }, false},
+		{"ContinueBlockchainReadOnly", func() *Blockchain { return ContinueBlockchainReadOnly("") }, true},
+		{"ContinueBlockchainSnapshot", func() *Blockchain { return ContinueBlockchainSnapshot(dbPath) }, false},
+	}
+	for _, o := range opens {
+		chain := o.open()
+		got := chain.Database.Opts()
+		chain.Database.Close()
+
+		if got.ReadOnly != o.readOnly {
+			t.Errorf("%s: ReadOnly = %v, want %v", o.name, got.ReadOnly, o.readOnly)
+		}
+		if got.ValueLogFileSize != want.ValueLogFileSize || got.MemTableSize != want.MemTableSize ||
+			got.BlockCacheSize != want.BlockCacheSize || got.NumVersionsToKeep != want.NumVersionsToKeep {
+			t.Errorf("%s: tuning %d/%d/%d/%d, want %d/%d/%d/%d", o.name,
+				got.ValueLogFileSize, got.MemTableSize, got.BlockCacheSize, got.NumVersionsToKeep,
+				want.ValueLogFileSize, want.MemTableSize, want.BlockCacheSize, want.NumVersionsToKeep)
+		}
+		if got.VerifyValueChecksum != want.VerifyValueChecksum || got.DetectConflicts != want.DetectConflicts {
+			t.Errorf("%s: VerifyValueChecksum=%v DetectConflicts=%v, want %v/%v", o.name,
+				got.VerifyValueChecksum, got.DetectConflicts, want.VerifyValueChecksum, want.DetectConflicts)
+		}
+		if got.Logger != nil {
+			t.Errorf("%s: Badger logger left enabled", o.name)
+		}
+	}
+}
+
+// fanOutBlock confirms a transaction of w with n outputs and returns it with
+// an unstored block holding n transactions, each spending one of them
+func fanOutBlock(tb testing.TB, chain *Blockchain, w *Wallet, n int) (*Transaction, *Block) {
+	tb.Helper()
+
+	coinbase := addTestBlock(tb, chain, w).Transactions[0]
+	outputs := make([]TxOutput, n)
+	for i := range outputs {
+		outputs[i] = *NewTxOutput(InitialSubsidy/int64(n+1), w.GetAddress())
+	}
+	funding := spendTx(tb, w, coinbase, 0, outputs...)
+	addTestBlock(tb, chain, w, funding)
+
+	spends := make([]*Transaction, n)
+	for i := range spends {
+		spends[i] = spendTx(tb, w, funding, i, *NewTxOutput(outputs[i].Value-1000, w.GetAddress()))
+	}
+	return funding, buildBlock(tb, tipBlock(tb, chain), w, spends...)
+}
+
+func TestVerifyBlockLoadsSharedParentOnce(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	_, block := fanOutBlock(t, chain, w, 20)
+
+	before := chain.Validation.ParentLoads.Load()
+	if !chain.VerifyBlockTransactions(block) {
+		t.Fatal("block rejected")
+	}
+	if got := chain.Validation.ParentLoads.Load() - before; got != 1 {
+		t.Fatalf("parent loaded %d times for 20 spends of its outputs, want 1", got)
+	}
+}
+
+func TestForgingCacheDoesNotOutliveItsRound(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	s := newTestServer(t, newTestChain(t))
+	s.ValidatorKeys = []ValidatorKey{loadValidatorKey(t, w)}
+	s.EmptyBlocks = true
+
+	coinbase := addTestBlock(t, s.Blockchain, w).Transactions[0]
+	parent := spendTx(t, w, coinbase, 0, *NewTxOutput(InitialSubsidy-1000, w.GetAddress()))
+	addTestBlock(t, s.Blockchain, w, parent)
+	child := spendTx(t, w, parent, 0, *NewTxOutput(InitialSubsidy-2000, w.GetAddress()))
+	childID := hex.EncodeToString(child.ID)
+
+	// A round's cache holds the confirmed parent...
+	cache := make(PrevTxCache)
+	if err := s.Blockchain.ValidateTransactionCached(child, s.Mempool, cache); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache[hex.EncodeToString(parent.ID)]; !ok {
+		t.Fatal("parent read from the database not cached")
+	}
+
+	// ...which the rollback takes off the chain before the next round
+	if _, err := s.Blockchain.RollbackTip(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddToMempool(*child, child.Fee, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	misses := s.Blockchain.Validation.ParentMisses.Load()
+	s.AttemptMine()
+
+	if s.Blockchain.Validation.ParentMisses.Load() == misses {
+		t.Fatal("forging round didn't look the parent up again")
+	}
+	for _, tx := range tipBlock(t, s.Blockchain).Transactions {
+		if hex.EncodeToString(tx.ID) == childID {
+			t.Fatal("child of a rolled back parent forged")
+		}
+	}
+	if _, ok := s.Mempool[childID]; ok {
+		t.Fatal("child of a rolled back parent kept in the mempool")
+	}
+}
+
+// BenchmarkSharedParent measures validating a block whose transactions each
+// spend one of n outputs of the same funding transaction. "cached" is
+// VerifyBlockTransactions, which reads the funding transaction once per
+// block; "uncached" checks each transaction with a nil cache, reading it
+// once per transaction. parent_loads/op reports the database reads.
+func BenchmarkSharedParent(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		chain := newTestChain(b)
+		w, _ := newValidator(b)
+		withValidators(b, 1, w)
+		_, block := fanOutBlock(b, chain, w, n)
+
+		run := func(b *testing.B, verify func() bool) {
+			before := chain.Validation.ParentLoads.Load()
+			for b.Loop() {
+				if !verify() {
+					b.Fatal("block rejected")
+				}
+			}
+			b.ReportMetric(float64(chain.Validation.ParentLoads.Load()-before)/float64(b.N), "parent_loads/op")
+		}
+		b.Run(fmt.Sprintf("cached/spends=%d", n), func(b *testing.B) {
+			run(b, func() bool { return chain.VerifyBlockTransactions(block) })
+		})
+		b.Run(fmt.Sprintf("uncached/spends=%d", n), func(b *testing.B) {
+			run(b, func() bool {
+				for _, tx := range block.Transactions[1:] {
+					if chain.ValidateTransactionCached(tx, nil, nil) != nil {
+						return false
+					}
+				}
+				return true
+			})
+		})
+	}
+}
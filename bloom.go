@@ -0,0 +1,120 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// BloomFilter is a fixed-size set with no false negatives: Test returning
+// false means the item was never added. A true answer must be confirmed
+// against the authoritative store (Badger or the mempool).
+type BloomFilter struct {
+	bits  []uint64
+	k     uint64 // Hash functions per item
+	count int    // Items added
+	mu    sync.RWMutex
+}
+
+// NewBloomFilter sizes a filter for expectedItems at the target false
+// positive rate. Past expectedItems it keeps working, with more positives.
+func NewBloomFilter(expectedItems int, fpRate float64) *BloomFilter {
+	if expectedItems < 1 {
+		expectedItems = 1
+	}
+	m := math.Ceil(-float64(expectedItems) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(expectedItems)*math.Ln2))
+
+	return &BloomFilter{
+		bits: make([]uint64, (uint64(m)+63)/64),
+		k:    uint64(k),
+	}
+}
+
+// Add inserts item into the filter
+func (bf *BloomFilter) Add(item []byte) {
+	h1, h2 := bloomHashes(item)
+	size := uint64(len(bf.bits)) * 64
+
+	bf.mu.Lock()
+	for i := uint64(0); i < bf.k; i++ {
+		bit := (h1 + i*h2) % size
+		bf.bits[bit/64] |= 1 << (bit % 64)
+	}
+	bf.count++
+	bf.mu.Unlock()
+}
+
+// Test reports whether item may have been added
+func (bf *BloomFilter) Test(item []byte) bool {
+	h1, h2 := bloomHashes(item)
+	size := uint64(len(bf.bits)) * 64
+
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+	for i := uint64(0); i < bf.k; i++ {
+		bit := (h1 + i*h2) % size
+		if bf.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Count returns the number of items added
+func (bf *BloomFilter) Count() int {
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+	return bf.count
+}
+
+// bloomHashes derives the two base hashes for double hashing (h1 + i*h2)
+func bloomHashes(item []byte) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write(item)
+	sum := h.Sum64()
+	h1 := sum & 0xffffffff
+	h2 := sum>>32 | 1 // Odd, so the probe sequence never collapses to one bit
+	return h1, h2
+}
+
+// RollingBloomFilter remembers roughly the last 2*capacity items: once the
+// current generation is full it becomes the previous one and a fresh filter
+// takes its place.
+type RollingBloomFilter struct {
+	current  *BloomFilter
+	previous *BloomFilter
+	capacity int
+	fpRate   float64
+	mu       sync.Mutex
+}
+
+func NewRollingBloomFilter(capacity int, fpRate float64) *RollingBloomFilter {
+	return &RollingBloomFilter{
+		current:  NewBloomFilter(capacity, fpRate),
+		previous: NewBloomFilter(capacity, fpRate),
+		capacity: capacity,
+		fpRate:   fpRate,
+	}
+}
+
+// Add inserts item, rotating generations when the current one is full
+func (rf *RollingBloomFilter) Add(item []byte) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.current.Count() >= rf.capacity {
+		rf.previous = rf.current
+		rf.current = NewBloomFilter(rf.capacity, rf.fpRate)
+	}
+	rf.current.Add(item)
+}
+
+// Test reports whether item may have been added recently
+func (rf *RollingBloomFilter) Test(item []byte) bool {
+	rf.mu.Lock()
+	current, previous := rf.current, rf.previous
+	rf.mu.Unlock()
+
+	return current.Test(item) || previous.Test(item)
+}
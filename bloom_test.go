@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// unknownHashes returns n block-sized hashes that are not in any chain
+func unknownHashes(n int) [][]byte {
+	hashes := make([][]byte, n)
+	for i := range hashes {
+		h := sha256.Sum256([]byte{byte(i), byte(i >> 8), 'x'})
+		hashes[i] = h[:]
+	}
+	return hashes
+}
+
+func TestBloomFilterNoFalseNegatives(t *testing.T) {
+	bf := NewBloomFilter(1000, 0.01)
+	items := unknownHashes(2000)
+	for _, item := range items[:1000] {
+		bf.Add(item)
+	}
+	for _, item := range items[:1000] {
+		if !bf.Test(item) {
+			t.Fatalf("added item %x reported absent", item[:4])
+		}
+	}
+
+	falsePositives := 0
+	for _, item := range items[1000:] {
+		if bf.Test(item) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 30 {
+		t.Fatalf("%d/1000 false positives at a 1%% target", falsePositives)
+	}
+}
+
+func TestBlockFilterHitIsConfirmedInStore(t *testing.T) {
+	chain := newTestChain(t)
+	if err := chain.EnableBlockFilter(); err != nil {
+		t.Fatal(err)
+	}
+	if !chain.HasBlock(chain.LastHash) {
+		t.Fatal("stored genesis block not found through the filter")
+	}
+
+	// A false positive: the filter says maybe, Badger says no
+	missing := unknownHashes(1)[0]
+	chain.KnownBlocks.Add(missing)
+	if !chain.MayHaveBlock(missing) {
+		t.Fatal("hash added to the filter reported absent")
+	}
+	if chain.HasBlock(missing) {
+		t.Fatal("filter hit reported as stored without a block in the database")
+	}
+	if skipped := chain.SkippedBlockReads.Load(); skipped != 0 {
+		t.Fatalf("filter hits counted as %d skipped reads", skipped)
+	}
+}
+
+func TestInvFilterSkipsBlockReads(t *testing.T) {
+	chain := newTestChain(t)
+	if err := chain.EnableBlockFilter(); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, chain)
+
+	remote := newTestHost(t)
+	requests := make(chan struct{}, 100)
+	remote.SetStreamHandler(protocolID, func(stream network.Stream) {
+		defer stream.Close()
+		lenBuf := make([]byte, 4)
+		if _, err := io.ReadFull(stream, lenBuf); err != nil {
+			return
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(lenBuf))
+		if _, err := io.ReadFull(stream, payload); err == nil && BytesToCommand(payload[:commandLength]) == "getdata" {
+			requests <- struct{}{}
+		}
+	})
+	connectHosts(t, remote, s.Host)
+
+	// Reads the filter can't skip are its false positives
+	unknown := unknownHashes(50)
+	expected := int64(0)
+	for _, hash := range unknown {
+		if !chain.KnownBlocks.Test(hash) {
+			expected++
+		}
+	}
+
+	items := append([][]byte{chain.LastHash}, unknown...)
+	s.HandleInv(GobEncode(Inv{remote.ID().String(), "block", items}), remote.ID())
+	if skipped := chain.SkippedBlockReads.Load(); skipped != expected {
+		t.Fatalf("HandleInv skipped %d block reads, want %d", skipped, expected)
+	}
+	for range unknown {
+		<-requests
+	}
+	select {
+	case <-requests:
+		t.Fatal("stored block requested again")
+	default:
+	}
+
+	for _, hash := range unknown {
+		s.HandleGetData(GobEncode(GetData{remote.ID().String(), "block", hash}), remote.ID())
+	}
+	if skipped := chain.SkippedBlockReads.Load(); skipped != 2*expected {
+		t.Fatalf("HandleGetData skipped %d block reads, want %d", skipped-expected, expected)
+	}
+	t.Logf("%d of %d unknown hashes answered without reading the database", expected, len(unknown))
+}
@@ -2,23 +2,30 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
 	"text/tabwriter"
 	"time"
 
+	"github.com/multiformats/go-multiaddr"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 const (
@@ -31,20 +38,57 @@ const (
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "sole-cli",
-	Short: "SOLE Blockchain CLI",
-	Long:  `Line command interface for SOLE Blockchain (Educational Project).`,
+	Use:              "sole-cli",
+	Short:            "SOLE Blockchain CLI",
+	Long:             `Line command interface for SOLE Blockchain (Educational Project).`,
+	PersistentPreRun: applyCommandTimeout,
 }
 
+const (
+	// DefaultCommandTimeout bounds one-shot commands (see --timeout). Disabled
+	// by default: chain commands scale with the length of the chain.
+	DefaultCommandTimeout = 0
+
+	// DefaultShutdownTimeout bounds the graceful stop of node start (see --shutdown-timeout)
+	DefaultShutdownTimeout = 15 * time.Second
+
+	// noTimeoutAnnotation marks long-running or interactive commands that ignore --timeout
+	noTimeoutAnnotation = "sole/no-timeout"
+)
+
+// cancelCommandTimeout releases the --timeout context once the command returns
+var cancelCommandTimeout context.CancelFunc = func() {}
+
 var (
-	addressFlag string
-	fromFlag    string
-	toFlag      string
-	amountFlag  float64
-	feeFlag     float64
-	memoFlag    string
-	dryRunFlag  bool
-	privKeyFlag string // Private Key Hex for import
+	addressFlag    string
+	fromFlag       string
+	changeFlag     string // Change address for tx send (defaults to the first --from)
+	toFlag         string
+	amountFlag     float64
+	feeFlag        float64
+	memoFlag       string
+	dryRunFlag     bool
+	lockHeightFlag int // Minimum block height for tx send (0 = no lock)
+	highFeeFlag    bool
+	privKeyFlag    string // Private Key Hex for import
+	compressedFlag bool   // Use the 33-byte compressed public key (create/recover/import)
+	pathFlag       string // Wallet backup file (wallet import-file / export-all)
+	passFileFlag   string // File holding the wallet backup passphrase
+	timeoutFlag    time.Duration
+	blocksFlag     int // Number of blocks for chain rollback
+	lastFlag       int // Newest N blocks for chain verify (0 = all)
+	fromHeightFlag int // First height for chain coverage (-1 = 99 below --to)
+	toHeightFlag   int // Last height for chain coverage (-1 = tip)
+	hashFlag       string
+	templateFlag   string // Canonical block hex for chain sign-block
+	pubKeyFlag     string // Validator public key for chain revoke-validator
+	certFlag       string // Revocation certificate hex for chain revoke-validator
+	presetFlag     string // Genesis preset for chain init / node verify-headers
+	peerFlag       string // Peer multiaddrs for node verify-headers
+	txIDFlag       string
+	heightFlag     int
+	jsonFlag       bool
+	intervalFlag   time.Duration // Balance poll interval for wallet watch-live
 )
 
 func Execute() {
@@ -61,12 +105,36 @@ func Execute() {
 		os.Exit(0)
 	}
 
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.ExecuteContext(context.Background())
+	cancelCommandTimeout()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
+// applyCommandTimeout gives the command a context bounded by --timeout. API
+// calls and peer dials made through it fail once it expires; the process is
+// never killed mid-command, so a database write can't be cut half way.
+func applyCommandTimeout(cmd *cobra.Command, args []string) {
+	if timeoutFlag <= 0 || cmd.Annotations[noTimeoutAnnotation] != "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeoutFlag)
+	cancelCommandTimeout = cancel
+	cmd.SetContext(ctx)
+}
+
+// commandTimeoutErr replaces err with a clear message when it was caused by
+// the --timeout context expiring
+func commandTimeoutErr(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s, is the node running? (raise with --timeout, 0 disables)", timeoutFlag)
+	}
+	return err
+}
+
 func printUsage(cmd *cobra.Command, args []string) {
 	fmt.Println(ColorGreen + `
    _____  ____  _      ______ 
@@ -76,12 +144,13 @@ func printUsage(cmd *cobra.Command, args []string) {
   ____) | |__| | |____| |____ 
  |_____/ \____/|______|______|
 ` + ColorReset)
-	fmt.Println(ColorBold + "   SOLE Blockchain CLI v3.0.0" + ColorReset)
+	fmt.Println(ColorBold + "   SOLE Blockchain CLI " + VersionString() + ColorReset)
 	fmt.Println("   (c) 2026 Università del Salento")
 	fmt.Println()
 
 	fmt.Println(ColorBold + "USAGE:" + ColorReset)
 	fmt.Println("  ./sole-cli <resource> <action> [flags]")
+	fmt.Println("  Global: --timeout <duration> (default 0 = no limit)")
 	fmt.Println()
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
@@ -94,27 +163,55 @@ func printUsage(cmd *cobra.Command, args []string) {
 	fmt.Fprintln(w, "  "+ColorGreen+"recover"+ColorReset+"\tRecovers a wallet from 12-word mnemonic.")
 	fmt.Fprintln(w, "  "+ColorGreen+"remove"+ColorReset+"\tRemoves a wallet (--address <ADDR>).")
 	fmt.Fprintln(w, "  "+ColorGreen+"balance"+ColorReset+"\tChecks balance of an address (--address <ADDR>).")
+	fmt.Fprintln(w, "  "+ColorGreen+"watch-live"+ColorReset+"\tFollows an address live until Ctrl-C (--address <ADDR>).")
 	fmt.Fprintln(w, "  "+ColorGreen+"export"+ColorReset+"\tExports private key (--address <ADDR>).")
+	fmt.Fprintln(w, "  "+ColorGreen+"export-all"+ColorReset+"\tWrites all wallets to a backup file (--out <FILE> [--passphrase-file <FILE>]).")
+	fmt.Fprintln(w, "  "+ColorGreen+"import-file"+ColorReset+"\tMerges the wallets of a backup file (--path <FILE> [--passphrase-file <FILE>]).")
 	fmt.Fprintln(w, "")
 
 	// 2. CHAIN
 	fmt.Fprintln(w, ColorYellow+"2. BLOCKCHAIN OPERATIONS (chain)"+ColorReset)
-	fmt.Fprintln(w, "  "+ColorGreen+"init"+ColorReset+"\tInitializes the Genesis Block and DB.")
+	fmt.Fprintln(w, "  "+ColorGreen+"init"+ColorReset+"\tInitializes the Genesis Block and DB (--preset <NAME> for a lab network).")
+	fmt.Fprintln(w, "  "+ColorGreen+"presets"+ColorReset+"\tLists the genesis presets for chain init --preset.")
 	fmt.Fprintln(w, "  "+ColorGreen+"reindex"+ColorReset+"\tRebuilds the UTXO index.")
 	fmt.Fprintln(w, "  "+ColorGreen+"print"+ColorReset+"\tPrints all blocks in the chain.")
+	fmt.Fprintln(w, "  "+ColorGreen+"block"+ColorReset+"\tPrints and verifies a single block (--hash <HEX> | --height <N>).")
+	fmt.Fprintln(w, "  "+ColorGreen+"verify"+ColorReset+"\tVerifies every stored block, or the newest N (--last <N>).")
+	fmt.Fprintln(w, "  "+ColorGreen+"audit-utxo"+ColorReset+"\tCompares the UTXO set with one recomputed from the blocks.")
+	fmt.Fprintln(w, "  "+ColorGreen+"coverage"+ColorReset+"\tTallies the validators that signed a height range (--from <N> --to <N>).")
+	fmt.Fprintln(w, "  "+ColorGreen+"sign-block"+ColorReset+"\tSigns a block template offline (--template <HEX> --address <ADDR>).")
+	fmt.Fprintln(w, "  "+ColorGreen+"revoke-validator"+ColorReset+"\tRevokes a compromised validator key once a majority of the others sign.")
+	fmt.Fprintln(w, "  "+ColorGreen+"rollback"+ColorReset+"\tRemoves the last N blocks (--blocks <N>), for development.")
 	fmt.Fprintln(w, "  "+ColorGreen+"reset"+ColorReset+"\t"+ColorRed+"DELETES"+ColorReset+" the blockchain database.")
+	fmt.Fprintln(w, "  "+ColorGreen+"clean-snapshots"+ColorReset+"\tRemoves leftover database snapshots from interrupted sends.")
 	fmt.Fprintln(w, "")
 
 	// 3. NODE
 	fmt.Fprintln(w, ColorYellow+"3. NODE & NETWORK (node)"+ColorReset)
 	fmt.Fprintln(w, "  "+ColorGreen+"start"+ColorReset+"\tStarts the P2P node and Miner.")
-	fmt.Fprintln(w, "\t"+ColorCyan+"Flags:"+ColorReset+" --port, --miner, --bootnodes, --public-ip")
+	fmt.Fprintln(w, "\t"+ColorCyan+"Flags:"+ColorReset+" --port, --miner, --reward-address, --bootnodes, --mdns, --p2p-compression, --public-ip, --coinbase-message, --empty-blocks, --verify-chain, --light, --dry-run")
+	fmt.Fprintln(w, "  "+ColorGreen+"serve-api"+ColorReset+"\tServes the REST API only, from a read-only DB.")
+	fmt.Fprintln(w, "  "+ColorGreen+"rotate-key"+ColorReset+"\tReplaces the P2P identity key (new PeerID), backing up the old one.")
+	fmt.Fprintln(w, "  "+ColorGreen+"verify-headers"+ColorReset+"\tVerifies a peer's header chain without a local database (--peer <MULTIADDR>).")
 	fmt.Fprintln(w, "")
 
 	// 4. TX
 	fmt.Fprintln(w, ColorYellow+"4. TRANSACTIONS (tx)"+ColorReset)
 	fmt.Fprintln(w, "  "+ColorGreen+"send"+ColorReset+"\tSends funds between wallets.")
-	fmt.Fprintln(w, "\t"+ColorCyan+"Flags:"+ColorReset+" --from, --to, --amount, --fee, --memo, --dry-run")
+	fmt.Fprintln(w, "  "+ColorGreen+"size"+ColorReset+"\tReports the size and suggested fee of a send, without sending.")
+	fmt.Fprintln(w, "\t"+ColorCyan+"Flags:"+ColorReset+" --from, --to, --amount, --fee, --memo, --change, --lock-height, --dry-run")
+	fmt.Fprintln(w, "  "+ColorGreen+"rebroadcast"+ColorReset+"\tRe-announces a mempool transaction to the node's peers.")
+	fmt.Fprintln(w, "\t"+ColorCyan+"Flags:"+ColorReset+" --id")
+	fmt.Fprintln(w, "")
+
+	// 5. VERSION
+	fmt.Fprintln(w, ColorYellow+"5. BUILD INFO"+ColorReset)
+	fmt.Fprintln(w, "  "+ColorGreen+"version"+ColorReset+"\tPrints build version, commit and date.")
+	fmt.Fprintln(w, "")
+
+	// 6. COMPLETION
+	fmt.Fprintln(w, ColorYellow+"6. SHELL COMPLETION (completion)"+ColorReset)
+	fmt.Fprintln(w, "  "+ColorGreen+"bash|zsh|fish|powershell"+ColorReset+"\tPrints the completion script (wallet addresses complete for --address, --from, --to).")
 	fmt.Fprintln(w, "")
 
 	w.Flush()
@@ -126,19 +223,92 @@ func initConfig() {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 
+	// Completion output is parsed by the shell: keep the config notices out of it
+	quiet := isCompletionRequest()
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			fmt.Println("ℹ️  No config file found, relying on flags/defaults")
-		} else {
+			if !quiet {
+				fmt.Println("ℹ️  No config file found, relying on flags/defaults")
+			}
+		} else if !quiet {
 			fmt.Printf("⚠️  Config file error: %v\n", err)
 		}
-	} else {
+	} else if !quiet {
 		fmt.Printf("ℹ️  Using config file: %s\n", viper.ConfigFileUsed())
 	}
+
+	tuning := BadgerTuning{
+		ValueLogFileMB:    viper.GetInt("db.vlog_mb"),
+		MemTableMB:        viper.GetInt("db.memtable_mb"),
+		BlockCacheMB:      viper.GetInt("db.cache_mb"),
+		NumVersionsToKeep: viper.GetInt("db.versions"),
+	}
+	if err := tuning.Validate(); err != nil {
+		fmt.Printf("⛔ ERROR: Invalid database tuning: %v.\n", err)
+		os.Exit(1)
+	}
+	DBTuning = tuning
+
+	switch format := strings.ToLower(strings.TrimSpace(viper.GetString("wallet.format"))); format {
+	case "", WalletFormatGob, WalletFormatJSON:
+		WalletFormat = format
+	default:
+		fmt.Printf("⛔ ERROR: wallet.format must be %s or %s, got %q.\n", WalletFormatGob, WalletFormatJSON, format)
+		os.Exit(1)
+	}
+
+	var allocations []struct {
+		Address string  `mapstructure:"address"`
+		Amount  float64 `mapstructure:"amount"` // SOLE
+	}
+	if err := viper.UnmarshalKey("genesis.allocations", &allocations); err != nil {
+		fmt.Printf("⛔ ERROR: Invalid genesis.allocations: %v\n", err)
+		os.Exit(1)
+	}
+	GenesisAllocations = nil
+	for _, a := range allocations {
+		GenesisAllocations = append(GenesisAllocations, GenesisAllocation{
+			Address: strings.TrimSpace(a.Address),
+			Amount:  int64(math.Round(a.Amount * 100000000)),
+		})
+	}
+	if err := ValidateGenesisAllocations(GenesisAllocations); err != nil {
+		fmt.Printf("⛔ ERROR: Invalid genesis.allocations: %v.\n", err)
+		os.Exit(1)
+	}
+
+	if viper.IsSet("consensus.quorum") {
+		quorum := viper.GetInt("consensus.quorum")
+		if quorum < 1 || quorum > len(AuthorizedValidators) {
+			fmt.Printf("⛔ ERROR: consensus.quorum must be between 1 and %d (the number of authorized validators), got %d.\n", len(AuthorizedValidators), quorum)
+			os.Exit(1)
+		}
+		BlockQuorum = quorum
+	}
+
+	if viper.IsSet("consensus.block_reward") {
+		reward := int64(math.Round(viper.GetFloat64("consensus.block_reward") * 100000000))
+		if reward < 0 || reward > MaxBlockReward {
+			fmt.Printf("⛔ ERROR: consensus.block_reward must be between 0 and %d SOLE, got %.8f.\n", MaxBlockReward/100000000, viper.GetFloat64("consensus.block_reward"))
+			os.Exit(1)
+		}
+		BlockReward = reward
+	}
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentFlags().DurationVar(&timeoutFlag, "timeout", DefaultCommandTimeout, "Give up on API calls and peer dials after this long (0 disables; node, chain-walking and interactive commands are exempt)")
+	rootCmd.PersistentFlags().Int("db-cache-mb", DBTuning.BlockCacheMB, "Badger block cache size in MB")
+	rootCmd.PersistentFlags().Int("db-memtable-mb", DBTuning.MemTableMB, "Badger memtable size in MB")
+	rootCmd.PersistentFlags().Int("db-vlog-mb", DBTuning.ValueLogFileMB, "Badger value log file size in MB")
+	rootCmd.PersistentFlags().Int("db-versions", DBTuning.NumVersionsToKeep, "Versions Badger keeps per key")
+	viper.BindPFlag("db.cache_mb", rootCmd.PersistentFlags().Lookup("db-cache-mb"))
+	viper.BindPFlag("db.memtable_mb", rootCmd.PersistentFlags().Lookup("db-memtable-mb"))
+	viper.BindPFlag("db.vlog_mb", rootCmd.PersistentFlags().Lookup("db-vlog-mb"))
+	viper.BindPFlag("db.versions", rootCmd.PersistentFlags().Lookup("db-versions"))
+	rootCmd.PersistentFlags().String("wallet-format", "", "Write wallet.dat as gob or json (default: keep the file's format; new files are gob)")
+	viper.BindPFlag("wallet.format", rootCmd.PersistentFlags().Lookup("wallet-format"))
 	var walletCmd = &cobra.Command{
 		Use:   "wallet",
 		Short: "Manage wallets",
@@ -150,6 +320,7 @@ func init() {
 		Short: "Create a new wallet",
 		Run:   createWallet,
 	}
+	walletCreateCmd.Flags().BoolVar(&compressedFlag, "compressed", false, "Use a compressed public key (smaller transactions, different address)")
 	walletCmd.AddCommand(walletCreateCmd)
 
 	var walletListCmd = &cobra.Command{
@@ -166,6 +337,7 @@ func init() {
 	}
 	// Changed flag from 'privkey' to 'key' as requested
 	walletImportCmd.Flags().StringVar(&privKeyFlag, "key", "", "Private Key in Hex format")
+	walletImportCmd.Flags().BoolVar(&compressedFlag, "compressed", false, "Derive the compressed-key address")
 	walletImportCmd.MarkFlagRequired("key")
 	walletCmd.AddCommand(walletImportCmd)
 
@@ -174,12 +346,14 @@ func init() {
 		Short: "Recovers a wallet from a 12-word Mnemonic Phrase",
 		Run:   runRecoverWallet,
 	}
+	walletRecoverCmd.Flags().BoolVar(&compressedFlag, "compressed", false, "Recover the compressed-key address")
 	walletCmd.AddCommand(walletRecoverCmd)
 
 	var walletRemoveCmd = &cobra.Command{
-		Use:   "remove",
-		Short: "Removes a wallet from a wallet file",
-		Run:   runRemoveWallet,
+		Use:         "remove",
+		Short:       "Removes a wallet from a wallet file",
+		Run:         runRemoveWallet,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Waits on a y/N prompt
 	}
 	walletRemoveCmd.Flags().StringVar(&addressFlag, "address", "", "Address of the wallet to remove")
 	walletRemoveCmd.MarkFlagRequired("address")
@@ -194,6 +368,17 @@ func init() {
 	walletBalanceCmd.MarkFlagRequired("address")
 	walletCmd.AddCommand(walletBalanceCmd)
 
+	var walletWatchLiveCmd = &cobra.Command{
+		Use:         "watch-live",
+		Short:       "Print an address's pending and confirmed transactions and balance changes as they happen",
+		Run:         runWatchLive,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Runs until Ctrl-C
+	}
+	walletWatchLiveCmd.Flags().StringVar(&addressFlag, "address", "", "Address to watch")
+	walletWatchLiveCmd.Flags().DurationVar(&intervalFlag, "interval", 10*time.Second, "Also re-check the balance this often")
+	walletWatchLiveCmd.MarkFlagRequired("address")
+	walletCmd.AddCommand(walletWatchLiveCmd)
+
 	var walletExportCmd = &cobra.Command{
 		Use:   "export",
 		Short: "Print wallet details (Private Key)",
@@ -203,6 +388,26 @@ func init() {
 	walletExportCmd.MarkFlagRequired("address")
 	walletCmd.AddCommand(walletExportCmd)
 
+	var walletExportAllCmd = &cobra.Command{
+		Use:   "export-all",
+		Short: "Writes every wallet to a backup file",
+		Run:   runExportAllWallets,
+	}
+	walletExportAllCmd.Flags().StringVar(&pathFlag, "out", "", "Backup file to create")
+	walletExportAllCmd.Flags().StringVar(&passFileFlag, "passphrase-file", "", "Read the passphrase encrypting the backup from this file")
+	walletExportAllCmd.MarkFlagRequired("out")
+	walletCmd.AddCommand(walletExportAllCmd)
+
+	var walletImportFileCmd = &cobra.Command{
+		Use:   "import-file",
+		Short: "Merges the wallets of a backup file (or another wallet.dat)",
+		Run:   runImportWalletFile,
+	}
+	walletImportFileCmd.Flags().StringVar(&pathFlag, "path", "", "Backup file to import")
+	walletImportFileCmd.Flags().StringVar(&passFileFlag, "passphrase-file", "", "Read the passphrase of an encrypted backup from this file")
+	walletImportFileCmd.MarkFlagRequired("path")
+	walletCmd.AddCommand(walletImportFileCmd)
+
 	// --- CHAIN COMMANDS ---
 	var chainCmd = &cobra.Command{
 		Use:   "chain",
@@ -211,33 +416,124 @@ func init() {
 	rootCmd.AddCommand(chainCmd)
 
 	var chainInitCmd = &cobra.Command{
-		Use:   "init",
-		Short: "Initializes the local database with the Official Genesis Block.",
-		Run:   runInit,
+		Use:         "init",
+		Short:       "Initializes the local database with the Official Genesis Block.",
+		Run:         runInit,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Writes the genesis block
 	}
+	chainInitCmd.Flags().StringVar(&presetFlag, "preset", "", "Start a lab network from a genesis preset (see 'chain presets')")
 	chainCmd.AddCommand(chainInitCmd)
 
+	var chainPresetsCmd = &cobra.Command{
+		Use:   "presets",
+		Short: "List the genesis presets for chain init --preset",
+		Run:   runListPresets,
+	}
+	chainPresetsCmd.Flags().BoolVar(&jsonFlag, "json", false, "Print the presets as JSON")
+	chainCmd.AddCommand(chainPresetsCmd)
+
 	var chainReindexCmd = &cobra.Command{
-		Use:   "reindex",
-		Short: "Rebuilds the UTXO set",
-		Run:   reindexUTXO,
+		Use:         "reindex",
+		Short:       "Rebuilds the UTXO set",
+		Run:         reindexUTXO,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Scales with the chain
 	}
 	chainCmd.AddCommand(chainReindexCmd)
 
 	var chainPrintCmd = &cobra.Command{
-		Use:   "print",
-		Short: "Print all blocks in the chain",
-		Run:   printChain,
+		Use:         "print",
+		Short:       "Print all blocks in the chain",
+		Run:         printChain,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Scales with the chain
 	}
 	chainCmd.AddCommand(chainPrintCmd)
 
+	var chainBlockCmd = &cobra.Command{
+		Use:   "block",
+		Short: "Print and verify a single block",
+		Run:   runInspectBlock,
+	}
+	chainBlockCmd.Flags().StringVar(&hashFlag, "hash", "", "Block hash in Hex format")
+	chainBlockCmd.Flags().IntVar(&heightFlag, "height", -1, "Block height")
+	chainBlockCmd.Flags().BoolVar(&jsonFlag, "json", false, "Print the block as JSON")
+	chainCmd.AddCommand(chainBlockCmd)
+
+	var chainVerifyCmd = &cobra.Command{
+		Use:         "verify",
+		Short:       "Verify every stored block (hashes, PoA signatures, header rules)",
+		Run:         runVerifyChain,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Scales with the chain
+	}
+	chainVerifyCmd.Flags().IntVar(&lastFlag, "last", 0, "Only verify the newest N blocks (0 = all)")
+	chainCmd.AddCommand(chainVerifyCmd)
+
+	var chainAuditUTXOCmd = &cobra.Command{
+		Use:         "audit-utxo",
+		Short:       "Recompute the UTXO set from the blocks and compare it with the stored one",
+		Run:         runAuditUTXO,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Scales with the chain
+	}
+	chainAuditUTXOCmd.Flags().BoolVar(&jsonFlag, "json", false, "Print the report as JSON")
+	chainCmd.AddCommand(chainAuditUTXOCmd)
+
+	var chainCoverageCmd = &cobra.Command{
+		Use:         "coverage",
+		Short:       "Show which validator signed each block of a height range",
+		Run:         runChainCoverage,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Scales with the chain
+	}
+	chainCoverageCmd.Flags().IntVar(&fromHeightFlag, "from", -1, "First height (default: 100 blocks before --to)")
+	chainCoverageCmd.Flags().IntVar(&toHeightFlag, "to", -1, "Last height (default: the tip)")
+	chainCoverageCmd.Flags().BoolVar(&jsonFlag, "json", false, "Print the report as JSON, including every block")
+	chainCmd.AddCommand(chainCoverageCmd)
+
+	var chainSignBlockCmd = &cobra.Command{
+		Use:         "sign-block",
+		Short:       "Sign a block template with a local validator key (offline validators)",
+		Run:         runSignBlock,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Mines the template (PoW)
+	}
+	chainSignBlockCmd.Flags().StringVar(&templateFlag, "template", "", "Block in canonical format (Hex)")
+	chainSignBlockCmd.Flags().StringVar(&addressFlag, "address", "", "Validator address whose key signs")
+	chainSignBlockCmd.MarkFlagRequired("template")
+	chainSignBlockCmd.MarkFlagRequired("address")
+	chainCmd.AddCommand(chainSignBlockCmd)
+
+	var chainRevokeValidatorCmd = &cobra.Command{
+		Use:   "revoke-validator",
+		Short: "Revoke a compromised validator key (needs a majority of the other validators)",
+		Run:   runRevokeValidator,
+	}
+	chainRevokeValidatorCmd.Flags().StringVar(&pubKeyFlag, "pubkey", "", "Public key (Hex) of the validator to revoke")
+	chainRevokeValidatorCmd.Flags().StringVar(&certFlag, "certificate", "", "Revocation signed so far (Hex), to add a signature")
+	chainRevokeValidatorCmd.Flags().StringVar(&addressFlag, "address", "", "Validator address whose key signs")
+	chainCmd.AddCommand(chainRevokeValidatorCmd)
+
 	var chainResetCmd = &cobra.Command{
-		Use:   "reset",
-		Short: "Resets (DELETES) the blockchain database",
-		Run:   runResetChain,
+		Use:         "reset",
+		Short:       "Resets (DELETES) the blockchain database",
+		Run:         runResetChain,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Waits on a y/N prompt
 	}
 	chainCmd.AddCommand(chainResetCmd)
 
+	var chainRollbackCmd = &cobra.Command{
+		Use:         "rollback",
+		Short:       "Removes the last N blocks from the local chain (development)",
+		Run:         runRollbackChain,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Waits on a y/N prompt
+	}
+	chainRollbackCmd.Flags().IntVar(&blocksFlag, "blocks", 0, "Number of blocks to roll back from the tip")
+	chainRollbackCmd.MarkFlagRequired("blocks")
+	chainCmd.AddCommand(chainRollbackCmd)
+
+	var chainCleanSnapshotsCmd = &cobra.Command{
+		Use:   "clean-snapshots",
+		Short: "Removes leftover tx send database snapshots",
+		Run:   runCleanSnapshots,
+	}
+	chainCmd.AddCommand(chainCleanSnapshotsCmd)
+
 	// --- NODE COMMANDS ---
 	var nodeCmd = &cobra.Command{
 		Use:   "node",
@@ -246,28 +542,98 @@ func init() {
 	rootCmd.AddCommand(nodeCmd)
 
 	var nodeStartCmd = &cobra.Command{
-		Use:   "start",
-		Short: "Start the P2P node",
-		Run:   startNode,
+		Use:         "start",
+		Short:       "Start the P2P node",
+		Run:         startNode,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"},
 	}
 	nodeStartCmd.Flags().Int("port", 3000, "P2P Port")
-	nodeStartCmd.Flags().String("listen", "0.0.0.0", "Local Listen IP for P2P")
+	nodeStartCmd.Flags().StringSlice("listen", []string{"0.0.0.0"}, "Local Listen IP(s) for P2P, repeatable (e.g. --listen 0.0.0.0 --listen ::)")
+	nodeStartCmd.Flags().String("transport", TransportTCP, "P2P transport: tcp, quic or both")
 	nodeStartCmd.Flags().String("public-ip", "", "Public IP Address (Announce)")
 	nodeStartCmd.Flags().String("public-dns", "", "Public Domain Name (Announce)")
 	nodeStartCmd.Flags().String("bootnodes", "", "Comma-separated list of Bootnodes")
-	nodeStartCmd.Flags().String("miner", "", "Miner address")
+	nodeStartCmd.Flags().Duration("dial-timeout", DefaultDialTimeout, "Give up on a P2P connection attempt after this long")
+	nodeStartCmd.Flags().Bool("mdns", true, "Discover peers on the local network (--mdns=false to rely on bootnodes only)")
+	nodeStartCmd.Flags().Bool("p2p-compression", true, "Gzip large P2P payloads for peers that support it")
+	nodeStartCmd.Flags().Float64("max-msg-rate", DefaultMaxMsgRate, "Disconnect peers sending more P2P messages per second than this (0 = unlimited)")
+	nodeStartCmd.Flags().String("miner", "", "Validator address(es), comma-separated")
+	nodeStartCmd.Flags().String("reward-address", "", "Address receiving block rewards (default: the signing --miner address)")
+	nodeStartCmd.Flags().String("coinbase-message", "", "Message stamped in the coinbase of forged blocks (max 100 bytes)")
+	nodeStartCmd.Flags().Bool("empty-blocks", false, "Forge a block every interval even when the mempool is empty")
+	nodeStartCmd.Flags().Int64("dust-limit", DefaultDustLimit, "Reject outputs below this many Photons")
+	nodeStartCmd.Flags().Float64("max-tx-fee", float64(DefaultMaxTxFee)/100000000, "Reject API transactions paying more than this fee in SOLE")
+	nodeStartCmd.Flags().Int("max-mempool-size", DefaultMaxMempoolBytes/(1024*1024), "Mempool size limit in MB (lowest fee-rate transactions are evicted)")
+	nodeStartCmd.Flags().Duration("mempool-expiry", DefaultMempoolExpiry, "Drop transactions still unmined after this long (0 = never)")
+	nodeStartCmd.Flags().Float64("block-reward", float64(InitialSubsidy)/100000000, "Block subsidy in SOLE before halvings (consensus rule, every node must agree)")
+	nodeStartCmd.Flags().Int("prune", 0, fmt.Sprintf("Discard the bodies of blocks older than this many blocks (0 = keep everything, min %d)", MinPruneKeepBlocks))
 	nodeStartCmd.Flags().Int("api-port", 8080, "API Server Port")
-	nodeStartCmd.Flags().String("api-listen", "0.0.0.0", "Local Listen IP for API")
+	nodeStartCmd.Flags().String("api-listen", "0.0.0.0", "Local Listen IP for API, or unix:<path> for a Unix socket")
+	nodeStartCmd.Flags().Bool("no-api", false, "Don't start the REST API (P2P relay or validator only)")
+	nodeStartCmd.Flags().String("verify-chain", "", "Verify stored blocks before starting: full, or last:N for the newest N")
+	nodeStartCmd.Flags().Lookup("verify-chain").NoOptDefVal = "full"
+	nodeStartCmd.Flags().Duration("shutdown-timeout", DefaultShutdownTimeout, "Force exit if a graceful shutdown takes longer than this")
+	nodeStartCmd.Flags().Bool("dry-run", false, "Check the configuration, print what the node would do and exit")
+	nodeStartCmd.Flags().Duration("audit-utxo", 0, "Compare the UTXO set with the chain at this interval (0 = off)")
+	nodeStartCmd.Flags().Bool("light", false, "Store block headers only and fetch blocks and transactions from peers when the API asks")
 	nodeCmd.AddCommand(nodeStartCmd)
 
 	viper.BindPFlag("node.port", nodeStartCmd.Flags().Lookup("port"))
 	viper.BindPFlag("node.listen", nodeStartCmd.Flags().Lookup("listen"))
+	viper.BindPFlag("node.transport", nodeStartCmd.Flags().Lookup("transport"))
 	viper.BindPFlag("network.public_ip", nodeStartCmd.Flags().Lookup("public-ip"))
 	viper.BindPFlag("network.public_dns", nodeStartCmd.Flags().Lookup("public-dns"))
 	viper.BindPFlag("network.bootnodes", nodeStartCmd.Flags().Lookup("bootnodes"))
+	viper.BindPFlag("network.mdns", nodeStartCmd.Flags().Lookup("mdns"))
+	viper.BindPFlag("network.compression", nodeStartCmd.Flags().Lookup("p2p-compression"))
+	viper.BindPFlag("network.dial_timeout", nodeStartCmd.Flags().Lookup("dial-timeout"))
+	viper.BindPFlag("network.max_msg_rate", nodeStartCmd.Flags().Lookup("max-msg-rate"))
 	viper.BindPFlag("node.miner", nodeStartCmd.Flags().Lookup("miner"))
+	viper.BindPFlag("node.reward_address", nodeStartCmd.Flags().Lookup("reward-address"))
+	viper.BindPFlag("node.coinbase_message", nodeStartCmd.Flags().Lookup("coinbase-message"))
+	viper.BindPFlag("node.empty_blocks", nodeStartCmd.Flags().Lookup("empty-blocks"))
+	viper.BindPFlag("node.dust_limit", nodeStartCmd.Flags().Lookup("dust-limit"))
+	viper.BindPFlag("node.max_tx_fee", nodeStartCmd.Flags().Lookup("max-tx-fee"))
+	viper.BindPFlag("node.max_mempool_size", nodeStartCmd.Flags().Lookup("max-mempool-size"))
+	viper.BindPFlag("node.mempool_expiry", nodeStartCmd.Flags().Lookup("mempool-expiry"))
+	viper.BindPFlag("node.prune", nodeStartCmd.Flags().Lookup("prune"))
+	viper.BindPFlag("consensus.block_reward", nodeStartCmd.Flags().Lookup("block-reward"))
 	viper.BindPFlag("api.port", nodeStartCmd.Flags().Lookup("api-port"))
 	viper.BindPFlag("api.listen", nodeStartCmd.Flags().Lookup("api-listen"))
+	viper.BindPFlag("api.disabled", nodeStartCmd.Flags().Lookup("no-api"))
+	viper.BindPFlag("node.verify_chain", nodeStartCmd.Flags().Lookup("verify-chain"))
+	viper.BindPFlag("node.shutdown_timeout", nodeStartCmd.Flags().Lookup("shutdown-timeout"))
+	viper.BindPFlag("node.audit_utxo", nodeStartCmd.Flags().Lookup("audit-utxo"))
+	viper.BindPFlag("node.light", nodeStartCmd.Flags().Lookup("light"))
+	viper.BindPFlag("node.dry_run", nodeStartCmd.Flags().Lookup("dry-run"))
+
+	var nodeServeAPICmd = &cobra.Command{
+		Use:         "serve-api",
+		Short:       "Serve the REST API from a read-only DB (no P2P, no mining)",
+		Run:         runServeAPI,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"},
+	}
+	nodeServeAPICmd.Flags().Int("api-port", 8080, "API Server Port")
+	nodeServeAPICmd.Flags().String("api-listen", "0.0.0.0", "Local Listen IP for API, or unix:<path> for a Unix socket")
+	nodeCmd.AddCommand(nodeServeAPICmd)
+
+	var nodeRotateKeyCmd = &cobra.Command{
+		Use:         "rotate-key",
+		Short:       "Replace the P2P identity key (new PeerID), keeping a backup of the old one",
+		Run:         runRotateNodeKey,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"},
+	}
+	nodeCmd.AddCommand(nodeRotateKeyCmd)
+
+	var nodeVerifyHeadersCmd = &cobra.Command{
+		Use:         "verify-headers",
+		Short:       "Download and verify a peer's block headers (light client, no database)",
+		Run:         runVerifyHeaders,
+		Annotations: map[string]string{noTimeoutAnnotation: "true"}, // Scales with the chain
+	}
+	nodeVerifyHeadersCmd.Flags().StringVar(&peerFlag, "peer", "", "Comma-separated peer multiaddrs to try (default: the bootnodes)")
+	nodeVerifyHeadersCmd.Flags().StringVar(&presetFlag, "preset", "", "Genesis preset of the network (see 'chain presets')")
+	nodeCmd.AddCommand(nodeVerifyHeadersCmd)
 
 	// --- TX COMMANDS ---
 	var txCmd = &cobra.Command{
@@ -281,27 +647,263 @@ func init() {
 		Short: "Send amount from one address to another",
 		Run:   send,
 	}
-	txSendCmd.Flags().StringVar(&fromFlag, "from", "", "Source address")
+	txSendCmd.Flags().StringVar(&fromFlag, "from", "", "Source address, or several comma-separated (change returns to the first)")
 	txSendCmd.Flags().StringVar(&toFlag, "to", "", "Destination address")
+	txSendCmd.Flags().StringVar(&changeFlag, "change", "", "Owned address that receives the change (default: first --from)")
 	txSendCmd.Flags().Float64Var(&amountFlag, "amount", 0, "Amount to send")
 	txSendCmd.Flags().Float64Var(&feeFlag, "fee", 0.001, "Transaction fee in SOLE")
 	txSendCmd.Flags().StringVar(&memoFlag, "memo", "", "Short public transaction memo (max 80 chars)")
 	txSendCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Print transaction hex without sending")
+	txSendCmd.Flags().IntVar(&lockHeightFlag, "lock-height", 0, "Don't let the transaction be mined before this block height")
+	txSendCmd.Flags().BoolVar(&highFeeFlag, "allow-high-fee", false, "Allow a fee above the node's ceiling")
 	txSendCmd.MarkFlagRequired("from")
 	txSendCmd.MarkFlagRequired("to")
 	txSendCmd.MarkFlagRequired("amount")
 	txCmd.AddCommand(txSendCmd)
+
+	var txSizeCmd = &cobra.Command{
+		Use:   "size",
+		Short: "Build a send without broadcasting it and report its size and fee",
+		Run:   runTxSize,
+	}
+	txSizeCmd.Flags().StringVar(&fromFlag, "from", "", "Source address, or several comma-separated")
+	txSizeCmd.Flags().StringVar(&toFlag, "to", "", "Destination address")
+	txSizeCmd.Flags().StringVar(&changeFlag, "change", "", "Owned address that receives the change (default: first --from)")
+	txSizeCmd.Flags().Float64Var(&amountFlag, "amount", 0, "Amount to send")
+	txSizeCmd.Flags().Float64Var(&feeFlag, "fee", 0.001, "Fee in SOLE used for UTXO selection")
+	txSizeCmd.Flags().StringVar(&memoFlag, "memo", "", "Short public transaction memo (max 80 chars)")
+	txSizeCmd.MarkFlagRequired("from")
+	txSizeCmd.MarkFlagRequired("to")
+	txSizeCmd.MarkFlagRequired("amount")
+	txCmd.AddCommand(txSizeCmd)
+
+	var txRebroadcastCmd = &cobra.Command{
+		Use:   "rebroadcast",
+		Short: "Re-announce a stuck mempool transaction to peers",
+		Run:   runRebroadcast,
+	}
+	txRebroadcastCmd.Flags().StringVar(&txIDFlag, "id", "", "Transaction ID (hex)")
+	txRebroadcastCmd.MarkFlagRequired("id")
+	txCmd.AddCommand(txRebroadcastCmd)
+
+	// --- VERSION ---
+	var versionCmd = &cobra.Command{
+		Use:   "version",
+		Short: "Print build version, commit and date",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Printf("Version:    %s\n", BuildVersion)
+			fmt.Printf("Commit:     %s\n", BuildCommit)
+			fmt.Printf("Build Date: %s\n", BuildDate)
+		},
+	}
+	rootCmd.AddCommand(versionCmd)
+
+	// --- SHELL COMPLETION ---
+	var completionCmd = &cobra.Command{
+		Use:       "completion [bash|zsh|fish|powershell]",
+		Short:     "Generate the shell completion script",
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+		Run:       runCompletion,
+	}
+	rootCmd.AddCommand(completionCmd)
+
+	// Suggest local wallet addresses for the address flags
+	for _, c := range []struct {
+		cmd  *cobra.Command
+		flag string
+	}{
+		{walletRemoveCmd, "address"},
+		{walletBalanceCmd, "address"},
+		{walletWatchLiveCmd, "address"},
+		{walletExportCmd, "address"},
+		{chainSignBlockCmd, "address"},
+		{chainRevokeValidatorCmd, "address"},
+		{nodeStartCmd, "miner"},
+		{nodeStartCmd, "reward-address"},
+		{txSendCmd, "from"},
+		{txSendCmd, "to"},
+		{txSendCmd, "change"},
+		{txSizeCmd, "from"},
+		{txSizeCmd, "to"},
+		{txSizeCmd, "change"},
+	} {
+		c.cmd.RegisterFlagCompletionFunc(c.flag, completeWalletAddresses)
+	}
+}
+
+// runCompletion writes the completion script for the requested shell to stdout
+func runCompletion(cmd *cobra.Command, args []string) {
+	var err error
+	switch args[0] {
+	case "bash":
+		err = rootCmd.GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		err = rootCmd.GenZshCompletion(os.Stdout)
+	case "fish":
+		err = rootCmd.GenFishCompletion(os.Stdout, true)
+	case "powershell":
+		err = rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⛔ ERROR: Failed to generate %s completion: %v\n", args[0], err)
+		os.Exit(1)
+	}
+}
+
+// completeWalletAddresses suggests the addresses in wallet.dat, described so
+// zsh/fish/powershell show which key they use. A missing or unreadable wallet
+// file yields no suggestions. Flags taking a comma-separated list (--from,
+// --miner) complete the entry after the last comma and skip addresses already listed.
+func completeWalletAddresses(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	wallets, err := CreateWallets()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	done, partial := "", toComplete
+	listed := make(map[string]bool)
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		done, partial = toComplete[:i+1], toComplete[i+1:]
+		for _, addr := range strings.Split(toComplete[:i], ",") {
+			listed[strings.TrimSpace(addr)] = true
+		}
+	}
+
+	var suggestions []string
+	for _, address := range wallets.GetAddresses() {
+		if listed[address] || !strings.HasPrefix(address, partial) {
+			continue
+		}
+		description := "local wallet"
+		if len(wallets.Wallets[address].PublicKey) == 33 {
+			description = "local wallet, compressed key"
+		}
+		suggestions = append(suggestions, done+address+"\t"+description)
+	}
+	return suggestions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// isCompletionRequest reports whether the process is generating a completion
+// script or answering a completion query, whose stdout the shell consumes
+func isCompletionRequest() bool {
+	if len(os.Args) < 2 {
+		return false
+	}
+	switch os.Args[1] {
+	case "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+	return false
+}
+
+// splitList splits a comma-separated setting, dropping blanks and repeats
+func splitList(value string) []string {
+	var items []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" && !seen[item] {
+			seen[item] = true
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validateNodeConfig checks the node start settings that need no database,
+// network or wallet, and returns every problem found. node start stops on
+// the first one; --dry-run lists them all.
+func validateNodeConfig() []string {
+	var problems []string
+	problem := func(format string, a ...any) { problems = append(problems, fmt.Sprintf(format, a...)) }
+
+	pruneKeep := viper.GetInt("node.prune")
+	auditUTXO := viper.GetDuration("node.audit_utxo")
+	if viper.GetDuration("network.dial_timeout") <= 0 {
+		problem("--dial-timeout must be positive.")
+	}
+	if viper.GetDuration("node.shutdown_timeout") <= 0 {
+		problem("--shutdown-timeout must be positive.")
+	}
+	if viper.GetFloat64("network.max_msg_rate") < 0 {
+		problem("--max-msg-rate must be 0 (unlimited) or positive.")
+	}
+	if viper.GetDuration("node.mempool_expiry") < 0 {
+		problem("--mempool-expiry must be 0 (never) or positive.")
+	}
+	if pruneKeep != 0 && pruneKeep < MinPruneKeepBlocks {
+		problem("--prune must keep at least %d blocks (got %d).", MinPruneKeepBlocks, pruneKeep)
+	}
+	if auditUTXO < 0 || (auditUTXO > 0 && auditUTXO < MinUTXOAuditEvery) {
+		problem("--audit-utxo must be 0 (off) or at least %s.", MinUTXOAuditEvery)
+	} else if auditUTXO > 0 && pruneKeep > 0 {
+		problem("--audit-utxo needs every block body and can't run with --prune.")
+	}
+	if err := ValidateCoinbaseMessage(viper.GetString("node.coinbase_message")); err != nil {
+		problem("%v.", err)
+	}
+	if verifyChain := viper.GetString("node.verify_chain"); verifyChain != "" {
+		if _, err := parseVerifyChainSpec(verifyChain); err != nil {
+			problem("%v", err)
+		}
+	}
+	if rewardAddress := strings.TrimSpace(viper.GetString("node.reward_address")); rewardAddress != "" && !ValidateAddress(rewardAddress) {
+		problem("Invalid reward address %s.", rewardAddress)
+	}
+	if viper.GetBool("node.light") {
+		// Each needs the transactions or the UTXO set a light node doesn't keep
+		conflicts := []struct {
+			flag string
+			set  bool
+		}{
+			{"--miner", viper.GetString("node.miner") != ""},
+			{"--prune", pruneKeep > 0},
+			{"--audit-utxo", auditUTXO > 0},
+			{"--verify-chain", viper.GetString("node.verify_chain") != ""},
+		}
+		for _, c := range conflicts {
+			if c.set {
+				problem("--light can't run with %s.", c.flag)
+			}
+		}
+	}
+	return problems
 }
 
 func startNode(cmd *cobra.Command, args []string) {
+	if viper.GetBool("node.dry_run") {
+		if !dryRunNode() {
+			os.Exit(1)
+		}
+		return
+	}
+
 	nodePort := viper.GetInt("node.port")
-	nodeListen := viper.GetString("node.listen")
+	nodeListen := splitList(strings.Join(viper.GetStringSlice("node.listen"), ","))
+	nodeTransport := strings.ToLower(strings.TrimSpace(viper.GetString("node.transport")))
 	netPublicIP := viper.GetString("network.public_ip")
 	netPublicDNS := viper.GetString("network.public_dns")
 	netBootnodesStr := viper.GetString("network.bootnodes")
+	netMDNS := viper.GetBool("network.mdns")
+	netCompression := viper.GetBool("network.compression")
+	dialTimeout := viper.GetDuration("network.dial_timeout")
+	maxMsgRate := viper.GetFloat64("network.max_msg_rate")
 	nodeMiner := viper.GetString("node.miner")
+	rewardAddress := strings.TrimSpace(viper.GetString("node.reward_address"))
+	coinbaseMessage := viper.GetString("node.coinbase_message")
+	emptyBlocks := viper.GetBool("node.empty_blocks")
+	dustLimit := viper.GetInt64("node.dust_limit")
+	maxTxFee := int64(viper.GetFloat64("node.max_tx_fee") * 100000000)
+	maxMempoolBytes := viper.GetInt("node.max_mempool_size") * 1024 * 1024
+	mempoolExpiry := viper.GetDuration("node.mempool_expiry")
+	pruneKeep := viper.GetInt("node.prune")
 	apiPort := viper.GetInt("api.port")
 	apiListen := viper.GetString("api.listen")
+	apiDisabled := viper.GetBool("api.disabled")
+	verifyChain := viper.GetString("node.verify_chain")
+	shutdownTimeout := viper.GetDuration("node.shutdown_timeout")
+	auditUTXO := viper.GetDuration("node.audit_utxo")
+	light := viper.GetBool("node.light")
 
 	fmt.Printf("Starting SOLE node on port %d...\n", nodePort)
 
@@ -309,70 +911,130 @@ func startNode(cmd *cobra.Command, args []string) {
 		fmt.Println("⚠️  Database not found. Did you run './sole-cli chain init'?")
 		os.Exit(1)
 	}
+	// The validator checks below need the chain's rules
+	if err := applyStoredPreset(); err != nil {
+		fmt.Printf("⛔ ERROR: Failed to load the genesis preset: %v\n", err)
+		os.Exit(1)
+	}
+
+	if removed, err := CleanSnapshots(); err != nil {
+		fmt.Printf("⚠️  Snapshot cleanup: %v\n", err)
+	} else if len(removed) > 0 {
+		fmt.Printf("🧹 Removed %d leftover database snapshot(s)\n", len(removed))
+	}
+
+	if problems := validateNodeConfig(); len(problems) > 0 {
+		fmt.Printf("⛔ ERROR: %s\n", problems[0])
+		os.Exit(1)
+	}
+
+	// Before the node serves anything: a corrupted DB must not reach peers
+	if verifyChain != "" {
+		last, _ := parseVerifyChainSpec(verifyChain) // Checked above
+		chain := ContinueBlockchain("")
+		start := time.Now()
+		checked, failures := VerifyChain(chain, last)
+		chain.Database.Close()
+		if !printVerifyChainResult(checked, failures, time.Since(start)) {
+			fmt.Println("⛔ Startup aborted. Restore the database from a backup or resync it from peers.")
+			os.Exit(1)
+		}
+	}
 
-	var validatorPrivKey *ecdsa.PrivateKey
+	// One or more comma-separated validator addresses
+	minerAddrs := splitList(nodeMiner)
 
-	if nodeMiner != "" {
-		fmt.Printf("Forging enabled for address: %s\n", nodeMiner)
+	var validatorKeys []ValidatorKey
 
-		// Load wallet for this address
-		wallets, err := CreateWallets()
-		if err != nil {
-			if os.IsNotExist(err) {
-				fmt.Printf("⛔ ERROR: Private Key not found for address %s. Wallet file missing.\n", nodeMiner)
+	if len(minerAddrs) > 0 {
+		fmt.Printf("Forging enabled for %d address(es): %s\n", len(minerAddrs), strings.Join(minerAddrs, ", "))
+
+		// Load wallets holding the validator keys
+		wallets := loadWallets(false)
+
+		for _, minerAddr := range minerAddrs {
+			wallet := wallets.GetWalletRef(minerAddr)
+			if wallet == nil {
+				fmt.Printf("⛔ ERROR: Private Key not found for address %s. Cannot mine without owning the wallet.\n", minerAddr)
 				os.Exit(1)
 			}
-			log.Panic("Error loading wallets:", err)
-		}
 
-		wallet := wallets.GetWalletRef(nodeMiner)
-		if wallet == nil {
-			fmt.Printf("⛔ ERROR: Private Key not found for address %s. Cannot mine without owning the wallet.\n", nodeMiner)
-			os.Exit(1)
-		}
+			privKey, err := wallet.GetPrivateKey()
+			if err != nil {
+				fmt.Printf("⛔ ERROR: Private Key not valid for address %s: %v\n", minerAddr, err)
+				os.Exit(1)
+			}
 
-		privKey, err := wallet.GetPrivateKey()
-		if err != nil {
-			fmt.Printf("⛔ ERROR: Private Key not valid for address %s: %v\n", nodeMiner, err)
-			os.Exit(1)
-		}
-		validatorPrivKey = &privKey
+			// Print validator public key for registration
+			pubKeyHex := GetValidatorHex(*wallet)
+			fmt.Printf("Validator PubKey (%s): %s\n", minerAddr, pubKeyHex)
 
-		// Print validator public key for registration
-		pubKeyHex := GetValidatorHex(*wallet)
-		fmt.Printf("Validator PubKey: %s\n", pubKeyHex)
+			// Authorization Check
+			if !IsAuthorizedValidator(pubKeyHex) {
+				fmt.Printf("⛔ ERROR: Address %s is not an Authorized Validator. Mining aborted.\n", minerAddr)
+				os.Exit(1)
+			}
 
-		// Authorization Check
-		if !IsAuthorizedValidator(pubKeyHex) {
-			fmt.Printf("⛔ ERROR: Address %s is not an Authorized Validator. Mining aborted.\n", nodeMiner)
-			os.Exit(1)
+			validatorKeys = append(validatorKeys, ValidatorKey{
+				Address:   minerAddr,
+				PrivKey:   &privKey,
+				PubKeyHex: pubKeyHex,
+			})
 		}
 		fmt.Println("✅ Authorized Validator recognized. Starting Consensus Engine...")
+		if len(validatorKeys) < BlockQuorum {
+			fmt.Printf("⚠️  Blocks need %d validator signatures but only %d key(s) are loaded: this node won't forge.\n", BlockQuorum, len(validatorKeys))
+		}
+	}
+
+	// Rewards may go to a cold address; the validator keys above only sign
+	if rewardAddress != "" {
+		if len(validatorKeys) == 0 {
+			fmt.Println("⚠️  --reward-address is ignored because forging is disabled (no --miner).")
+		} else {
+			fmt.Printf("💰 Block rewards go to %s\n", rewardAddress)
+		}
 	}
 
-	// Parse bootnodes
-	var bootnodes []string
-	if netBootnodesStr != "" {
-		bootnodes = strings.Split(netBootnodesStr, ",")
+	if emptyBlocks {
+		if len(validatorKeys) == 0 {
+			fmt.Println("⚠️  --empty-blocks is ignored because forging is disabled (no --miner).")
+		} else {
+			fmt.Printf("⏱️  Forging empty blocks every %s while the mempool is empty\n", MiningInterval)
+		}
 	}
 
+	bootnodes := splitList(netBootnodesStr)
+
 	// Load Persistent P2P Identity
-	nodeKeyPath := "node_key.dat"
-	privKeyP2P, err := LoadOrGenerateNodeKey(nodeKeyPath)
+	privKeyP2P, err := LoadOrGenerateNodeKey(NodeKeyFile)
 	if err != nil {
 		log.Panic("Error loading node key:", err)
 	}
 
 	// Config
 	cfg := ServerConfig{
-		ListenHost: nodeListen,
-		Port:       nodePort,
-		PublicIP:   netPublicIP,
-		PublicDNS:  netPublicDNS,
-		Bootnodes:  bootnodes,
-		MinerAddr:  nodeMiner,
-		PrivKey:    validatorPrivKey,
-		NodeKey:    privKeyP2P,
+		ListenHosts:     nodeListen,
+		Transport:       nodeTransport,
+		Port:            nodePort,
+		PublicIP:        netPublicIP,
+		PublicDNS:       netPublicDNS,
+		Bootnodes:       bootnodes,
+		ValidatorKeys:   validatorKeys,
+		RewardAddress:   rewardAddress,
+		CoinbaseMessage: coinbaseMessage,
+		DustLimit:       dustLimit,
+		MaxTxFee:        maxTxFee,
+		MaxMempoolBytes: maxMempoolBytes,
+		MempoolExpiry:   mempoolExpiry,
+		PruneKeep:       pruneKeep,
+		EmptyBlocks:     emptyBlocks,
+		DisableMDNS:     !netMDNS,
+		NoCompression:   !netCompression,
+		DialTimeout:     dialTimeout,
+		MaxMsgRate:      maxMsgRate,
+		Light:           light,
+		NodeKey:         privKeyP2P,
 	}
 
 	// Initialize P2P Server
@@ -380,16 +1042,33 @@ func startNode(cmd *cobra.Command, args []string) {
 	// We handle DB closing manually on signal
 	// defer server.Blockchain.Database.Close()
 
-	// Start API Server
-	go StartRestServer(server, apiListen, apiPort)
+	apiEndpoint := "disabled"
+	if !apiDisabled {
+		apiEndpoint = APIEndpoint(apiListen, apiPort)
+	}
+	printNodeSummary(server, nodePort, apiEndpoint)
+
+	// Start API Server (not on relay nodes started with --no-api)
+	var apiServer *http.Server
+	if !apiDisabled {
+		apiServer = StartRestServer(server, apiListen, apiPort)
+	}
 
 	// Start P2P Loop (in background)
 	go server.Start()
+	if auditUTXO > 0 {
+		go server.UTXOAuditLoop(auditUTXO)
+	}
 
 	// Start Periodic Mining Loop (if miner)
-	if nodeMiner != "" {
-		go server.StartMiningLoop()
-	}
+	miningCtx, stopMining := context.WithCancel(context.Background())
+	miningDone := make(chan struct{})
+	go func() {
+		if len(validatorKeys) > 0 {
+			server.StartMiningLoop(miningCtx)
+		}
+		close(miningDone)
+	}()
 
 	// Graceful Shutdown Handling
 	stop := make(chan os.Signal, 1)
@@ -399,18 +1078,191 @@ func startNode(cmd *cobra.Command, args []string) {
 
 	fmt.Println("\n⚠️  Stop signal received. Shutting down...")
 
-	// 1. Close P2P Host (Network)
+	// A hung libp2p close or Badger flush must not need a SIGKILL
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		shutdownNode(ctx, server, apiServer, stopMining, miningDone)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		fmt.Println("✅ Node shut down correctly. See you soon!")
+	case <-ctx.Done():
+		fmt.Printf("⚠️  Shutdown did not finish within %s. Forcing exit.\n", shutdownTimeout)
+		os.Exit(1)
+	}
+}
+
+// shutdownNode stops the node in dependency order: API first (no new
+// requests), then mining (no new blocks), the P2P host and finally the DB.
+// ctx bounds the waits that support it.
+func shutdownNode(ctx context.Context, server *Server, apiServer *http.Server, stopMining context.CancelFunc, miningDone <-chan struct{}) {
+	// 1. Stop the API Server
+	if apiServer != nil {
+		if err := apiServer.Shutdown(ctx); err != nil {
+			fmt.Printf("Error closing API Server: %s\n", err)
+		}
+	}
+
+	// 2. Stop Mining, letting a block in progress be stored
+	stopMining()
+	select {
+	case <-miningDone:
+	case <-ctx.Done():
+		return
+	}
+
+	// 3. Stop the background loops and dial retries, then close the P2P Host (Network)
+	server.Stop()
 	if err := server.Host.Close(); err != nil {
 		fmt.Printf("Error closing P2P Host: %s\n", err)
 	}
 
-	// 2. Close Database (Persistence)
+	// 4. Close Database (Persistence)
 	// Important: This releases the LOCK file
 	if err := server.Blockchain.Database.Close(); err != nil {
 		fmt.Printf("Error closing Database: %s\n", err)
 	}
+}
+
+// printNodeSummary shows in one place how the node came up, once the
+// interleaved initialization logs are done
+func printNodeSummary(server *Server, nodePort int, apiEndpoint string) {
+	peerID := server.Host.ID().String()
+	rows := [][2]string{
+		{"Version", VersionString()},
+		{"Network", NetworkID},
+		{"PeerID", peerID},
+	}
+
+	addRows := func(label string, values []string) {
+		if len(values) == 0 {
+			values = []string{"-"}
+		}
+		for i, v := range values {
+			if i > 0 {
+				label = ""
+			}
+			rows = append(rows, [2]string{label, v})
+		}
+	}
+
+	var listen, announce []string
+	for _, addr := range server.Host.Network().ListenAddresses() {
+		if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+			continue // Relay placeholder, not a socket
+		}
+		listen = append(listen, addr.String())
+	}
+	for _, addr := range server.Host.Addrs() {
+		announce = append(announce, fmt.Sprintf("%s/p2p/%s", addr, peerID))
+	}
+	addRows("Listen", listen)
+	addRows("Announce", announce)
+	rows = append(rows, [2]string{"API", apiEndpoint})
+
+	if len(server.ValidatorKeys) == 0 {
+		rows = append(rows, [2]string{"Forging", "disabled"})
+	} else {
+		var validators []string
+		for _, key := range server.ValidatorKeys {
+			validators = append(validators, key.Address)
+		}
+		rows = append(rows, [2]string{"Forging", fmt.Sprintf("enabled (quorum %d)", BlockQuorum)})
+		addRows("Validator", validators)
+		if server.RewardAddress != "" {
+			rows = append(rows, [2]string{"Rewards to", server.RewardAddress})
+		}
+	}
+
+	tip := hex.EncodeToString(server.Blockchain.LastHash)
+	if len(tip) > 16 {
+		tip = tip[:16] + "..."
+	}
+	rows = append(rows, [2]string{"Tip", fmt.Sprintf("height %d (%s)", server.Blockchain.GetBestHeight(), tip)})
+	rows = append(rows, [2]string{"Peers", strconv.Itoa(len(server.Host.Network().Peers()))})
+
+	dataDir := dbPath
+	if abs, err := filepath.Abs(dbPath); err == nil {
+		dataDir = abs
+	}
+	if server.PruneKeep > 0 {
+		dataDir += fmt.Sprintf(" (pruned, last %d blocks)", server.PruneKeep)
+	}
+	if server.Light {
+		dataDir += " (light, headers only)"
+	}
+	rows = append(rows, [2]string{"Data dir", dataDir})
+
+	fmt.Println()
+	PrintSummaryBox(fmt.Sprintf("☀️  SOLE node on port %d", nodePort), rows)
+	fmt.Println()
+}
+
+func runRotateNodeKey(cmd *cobra.Command, args []string) {
+	if _, err := os.Stat(NodeKeyFile); err != nil {
+		fmt.Printf("⚠️  No %s found. A new identity is generated on the next 'node start'.\n", NodeKeyFile)
+		return
+	}
+
+	fmt.Print("⚠️  Rotate the P2P identity? Peers and bootnode lists that pin the current PeerID must be updated. Stop the node first. [y/N]: ")
+	var response string
+	fmt.Scanln(&response)
+
+	if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
+		fmt.Println("Operation cancelled.")
+		return
+	}
+
+	oldID, newID, backupFile, err := RotateNodeKey(NodeKeyFile)
+	if err != nil {
+		fmt.Printf("⛔ ERROR: Key rotation failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("✅ P2P identity rotated.")
+	fmt.Printf("   Old PeerID: %s (key saved to %s)\n", oldID, backupFile)
+	fmt.Printf("   New PeerID: %s\n", newID)
+	fmt.Println("   Restart the node, then replace the old PeerID in any bootnode lists (/ip4/<IP>/tcp/<PORT>/p2p/<PeerID>).")
+}
+
+func runServeAPI(cmd *cobra.Command, args []string) {
+	// Flags are not bound to viper here (node start owns the bindings),
+	// so explicit flags override config.yaml manually.
+	apiPort := viper.GetInt("api.port")
+	if cmd.Flags().Changed("api-port") || apiPort == 0 {
+		apiPort, _ = cmd.Flags().GetInt("api-port")
+	}
+	apiListen := viper.GetString("api.listen")
+	if cmd.Flags().Changed("api-listen") || apiListen == "" {
+		apiListen, _ = cmd.Flags().GetString("api-listen")
+	}
+
+	chain := ContinueBlockchainReadOnly("")
+	server := NewAPIOnlyServer(chain)
 
-	fmt.Println("✅ Node shut down correctly. See you soon!")
+	fmt.Println("📖 Read-only API mode: P2P and mining are disabled.")
+	apiServer := StartRestServer(server, apiListen, apiPort)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	fmt.Println("\n⚠️  Stop signal received. Shutting down...")
+	// Also removes a unix: socket file
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+	if err := apiServer.Shutdown(ctx); err != nil {
+		fmt.Printf("Error closing API Server: %s\n", err)
+	}
+	if err := chain.Database.Close(); err != nil {
+		fmt.Printf("Error closing Database: %s\n", err)
+	}
+	fmt.Println("✅ API server shut down correctly.")
 }
 
 func runInit(cmd *cobra.Command, args []string) {
@@ -419,6 +1271,25 @@ func runInit(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	var preset *GenesisPreset
+	if presetFlag != "" {
+		var err error
+		if preset, err = FindGenesisPreset(presetFlag); err != nil {
+			fmt.Printf("⛔ ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		// Parsed by initConfig; the empty list config.example.yaml ships is fine
+		if len(GenesisAllocations) > 0 {
+			fmt.Println("⛔ ERROR: --preset brings its own allocations: empty genesis.allocations in the config.")
+			os.Exit(1)
+		}
+		if err := preset.Validate(); err != nil {
+			fmt.Printf("⛔ ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		preset.Apply()
+	}
+
 	chain, err := InitBlockchain()
 	if err != nil {
 		fmt.Printf("⚠️  Error initializing blockchain: %s\n", err)
@@ -428,19 +1299,82 @@ func runInit(cmd *cobra.Command, args []string) {
 
 	// Auto-Reindex UTXO Set
 	UTXOSet := UTXOSet{chain}
-	UTXOSet.Reindex()
+	if err := UTXOSet.Reindex(); err != nil {
+		fmt.Printf("⛔ ERROR: Failed to build the UTXO set: %v\n", err)
+		os.Exit(1)
+	}
 
 	fmt.Println("\n☀️  SOLE Blockchain Initialized!")
 	fmt.Printf("- Genesis Hash: %x\n", chain.LastHash)
-	fmt.Println("- Network: Unisalento Mainnet")
+	if preset != nil {
+		fmt.Printf("- Network: %s (preset %s), %d validator(s), quorum %d\n", NetworkID, preset.Name, len(AuthorizedValidators), BlockQuorum)
+		fmt.Println("  Every node joining this network must run 'chain init --preset " + preset.Name + "'.")
+	} else if len(GenesisAllocations) > 0 {
+		fmt.Printf("- Network: Custom genesis, premine split across %d addresses\n", len(GenesisAllocations))
+		fmt.Println("  Every node joining this network needs the same genesis.allocations list.")
+	} else {
+		fmt.Println("- Network: Unisalento Mainnet")
+	}
 	fmt.Println("- UTXO Set: Reindexed automatically.")
 	fmt.Println("- Run 'wallet create' or 'node start'.")
 }
 
+// runListPresets prints the genesis presets built into this binary
+func runListPresets(cmd *cobra.Command, args []string) {
+	presets, err := GenesisPresets()
+	if err != nil {
+		fmt.Printf("⛔ ERROR: Failed to read the presets: %v\n", err)
+		os.Exit(1)
+	}
+
+	if jsonFlag {
+		type allocationJSON struct {
+			Address string `json:"address"`
+			Amount  int64  `json:"amount"` // Photons
+		}
+		type presetJSON struct {
+			Name        string           `json:"name"`
+			Description string           `json:"description"`
+			NetworkID   string           `json:"network_id"`
+			Validators  []string         `json:"validators"`
+			Quorum      int              `json:"quorum"`
+			BlockReward int64            `json:"block_reward"`
+			Allocations []allocationJSON `json:"allocations"`
+		}
+		var out []presetJSON
+		for _, p := range presets {
+			entry := presetJSON{p.Name, p.Description, p.NetworkID, p.ValidatorSet(), p.Quorum, p.BlockReward, nil}
+			for _, a := range p.Allocations {
+				entry.Allocations = append(entry.Allocations, allocationJSON{a.Address, a.Amount})
+			}
+			out = append(out, entry)
+		}
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tNETWORK\tVALIDATORS\tQUORUM\tREWARD\tPREMINE\tDESCRIPTION")
+	for _, p := range presets {
+		var premine int64
+		for _, a := range p.Allocations {
+			premine += a.Amount
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.8f\t%.8f\t%s\n", p.Name, p.NetworkID, len(p.ValidatorSet()), p.Quorum,
+			float64(p.BlockReward)/100000000, float64(premine)/100000000, p.Description)
+	}
+	w.Flush()
+	fmt.Println("\nℹ️  Start one with 'chain init --preset <NAME>'.")
+}
+
 func createWallet(cmd *cobra.Command, args []string) {
-	wallets, _ := CreateWallets()
-	address, mnemonic := wallets.AddWallet()
-	wallets.SaveToFile()
+	wallets := loadWallets(true)
+	address, mnemonic := wallets.AddWallet(compressedFlag)
+	if err := wallets.SaveToFile(); err != nil {
+		fmt.Printf("⛔ ERROR: Failed to save %s: %v\n", walletFile, err)
+		os.Exit(1)
+	}
 
 	fmt.Println(ColorRed + "⚠️  IMPORTANT: Write down these 12 words." + ColorReset)
 	fmt.Println(ColorYellow + "If you lose them, you lose your SOLE forever." + ColorReset)
@@ -451,13 +1385,16 @@ func createWallet(cmd *cobra.Command, args []string) {
 }
 
 func runImportWallet(cmd *cobra.Command, args []string) {
-	wallets, _ := CreateWallets()
-	address, err := wallets.ImportWallet(privKeyFlag)
+	wallets := loadWallets(true)
+	address, err := wallets.ImportWallet(privKeyFlag, compressedFlag)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	wallets.SaveToFile()
+	if err := wallets.SaveToFile(); err != nil {
+		fmt.Printf("⛔ ERROR: Failed to save %s: %v\n", walletFile, err)
+		os.Exit(1)
+	}
 
 	fmt.Printf("Success! Wallet imported. Address: %s\n", address)
 }
@@ -471,29 +1408,132 @@ func runRecoverWallet(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	wallets, _ := CreateWallets()
+	wallets := loadWallets(true)
 
-	address, err := wallets.RecoverWallet(mnemonic)
+	address, err := wallets.RecoverWallet(mnemonic, compressedFlag)
 	if err != nil {
 		fmt.Println(ColorRed + "❌ Error: " + err.Error() + ColorReset)
 		os.Exit(1)
 	}
 
-	wallets.SaveToFile()
+	if err := wallets.SaveToFile(); err != nil {
+		fmt.Printf("⛔ ERROR: Failed to save %s: %v\n", walletFile, err)
+		os.Exit(1)
+	}
 	fmt.Printf("✅ Success! Wallet recovered. Address: %s\n", address)
 }
 
-func runRemoveWallet(cmd *cobra.Command, args []string) {
-	if !ValidateAddress(addressFlag) {
-		fmt.Println("⛔ ERROR: Invalid address provided.")
-		os.Exit(1)
+// backupPassphraseEnv names the environment variable read for a wallet
+// backup passphrase when --passphrase-file isn't given
+const backupPassphraseEnv = "SOLE_BACKUP_PASSPHRASE"
+
+// readBackupPassphrase returns the wallet backup passphrase from
+// --passphrase-file, then SOLE_BACKUP_PASSPHRASE, then a prompt that doesn't
+// echo (asked twice when confirm is set). It is never a command-line value,
+// which would be left in the shell history and shown by ps. Without a
+// terminal to prompt on, no passphrase is "".
+func readBackupPassphrase(prompt string, confirm bool) (string, error) {
+	if passFileFlag != "" {
+		data, err := os.ReadFile(passFileFlag)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if passphrase, ok := os.LookupEnv(backupPassphraseEnv); ok {
+		return passphrase, nil
 	}
 
-	wallets, err := CreateWallets()
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", nil
+	}
+	fmt.Print(prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Println()
 	if err != nil {
-		log.Panic(err)
+		return "", err
+	}
+	if confirm && len(passphrase) > 0 {
+		fmt.Print("Repeat it: ")
+		repeated, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(passphrase, repeated) {
+			return "", errors.New("the passphrases don't match")
+		}
+	}
+	return string(passphrase), nil
+}
+
+func runExportAllWallets(cmd *cobra.Command, args []string) {
+	wallets := loadWallets(false)
+
+	passphrase, err := readBackupPassphrase("Passphrase to encrypt the backup (empty for none): ", true)
+	if err != nil {
+		fmt.Printf("⛔ ERROR: Failed to read the passphrase: %v\n", err)
+		os.Exit(1)
+	}
+	if err := wallets.ExportBackup(pathFlag, passphrase); err != nil {
+		fmt.Printf("⛔ ERROR: Failed to write backup: %v\n", err)
+		os.Exit(1)
 	}
 
+	fmt.Printf("✅ %d wallet(s) written to %s\n", len(wallets.Wallets), pathFlag)
+	if passphrase == "" {
+		fmt.Printf("⚠️  The backup is not encrypted: it holds your private keys in the clear. Enter a passphrase at the prompt, or use --passphrase-file or %s, to encrypt it.\n", backupPassphraseEnv)
+	}
+}
+
+func runImportWalletFile(cmd *cobra.Command, args []string) {
+	backup, err := ReadWalletBackup(pathFlag, "")
+	if errors.Is(err, ErrBackupEncrypted) {
+		var passphrase string
+		passphrase, err = readBackupPassphrase("Backup passphrase: ", false)
+		if err == nil {
+			backup, err = ReadWalletBackup(pathFlag, passphrase)
+		}
+	}
+	if err != nil {
+		fmt.Printf("⛔ ERROR: Failed to read %s: %v\n", pathFlag, err)
+		os.Exit(1)
+	}
+
+	wallets := loadWallets(true)
+	result := wallets.Merge(backup)
+
+	if len(result.Added) > 0 {
+		if err := wallets.SaveToFile(); err != nil {
+			fmt.Printf("⛔ ERROR: Failed to save %s: %v\n", walletFile, err)
+			os.Exit(1)
+		}
+	}
+
+	for _, address := range result.Added {
+		fmt.Printf("   ✅ %s\n", address)
+	}
+	for _, address := range result.Skipped {
+		fmt.Printf("   ℹ️  %s (already in %s)\n", address, walletFile)
+	}
+	for _, address := range result.Invalid {
+		fmt.Printf("   ⛔ %s (key doesn't match the address, not imported)\n", address)
+	}
+	fmt.Printf("Imported %d wallet(s), %d already present, %d invalid.\n", len(result.Added), len(result.Skipped), len(result.Invalid))
+	if len(result.Invalid) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runRemoveWallet(cmd *cobra.Command, args []string) {
+	if !ValidateAddress(addressFlag) {
+		fmt.Println("⛔ ERROR: Invalid address provided.")
+		os.Exit(1)
+	}
+
+	wallets := loadWallets(false)
+
 	if wallets.GetWalletRef(addressFlag) == nil {
 		fmt.Println("❌ Error: Address not found in wallet file.")
 		os.Exit(1)
@@ -508,13 +1548,16 @@ func runRemoveWallet(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	err = wallets.RemoveWallet(addressFlag)
+	err := wallets.RemoveWallet(addressFlag)
 	if err != nil {
 		fmt.Println("❌ Error:", err)
 		os.Exit(1)
 	}
 
-	wallets.SaveToFile()
+	if err := wallets.SaveToFile(); err != nil {
+		fmt.Printf("⛔ ERROR: Failed to save %s: %v\n", walletFile, err)
+		os.Exit(1)
+	}
 
 	fmt.Printf("✅ Wallet %s removed successfully.\n", addressFlag)
 }
@@ -530,7 +1573,7 @@ func getBalance(cmd *cobra.Command, args []string) {
 		apiPort = 8080
 	}
 
-	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/balance/%s", apiPort, addressFlag))
+	resp, err := apiGet(cmd.Context(), fmt.Sprintf("http://localhost:%d/balance/%s", apiPort, addressFlag))
 	if err != nil {
 		fmt.Printf("⛔ ERROR: Failed to connect to API: %v\n", err)
 		os.Exit(1)
@@ -547,11 +1590,31 @@ func getBalance(cmd *cobra.Command, args []string) {
 		balResp.Address, balResp.Balance, float64(balResp.Balance)/100000000.0)
 }
 
-func send(cmd *cobra.Command, args []string) {
-	if !ValidateAddress(fromFlag) {
+// sendPlan is a signed, not yet broadcast, tx send transaction
+type sendPlan struct {
+	tx         Transaction
+	amount     int64 // Photons to the recipient
+	fee        int64 // Requested fee (--fee); tx.Fee also holds dust change
+	change     int64
+	changeAddr string
+	dustLimit  int64 // The node's --dust-limit: smaller change is left to the fee
+	apiPort    int
+}
+
+// buildSend validates the tx send flags, selects UTXOs through the local
+// node's API and signs the transaction. Shared by tx send and tx size.
+func buildSend(cmd *cobra.Command) sendPlan {
+	fromAddrs := parseAddressList(fromFlag)
+	if len(fromAddrs) == 0 {
 		fmt.Println("⛔ ERROR: Invalid sender address.")
 		os.Exit(1)
 	}
+	for _, addr := range fromAddrs {
+		if !ValidateAddress(addr) {
+			fmt.Printf("⛔ ERROR: Invalid sender address %s.\n", addr)
+			os.Exit(1)
+		}
+	}
 	if !ValidateAddress(toFlag) {
 		fmt.Println("⛔ ERROR: Invalid recipient address.")
 		os.Exit(1)
@@ -560,26 +1623,38 @@ func send(cmd *cobra.Command, args []string) {
 		fmt.Println("⛔ ERROR: Amount must be greater than zero.")
 		os.Exit(1)
 	}
+	changeAddr := fromAddrs[0]
+	if changeFlag != "" {
+		if !ValidateAddress(changeFlag) {
+			fmt.Println("⛔ ERROR: Invalid change address.")
+			os.Exit(1)
+		}
+		changeAddr = changeFlag
+	}
 
 	amountInt := int64(amountFlag * 100000000)
 	feeInt := int64(feeFlag * 100000000)
 	totalRequired := amountInt + feeInt
 
-	fmt.Printf("💸 Sending: %.8f SOLE (%d Photons) | Fee: %.8f SOLE (%d Photons)\n", amountFlag, amountInt, feeFlag, feeInt)
+	// Every source must be ours: each input is signed with its own wallet's key
+	wallets := loadWallets(false)
+	keys := make(map[string]ecdsa.PrivateKey) // Hex public key -> private key
+	for _, addr := range fromAddrs {
+		wallet := wallets.GetWalletRef(addr)
+		if wallet == nil {
+			fmt.Printf("⛔ ERRORE: Wallet non trovato per l'indirizzo mittente %s.\n", addr)
+			os.Exit(1)
+		}
 
-	wallets, err := CreateWallets()
-	if err != nil {
-		log.Panic(err)
-	}
-	wallet := wallets.GetWalletRef(fromFlag)
-	if wallet == nil {
-		fmt.Printf("⛔ ERRORE: Wallet non trovato per l'indirizzo mittente %s.\n", fromFlag)
-		os.Exit(1)
+		privKey, err := wallet.GetPrivateKey()
+		if err != nil {
+			fmt.Printf("⛔ ERROR: Failed to get private key for %s: %v\n", addr, err)
+			os.Exit(1)
+		}
+		keys[hex.EncodeToString(wallet.PublicKey)] = privKey
 	}
-
-	privKey, err := wallet.GetPrivateKey()
-	if err != nil {
-		fmt.Printf("⛔ ERROR: Failed to get private key for %s: %v\n", fromFlag, err)
+	if wallets.GetWalletRef(changeAddr) == nil {
+		fmt.Printf("⛔ ERROR: Change address %s is not in your local wallet.\n", changeAddr)
 		os.Exit(1)
 	}
 
@@ -588,52 +1663,57 @@ func send(cmd *cobra.Command, args []string) {
 		apiPort = 8080
 	}
 
-	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/utxos/%s", apiPort, fromFlag))
-	if err != nil {
-		fmt.Printf("⛔ ERROR: Failed to fetch UTXOs. Is the node running? %v\n", err)
-		os.Exit(1)
-	}
-	defer resp.Body.Close()
-
-	var utxos []UTXOResponse
-	if err := json.NewDecoder(resp.Body).Decode(&utxos); err != nil {
-		fmt.Printf("⛔ ERROR: Failed to parse API UTXO response: %v\n", err)
-		os.Exit(1)
-	}
+	dustLimit := nodeDustLimit(cmd.Context(), apiPort)
 
 	var inputs []TxInput
 	accumulated := int64(0)
-	prevTXs := make(map[string]Transaction)
+	spent := make(map[string]TxOutput) // OutpointKey -> output being spent
+
+	// Gather UTXOs address by address, in --from order, until the amount is covered
+Gather:
+	for _, addr := range fromAddrs {
+		wallet := wallets.GetWalletRef(addr)
+
+		// A page at a time: large wallets have more UTXOs than one response
+		// holds. Each page resumes after the last output of the previous one,
+		// so a mempool change between pages can't skip or repeat outputs.
+		for after := ""; ; {
+			resp, err := apiGet(cmd.Context(), fmt.Sprintf("http://localhost:%d/utxos/%s?limit=%d&after=%s", apiPort, addr, maxUTXOPageSize, after))
+			if err != nil {
+				fmt.Printf("⛔ ERROR: Failed to fetch UTXOs. Is the node running? %v\n", err)
+				os.Exit(1)
+			}
 
-	for _, utxo := range utxos {
-		accumulated += utxo.Amount
+			var page UTXOPageResponse
+			err = json.NewDecoder(resp.Body).Decode(&page)
+			resp.Body.Close()
+			if err != nil {
+				fmt.Printf("⛔ ERROR: Failed to parse API UTXO response: %v\n", err)
+				os.Exit(1)
+			}
+
+			for _, utxo := range page.UTXOs {
+				accumulated += utxo.Amount
 
-		txIDBytes, _ := hex.DecodeString(utxo.TxID)
-		inputs = append(inputs, TxInput{txIDBytes, utxo.Vout, nil, wallet.PublicKey})
+				txIDBytes, _ := hex.DecodeString(utxo.TxID)
+				inputs = append(inputs, TxInput{txIDBytes, utxo.Vout, nil, wallet.PublicKey})
 
-		if prevTXs[utxo.TxID].ID == nil {
-			rawResp, rawErr := http.Get(fmt.Sprintf("http://localhost:%d/rawtx/%s", apiPort, utxo.TxID))
-			if rawErr == nil {
-				var rawData RawTxResponse
-				json.NewDecoder(rawResp.Body).Decode(&rawData)
-				rawResp.Body.Close()
+				// The node lists outputs locked to addr: that's all signing needs
+				spent[OutpointKey(txIDBytes, utxo.Vout)] = *NewTxOutput(utxo.Amount, addr)
 
-				if rawData.Hex != "" {
-					txBytes, _ := hex.DecodeString(rawData.Hex)
-					prevTx := DeserializeTransaction(txBytes)
-					prevTXs[utxo.TxID] = prevTx
+				if accumulated >= totalRequired {
+					break Gather
 				}
 			}
-		}
-
-		if accumulated >= totalRequired {
-			break
+			if page.Next == "" {
+				break
+			}
+			after = page.Next
 		}
 	}
 
 	if accumulated < totalRequired {
-		fmt.Printf("⛔ ERRORE: Fondi insufficienti. Disponibili: %d, Richiesti: %d\n", accumulated, totalRequired)
-		os.Exit(1)
+		exitTxError(fmt.Errorf("%w: available %d, required %d", ErrInsufficientFunds, accumulated, totalRequired))
 	}
 
 	var outputs []TxOutput
@@ -645,16 +1725,77 @@ func send(cmd *cobra.Command, args []string) {
 		outputs = append(outputs, TxOutput{0, []byte(memo)})
 	}
 	outputs = append(outputs, *NewTxOutput(amountInt, toFlag))
-	if accumulated > totalRequired {
-		outputs = append(outputs, *NewTxOutput(accumulated-totalRequired, fromFlag))
+	change := accumulated - totalRequired
+	if change > 0 && change >= dustLimit {
+		outputs = append(outputs, *NewTxOutput(change, changeAddr))
+	} else if change > 0 {
+		fmt.Printf("ℹ️  Change of %d Photons is below the node's dust limit (%d) and is added to the fee.\n", change, dustLimit)
+	}
+
+	if lockHeightFlag < 0 {
+		fmt.Println("⛔ ERROR: --lock-height can't be negative.")
+		os.Exit(1)
+	}
+
+	paidFee := feeInt
+	if change < dustLimit {
+		paidFee += change // Dust change goes to the validator
 	}
 
-	tx := Transaction{nil, inputs, outputs, time.Now().Unix()}
+	tx := Transaction{nil, inputs, outputs, time.Now().Unix(), lockHeightFlag, paidFee}
 	tx.ID = tx.Hash()
 
-	tx.Sign(privKey, prevTXs)
+	if err := tx.SignWithKeys(keys, spent); err != nil {
+		fmt.Printf("⛔ ERROR: Failed to sign transaction: %v\n", err)
+		os.Exit(1)
+	}
+
+	return sendPlan{
+		tx:         tx,
+		amount:     amountInt,
+		fee:        feeInt,
+		change:     change,
+		changeAddr: changeAddr,
+		dustLimit:  dustLimit,
+		apiPort:    apiPort,
+	}
+}
+
+// nodeDustLimit asks the local node for the --dust-limit it relays with.
+// Nodes that don't report one use DefaultDustLimit.
+func nodeDustLimit(ctx context.Context, apiPort int) int64 {
+	resp, err := apiGet(ctx, fmt.Sprintf("http://localhost:%d/node/info", apiPort))
+	if err != nil {
+		return DefaultDustLimit
+	}
+	defer resp.Body.Close()
+
+	var info struct {
+		DustLimit *int64 `json:"dust_limit"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&info) != nil || info.DustLimit == nil {
+		return DefaultDustLimit
+	}
+	return *info.DustLimit
+}
+
+func send(cmd *cobra.Command, args []string) {
+	plan := buildSend(cmd)
+	tx := plan.tx
+	fmt.Printf("💸 Sending: %.8f SOLE (%d Photons) | Fee: %.8f SOLE (%d Photons)\n", amountFlag, plan.amount, feeFlag, plan.fee)
 
 	if dryRunFlag {
+		fmt.Printf("Dry-Run: Transaction ID: %x\n", tx.ID)
+		fmt.Printf("   To:     %s  %.8f SOLE\n", toFlag, float64(plan.amount)/100000000.0)
+		if plan.change > 0 && plan.change >= plan.dustLimit {
+			fmt.Printf("   Change: %s  %.8f SOLE\n", plan.changeAddr, float64(plan.change)/100000000.0)
+		} else {
+			fmt.Println("   Change: none")
+		}
+		fmt.Printf("   Fee:    %.8f SOLE\n", float64(tx.Fee)/100000000.0)
+		if tx.LockHeight > 0 {
+			fmt.Printf("   Locked until height %d\n", tx.LockHeight)
+		}
 		fmt.Printf("Dry-Run: Transaction Hex:\n%x\n", tx.Serialize())
 		return
 	}
@@ -662,13 +1803,14 @@ func send(cmd *cobra.Command, args []string) {
 	fmt.Println("Broadcasting transaction via API...")
 
 	txSendReq := TxSendRequest{
-		Hex:  hex.EncodeToString(tx.Serialize()),
-		Fee:  float64(feeInt) / 100000000.0,
-		Memo: memoFlag,
+		Hex:          hex.EncodeToString(tx.Serialize()),
+		Fee:          float64(plan.fee) / 100000000.0,
+		Memo:         memoFlag,
+		AllowHighFee: highFeeFlag,
 	}
 
 	reqBody, _ := json.Marshal(txSendReq)
-	postResp, err := http.Post(fmt.Sprintf("http://localhost:%d/tx/send", apiPort), "application/json", bytes.NewBuffer(reqBody))
+	postResp, err := apiPost(cmd.Context(), fmt.Sprintf("http://localhost:%d/tx/send", plan.apiPort), bytes.NewBuffer(reqBody))
 	if err != nil {
 		fmt.Printf("⛔ ERROR: Failed to broadcast tx: %v\n", err)
 		os.Exit(1)
@@ -681,11 +1823,151 @@ func send(cmd *cobra.Command, args []string) {
 
 	if apiResult.Status == "success" {
 		fmt.Println("✅ Transaction sent successfully! ID:", apiResult.TxID)
+	} else if apiResult.Status == "waiting" {
+		fmt.Printf("⏳ Transaction accepted, it enters the mempool at height %d. ID: %s\n", tx.LockHeight, apiResult.TxID)
 	} else {
 		var apiError ErrorResponse
 		json.Unmarshal(bodyBytes, &apiError)
 		fmt.Println("⛔ ERROR:", apiError.Error)
+		os.Exit(TxErrorExitCode(TxErrorFromCode(apiError.Code)))
+	}
+}
+
+// runTxSize builds and signs the transaction tx send would create, then
+// prices its exact size at the node's current fee rate (GET /fee/estimate)
+func runTxSize(cmd *cobra.Command, args []string) {
+	plan := buildSend(cmd)
+	size := len(plan.tx.Serialize())
+
+	resp, err := apiGet(cmd.Context(), fmt.Sprintf("http://localhost:%d/fee/estimate", plan.apiPort))
+	if err != nil {
+		fmt.Printf("⛔ ERROR: Failed to fetch the fee estimate: %v\n", err)
+		os.Exit(1)
+	}
+	var estimate FeeEstimateResponse
+	err = json.NewDecoder(resp.Body).Decode(&estimate)
+	resp.Body.Close()
+	if err != nil {
+		fmt.Printf("⛔ ERROR: Failed to parse API fee estimate: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Same rule as the endpoint: the median, but always above the eviction floor
+	rate := estimate.MedianFeeRate
+	if rate <= estimate.MinFeeRate {
+		rate = estimate.MinFeeRate + 1
+	}
+	suggested := int64(math.Ceil(rate * float64(size)))
+
+	fmt.Printf("Transaction size: %d bytes (%d input(s), %d output(s))\n", size, len(plan.tx.Vin), len(plan.tx.Vout))
+	fmt.Printf("   Fee rate now:  %.2f Photons/byte (mempool floor %.2f)\n", rate, estimate.MinFeeRate)
+	fmt.Printf("   Suggested fee: %.8f SOLE (%d Photons)\n", float64(suggested)/100000000.0, suggested)
+	fmt.Printf("   Your fee:      %.8f SOLE (%.2f Photons/byte)\n", float64(plan.tx.Fee)/100000000.0, float64(plan.tx.Fee)/float64(size))
+	if plan.tx.Fee < suggested {
+		fmt.Printf("⚠️  Below the suggested fee: the transaction may wait or be evicted when the mempool is busy.\n")
+	}
+	fmt.Println("ℹ️  Nothing was broadcast. Inputs were selected for the given --amount and --fee, so a much larger fee can change the size.")
+}
+
+func runRebroadcast(cmd *cobra.Command, args []string) {
+	apiPort := viper.GetInt("api.port")
+	if apiPort == 0 {
+		apiPort = 8080
+	}
+
+	resp, err := apiPost(cmd.Context(), fmt.Sprintf("http://localhost:%d/tx/%s/rebroadcast", apiPort, url.PathEscape(txIDFlag)), nil)
+	if err != nil {
+		fmt.Printf("⛔ ERROR: Failed to connect to API: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiError ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&apiError)
+		fmt.Println("⛔ ERROR:", apiError.Error)
+		os.Exit(1)
+	}
+
+	var result RebroadcastResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Printf("⛔ ERROR: Failed to parse API response: %v\n", err)
+		os.Exit(1)
+	}
+	if result.Peers == 0 {
+		fmt.Println("⚠️  The node has no peers right now: nothing was sent.")
+		return
+	}
+	fmt.Printf("📣 Transaction %s announced to %d peer(s).\n", result.TxID, result.Peers)
+}
+
+// parseAddressList splits a comma-separated --from value, dropping blanks and duplicates
+func parseAddressList(value string) []string {
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, addr := range strings.Split(value, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		addrs = append(addrs, addr)
 	}
+	return addrs
+}
+
+// apiGet issues a GET to the local node, bounded by the command context (--timeout)
+func apiGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	return resp, commandTimeoutErr(ctx, err)
+}
+
+// apiPost issues a JSON POST to the local node, bounded by the command context (--timeout)
+func apiPost(ctx context.Context, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	return resp, commandTimeoutErr(ctx, err)
+}
+
+// loadWallets opens wallet.dat for a CLI command. With allowMissing, a missing
+// file yields an empty set (commands that create the file); corruption always
+// exits. Loading never rewrites the file: a file in another format than
+// --wallet-format is converted by the next command that saves it.
+func loadWallets(allowMissing bool) *Wallets {
+	wallets, err := CreateWallets()
+	if err == nil || (allowMissing && os.IsNotExist(err)) {
+		return wallets
+	}
+	exitWalletError(err)
+	return nil
+}
+
+// exitWalletError prints a recovery hint for wallet.dat load failures and exits
+func exitWalletError(err error) {
+	switch {
+	case os.IsNotExist(err):
+		fmt.Printf("⛔ ERROR: %s not found. Create a wallet with './sole-cli wallet create' or restore one with 'wallet recover'.\n", walletFile)
+	case errors.Is(err, ErrWalletCorrupt):
+		fmt.Printf("⛔ ERROR: %s is corrupt and cannot be read (%v).\n", walletFile, err)
+		fmt.Printf("   Restore the previous copy (mv %s %s), or move it aside and use 'wallet recover' with your 12 words.\n", walletBackupFile, walletFile)
+	default:
+		fmt.Printf("⛔ ERROR: Failed to load %s: %v\n", walletFile, err)
+	}
+	os.Exit(1)
+}
+
+// exitTxError prints a transaction pipeline error and exits with its mapped code
+func exitTxError(err error) {
+	fmt.Println("⛔ ERROR:", err)
+	os.Exit(TxErrorExitCode(err))
 }
 
 func printChain(cmd *cobra.Command, args []string) {
@@ -709,21 +1991,514 @@ func printChain(cmd *cobra.Command, args []string) {
 		}
 		fmt.Println()
 
-		if len(block.PrevBlockHash) == 0 {
+		if block.IsGenesis() {
 			break
 		}
 	}
 }
 
+// BlockInspection is the verification report printed by 'chain block'
+type BlockInspection struct {
+	JSONBlock
+	ValidatorAddress  string   `json:"validator_address"`
+	HashValid         bool     `json:"hash_valid"`
+	SignatureValid    bool     `json:"signature_valid"`
+	HeaderValid       bool     `json:"header_valid"`
+	TransactionsValid bool     `json:"transactions_valid"`
+	Valid             bool     `json:"valid"`
+	Errors            []string `json:"errors,omitempty"`
+}
+
+// InspectBlock runs the same checks AddBlock applies (header, PoA signature,
+// transaction signatures) against a block that is already stored, and
+// recomputes its hash.
+func InspectBlock(chain *Blockchain, block *Block) BlockInspection {
+	var parent *Block
+	if len(block.PrevBlockHash) > 0 {
+		if prevBlock, err := chain.LoadBlock(block.PrevBlockHash); err == nil {
+			parent = prevBlock
+		}
+	}
+
+	report := checkStoredBlock(chain, block, parent)
+	report.JSONBlock = ToJSONBlock(block, chain)
+	return report
+}
+
+// checkStoredBlock is InspectBlock without the JSON rendering. parent is nil
+// if it could not be loaded (ignored for genesis).
+func checkStoredBlock(chain *Blockchain, block *Block, parent *Block) BlockInspection {
+	report := BlockInspection{ValidatorAddress: ValidatorAddress(block.Validator)}
+
+	recomputed := block.HeaderHash()
+	report.HashValid = bytes.Equal(recomputed, block.Hash)
+	if !report.HashValid {
+		report.Errors = append(report.Errors, fmt.Sprintf("hash mismatch: header hashes to %x", recomputed))
+	}
+
+	// Genesis is hardcoded and carries no PoA signature
+	if block.IsGenesis() {
+		report.SignatureValid = true
+		report.HeaderValid = true
+		report.TransactionsValid = true
+		report.Valid = report.HashValid
+		return report
+	}
+
+	report.SignatureValid = VerifyBlockSignature(block)
+	if !report.SignatureValid {
+		report.Errors = append(report.Errors, "invalid PoA signature")
+	}
+
+	if parent == nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("parent block %x not found", block.PrevBlockHash))
+	} else if err := ValidateBlockHeader(block, parent); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	} else {
+		report.HeaderValid = true
+	}
+
+	if block.Pruned {
+		// Checked when the block was added; the body is gone now
+		report.TransactionsValid = true
+	} else {
+		report.TransactionsValid = chain.VerifyBlockTransactions(block)
+		if !report.TransactionsValid {
+			report.Errors = append(report.Errors, "invalid transaction signatures")
+		}
+	}
+
+	report.Valid = report.HashValid && report.SignatureValid && report.HeaderValid && report.TransactionsValid
+	return report
+}
+
+// ChainVerifyFailure is a block that failed VerifyChain
+type ChainVerifyFailure struct {
+	Height int
+	Hash   []byte
+	Errors []string
+}
+
+// VerifyChain walks back from the tip checking every block as 'chain block'
+// does, or only the newest last blocks when last > 0. It prints progress and
+// returns the number of blocks checked and the ones that failed. A block that
+// can't be read ends the walk, since its ancestors are unreachable.
+func VerifyChain(chain *Blockchain, last int) (int, []ChainVerifyFailure) {
+	tip, err := chain.LoadBlock(chain.LastHash)
+	if err != nil {
+		return 0, []ChainVerifyFailure{{Height: -1, Hash: chain.LastHash, Errors: []string{fmt.Sprintf("tip block unreadable: %v", err)}}}
+	}
+
+	total := tip.Height + 1
+	if last > 0 && last < total {
+		total = last
+	}
+	fmt.Printf("🔍 Verifying %d block(s), heights %d to %d...\n", total, tip.Height-total+1, tip.Height)
+
+	step := total / 10
+	if step < 1000 {
+		step = 1000
+	}
+
+	var failures []ChainVerifyFailure
+	checked := 0
+	block := tip
+	for block != nil && checked < total {
+		var parent *Block
+		if len(block.PrevBlockHash) > 0 {
+			parent, err = chain.LoadBlock(block.PrevBlockHash)
+			if err != nil && checked+1 < total {
+				failures = append(failures, ChainVerifyFailure{
+					Height: block.Height - 1,
+					Hash:   block.PrevBlockHash,
+					Errors: []string{fmt.Sprintf("block unreadable: %v", err)},
+				})
+			}
+		}
+
+		if report := checkStoredBlock(chain, block, parent); !report.Valid {
+			failures = append(failures, ChainVerifyFailure{Height: block.Height, Hash: block.Hash, Errors: report.Errors})
+		}
+
+		checked++
+		if checked%step == 0 && checked < total {
+			fmt.Printf("   ... %d/%d blocks checked\n", checked, total)
+		}
+		block = parent
+	}
+
+	return checked, failures
+}
+
+// parseVerifyChainSpec reads the --verify-chain value: "full" checks every
+// block, "last:N" the newest N. It returns the block count for VerifyChain
+// (0 = all).
+func parseVerifyChainSpec(spec string) (int, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "full" || spec == "true" {
+		return 0, nil
+	}
+	if n, ok := strings.CutPrefix(spec, "last:"); ok {
+		last, err := strconv.Atoi(n)
+		if err != nil || last < 1 {
+			return 0, fmt.Errorf("invalid block count %q in --verify-chain=%s", n, spec)
+		}
+		return last, nil
+	}
+	return 0, fmt.Errorf("invalid --verify-chain value %q (use full or last:N)", spec)
+}
+
+// printVerifyChainResult reports a VerifyChain run and whether it passed
+func printVerifyChainResult(checked int, failures []ChainVerifyFailure, elapsed time.Duration) bool {
+	if len(failures) == 0 {
+		fmt.Printf(ColorGreen+"✅ Chain verified: %d block(s) OK (%s)."+ColorReset+"\n", checked, elapsed.Round(time.Millisecond))
+		return true
+	}
+
+	fmt.Printf(ColorRed+"⛔ Chain verification FAILED: %d bad block(s) out of %d checked."+ColorReset+"\n", len(failures), checked)
+	for _, f := range failures {
+		fmt.Printf("  Block %d (%x):\n", f.Height, f.Hash)
+		for _, e := range f.Errors {
+			fmt.Printf("    - %s\n", e)
+		}
+	}
+	return false
+}
+
+func runVerifyChain(cmd *cobra.Command, args []string) {
+	if lastFlag < 0 {
+		fmt.Println("⛔ ERROR: --last must be positive.")
+		os.Exit(1)
+	}
+
+	chain := ContinueBlockchain("")
+	defer chain.Database.Close()
+
+	start := time.Now()
+	checked, failures := VerifyChain(chain, lastFlag)
+	if !printVerifyChainResult(checked, failures, time.Since(start)) {
+		chain.Database.Close()
+		os.Exit(1)
+	}
+}
+
+// runAuditUTXO compares the stored UTXO set with one recomputed from the
+// blocks. The database is opened read-only; on a running node (which holds
+// the database lock) use node start --audit-utxo instead.
+func runAuditUTXO(cmd *cobra.Command, args []string) {
+	chain := ContinueBlockchainReadOnly("")
+	defer chain.Database.Close()
+
+	start := time.Now()
+	report, err := UTXOSet{chain}.Audit()
+	if err != nil {
+		fmt.Printf("⛔ ERROR: %v\n", err)
+		chain.Database.Close()
+		os.Exit(1)
+	}
+
+	if jsonFlag {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	} else if report.Clean() {
+		fmt.Printf(ColorGreen+"✅ UTXO set OK: %d UTXOs match %d block(s) up to height %d (%s)."+ColorReset+"\n",
+			report.StoredUTXOs, report.Blocks, report.Height, time.Since(start).Round(time.Millisecond))
+	} else {
+		fmt.Printf(ColorRed+"⛔ UTXO set drift at height %d: %d missing, %d extra, %d mismatched (chain %d, stored %d)."+ColorReset+"\n",
+			report.Height, report.Missing, report.Extra, report.Mismatched, report.ChainUTXOs, report.StoredUTXOs)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tOUTPOINT\tCHAIN\tSTORED")
+		for _, issue := range report.Issues {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", issue.Kind, issue.Outpoint, issue.ChainValue, issue.StoredValue)
+		}
+		w.Flush()
+		if shown := len(report.Issues); shown < report.Missing+report.Extra+report.Mismatched {
+			fmt.Printf("... first %d shown.\n", shown)
+		}
+		fmt.Println("ℹ️  Run 'chain reindex' to rebuild the UTXO set from the blocks.")
+	}
+
+	if !report.Clean() {
+		chain.Database.Close()
+		os.Exit(1)
+	}
+}
+
+// runRevokeValidator builds a validator revocation one signature at a time:
+// the first validator creates it (--pubkey), the next ones add theirs
+// (--certificate), and once a majority signed it any node takes it through
+// POST /consensus/revocations and relays it until a validator includes it
+// in a block.
+func runRevokeValidator(cmd *cobra.Command, args []string) {
+	applyStoredPresetOrWarn()
+	var revocation *ValidatorRevocation
+	if certFlag != "" {
+		data, err := hex.DecodeString(strings.TrimSpace(certFlag))
+		if err != nil {
+			fmt.Println("⛔ ERROR: --certificate is not valid Hex.")
+			os.Exit(1)
+		}
+		if revocation, err = DeserializeRevocation(data); err != nil {
+			fmt.Printf("⛔ ERROR: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		if pubKeyFlag == "" {
+			fmt.Println("⛔ ERROR: A new revocation needs --pubkey.")
+			os.Exit(1)
+		}
+		revocation = &ValidatorRevocation{PubKey: strings.ToLower(strings.TrimSpace(pubKeyFlag))}
+	}
+
+	// Checked without the chain (the node may hold the database): keys
+	// revoked since are caught when the certificate is submitted
+	noneRevoked := map[string]int{}
+	if _, err := revocation.Verify(noneRevoked); err != nil {
+		fmt.Printf("⛔ ERROR: Invalid revocation: %v\n", err)
+		os.Exit(1)
+	}
+
+	if addressFlag != "" {
+		wallets := loadWallets(false)
+		wallet := wallets.GetWalletRef(addressFlag)
+		if wallet == nil {
+			fmt.Printf("⛔ ERROR: Private Key not found for address %s.\n", addressFlag)
+			os.Exit(1)
+		}
+		privKey, err := wallet.GetPrivateKey()
+		if err != nil {
+			fmt.Printf("⛔ ERROR: Private Key not valid for address %s: %v\n", addressFlag, err)
+			os.Exit(1)
+		}
+		if !IsAuthorizedValidator(GetValidatorHex(*wallet)) {
+			fmt.Printf("⛔ ERROR: Address %s is not an Authorized Validator. Revocation not signed.\n", addressFlag)
+			os.Exit(1)
+		}
+		if err := revocation.Sign(privKey); err != nil {
+			fmt.Printf("⛔ ERROR: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	signers, _ := revocation.Verify(noneRevoked)
+	quorum := RevocationQuorum(revocation.PubKey, noneRevoked)
+	fmt.Printf("Revocation of %.16s...: %d of %d signatures\n", revocation.PubKey, signers, quorum)
+
+	if signers < quorum {
+		fmt.Println("ℹ️  Pass the certificate below to the next validator: chain revoke-validator --certificate <HEX> --address <ADDR>")
+	} else {
+		fmt.Println("✅ Quorum reached. Submit it to any node (POST /consensus/revocations): the next block forged includes it and the key can't sign the blocks after that one.")
+	}
+	fmt.Println(hex.EncodeToString(revocation.Serialize()))
+}
+
+func runChainCoverage(cmd *cobra.Command, args []string) {
+	chain := ContinueBlockchain("")
+	defer chain.Database.Close()
+
+	to := toHeightFlag
+	if to < 0 {
+		to = chain.GetBestHeight()
+	}
+	from := fromHeightFlag
+	if from < 0 {
+		from = max(to-defaultCoverageBlocks+1, 0)
+	}
+	if from > to {
+		fmt.Printf("⛔ ERROR: --from (%d) is above --to (%d).\n", from, to)
+		chain.Database.Close()
+		os.Exit(1)
+	}
+
+	report := SigningCoverage(chain, from, to)
+	if jsonFlag {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	fmt.Printf("Signing coverage, heights %d-%d (%d signed blocks)\n\n", report.From, report.To, len(report.Blocks))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VALIDATOR\tBLOCKS\tSHARE\tIN TURN\tEXPECTED")
+	outOfTurn := 0
+	for _, t := range report.Tally {
+		name := t.Address
+		if !t.Authorized {
+			name += " (not authorized)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%d\t%d\n", name, t.Blocks, t.Share*100, t.OnSchedule, t.Expected)
+		outOfTurn += t.Blocks - t.OnSchedule
+	}
+	w.Flush()
+
+	if outOfTurn > 0 {
+		fmt.Printf("\n⚠️  %d block(s) were signed out of round-robin turn.\n", outOfTurn)
+	}
+}
+
+// runSignBlock signs a canonical block with a validator key from the local
+// wallet, so the key can live on a machine that never runs a node.
+func runSignBlock(cmd *cobra.Command, args []string) {
+	applyStoredPresetOrWarn()
+	data, err := hex.DecodeString(strings.TrimSpace(templateFlag))
+	if err != nil {
+		fmt.Println("⛔ ERROR: --template is not valid Hex.")
+		os.Exit(1)
+	}
+	block, err := DeserializeBlockCanonical(data)
+	if err != nil {
+		fmt.Printf("⛔ ERROR: Invalid block template: %v\n", err)
+		os.Exit(1)
+	}
+	if block.Pruned {
+		fmt.Println("⛔ ERROR: Pruned blocks cannot be signed.")
+		os.Exit(1)
+	}
+
+	wallets := loadWallets(false)
+	wallet := wallets.GetWalletRef(addressFlag)
+	if wallet == nil {
+		fmt.Printf("⛔ ERROR: Private Key not found for address %s.\n", addressFlag)
+		os.Exit(1)
+	}
+	forged := len(block.Signature) == 0
+	if err := signBlockTemplate(block, wallet); err != nil {
+		fmt.Printf("⛔ ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	if scheduled := ScheduledValidator(block.Height); forged && scheduled != "" && scheduled != GetValidatorHex(*wallet) {
+		fmt.Printf("⚠️  Height %d is not %s's turn: peers prefer the scheduled validator's block.\n", block.Height, addressFlag)
+	}
+
+	signatures := 1 + len(block.Attestations)
+	fmt.Printf("✅ Block %d signed by %s (%d of %d signatures)\n", block.Height, addressFlag, signatures, BlockQuorum)
+	fmt.Printf("   Hash: %x\n", block.Hash)
+	if signatures < BlockQuorum {
+		fmt.Println("   More validators must sign before it is accepted: pass the hex below to 'chain sign-block' on their machines.")
+	} else {
+		fmt.Println("   Submit it with POST /blocks/submit.")
+	}
+	fmt.Println(hex.EncodeToString(block.SerializeCanonical()))
+}
+
+// signBlockTemplate signs block with wallet's key, which must belong to an
+// authorized validator. An unsigned block is forged like ForgeBlock does
+// (hash, nonce, signature); an already signed one gets an attestation.
+func signBlockTemplate(block *Block, wallet *Wallet) error {
+	privKey, err := wallet.GetPrivateKey()
+	if err != nil {
+		return fmt.Errorf("private key not valid for address %s: %w", wallet.GetAddress(), err)
+	}
+	if !IsAuthorizedValidator(GetValidatorHex(*wallet)) {
+		return fmt.Errorf("address %s is not an Authorized Validator, block not signed", wallet.GetAddress())
+	}
+
+	if len(block.Signature) == 0 {
+		// Never sign a hash taken from the template: recompute it from the content
+		block.Validator = nil
+		MineBlock(block)
+		if err := SignBlock(block, privKey); err != nil {
+			return fmt.Errorf("failed to sign block: %w", err)
+		}
+		return nil
+	}
+
+	unsigned := *block
+	unsigned.Validator = nil
+	unsigned.SetHash()
+	if !bytes.Equal(unsigned.Hash, block.Hash) {
+		return errors.New("block hash doesn't match its content, not attesting")
+	}
+	if _, ok := verifyValidatorSignature(block.Validator, block.Signature, block.Hash); !ok {
+		return errors.New("block is signed, but not by an authorized validator, not attesting")
+	}
+	signers := [][]byte{block.Validator}
+	for _, a := range block.Attestations {
+		signers = append(signers, a.Validator)
+	}
+	ownKey := append(privKey.PublicKey.X.FillBytes(make([]byte, 32)), privKey.PublicKey.Y.FillBytes(make([]byte, 32))...)
+	for _, signer := range signers {
+		if bytes.Equal(signer, ownKey) {
+			return fmt.Errorf("%s already signed this block", wallet.GetAddress())
+		}
+	}
+	if err := AttestBlock(block, privKey); err != nil {
+		return fmt.Errorf("failed to attest block: %w", err)
+	}
+	return nil
+}
+
+func runInspectBlock(cmd *cobra.Command, args []string) {
+	if hashFlag == "" && heightFlag < 0 {
+		fmt.Println("⛔ ERROR: Provide either --hash <HEX> or --height <N>.")
+		os.Exit(1)
+	}
+
+	chain := ContinueBlockchain("")
+	defer chain.Database.Close()
+
+	var block Block
+	var err error
+	if hashFlag != "" {
+		hash, decodeErr := hex.DecodeString(hashFlag)
+		if decodeErr != nil {
+			fmt.Println("⛔ ERROR: Invalid block hash format.")
+			os.Exit(1)
+		}
+		block, err = chain.GetBlock(hash)
+	} else {
+		block, err = chain.GetBlockByHeight(heightFlag)
+	}
+	if err != nil {
+		fmt.Printf("⛔ ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	report := InspectBlock(chain, &block)
+
+	if jsonFlag {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	fmt.Printf("============ Block %x ============\n", block.Hash)
+	fmt.Printf("Height:      %d\n", block.Height)
+	fmt.Printf("Timestamp:   %s\n", time.Unix(block.Timestamp, 0).Format(time.RFC3339))
+	fmt.Printf("Prev. hash:  %x\n", block.PrevBlockHash)
+	fmt.Printf("Hash:        %x\n", block.Hash)
+	fmt.Printf("Nonce:       %d\n", block.Nonce)
+	fmt.Printf("Validator:   %s\n", report.ValidatorAddress)
+	fmt.Printf("Signature:   %x\n", block.Signature)
+	fmt.Println("Transactions:")
+	for _, tx := range report.Transactions {
+		fmt.Printf("  TX ID: %s (%d inputs, %d outputs)\n", tx.ID, len(tx.Inputs), len(tx.Outputs))
+		if tx.Memo != "" {
+			fmt.Printf("    Memo: %s\n", tx.Memo)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("Hash:          %s\n", strconv.FormatBool(report.HashValid))
+	fmt.Printf("PoA Signature: %s\n", strconv.FormatBool(report.SignatureValid))
+	fmt.Printf("Header:        %s\n", strconv.FormatBool(report.HeaderValid))
+	fmt.Printf("Transactions:  %s\n", strconv.FormatBool(report.TransactionsValid))
+
+	if report.Valid {
+		fmt.Println(ColorGreen + "✅ Block passes validation." + ColorReset)
+	} else {
+		fmt.Println(ColorRed + "⛔ Block FAILS validation:" + ColorReset)
+		for _, e := range report.Errors {
+			fmt.Printf("  - %s\n", e)
+		}
+	}
+}
+
 func printWallet(cmd *cobra.Command, args []string) {
 	if !ValidateAddress(addressFlag) {
 		log.Panic("Error: Invalid Address")
 	}
 
-	wallets, err := CreateWallets()
-	if err != nil {
-		log.Panic(err)
-	}
+	wallets := loadWallets(false)
 
 	wallet := wallets.GetWalletRef(addressFlag)
 	if wallet == nil {
@@ -754,7 +2529,7 @@ func listAddresses(cmd *cobra.Command, args []string) {
 			fmt.Println("No wallets found.")
 			return
 		}
-		log.Panic(err)
+		exitWalletError(err)
 	}
 	addresses := wallets.GetAddresses()
 
@@ -770,7 +2545,14 @@ func reindexUTXO(cmd *cobra.Command, args []string) {
 	defer chain.Database.Close()
 
 	UTXOSet := UTXOSet{chain}
-	UTXOSet.Reindex()
+	if err := UTXOSet.Reindex(); err != nil {
+		if errors.Is(err, ErrBlockPruned) {
+			fmt.Println("⛔ ERROR: This chain was pruned, so its UTXO set can't be rebuilt. Nothing was changed. Run 'chain reset' and resync without --prune.")
+		} else {
+			fmt.Printf("⛔ ERROR: Reindex failed: %v\n", err)
+		}
+		os.Exit(1)
+	}
 
 	// Re-add reindexUTXO at end of file if it was cut off, or just append runResetChain
 	count := UTXOSet.CountTransactions()
@@ -798,3 +2580,55 @@ func runResetChain(cmd *cobra.Command, args []string) {
 	}
 	fmt.Println("✅ Blockchain database deleted.")
 }
+
+func runCleanSnapshots(cmd *cobra.Command, args []string) {
+	removed, err := CleanSnapshots()
+	for _, dir := range removed {
+		fmt.Printf("🧹 Removed %s\n", dir)
+	}
+	if err != nil {
+		fmt.Printf("⛔ ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	if len(removed) == 0 {
+		fmt.Println("✅ No leftover snapshots found.")
+		return
+	}
+	fmt.Printf("✅ Removed %d snapshot(s).\n", len(removed))
+}
+
+func runRollbackChain(cmd *cobra.Command, args []string) {
+	if blocksFlag <= 0 {
+		fmt.Println("⛔ ERROR: --blocks must be at least 1.")
+		os.Exit(1)
+	}
+
+	chain := ContinueBlockchain("")
+	defer chain.Database.Close()
+
+	height := chain.GetBestHeight()
+	if blocksFlag > height {
+		fmt.Printf("⛔ ERROR: Cannot roll back %d blocks: the tip is at height %d and the genesis block must stay.\n", blocksFlag, height)
+		return
+	}
+
+	fmt.Printf("⚠️  Roll back %d block(s), from height %d to %d? Their transactions will be dropped. [y/N]: ", blocksFlag, height, height-blocksFlag)
+	var response string
+	fmt.Scanln(&response)
+
+	if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
+		fmt.Println("Operation cancelled.")
+		return
+	}
+
+	for i := 0; i < blocksFlag; i++ {
+		block, err := chain.RollbackTip()
+		if err != nil {
+			fmt.Printf("⛔ ERROR: Rollback stopped: %v\n", err)
+			return
+		}
+		fmt.Printf("↩️  Removed block %x (height %d)\n", block.Hash, block.Height)
+	}
+
+	fmt.Printf("✅ Rollback complete. New tip: %x (height %d)\n", chain.LastHash, chain.GetBestHeight())
+}
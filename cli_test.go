@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/viper"
+)
+
+func TestAPIGetStopsAtCommandTimeout(t *testing.T) {
+	release := make(chan struct{})
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // An unresponsive node
+	}))
+	defer node.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := apiGet(ctx, node.URL)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("got %v, want a timeout error", err)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	for range 3 {
+		addTestBlock(t, chain, w)
+	}
+
+	if checked, failures := VerifyChain(chain, 0); checked != 4 || len(failures) != 0 {
+		t.Fatalf("clean chain: %d checked, failures %v", checked, failures)
+	}
+	if checked, _ := VerifyChain(chain, 2); checked != 2 {
+		t.Fatalf("last 2: %d checked", checked)
+	}
+
+	// Corrupt block 2 on disk: its stored header no longer hashes to its key
+	block, err := chain.GetBlockByHeight(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Nonce++
+	if err := chain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set(block.Hash, block.Serialize())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_, failures := VerifyChain(chain, 0)
+	if len(failures) != 1 || failures[0].Height != 2 {
+		t.Fatalf("corrupted block 2: failures %v", failures)
+	}
+
+	// Blocks signed by a key that isn't authorized
+	other, _ := newValidator(t)
+	withValidators(t, 1, other)
+	if _, failures := VerifyChain(chain, 0); len(failures) != 3 {
+		t.Fatalf("unauthorized signer: %d failures, want 3", len(failures))
+	}
+	withValidators(t, 1, w)
+
+	// Undecodable block data is reported, not a panic, and ends the walk
+	if err := chain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set(block.PrevBlockHash, []byte("garbage"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	checked, failures := VerifyChain(chain, 0)
+	if checked != 2 || len(failures) == 0 || failures[0].Height != 1 {
+		t.Fatalf("unreadable block 1: %d checked, failures %v", checked, failures)
+	}
+}
+
+func TestParseVerifyChainSpec(t *testing.T) {
+	for spec, want := range map[string]int{"full": 0, "true": 0, "last:1000": 1000, " LAST:5 ": 5} {
+		if got, err := parseVerifyChainSpec(spec); err != nil || got != want {
+			t.Errorf("%q: got %d, %v; want %d", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"last:0", "last:-3", "last:x", "partial", ""} {
+		if _, err := parseVerifyChainSpec(spec); err == nil {
+			t.Errorf("%q accepted", spec)
+		}
+	}
+}
+
+func TestShutdownNodeStopsInOrder(t *testing.T) {
+	s := newTestServer(t, newTestChain(t))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiServer := &http.Server{Handler: http.NotFoundHandler()}
+	go apiServer.Serve(ln)
+	apiURL := "http://" + ln.Addr().String()
+
+	// The mining loop sees the API already down and the database still open
+	miningCtx, stopMining := context.WithCancel(context.Background())
+	miningDone := make(chan struct{})
+	var apiUpWhileMining, dbClosedWhileMining bool
+	go func() {
+		<-miningCtx.Done()
+		if resp, err := http.Get(apiURL); err == nil {
+			resp.Body.Close()
+			apiUpWhileMining = true
+		}
+		dbClosedWhileMining = s.Blockchain.Database.IsClosed()
+		close(miningDone)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownNode(ctx, s, apiServer, stopMining, miningDone)
+
+	if apiUpWhileMining || dbClosedWhileMining {
+		t.Fatalf("mining stopped with the API up (%v) or the database closed (%v)", apiUpWhileMining, dbClosedWhileMining)
+	}
+	if s.ctx.Err() == nil {
+		t.Error("background loops not stopped")
+	}
+	if err := newTestHost(t).Connect(context.Background(), peer.AddrInfo{ID: s.Host.ID(), Addrs: s.Host.Addrs()}); err == nil {
+		t.Error("P2P host still accepts connections")
+	}
+	if !s.Blockchain.Database.IsClosed() {
+		t.Error("database left open")
+	}
+}
+
+func TestShutdownNodeGivesUpOnStuckMining(t *testing.T) {
+	s := newTestServer(t, newTestChain(t))
+	miningDone := make(chan struct{}) // A block that never finishes storing
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	shutdownNode(ctx, s, nil, func() {}, miningDone)
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("shutdown waited %s past its timeout", elapsed)
+	}
+	// The block may still be writing: the database must not be closed under it
+	if s.Blockchain.Database.IsClosed() {
+		t.Fatal("database closed while a block was being stored")
+	}
+}
+
+func TestSignBlockTemplateSubmitRoundTrip(t *testing.T) {
+	forger, _ := newValidator(t)
+	attester, _ := newValidator(t)
+	stranger, _ := newValidator(t)
+	withValidators(t, 2, forger, attester)
+	rs := &RestServer{P2P: newTestServer(t, newTestChain(t))}
+	chain := rs.P2P.Blockchain
+
+	tip := tipBlock(t, chain)
+	coinbase := NewCoinbaseTX(forger.GetAddress(), "offline", InitialSubsidy)
+	template := NewBlock([]*Transaction{coinbase}, tip.Hash, tip.Height+1, nil)
+	template.Timestamp = tip.Timestamp + 1
+
+	// Each signer only sees the hex the previous one printed
+	sign := func(hexBlock string, w *Wallet) (string, error) {
+		data, err := hex.DecodeString(hexBlock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := DeserializeBlockCanonical(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signBlockTemplate(block, w); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(block.SerializeCanonical()), nil
+	}
+	submit := func(hexBlock string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(BlockSubmitRequest{Hex: hexBlock})
+		return callAPI(rs.submitBlock, "POST", "/blocks/submit", body, nil)
+	}
+
+	if _, err := sign(hex.EncodeToString(template.SerializeCanonical()), stranger); err == nil {
+		t.Fatal("template signed by an unauthorized key")
+	}
+	signed, err := sign(hex.EncodeToString(template.SerializeCanonical()), forger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sign(signed, forger); err == nil {
+		t.Fatal("forger attested its own block")
+	}
+	if rec := submit(signed); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("block below quorum: %d %s", rec.Code, rec.Body)
+	}
+
+	attested, err := sign(signed, attester)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := submit(attested); rec.Code != http.StatusOK {
+		t.Fatalf("signed block rejected: %d %s", rec.Code, rec.Body)
+	}
+	if chain.GetBestHeight() != tip.Height+1 {
+		t.Fatalf("tip at height %d after submitting, want %d", chain.GetBestHeight(), tip.Height+1)
+	}
+}
+
+func TestReadBackupPassphrase(t *testing.T) {
+	t.Setenv(backupPassphraseEnv, "from the environment")
+	if got, err := readBackupPassphrase("", false); err != nil || got != "from the environment" {
+		t.Fatalf("got %q, %v; want the environment variable", got, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(file, []byte("correct horse\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	passFileFlag = file
+	t.Cleanup(func() { passFileFlag = "" })
+	if got, err := readBackupPassphrase("", false); err != nil || got != "correct horse" {
+		t.Fatalf("got %q, %v; want the file without its newline", got, err)
+	}
+}
+
+func TestValidateNodeConfig(t *testing.T) {
+	if problems := validateNodeConfig(); len(problems) != 0 {
+		t.Fatalf("default settings rejected: %v", problems)
+	}
+
+	set := func(key string, value any) {
+		prev := viper.Get(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, prev) })
+	}
+	set("network.dial_timeout", "0s")
+	set("node.prune", MinPruneKeepBlocks-1)
+	set("node.coinbase_message", strings.Repeat("x", MaxCoinbaseMessageLen+1))
+	set("node.verify_chain", "last:0")
+
+	// Every problem is reported, not just the first
+	if problems := validateNodeConfig(); len(problems) != 4 {
+		t.Fatalf("%d problem(s) reported, want 4: %v", len(problems), problems)
+	}
+}
@@ -0,0 +1,518 @@
+// Package client is a typed Go client for the SOLE node REST API.
+//
+// The node itself lives in package main, so the response types are mirrored
+// here with the same JSON tags as the server structs in api_server.go.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "http://localhost:8080"
+	DefaultTimeout = 15 * time.Second
+)
+
+// Client talks to a single SOLE node
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout sets the timeout of the underlying HTTP client
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.HTTPClient.Timeout = timeout
+	}
+}
+
+// WithHTTPClient replaces the underlying HTTP client
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.HTTPClient = hc
+	}
+}
+
+// New creates a client for the node at baseURL (e.g. "http://localhost:8080").
+// An empty baseURL falls back to DefaultBaseURL.
+func New(baseURL string, opts ...Option) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	c := &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// --- Response types (mirror api_server.go) ---
+
+type BalanceResponse struct {
+	Address string `json:"address"`
+	Balance int64  `json:"balance"`
+}
+
+type TipResponse struct {
+	Height int    `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+type AddressValidationResponse struct {
+	Address       string `json:"address"`
+	Valid         bool   `json:"valid"`
+	Version       *int   `json:"version,omitempty"`
+	PubKeyHashHex string `json:"pubkey_hash_hex,omitempty"`
+}
+
+type UTXOResponse struct {
+	TxID   string `json:"txid"`
+	Vout   int    `json:"vout"`
+	Amount int64  `json:"amount"`
+}
+
+// UTXOPageResponse is one page of an address's UTXOs. Total and TotalValue
+// cover every output matching the filter, not just this page. Next is the
+// cursor of the following page, empty on the last one.
+type UTXOPageResponse struct {
+	Address    string         `json:"address"`
+	Total      int            `json:"total"`
+	TotalValue int64          `json:"total_value"`
+	Offset     int            `json:"offset"`
+	Limit      int            `json:"limit"`
+	UTXOs      []UTXOResponse `json:"utxos"`
+	Next       string         `json:"next,omitempty"`
+}
+
+type SuccessResponse struct {
+	Status string `json:"status"`
+	TxID   string `json:"txid,omitempty"`
+}
+
+type RebroadcastResponse struct {
+	TxID  string `json:"txid"`
+	Peers int    `json:"peers"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+type PeerResponse struct {
+	TotalPeers int      `json:"total_peers"`
+	Peers      []string `json:"peers"`
+}
+
+type ValidatorStat struct {
+	PubKey             string  `json:"pubkey"`
+	Address            string  `json:"address"`
+	Authorized         bool    `json:"authorized"`
+	BlocksForged       int     `json:"blocks_forged"`
+	LastHeight         int     `json:"last_height"`
+	LastTimestamp      int64   `json:"last_timestamp"`
+	AvgIntervalSeconds float64 `json:"avg_interval_seconds"`
+}
+
+type ValidatorResponse struct {
+	TotalValidators int             `json:"total_validators"`
+	Validators      []string        `json:"validators"`
+	Details         []ValidatorStat `json:"details"`
+}
+
+type ScheduleEntry struct {
+	Height     int    `json:"height"`
+	PubKey     string `json:"pubkey"`
+	Address    string `json:"address"`
+	EtaSeconds int64  `json:"eta_seconds"`
+}
+
+type ScheduleResponse struct {
+	Height           int             `json:"height"`
+	BlockTimeSeconds int64           `json:"block_time_seconds"`
+	Schedule         []ScheduleEntry `json:"schedule"`
+}
+
+type CoverageBlock struct {
+	Height       int    `json:"height"`
+	Hash         string `json:"hash"`
+	PubKey       string `json:"pubkey"`
+	Address      string `json:"address"`
+	Scheduled    bool   `json:"scheduled"`
+	Attestations int    `json:"attestations"`
+}
+
+type CoverageTally struct {
+	PubKey     string  `json:"pubkey"`
+	Address    string  `json:"address"`
+	Authorized bool    `json:"authorized"`
+	Blocks     int     `json:"blocks"`
+	Share      float64 `json:"share"`
+	OnSchedule int     `json:"on_schedule"`
+	Expected   int     `json:"expected"`
+}
+
+type CoverageReport struct {
+	From   int             `json:"from"`
+	To     int             `json:"to"`
+	Blocks []CoverageBlock `json:"blocks"`
+	Tally  []CoverageTally `json:"tally"`
+}
+
+type JSONInput struct {
+	SenderAddress string   `json:"sender_address"`
+	Signature     string   `json:"signature"`
+	CoinbaseData  string   `json:"coinbase_data,omitempty"`
+	PrevTxID      string   `json:"prev_txid,omitempty"`
+	Vout          int      `json:"vout"`
+	Value         *int64   `json:"value,omitempty"` // nil when the node couldn't resolve the spent output
+	ValueSole     *float64 `json:"value_sole,omitempty"`
+}
+
+type JSONOutput struct {
+	ReceiverAddress string  `json:"receiver_address"`
+	Value           int64   `json:"value"`
+	ValueSole       float64 `json:"value_sole"`
+}
+
+type JSONTransactionResponse struct {
+	ID         string       `json:"id"`
+	Inputs     []JSONInput  `json:"inputs"`
+	Outputs    []JSONOutput `json:"outputs"`
+	Timestamp  int64        `json:"timestamp"`
+	BlockTime  int64        `json:"block_timestamp,omitempty"` // 0 while unconfirmed
+	LockHeight int          `json:"lock_height,omitempty"`     // Can't be mined below this height
+	Memo       string       `json:"memo,omitempty"`
+	Fee        *int64       `json:"fee,omitempty"` // nil unless declared or every input value is known
+}
+
+// SearchResponse is what Search found. Type ("block", "transaction" or
+// "address") says which field is set.
+type SearchResponse struct {
+	Type        string                   `json:"type"`
+	Block       *JSONBlock               `json:"block,omitempty"`
+	Transaction *JSONTransactionResponse `json:"transaction,omitempty"`
+	TxStatus    *TxStatusResponse        `json:"tx_status,omitempty"`
+	Address     *BalanceResponse         `json:"address,omitempty"`
+}
+
+type TxStatusResponse struct {
+	TxID          string `json:"txid"`
+	Status        string `json:"status"` // "confirmed", "pending", "waiting" (time-locked), "replaced" or "unknown"
+	BlockHash     string `json:"block_hash,omitempty"`
+	BlockHeight   int    `json:"block_height"`
+	Confirmations int    `json:"confirmations"`
+	ReplacedBy    string `json:"replaced_by,omitempty"` // Conflicting tx that evicted it from the mempool
+}
+
+type JSONBlock struct {
+	Timestamp     int64                     `json:"timestamp"`
+	Height        int                       `json:"height"`
+	PrevBlockHash string                    `json:"prev_block_hash"`
+	Hash          string                    `json:"hash"`
+	Transactions  []JSONTransactionResponse `json:"transactions"`
+	Validator     string                    `json:"validator"`
+	Signature     string                    `json:"signature"`
+	Attestations  []JSONAttestation         `json:"attestations,omitempty"`
+	Pruned        bool                      `json:"pruned,omitempty"` // Transactions discarded by a pruning node
+}
+
+type JSONAttestation struct {
+	Validator string `json:"validator"`
+	Signature string `json:"signature"`
+}
+
+// Error codes reported by POST /tx/send (APIError.Code)
+const (
+	CodeInsufficientFunds = "insufficient_funds"
+	CodeInvalidSignature  = "invalid_signature"
+	CodeDoubleSpend       = "double_spend"
+	CodeUnknownInput      = "unknown_input"
+	CodeDustOutput        = "dust_output"
+	CodeFeeMismatch       = "fee_mismatch"
+	CodeUnconfirmedInput  = "unconfirmed_input"
+	CodeTxInvalid         = "tx_invalid"
+	CodeTxTooLarge        = "tx_too_large"
+	CodeFeeTooHigh        = "fee_too_high"
+	CodeMempoolRejected   = "mempool_rejected"
+)
+
+// Error codes any endpoint may report (APIError.Code)
+const (
+	CodeInvalidRequest = "invalid_request"
+	CodeInvalidAddress = "invalid_address"
+	CodeNotFound       = "not_found"
+	CodePruned         = "pruned"
+	CodeReadOnly       = "read_only"
+	CodeAlreadyKnown   = "already_known"
+	CodeBlockInvalid   = "block_invalid"
+	CodeRateLimited    = "rate_limited"
+	CodeInternal       = "internal_error"
+
+	// Light nodes (node start --light)
+	CodeLightNode        = "light_node"
+	CodeBlockUnavailable = "block_unavailable"
+)
+
+// APIError is returned when the node answers with an error payload
+type APIError struct {
+	StatusCode int
+	Code       string // One of the Code* constants ("" from nodes older than the codes)
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("sole api: %s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// --- Endpoints ---
+
+// Balance returns the confirmed balance (Photons) of an address
+func (c *Client) Balance(ctx context.Context, addr string) (*BalanceResponse, error) {
+	var resp BalanceResponse
+	if err := c.get(ctx, "/balance/"+url.PathEscape(addr), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ValidateAddress asks the node to decode addr. A malformed address is not an
+// error: it comes back with Valid set to false.
+func (c *Client) ValidateAddress(ctx context.Context, addr string) (*AddressValidationResponse, error) {
+	var resp AddressValidationResponse
+	if err := c.get(ctx, "/address/"+url.PathEscape(addr)+"/validate", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// MaxUTXOPageSize is the largest limit /utxos/{address} accepts
+const MaxUTXOPageSize = 1000
+
+// UTXOs returns all spendable outputs of an address (mempool spends
+// excluded), fetching as many pages as needed
+func (c *Client) UTXOs(ctx context.Context, addr string) ([]UTXOResponse, error) {
+	utxos := []UTXOResponse{}
+	for after := ""; ; {
+		page, err := c.UTXOPage(ctx, addr, UTXOQuery{After: after, Limit: MaxUTXOPageSize})
+		if err != nil {
+			return nil, err
+		}
+		utxos = append(utxos, page.UTXOs...)
+		if page.Next == "" {
+			return utxos, nil
+		}
+		after = page.Next
+	}
+}
+
+// UTXOQuery selects a page of /utxos/{address}. Zero fields take the node's
+// defaults: from the first output, 100 per page, no minimum value.
+type UTXOQuery struct {
+	After    string // Resume after this output ("<txid>:<vout>", a page's Next)
+	Offset   int    // Outputs to skip, counted after After
+	Limit    int    // Page size, up to MaxUTXOPageSize
+	MinValue int64  // Skip outputs below this many Photons
+}
+
+// UTXOPage returns one page of the spendable outputs of an address
+func (c *Client) UTXOPage(ctx context.Context, addr string, q UTXOQuery) (*UTXOPageResponse, error) {
+	query := url.Values{}
+	query.Set("offset", strconv.Itoa(q.Offset))
+	if q.After != "" {
+		query.Set("after", q.After)
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.MinValue > 0 {
+		query.Set("min_value", strconv.FormatInt(q.MinValue, 10))
+	}
+	var resp UTXOPageResponse
+	if err := c.get(ctx, "/utxos/"+url.PathEscape(addr)+"?"+query.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Tip returns the height and hash of the current chain tip
+func (c *Client) Tip(ctx context.Context) (*TipResponse, error) {
+	var resp TipResponse
+	if err := c.get(ctx, "/blocks/tip", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Block returns the block with the given hex hash
+func (c *Client) Block(ctx context.Context, hash string) (*JSONBlock, error) {
+	var resp JSONBlock
+	if err := c.get(ctx, "/blocks/"+url.PathEscape(hash), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// BlockByHeight returns the main-chain block at the given height
+func (c *Client) BlockByHeight(ctx context.Context, height int) (*JSONBlock, error) {
+	var resp JSONBlock
+	if err := c.get(ctx, "/blocks/height/"+strconv.Itoa(height), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SendTx submits a signed, hex-serialized transaction to the node mempool
+func (c *Client) SendTx(ctx context.Context, txHex string) (*SuccessResponse, error) {
+	body, err := json.Marshal(map[string]string{"hex": txHex})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp SuccessResponse
+	if err := c.do(ctx, http.MethodPost, "/tx/send", bytes.NewReader(body), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Rebroadcast re-announces a mempool transaction to the node's peers.
+// Transactions not in the mempool come back as an *APIError with StatusCode 404.
+func (c *Client) Rebroadcast(ctx context.Context, txID string) (*RebroadcastResponse, error) {
+	var resp RebroadcastResponse
+	if err := c.do(ctx, http.MethodPost, "/tx/"+url.PathEscape(txID)+"/rebroadcast", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// TxStatus reports whether a transaction is pending or mined (and where).
+// Unknown transactions come back as an *APIError with StatusCode 404.
+func (c *Client) TxStatus(ctx context.Context, txID string) (*TxStatusResponse, error) {
+	var resp TxStatusResponse
+	if err := c.get(ctx, "/transaction/"+url.PathEscape(txID)+"/status", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Search looks up a height, block hash, transaction ID or address.
+// No match comes back as an *APIError with StatusCode 404.
+func (c *Client) Search(ctx context.Context, query string) (*SearchResponse, error) {
+	var resp SearchResponse
+	if err := c.get(ctx, "/search/"+url.PathEscape(query), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Peers returns the libp2p peers the node is connected to
+func (c *Client) Peers(ctx context.Context) (*PeerResponse, error) {
+	var resp PeerResponse
+	if err := c.get(ctx, "/network/peers", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Validators returns the authorized PoA validator public keys
+func (c *Client) Validators(ctx context.Context) (*ValidatorResponse, error) {
+	var resp ValidatorResponse
+	if err := c.get(ctx, "/consensus/validators", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Schedule returns the round-robin proposers of the next count heights.
+// count <= 0 lets the node pick its default.
+func (c *Client) Schedule(ctx context.Context, count int) (*ScheduleResponse, error) {
+	path := "/consensus/schedule"
+	if count > 0 {
+		path += "?count=" + strconv.Itoa(count)
+	}
+
+	var resp ScheduleResponse
+	if err := c.get(ctx, path, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Coverage reports which validator signed each block with a height in
+// [from, to]. A negative bound lets the node pick its default.
+func (c *Client) Coverage(ctx context.Context, from, to int) (*CoverageReport, error) {
+	query := url.Values{}
+	if from >= 0 {
+		query.Set("from", strconv.Itoa(from))
+	}
+	if to >= 0 {
+		query.Set("to", strconv.Itoa(to))
+	}
+	path := "/consensus/coverage"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var resp CoverageReport
+	if err := c.get(ctx, path, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// --- Plumbing ---
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// Some handlers reply 200 with an {"error": "..."} body, so check both
+	var apiErr ErrorResponse
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+		return &APIError{StatusCode: resp.StatusCode, Code: apiErr.Code, Message: apiErr.Error}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("sole api: decoding %s response: %w", path, err)
+	}
+	return nil
+}
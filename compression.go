@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+const (
+	// compressedFrame starts a gzip-compressed P2P payload. Commands are
+	// ASCII, so an uncompressed payload never starts with this byte and
+	// peers without compression support keep working unchanged.
+	compressedFrame = 0xC5
+
+	// Payloads up to this size are sent as they are: the gzip header would
+	// eat most of the savings
+	compressThreshold = 1024
+
+	// maxP2PPayload caps a payload on the wire and once decompressed
+	maxP2PPayload = 8 * 1024 * 1024
+)
+
+// WireStats counts P2P payload bytes before compression (Raw) and as sent
+// or received (Wire), for every message, compressed or not
+type WireStats struct {
+	SentRaw  atomic.Int64
+	SentWire atomic.Int64
+	RecvRaw  atomic.Int64
+	RecvWire atomic.Int64
+}
+
+// compressPayload gzips a command+content payload into a compressed frame.
+// It returns false when compression would not make the payload smaller.
+func compressPayload(data []byte) ([]byte, bool) {
+	if len(data) <= compressThreshold {
+		return nil, false
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(compressedFrame)
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, false
+	}
+	if err := zw.Close(); err != nil {
+		return nil, false
+	}
+	if buf.Len() >= len(data) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// decompressPayload expands a compressed frame (magic byte included),
+// refusing output above maxP2PPayload
+func decompressPayload(frame []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(frame[1:]))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(io.LimitReader(zr, maxP2PPayload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxP2PPayload {
+		return nil, errors.New("decompressed payload too large")
+	}
+	return data, nil
+}
+
+// peerAcceptsCompression reports whether the peer advertised compression in
+// its handshake
+func (s *Server) peerAcceptsCompression(id string) bool {
+	s.KnownPeersMux.RLock()
+	defer s.KnownPeersMux.RUnlock()
+	return s.PeerCompress[id]
+}
+
+// wireCounts is a reading of the received-bytes counters of WireStats
+type wireCounts struct {
+	raw, wire int64
+}
+
+// received reads the received-bytes counters
+func (w *WireStats) received() wireCounts {
+	return wireCounts{raw: w.RecvRaw.Load(), wire: w.RecvWire.Load()}
+}
+
+// compressionSummary describes the received-bytes savings since an earlier
+// reading, e.g. over a sync
+func (w *WireStats) compressionSummary(since wireCounts) string {
+	now := w.received()
+	raw, wire := now.raw-since.raw, now.wire-since.wire
+	if raw <= 0 || wire >= raw {
+		return fmt.Sprintf("received %d KB over P2P, no compression savings", wire/1024)
+	}
+	return fmt.Sprintf("received %d KB as %d KB over P2P (%.0f%% saved by compression)",
+		raw/1024, wire/1024, 100*(1-float64(wire)/float64(raw)))
+}
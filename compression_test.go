@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+func TestCompressPayloadRoundTrip(t *testing.T) {
+	data := append(CommandToBytes("block"), bytes.Repeat([]byte("sole"), 1024)...)
+	frame, ok := compressPayload(data)
+	if !ok || frame[0] != compressedFrame || len(frame) >= len(data) {
+		t.Fatalf("repetitive %d-byte payload not compressed", len(data))
+	}
+	got, err := decompressPayload(frame)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("round trip changed the payload (%v)", err)
+	}
+
+	if _, ok := compressPayload(CommandToBytes("version")); ok {
+		t.Fatal("payload under compressThreshold compressed")
+	}
+}
+
+func TestDecompressPayloadCapsSize(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteByte(compressedFrame)
+	zw := gzip.NewWriter(&buf)
+	zw.Write(make([]byte, maxP2PPayload+1))
+	zw.Close()
+
+	// A few KB on the wire, past the cap once expanded
+	if _, err := decompressPayload(buf.Bytes()); err == nil {
+		t.Fatalf("%d-byte frame expanding past maxP2PPayload accepted", buf.Len())
+	}
+}
+
+func TestCompressionOnlyForNegotiatingPeers(t *testing.T) {
+	s := newTestServer(t, newTestChain(t))
+	s.Compression = true
+	remote := newTestHost(t)
+	frames := make(chan []byte, 1)
+	remote.SetStreamHandler(protocolID, func(stream network.Stream) {
+		defer stream.Close()
+		lenBuf := make([]byte, 4)
+		if _, err := io.ReadFull(stream, lenBuf); err != nil {
+			return
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(lenBuf))
+		if _, err := io.ReadFull(stream, payload); err == nil {
+			frames <- payload
+		}
+	})
+	connectHosts(t, remote, s.Host)
+	data := append(CommandToBytes("block"), bytes.Repeat([]byte("sole"), 1024)...)
+
+	for _, accepts := range []bool{false, true} {
+		s.KnownPeersMux.Lock()
+		s.PeerCompress[remote.ID().String()] = accepts
+		s.KnownPeersMux.Unlock()
+
+		s.SendData(remote.ID(), data)
+		frame := <-frames
+		if compressed := frame[0] == compressedFrame; compressed != accepts {
+			t.Fatalf("peer accepting compression: %v, frame compressed: %v", accepts, compressed)
+		}
+		if !accepts && !bytes.Equal(frame, data) {
+			t.Fatal("uncompressed frame differs from the payload")
+		}
+	}
+}
+
+func TestSyncCompressionSavings(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	full := newTestServer(t, newTestChain(t))
+	full.Compression = true
+	for i := 0; i < 20; i++ {
+		// A fan-out transaction puts each block well over compressThreshold
+		fanOutBlock(t, full.Blockchain, w, 30)
+	}
+	height := full.Blockchain.GetBestHeight()
+
+	syncing := newTestServer(t, newTestChain(t))
+	syncing.Compression = true
+	connectHosts(t, syncing.Host, full.Host)
+	start := syncing.Wire.received()
+	syncing.SendVersion(full.Host.ID())
+	waitFor(t, "sync", func() bool { return syncing.Blockchain.GetBestHeight() == height })
+
+	got := syncing.Wire.received()
+	raw, wire := got.raw-start.raw, got.wire-start.wire
+	if wire >= raw {
+		t.Fatalf("sync received %d bytes as %d on the wire: nothing saved", raw, wire)
+	}
+	t.Logf("sync of %d blocks: %s", height, syncing.Wire.compressionSummary(start))
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -19,6 +23,11 @@ var AuthorizedValidators = []string{
 	// Example: "deadbeef..."
 }
 
+// BlockQuorum is how many distinct authorized validators must sign each
+// block: the forger plus BlockQuorum-1 attestations. It is a consensus rule,
+// so every node must use the same value (config: consensus.quorum).
+var BlockQuorum = 1
+
 func IsAuthorizedValidator(pubKeyHex string) bool {
 	for _, v := range AuthorizedValidators {
 		if v == pubKeyHex {
@@ -28,6 +37,33 @@ func IsAuthorizedValidator(pubKeyHex string) bool {
 	return false
 }
 
+// ScheduledValidator returns the validator whose round-robin turn it is to
+// forge the block at the given height.
+func ScheduledValidator(height int) string {
+	if len(AuthorizedValidators) == 0 || height < 0 {
+		return ""
+	}
+	return AuthorizedValidators[height%len(AuthorizedValidators)]
+}
+
+// ValidatorSchedule returns the round-robin proposers of the count heights
+// starting at fromHeight, in order
+func ValidatorSchedule(fromHeight, count int) []string {
+	if len(AuthorizedValidators) == 0 {
+		return nil
+	}
+	schedule := make([]string, 0, count)
+	for h := fromHeight; h < fromHeight+count; h++ {
+		schedule = append(schedule, ScheduledValidator(h))
+	}
+	return schedule
+}
+
+// ValidatorPubKeyHex returns the hex-encoded Standard (65 bytes) public key of a validator key
+func ValidatorPubKeyHex(privKey *ecdsa.PrivateKey) string {
+	return hex.EncodeToString(elliptic.Marshal(elliptic.P256(), privKey.PublicKey.X, privKey.PublicKey.Y))
+}
+
 func GetSignatureBytes(r, s *big.Int) []byte {
 	rBytes := r.Bytes()
 	sBytes := s.Bytes()
@@ -57,57 +93,346 @@ func SignBlock(block *Block, privKey ecdsa.PrivateKey) error {
 	return nil
 }
 
+// AttestBlock adds privKey's co-signature over the hash of an already signed block
+func AttestBlock(block *Block, privKey ecdsa.PrivateKey) error {
+	r, s, err := ecdsa.Sign(rand.Reader, &privKey, block.Hash)
+	if err != nil {
+		return err
+	}
+
+	block.Attestations = append(block.Attestations, BlockAttestation{
+		Validator: append(privKey.PublicKey.X.FillBytes(make([]byte, 32)),
+			privKey.PublicKey.Y.FillBytes(make([]byte, 32))...),
+		Signature: GetSignatureBytes(r, s),
+	})
+	return nil
+}
+
+// VerifyBlockSignature checks the forger's signature and every attestation,
+// then requires BlockQuorum distinct authorized validators among them
 func VerifyBlockSignature(block *Block) bool {
-	if len(block.Signature) != 64 {
-		fmt.Printf("PoA: Invalid signature length. Expected 64, Got %d\n", len(block.Signature))
+	// Genesis is matched against the local genesis hash, never signed
+	if block.IsGenesis() {
+		fmt.Println("PoA: Genesis block carries no validator signature")
+		return false
+	}
+
+	signer, ok := verifyValidatorSignature(block.Validator, block.Signature, block.Hash)
+	if !ok {
 		return false
 	}
 
+	signers := map[string]bool{signer: true}
+	for i, a := range block.Attestations {
+		attester, ok := verifyValidatorSignature(a.Validator, a.Signature, block.Hash)
+		if !ok {
+			fmt.Printf("PoA: Attestation %d rejected\n", i)
+			return false
+		}
+		signers[attester] = true
+	}
+
+	if len(signers) < BlockQuorum {
+		fmt.Printf("PoA: Quorum not met (%d of %d validators signed)\n", len(signers), BlockQuorum)
+		return false
+	}
+	return true
+}
+
+// verifyValidatorSignature checks that an authorized validator signed hash and
+// returns its Standard (65 bytes) hex public key. Revocations depend on the
+// branch, see CheckRevocations.
+func verifyValidatorSignature(validator, signature, hash []byte) (string, bool) {
+	if len(signature) != 64 {
+		fmt.Printf("PoA: Invalid signature length. Expected 64, Got %d\n", len(signature))
+		return "", false
+	}
+
 	// Handle both Raw (64 bytes) and Standard (65 bytes) Public Keys seamlessly
 	var pubKeyBytes []byte
 	var x, y *big.Int
 
-	if len(block.Validator) == 64 {
-		pubKeyBytes = append([]byte{0x04}, block.Validator...)
-		x = new(big.Int).SetBytes(block.Validator[:32])
-		y = new(big.Int).SetBytes(block.Validator[32:])
-	} else if len(block.Validator) == 65 {
-		if block.Validator[0] != 0x04 {
-			fmt.Printf("PoA: Invalid Standard Key Prefix. Expected 0x04, Got 0x%x\n", block.Validator[0])
-			return false
+	if len(validator) == 64 {
+		pubKeyBytes = append([]byte{0x04}, validator...)
+		x = new(big.Int).SetBytes(validator[:32])
+		y = new(big.Int).SetBytes(validator[32:])
+	} else if len(validator) == 65 {
+		if validator[0] != 0x04 {
+			fmt.Printf("PoA: Invalid Standard Key Prefix. Expected 0x04, Got 0x%x\n", validator[0])
+			return "", false
 		}
-		pubKeyBytes = block.Validator
-		x = new(big.Int).SetBytes(block.Validator[1:33])
-		y = new(big.Int).SetBytes(block.Validator[33:])
+		pubKeyBytes = validator
+		x = new(big.Int).SetBytes(validator[1:33])
+		y = new(big.Int).SetBytes(validator[33:])
 	} else {
-		fmt.Printf("PoA: Invalid validator length. Expected 64 or 65, Got %d\n", len(block.Validator))
-		return false
+		fmt.Printf("PoA: Invalid validator length. Expected 64 or 65, Got %d\n", len(validator))
+		return "", false
 	}
 
 	validatorHex := hex.EncodeToString(pubKeyBytes)
 	if !IsAuthorizedValidator(validatorHex) {
 		fmt.Printf("PoA: Validator %s... is not authorized\n", validatorHex[:16])
-		return false
+		return "", false
 	}
 
 	curve := elliptic.P256()
 	pubKey := ecdsa.PublicKey{Curve: curve, X: x, Y: y}
 
-	r := new(big.Int).SetBytes(block.Signature[:32])
-	s := new(big.Int).SetBytes(block.Signature[32:])
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
 
-	if !ecdsa.Verify(&pubKey, block.Hash, r, s) {
-		fmt.Printf("PoA: Block signature verification failed. len(sig)=%d\n", len(block.Signature))
-		return false
+	if !ecdsa.Verify(&pubKey, hash, r, s) {
+		fmt.Printf("PoA: Block signature verification failed. len(sig)=%d\n", len(signature))
+		return "", false
 	}
 
-	return true
+	return validatorHex, true
 }
 
 func GetValidatorHex(w Wallet) string {
 	return hex.EncodeToString(w.PublicKey)
 }
 
+// ValidatorAddress derives the SOLE address of a block validator key.
+// Accepts both Raw (64 bytes) and Standard (65 bytes) public keys.
+func ValidatorAddress(validator []byte) string {
+	switch len(validator) {
+	case 64:
+		return AddressFromPubKeyHash(HashPubKey(append([]byte{0x04}, validator...)))
+	case 65:
+		return AddressFromPubKeyHash(HashPubKey(validator))
+	default:
+		return ""
+	}
+}
+
+// ValidatorPubKeyFromBlock returns the Standard (65 bytes) hex public key of a
+// block validator, accepting Raw (64 bytes) and Standard keys. "" if invalid.
+func ValidatorPubKeyFromBlock(validator []byte) string {
+	switch len(validator) {
+	case 64:
+		return hex.EncodeToString(append([]byte{0x04}, validator...))
+	case 65:
+		return hex.EncodeToString(validator)
+	default:
+		return ""
+	}
+}
+
+// --- Forging Statistics ---
+
+// ValidatorStat summarises the main-chain blocks forged by one validator
+type ValidatorStat struct {
+	PubKey             string  `json:"pubkey"`
+	Address            string  `json:"address"`
+	Authorized         bool    `json:"authorized"`
+	BlocksForged       int     `json:"blocks_forged"`
+	LastHeight         int     `json:"last_height"`
+	LastTimestamp      int64   `json:"last_timestamp"`
+	AvgIntervalSeconds float64 `json:"avg_interval_seconds"`
+
+	firstTimestamp int64
+}
+
+// ValidatorStatsTracker keeps per-validator forging counters for the main chain.
+// It is built once from the chain and then fed each block that extends the tip.
+type ValidatorStatsTracker struct {
+	mux   sync.Mutex
+	stats map[string]*ValidatorStat // Validator pubkey hex -> stats
+}
+
+func NewValidatorStatsTracker() *ValidatorStatsTracker {
+	return &ValidatorStatsTracker{stats: make(map[string]*ValidatorStat)}
+}
+
+// Rebuild rescans the whole main chain (startup and after reorgs)
+func (t *ValidatorStatsTracker) Rebuild(chain *Blockchain) {
+	stats := make(map[string]*ValidatorStat)
+
+	iter := chain.Iterator()
+	for {
+		block := iter.Next()
+		recordValidatorBlock(stats, block)
+		if block.IsGenesis() {
+			break
+		}
+	}
+
+	t.mux.Lock()
+	t.stats = stats
+	t.mux.Unlock()
+}
+
+// Record accounts for a block that was just connected to the tip
+func (t *ValidatorStatsTracker) Record(block *Block) {
+	t.mux.Lock()
+	recordValidatorBlock(t.stats, block)
+	t.mux.Unlock()
+}
+
+func recordValidatorBlock(stats map[string]*ValidatorStat, block *Block) {
+	if block.IsGenesis() {
+		return
+	}
+	pubKey := ValidatorPubKeyFromBlock(block.Validator)
+	if pubKey == "" {
+		return
+	}
+
+	st, ok := stats[pubKey]
+	if !ok {
+		st = &ValidatorStat{PubKey: pubKey, Address: ValidatorAddress(block.Validator), firstTimestamp: block.Timestamp}
+		stats[pubKey] = st
+	}
+	st.BlocksForged++
+	if block.Height > st.LastHeight {
+		st.LastHeight = block.Height
+	}
+	if block.Timestamp > st.LastTimestamp {
+		st.LastTimestamp = block.Timestamp
+	}
+	if block.Timestamp < st.firstTimestamp {
+		st.firstTimestamp = block.Timestamp
+	}
+	if st.BlocksForged > 1 {
+		st.AvgIntervalSeconds = float64(st.LastTimestamp-st.firstTimestamp) / float64(st.BlocksForged-1)
+	}
+}
+
+// Snapshot lists every authorized validator (even with zero blocks, so offline
+// ones stand out) in schedule order, followed by any other keys seen on chain.
+func (t *ValidatorStatsTracker) Snapshot() []ValidatorStat {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	result := make([]ValidatorStat, 0, len(AuthorizedValidators))
+	seen := make(map[string]bool)
+	for _, pubKey := range AuthorizedValidators {
+		st := ValidatorStat{PubKey: pubKey}
+		if existing, ok := t.stats[pubKey]; ok {
+			st = *existing
+		} else if raw, err := hex.DecodeString(pubKey); err == nil {
+			st.Address = ValidatorAddress(raw)
+		}
+		st.Authorized = true
+		result = append(result, st)
+		seen[pubKey] = true
+	}
+
+	var others []ValidatorStat
+	for pubKey, st := range t.stats {
+		if !seen[pubKey] {
+			others = append(others, *st)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].PubKey < others[j].PubKey })
+
+	return append(result, others...)
+}
+
+// --- Signing Coverage ---
+
+// CoverageBlock is who signed one block of a coverage report
+type CoverageBlock struct {
+	Height       int    `json:"height"`
+	Hash         string `json:"hash"`
+	PubKey       string `json:"pubkey"`
+	Address      string `json:"address"`
+	Scheduled    bool   `json:"scheduled"`    // Signed by the round-robin proposer of its height
+	Attestations int    `json:"attestations"` // Co-signatures besides the forger's
+}
+
+// CoverageTally counts the blocks one validator signed in a coverage report
+type CoverageTally struct {
+	PubKey     string  `json:"pubkey"`
+	Address    string  `json:"address"`
+	Authorized bool    `json:"authorized"`
+	Blocks     int     `json:"blocks"`
+	Share      float64 `json:"share"`       // Fraction of the range's blocks, 0-1
+	OnSchedule int     `json:"on_schedule"` // Blocks signed in its own round-robin turn
+	Expected   int     `json:"expected"`    // Blocks the schedule assigns it in the range
+}
+
+// CoverageReport lists which validator signed each main-chain block in
+// [From, To] and tallies them, so a validator forging out of turn stands out
+type CoverageReport struct {
+	From   int             `json:"from"`
+	To     int             `json:"to"`
+	Blocks []CoverageBlock `json:"blocks"` // Ascending height; the genesis block has no signer and is left out
+	Tally  []CoverageTally `json:"tally"`  // Authorized validators in schedule order, then unknown keys
+}
+
+// SigningCoverage reports the main-chain blocks with heights in [from, to].
+// to is capped at the tip height. The height index gives the top of the
+// range, so only blocks inside it are read.
+func SigningCoverage(chain *Blockchain, from, to int) CoverageReport {
+	report := CoverageReport{From: from, To: min(to, chain.GetBestHeight()), Blocks: []CoverageBlock{}, Tally: []CoverageTally{}}
+
+	top, err := chain.HashAtHeight(report.To)
+	if err != nil {
+		top = chain.LastHash // Not indexed: walk down from the tip
+	}
+	iter := &BlockchainIterator{top, chain.Database}
+	for {
+		block := iter.Next()
+		if block.Height < from {
+			break
+		}
+		pubKey := ValidatorPubKeyFromBlock(block.Validator)
+		if block.Height <= report.To && !block.IsGenesis() && pubKey != "" {
+			report.Blocks = append(report.Blocks, CoverageBlock{
+				Height:       block.Height,
+				Hash:         hex.EncodeToString(block.Hash),
+				PubKey:       pubKey,
+				Address:      ValidatorAddress(block.Validator),
+				Scheduled:    pubKey == ScheduledValidator(block.Height),
+				Attestations: len(block.Attestations),
+			})
+		}
+		if block.IsGenesis() {
+			break
+		}
+	}
+	sort.Slice(report.Blocks, func(i, j int) bool { return report.Blocks[i].Height < report.Blocks[j].Height })
+
+	tallies := make(map[string]*CoverageTally)
+	for _, pubKey := range AuthorizedValidators {
+		t := &CoverageTally{PubKey: pubKey, Authorized: true}
+		if raw, err := hex.DecodeString(pubKey); err == nil {
+			t.Address = ValidatorAddress(raw)
+		}
+		tallies[pubKey] = t
+	}
+	for h := max(from, 1); h <= report.To; h++ {
+		if t, ok := tallies[ScheduledValidator(h)]; ok {
+			t.Expected++
+		}
+	}
+	var others []string
+	for _, b := range report.Blocks {
+		t, ok := tallies[b.PubKey]
+		if !ok {
+			t = &CoverageTally{PubKey: b.PubKey, Address: b.Address}
+			tallies[b.PubKey] = t
+			others = append(others, b.PubKey)
+		}
+		t.Blocks++
+		if b.Scheduled {
+			t.OnSchedule++
+		}
+	}
+	sort.Strings(others)
+
+	for _, pubKey := range append(append([]string{}, AuthorizedValidators...), others...) {
+		t := tallies[pubKey]
+		if len(report.Blocks) > 0 {
+			t.Share = float64(t.Blocks) / float64(len(report.Blocks))
+		}
+		report.Tally = append(report.Tally, *t)
+	}
+	return report
+}
+
 // --- PoA Hardening: Temporal Validation & Anti-Spam ---
 
 const (
@@ -145,7 +470,33 @@ func CheckProofOfWork(hash []byte) bool {
 	return true
 }
 
+// ValidateOrphanHeader runs the header checks that don't need the parent:
+// the hash commits to the content and meets the PoW target, the timestamp
+// isn't too far ahead, and a quorum of authorized validators signed it.
+func ValidateOrphanHeader(block *Block) error {
+	if !bytes.Equal(block.HeaderHash(), block.Hash) {
+		return fmt.Errorf("hash %x doesn't match the block's content", block.Hash)
+	}
+	if !CheckProofOfWork(block.Hash) {
+		return fmt.Errorf("invalid PoA Proof-of-Work (Hash: %x)", block.Hash)
+	}
+	now := time.Now().Unix()
+	if block.Timestamp > now+int64(DriftTolerance.Seconds()) {
+		return fmt.Errorf("timestamp too far in future (Block: %d, Now: %d, Limit: %d)", block.Timestamp, now, int64(DriftTolerance.Seconds()))
+	}
+	if !VerifyBlockSignature(block) {
+		return errors.New("invalid PoA signature")
+	}
+	return nil
+}
+
 func ValidateBlockHeader(block *Block, prevBlock *Block) error {
+	// 0. Height: exactly one above the parent. A gap would let a single block
+	// jump the tip and leave holes in the height index.
+	if block.Height != prevBlock.Height+1 {
+		return fmt.Errorf("height mismatch: expected %d, got %d", prevBlock.Height+1, block.Height)
+	}
+
 	// 1. Monotonic Timestamp
 	if block.Timestamp <= prevBlock.Timestamp {
 		return fmt.Errorf("timestamp is not monotonic (Current: %d, Prev: %d)", block.Timestamp, prevBlock.Timestamp)
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func TestSigningCoverageReadsOnlyItsRange(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	other, _ := newValidator(t)
+	withValidators(t, 1, w, other)
+	for range 5 {
+		addTestBlock(t, chain, w)
+	}
+
+	// The blocks between the range and the tip are never read
+	above, err := chain.HashAtHeight(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set(above, []byte("garbage"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	report := SigningCoverage(chain, 2, 3)
+	if len(report.Blocks) != 2 || report.Blocks[0].Height != 2 || report.Blocks[1].Height != 3 {
+		t.Fatalf("blocks %+v, want heights 2 and 3", report.Blocks)
+	}
+	if len(report.Tally) != 2 || report.Tally[0].Blocks != 2 || report.Tally[0].Share != 1 || report.Tally[1].Blocks != 0 {
+		t.Fatalf("tally %+v", report.Tally)
+	}
+	if report.Tally[0].Expected+report.Tally[1].Expected != 2 {
+		t.Fatalf("tally %+v expects %d blocks, want 2", report.Tally, report.Tally[0].Expected+report.Tally[1].Expected)
+	}
+}
+
+func TestValidateBlockHeaderRejectsHeightGap(t *testing.T) {
+	chain := newTestChain(t)
+	w, key := newValidator(t)
+	withValidators(t, 1, w)
+	parent := addTestBlock(t, chain, w)
+
+	atHeight := func(height int) *Block {
+		block := buildBlock(t, parent, w)
+		block.Height = height
+		MineBlock(block)
+		if err := SignBlock(block, key); err != nil {
+			t.Fatal(err)
+		}
+		return block
+	}
+
+	if err := ValidateBlockHeader(atHeight(parent.Height+1), parent); err != nil {
+		t.Fatalf("child at the next height: %v", err)
+	}
+	for _, height := range []int{parent.Height + 2, parent.Height + 100, parent.Height, parent.Height - 1} {
+		if err := ValidateBlockHeader(atHeight(height), parent); err == nil {
+			t.Fatalf("child of height %d at height %d accepted", parent.Height, height)
+		}
+	}
+
+	// A gap must not move the tip or leave a hole in the height index
+	if chain.AddBlock(atHeight(parent.Height + 5)) {
+		t.Fatal("block skipping heights stored")
+	}
+	if got := chain.GetBestHeight(); got != parent.Height {
+		t.Fatalf("tip at height %d, want %d", got, parent.Height)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/viper"
+)
+
+// dryRunNode checks the node start configuration without starting P2P, the
+// API or forging, then prints what the node would do. Every problem is
+// reported, not just the first. Returns false if any was found.
+func dryRunNode() bool {
+	nodePort := viper.GetInt("node.port")
+	nodeListen := splitList(strings.Join(viper.GetStringSlice("node.listen"), ","))
+	nodeTransport := strings.ToLower(strings.TrimSpace(viper.GetString("node.transport")))
+	apiPort := viper.GetInt("api.port")
+	apiListen := viper.GetString("api.listen")
+	apiDisabled := viper.GetBool("api.disabled")
+	pruneKeep := viper.GetInt("node.prune")
+	rewardAddress := strings.TrimSpace(viper.GetString("node.reward_address"))
+	verifyChain := viper.GetString("node.verify_chain")
+
+	var problems, warnings []string
+	problem := func(format string, a ...any) { problems = append(problems, fmt.Sprintf(format, a...)) }
+	rows := [][2]string{{"Version", VersionString()}, {"Network", ""}}
+
+	// Database: must exist and not be held by a running node
+	var chain *Blockchain
+	if !DBExists() {
+		problem("Database not found in %s. Run 'chain init' first.", dbPath)
+	} else if db, err := badger.Open(getBadgerOptions(dbPath, true)); err != nil {
+		problem("Database can't be opened (is a node already running?): %v", err)
+	} else {
+		defer db.Close()
+		chain = &Blockchain{Database: db}
+		if err := chain.LoadPreset(); err != nil {
+			problem("Genesis preset can't be applied: %v", err)
+		}
+	}
+	rows[1][1] = NetworkID // The preset, if any, is applied now
+	nextHeight := -1
+	revoked := map[string]int{}
+	if chain != nil {
+		if tip, err := chainTipHeight(chain); err != nil {
+			problem("Chain tip can't be read: %v", err)
+		} else {
+			nextHeight = tip + 1
+			if revoked, err = chain.Revoked(); err != nil {
+				problem("Validator revocations can't be read: %v", err)
+			}
+			if err := chain.CheckLightMode(viper.GetBool("node.light")); err != nil {
+				problem("%v.", err)
+			}
+		}
+	}
+
+	// Numeric and enum settings, as node start checks them
+	problems = append(problems, validateNodeConfig()...)
+
+	// P2P listen addresses and ports
+	if _, err := ListenMultiaddrs(nodeListen, nodePort, nodeTransport); err != nil {
+		problem("Invalid listen address: %v", err)
+	} else {
+		for _, host := range nodeListen {
+			addr := net.JoinHostPort(host, strconv.Itoa(nodePort))
+			if nodeTransport != TransportQUIC {
+				if err := checkPortFree("tcp", addr); err != nil {
+					problem("P2P port %s/tcp is not available: %v", addr, err)
+				}
+			}
+			if nodeTransport == TransportQUIC || nodeTransport == TransportBoth {
+				if err := checkPortFree("udp", addr); err != nil {
+					problem("P2P port %s/udp is not available: %v", addr, err)
+				}
+			}
+			rows = append(rows, [2]string{"Listen", fmt.Sprintf("%s (%s)", addr, transportLabel(nodeTransport))})
+		}
+	}
+
+	// Announce addresses
+	announce, err := PublicAnnounceAddrs(ServerConfig{
+		Transport: nodeTransport,
+		Port:      nodePort,
+		PublicIP:  viper.GetString("network.public_ip"),
+		PublicDNS: viper.GetString("network.public_dns"),
+	})
+	if err != nil {
+		problem("Invalid public announce address: %v", err)
+	}
+	for _, addr := range announce {
+		rows = append(rows, [2]string{"Announce", addr.String()})
+	}
+
+	// Bootnodes
+	bootnodes := splitList(viper.GetString("network.bootnodes"))
+	for _, addr := range bootnodes {
+		if _, err := ParseBootnode(addr); err != nil {
+			problem("Invalid bootnode %s: %v", addr, err)
+		}
+	}
+	if len(bootnodes) == 0 {
+		rows = append(rows, [2]string{"Bootnodes", fmt.Sprintf("default (%d)", len(DefaultBootnodes))})
+	} else {
+		rows = append(rows, [2]string{"Bootnodes", strconv.Itoa(len(bootnodes))})
+	}
+	if !viper.GetBool("network.mdns") {
+		rows = append(rows, [2]string{"mDNS", "off"})
+	}
+
+	// API
+	if apiDisabled {
+		rows = append(rows, [2]string{"API", "disabled"})
+	} else {
+		if path, ok := strings.CutPrefix(apiListen, unixSocketPrefix); ok {
+			if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+				problem("API socket directory %s does not exist.", filepath.Dir(path))
+			}
+			if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket == 0 {
+				problem("API socket path %s exists and is not a socket.", path)
+			}
+		} else if net.ParseIP(apiListen) == nil {
+			problem("Invalid --api-listen %q (an IP, or unix:<path>).", apiListen)
+		} else if err := checkPortFree("tcp", net.JoinHostPort(apiListen, strconv.Itoa(apiPort))); err != nil {
+			problem("API port %d is not available: %v", apiPort, err)
+		}
+		rows = append(rows, [2]string{"API", APIEndpoint(apiListen, apiPort)})
+	}
+
+	// Validator keys: in the wallet, valid and authorized for the next block
+	minerAddrs := splitList(viper.GetString("node.miner"))
+	if len(minerAddrs) == 0 {
+		rows = append(rows, [2]string{"Forging", "disabled"})
+	} else {
+		wallets, err := CreateWallets()
+		if err != nil {
+			problem("%s can't be loaded: %v", walletFile, err)
+			wallets = &Wallets{}
+		}
+		var validators []string
+		for _, addr := range minerAddrs {
+			wallet := wallets.GetWalletRef(addr)
+			if wallet == nil {
+				problem("Validator %s: Private Key not found in the wallet.", addr)
+				continue
+			}
+			if _, err := wallet.GetPrivateKey(); err != nil {
+				problem("Validator %s: Private Key not valid: %v", addr, err)
+				continue
+			}
+			pubKeyHex := GetValidatorHex(*wallet)
+			if !IsAuthorizedValidator(pubKeyHex) {
+				problem("Validator %s is not an Authorized Validator.", addr)
+				continue
+			}
+			if _, gone := revoked[pubKeyHex]; gone {
+				problem("Validator %s has been revoked.", addr)
+				continue
+			}
+			validators = append(validators, addr)
+		}
+		rows = append(rows, [2]string{"Forging", fmt.Sprintf("enabled (quorum %d)", BlockQuorum)})
+		for _, addr := range validators {
+			rows = append(rows, [2]string{"Validator", addr})
+		}
+		if len(validators) > 0 && len(validators) < BlockQuorum {
+			warnings = append(warnings, fmt.Sprintf("Blocks need %d validator signatures but only %d key(s) would be loaded: this node won't forge.", BlockQuorum, len(validators)))
+		}
+	}
+	if rewardAddress != "" && ValidateAddress(rewardAddress) {
+		if len(minerAddrs) == 0 {
+			warnings = append(warnings, "--reward-address is ignored because forging is disabled (no --miner).")
+		} else {
+			rows = append(rows, [2]string{"Rewards to", rewardAddress})
+		}
+	}
+
+	// P2P identity: reported, never generated here
+	if data, err := os.ReadFile(NodeKeyFile); err != nil {
+		rows = append(rows, [2]string{"PeerID", "new (" + NodeKeyFile + " will be created)"})
+	} else if key, err := crypto.UnmarshalPrivateKey(data); err != nil {
+		problem("Node key %s is corrupt: %v", NodeKeyFile, err)
+	} else if id, err := peer.IDFromPrivateKey(key); err == nil {
+		rows = append(rows, [2]string{"PeerID", id.String()})
+	}
+
+	dataDir := dbPath
+	if abs, err := filepath.Abs(dbPath); err == nil {
+		dataDir = abs
+	}
+	if pruneKeep > 0 {
+		dataDir += fmt.Sprintf(" (pruned, last %d blocks)", pruneKeep)
+	}
+	if viper.GetBool("node.light") {
+		dataDir += " (light, headers only)"
+	}
+	if nextHeight > 0 {
+		rows = append(rows, [2]string{"Tip", fmt.Sprintf("height %d", nextHeight-1)})
+	}
+	rows = append(rows, [2]string{"Data dir", dataDir})
+	if verifyChain != "" {
+		rows = append(rows, [2]string{"Verify chain", verifyChain})
+	}
+
+	fmt.Println()
+	PrintSummaryBox(fmt.Sprintf("🔎 Dry run: SOLE node on port %d", nodePort), rows)
+	fmt.Println()
+
+	for _, w := range warnings {
+		fmt.Printf("⚠️  %s\n", w)
+	}
+	if len(problems) > 0 {
+		fmt.Printf(ColorRed+"⛔ %d problem(s) found:"+ColorReset+"\n", len(problems))
+		for _, p := range problems {
+			fmt.Printf("  - %s\n", p)
+		}
+		return false
+	}
+	fmt.Println(ColorGreen + "✅ Configuration OK. Run the same command without --dry-run to start the node." + ColorReset)
+	return true
+}
+
+// checkPortFree binds addr briefly to see whether the node could listen on it
+func checkPortFree(network, addr string) error {
+	if network == "udp" {
+		conn, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	return ln.Close()
+}
+
+func transportLabel(transport string) string {
+	if transport == "" {
+		return TransportTCP
+	}
+	return transport
+}
+
+// chainTipHeight reads the tip height from a database opened outside
+// ContinueBlockchain, and points chain.LastHash at the tip
+func chainTipHeight(chain *Blockchain) (int, error) {
+	var height int
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("lh"))
+		if err != nil {
+			return err
+		}
+		lastHash, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if item, err = txn.Get(lastHash); err != nil {
+			return err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		block := DeserializeBlock(data)
+		if block == nil {
+			return fmt.Errorf("tip block cannot be decoded")
+		}
+		height = block.Height
+		chain.LastHash = lastHash
+		return nil
+	})
+	return height, err
+}
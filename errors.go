@@ -0,0 +1,94 @@
+package main
+
+import "errors"
+
+// Transaction pipeline errors. Build/verify functions wrap these with
+// fmt.Errorf("%w: ...") so callers can branch with errors.Is.
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidSignature  = errors.New("invalid signature")
+	ErrDoubleSpend       = errors.New("double spend")
+	ErrUnknownInput      = errors.New("unknown input")
+	ErrDustOutput        = errors.New("dust output")
+	ErrFeeMismatch       = errors.New("fee mismatch")
+	ErrUnconfirmedInput  = errors.New("input not yet confirmed") // Too many mempool generations deep
+)
+
+// txErrorKinds maps each sentinel to its API code, HTTP status and CLI exit code
+var txErrorKinds = []struct {
+	err      error
+	code     string
+	status   int
+	exitCode int
+}{
+	{ErrInsufficientFunds, "insufficient_funds", 400, 2},
+	{ErrInvalidSignature, "invalid_signature", 400, 3},
+	{ErrDoubleSpend, "double_spend", 409, 4},
+	{ErrUnknownInput, "unknown_input", 422, 5},
+	{ErrDustOutput, "dust_output", 400, 6},
+	{ErrFeeMismatch, "fee_mismatch", 400, 7},
+	{ErrUnconfirmedInput, "unconfirmed_input", 409, 8},
+}
+
+// API error codes for failures outside the transaction pipeline. Every
+// ErrorResponse carries one, so clients can branch without parsing messages.
+const (
+	CodeInvalidRequest  = "invalid_request" // Malformed path parameter, query or body
+	CodeInvalidAddress  = "invalid_address"
+	CodeNotFound        = "not_found"
+	CodePruned          = "pruned"        // Data discarded by --prune
+	CodeReadOnly        = "read_only"     // Write endpoint on node serve-api
+	CodeAlreadyKnown    = "already_known" // Block or transaction submitted twice
+	CodeTxInvalid       = "tx_invalid"    // Rejected transaction without a more specific code
+	CodeTxTooLarge      = "tx_too_large"
+	CodeFeeTooHigh      = "fee_too_high"     // Above the node's ceiling, see allow_high_fee
+	CodeMempoolRejected = "mempool_rejected" // Mempool or waiting pool can't take it
+	CodeBlockInvalid    = "block_invalid"
+	CodeRevocationBad   = "revocation_invalid" // Bad or under-signed validator revocation
+	CodeRateLimited     = "rate_limited"
+	CodeInternal        = "internal_error"
+
+	// node start --light
+	CodeLightNode        = "light_node"        // Needs the UTXO set, tx index or mempool, which it lacks
+	CodeBlockUnavailable = "block_unavailable" // No peer supplied the block in time
+)
+
+// TxErrorCode returns the stable API code of a pipeline error ("" if untyped)
+func TxErrorCode(err error) string {
+	for _, k := range txErrorKinds {
+		if errors.Is(err, k.err) {
+			return k.code
+		}
+	}
+	return ""
+}
+
+// TxErrorFromCode returns the sentinel matching an API code, or nil
+func TxErrorFromCode(code string) error {
+	for _, k := range txErrorKinds {
+		if k.code == code {
+			return k.err
+		}
+	}
+	return nil
+}
+
+// TxErrorHTTPStatus returns the HTTP status for a pipeline error (400 if untyped)
+func TxErrorHTTPStatus(err error) int {
+	for _, k := range txErrorKinds {
+		if errors.Is(err, k.err) {
+			return k.status
+		}
+	}
+	return 400
+}
+
+// TxErrorExitCode returns the CLI exit code for a pipeline error (1 if untyped)
+func TxErrorExitCode(err error) int {
+	for _, k := range txErrorKinds {
+		if errors.Is(err, k.err) {
+			return k.exitCode
+		}
+	}
+	return 1
+}
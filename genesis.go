@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 )
 
@@ -9,25 +10,90 @@ const (
 	GenesisCoinbaseData = "Lu sule, lu mare, lu ientu. Unisalento 2026."
 	GenesisAdminAddress = "1HSYNy8yXUuUZrkBCnzSc34Lqr8soPAKQL"
 	GenesisReward       = 5000000
+
+	MainnetNetworkID = "sole-mainnet"
 )
 
+// NetworkID names the chain this node runs: mainnet, or a genesis preset's
+var NetworkID = MainnetNetworkID
+
+// GenesisAllocation is one premine output of the genesis coinbase
+type GenesisAllocation struct {
+	Address string
+	Amount  int64 // Photons
+}
+
+// GenesisAllocations splits the premine across several addresses (the
+// genesis.allocations config list). Empty means the whole premine goes to
+// GenesisAdminAddress. The list, in order, is part of the genesis hash, so
+// every node of a network must use the same one.
+var GenesisAllocations []GenesisAllocation
+
+// ValidateGenesisAllocations checks that allocs pay valid, distinct addresses
+// and add up to exactly the premine
+func ValidateGenesisAllocations(allocs []GenesisAllocation) error {
+	premine := int64(GenesisReward * 100000000)
+	seen := make(map[string]bool)
+	var total int64
+
+	for i, a := range allocs {
+		if !ValidateAddress(a.Address) {
+			return fmt.Errorf("allocation %d: invalid address %q", i+1, a.Address)
+		}
+		if seen[a.Address] {
+			return fmt.Errorf("allocation %d: address %s is listed twice", i+1, a.Address)
+		}
+		seen[a.Address] = true
+		if a.Amount < DefaultDustLimit {
+			return fmt.Errorf("allocation %d: amount %d Photons is below the dust limit", i+1, a.Amount)
+		}
+		total += a.Amount
+	}
+
+	if len(allocs) > 0 && total != premine {
+		return fmt.Errorf("allocations add up to %d Photons, the premine is %d", total, premine)
+	}
+	return nil
+}
+
 func NewGenesisBlock() *Block {
-	// Deserialize address
-	pubKeyHash, err := ExtractPubKeyHash(GenesisAdminAddress)
-	if err != nil {
-		log.Panic("Invalid Genesis Admin Address:", err)
+	allocations := GenesisAllocations
+	if len(allocations) == 0 {
+		allocations = []GenesisAllocation{{GenesisAdminAddress, int64(GenesisReward * 100000000)}} // 5M * 10^8
+	}
+
+	var outputs []TxOutput
+	for _, a := range allocations {
+		// Deserialize address
+		pubKeyHash, err := ExtractPubKeyHash(a.Address)
+		if err != nil {
+			log.Panic("Invalid Genesis Address:", err)
+		}
+		outputs = append(outputs, TxOutput{Value: a.Amount, PubKeyHash: pubKeyHash})
 	}
 
 	// Create Coinbase Transaction manually
-	txin := TxInput{[]byte{}, -1, nil, []byte(GenesisCoinbaseData)}
-	txout := NewTxOutput(int64(GenesisReward*100000000), GenesisAdminAddress) // 5M * 10^8
-	txout.PubKeyHash = pubKeyHash
-	coinbase := &Transaction{[]byte("SOLE_GENESIS_TX_ID"), []TxInput{txin}, []TxOutput{*txout}, int64(GenesisTimestamp)}
-
-	// Hash is usually set by Hash(), but we want fixed ID
-	// Check if Hash() logic in transaction.go is compatible or if we force it.
-	// The prompt says: "La Transazione Coinbase deve avere un ID fisso... []byte("SOLE_GENESIS_TX_ID")"
-	// So we just set it.
+	// A preset's name is part of its genesis, so no two presets (or a preset
+	// and a custom allocation list) ever share a chain
+	coinbaseData := GenesisCoinbaseData
+	if ActivePreset != "" {
+		coinbaseData += " [" + ActivePreset + "]"
+	}
+	txin := TxInput{[]byte{}, -1, nil, []byte(coinbaseData)}
+	coinbase := &Transaction{[]byte("SOLE_GENESIS_TX_ID"), []TxInput{txin}, outputs, int64(GenesisTimestamp), 0, 0}
+
+	// The official coinbase has a fixed ID instead of its hash. It is still an
+	// ordinary byte string everywhere: the UTXO set, the tx- index and
+	// FindSpendableOutputs all key it by hex.EncodeToString(ID)
+	// ("534f4c455f47454e455349535f54585f4944"), so the premine is spent like
+	// any other output.
+
+	// The fixed ID doesn't cover the outputs, so a custom allocation list
+	// would leave the genesis hash unchanged. Use the content hash instead,
+	// which pins the list into the block hash.
+	if len(GenesisAllocations) > 0 {
+		coinbase.ID = coinbase.Hash()
+	}
 
 	// Create Block
 	block := &Block{
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/cobra"
+)
+
+// headersTimeout is how long node verify-headers waits for each batch
+const headersTimeout = 30 * time.Second
+
+// GetHeaders asks a peer for its main chain from FromHeight up, headers only
+type GetHeaders struct {
+	AddrFrom   string
+	FromHeight int
+}
+
+// HeadersMsg answers GetHeaders with up to maxHeadersPerMsg header-only
+// blocks (see HeaderOnly) in canonical format, lowest height first. Fewer
+// than maxHeadersPerMsg means the sender's tip was reached.
+type HeadersMsg struct {
+	AddrFrom string
+	Headers  [][]byte
+}
+
+func (s *Server) HandleGetHeaders(request []byte, peerID peer.ID) {
+	var payload GetHeaders
+	dec := gob.NewDecoder(bytes.NewReader(request))
+	if err := dec.Decode(&payload); err != nil {
+		log.Printf("⚠️ HandleGetHeaders: gob decode error from %s: %v", ShortID(peerID.String()), err)
+		return
+	}
+	if payload.FromHeight < 0 {
+		return
+	}
+	if !s.peerSupports(peerID, ProtocolHeaders) {
+		log.Printf("⚠️ HandleGetHeaders: %s negotiated a protocol below v%d, ignoring", ShortID(peerID.String()), ProtocolHeaders)
+		return
+	}
+
+	headers := s.Blockchain.HeadersFrom(payload.FromHeight, maxHeadersPerMsg)
+	s.SendHeaders(peerID, headers)
+}
+
+func (s *Server) SendHeaders(peerID peer.ID, headers [][]byte) {
+	payload := GobEncode(HeadersMsg{s.Host.ID().String(), headers})
+	request := append(CommandToBytes("headers"), payload...)
+	s.SendData(peerID, request)
+}
+
+// HeadersFrom returns the main-chain headers from height from up, at most
+// max of them, lowest height first and canonically encoded. They are read
+// through the height index, in one transaction so a reorg can't mix
+// branches.
+func (chain *Blockchain) HeadersFrom(from, max int) [][]byte {
+	var headers [][]byte
+	chain.Database.View(func(txn *badger.Txn) error {
+		for h := from; h < from+max; h++ {
+			item, err := txn.Get(heightKey(h))
+			if err != nil {
+				return nil // above the tip
+			}
+			hash, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if item, err = txn.Get(hash); err != nil {
+				return err
+			}
+			data, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			block := DeserializeBlock(data)
+			if block == nil {
+				return fmt.Errorf("undecodable block %x", hash)
+			}
+			headers = append(headers, block.HeaderOnly().SerializeCanonical())
+		}
+		return nil
+	})
+	return headers
+}
+
+// VerifyHeaders checks that headers extend parent one block at a time: each
+// links to the previous one, hashes to its own hash, passes
+// ValidateBlockHeader and carries the PoA signatures BlockQuorum requires.
+// Transactions aren't needed: the header commits to their merkle root.
+// revoked holds the keys revoked up to parent and is updated with the
+// revocations the headers carry.
+func VerifyHeaders(parent *Block, headers []*Block, revoked map[string]int) error {
+	for _, h := range headers {
+		if !h.Pruned {
+			return fmt.Errorf("header %d: full block sent instead of a header", h.Height)
+		}
+		if !bytes.Equal(h.PrevBlockHash, parent.Hash) {
+			return fmt.Errorf("header %d: does not link to block %d (%x)", h.Height, parent.Height, parent.Hash)
+		}
+		if recomputed := h.HeaderHash(); !bytes.Equal(recomputed, h.Hash) {
+			return fmt.Errorf("header %d: hash mismatch: header hashes to %x", h.Height, recomputed)
+		}
+		if err := ValidateBlockHeader(h, parent); err != nil {
+			return fmt.Errorf("header %d: %w", h.Height, err)
+		}
+		if !VerifyBlockSignature(h) {
+			return fmt.Errorf("header %d: invalid PoA signature", h.Height)
+		}
+		if err := CheckRevocations(h, revoked); err != nil {
+			return fmt.Errorf("header %d: %w", h.Height, err)
+		}
+		parent = h
+	}
+	return nil
+}
+
+// runVerifyHeaders is a light client check of a peer's chain: it downloads
+// the headers (getheaders) and verifies them from the local genesis to the
+// peer's tip, with no database and no transactions. Headers carry the
+// validator revocations, so a revoked key is rejected as on a full node.
+func runVerifyHeaders(cmd *cobra.Command, args []string) {
+	if presetFlag != "" {
+		preset, err := FindGenesisPreset(presetFlag)
+		if err == nil {
+			err = preset.Validate()
+		}
+		if err != nil {
+			fmt.Printf("⛔ ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		preset.Apply()
+	}
+
+	peers := splitList(peerFlag)
+	if len(peers) == 0 {
+		peers = DefaultBootnodes
+	}
+
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	if err != nil {
+		fmt.Printf("⛔ ERROR: Failed to start libp2p host: %v\n", err)
+		os.Exit(1)
+	}
+	defer h.Close()
+
+	// Answers come back on streams the peer opens, like every other reply
+	batches := make(chan [][]byte, 1)
+	versions := make(chan int, 1)
+	h.SetStreamHandler(protocolID, func(stream network.Stream) {
+		defer stream.Close()
+		stream.SetReadDeadline(time.Now().Add(headersTimeout))
+		payload, err := readP2PFrame(stream)
+		if err != nil || len(payload) < commandLength {
+			return
+		}
+		switch BytesToCommand(payload[:commandLength]) {
+		case "version":
+			var msg Version
+			if err := gob.NewDecoder(bytes.NewReader(payload[commandLength:])).Decode(&msg); err != nil {
+				return
+			}
+			select {
+			case versions <- msg.Version:
+			default:
+			}
+		case "headers":
+			var msg HeadersMsg
+			if err := gob.NewDecoder(bytes.NewReader(payload[commandLength:])).Decode(&msg); err != nil {
+				return
+			}
+			select {
+			case batches <- msg.Headers:
+			default:
+			}
+		}
+	})
+
+	var from *peer.AddrInfo
+	for _, addr := range peers {
+		pi, err := ParseBootnode(addr)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping %s: %v\n", addr, err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), DefaultDialTimeout)
+		err = h.Connect(ctx, *pi)
+		cancel()
+		if err != nil {
+			fmt.Printf("⚠️  Could not reach %s: %v\n", ShortID(pi.ID.String()), err)
+			continue
+		}
+		from = pi
+		break
+	}
+	if from == nil {
+		fmt.Println("⛔ ERROR: No peer reachable.")
+		os.Exit(1)
+	}
+
+	// Handshake first: peers only answer getheaders once v2 is negotiated.
+	// Height 0 keeps the peer from trying to sync from us.
+	hello := append(CommandToBytes("version"), GobEncode(Version{Version: ProtocolVersion, AddrFrom: h.ID().String()})...)
+	if err := sendP2PFrame(cmd.Context(), h, from.ID, hello); err != nil {
+		fmt.Printf("⛔ ERROR: Handshake with %s failed: %v\n", ShortID(from.ID.String()), err)
+		os.Exit(1)
+	}
+	select {
+	case v := <-versions:
+		if v < ProtocolHeaders {
+			fmt.Printf("⛔ ERROR: %s speaks protocol v%d; header sync needs v%d (upgrade the node).\n", ShortID(from.ID.String()), v, ProtocolHeaders)
+			os.Exit(1)
+		}
+	case <-time.After(headersTimeout):
+		fmt.Printf("⛔ ERROR: No handshake from %s after %s.\n", ShortID(from.ID.String()), headersTimeout)
+		os.Exit(1)
+	}
+	fmt.Printf("🔗 Downloading headers from %s...\n", ShortID(from.ID.String()))
+
+	genesis := NewGenesisBlock()
+	var parent *Block
+	revoked := make(map[string]int)
+	verified := 0
+	for {
+		next := 0
+		if parent != nil {
+			next = parent.Height + 1
+		}
+		request := append(CommandToBytes("getheaders"), GobEncode(GetHeaders{h.ID().String(), next})...)
+		if err := sendP2PFrame(cmd.Context(), h, from.ID, request); err != nil {
+			fmt.Printf("⛔ ERROR: Failed to request headers: %v\n", err)
+			os.Exit(1)
+		}
+
+		var batch [][]byte
+		select {
+		case batch = <-batches:
+		case <-time.After(headersTimeout):
+			fmt.Printf("⛔ ERROR: No headers from %s after %s.\n", ShortID(from.ID.String()), headersTimeout)
+			os.Exit(1)
+		}
+
+		headers := make([]*Block, 0, len(batch))
+		for _, data := range batch {
+			header, err := DeserializeBlockCanonical(data)
+			if err != nil {
+				fmt.Printf("⛔ ERROR: Undecodable header after height %d: %v\n", next-1, err)
+				os.Exit(1)
+			}
+			headers = append(headers, header)
+		}
+
+		if parent == nil {
+			if len(headers) == 0 || !headers[0].IsGenesis() || !bytes.Equal(headers[0].Hash, genesis.Hash) ||
+				!bytes.Equal(headers[0].HeaderHash(), genesis.Hash) {
+				fmt.Println("⛔ ERROR: The peer's genesis differs from ours: it is on another network (see --preset).")
+				os.Exit(1)
+			}
+			parent = headers[0]
+			headers = headers[1:]
+			verified++
+		}
+		if err := VerifyHeaders(parent, headers, revoked); err != nil {
+			fmt.Printf(ColorRed+"⛔ Header chain verification FAILED: %v"+ColorReset+"\n", err)
+			os.Exit(1)
+		}
+		if len(headers) > 0 {
+			parent = headers[len(headers)-1]
+			verified += len(headers)
+			fmt.Printf("   ... %d headers verified\n", verified)
+		}
+		if len(batch) < maxHeadersPerMsg {
+			break
+		}
+	}
+
+	fmt.Printf(ColorGreen+"✅ Header chain verified: %d block(s), genesis to tip."+ColorReset+"\n", verified)
+	fmt.Printf("- Tip: height %d, %x\n", parent.Height, parent.Hash)
+	if parent.Height > 0 {
+		fmt.Printf("- Forged: %s by %s\n", time.Unix(parent.Timestamp, 0).Format(time.RFC3339), ValidatorAddress(parent.Validator))
+	}
+}
+
+// readP2PFrame reads one length-prefixed message as ReadData does,
+// decompressing it if needed
+func readP2PFrame(r io.Reader) ([]byte, error) {
+	lenBuf := make([]byte, 4)
+	if _, err := io.ReadFull(r, lenBuf); err != nil {
+		return nil, err
+	}
+	payloadLen := binary.BigEndian.Uint32(lenBuf)
+	if payloadLen == 0 || payloadLen > maxP2PPayload {
+		return nil, fmt.Errorf("invalid payload length %d", payloadLen)
+	}
+	payload := make([]byte, payloadLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if payload[0] == compressedFrame {
+		return decompressPayload(payload)
+	}
+	return payload, nil
+}
+
+// sendP2PFrame writes one uncompressed message on a new stream, as SendData
+// does for a peer that didn't advertise compression
+func sendP2PFrame(ctx context.Context, h host.Host, peerID peer.ID, data []byte) error {
+	stream, err := h.NewStream(ctx, peerID, protocolID)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	frame := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	_, err = stream.Write(append(frame, data...))
+	return err
+}
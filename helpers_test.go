@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// newTestChain creates a fresh chain (genesis only, with its UTXO set) in a
+// temporary working directory. The database is closed on cleanup.
+func newTestChain(t testing.TB) *Blockchain {
+	t.Helper()
+
+	chdirTemp(t)
+	chain, err := InitBlockchain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chain.Database.Close() })
+	// As chain init does: the genesis outputs enter the UTXO set
+	if err := (UTXOSet{chain}).Reindex(); err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+// chdirTemp moves the test into an empty working directory, where the
+// relative data paths (database, wallet.dat, node key) are created
+func chdirTemp(t testing.TB) string {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+// withValidators replaces the authorized validator set (and quorum) for the
+// duration of the test
+func withValidators(t testing.TB, quorum int, wallets ...*Wallet) {
+	t.Helper()
+
+	validators, prevQuorum := AuthorizedValidators, BlockQuorum
+	AuthorizedValidators = nil
+	for _, w := range wallets {
+		AuthorizedValidators = append(AuthorizedValidators, GetValidatorHex(*w))
+	}
+	BlockQuorum = quorum
+	t.Cleanup(func() {
+		AuthorizedValidators, BlockQuorum = validators, prevQuorum
+	})
+}
+
+// newValidator generates a wallet and its signing key
+func newValidator(t testing.TB) (*Wallet, ecdsa.PrivateKey) {
+	t.Helper()
+
+	w, _ := NewWallet()
+	key, err := w.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, key
+}
+
+// buildBlock mines and signs a child of prev holding a coinbase to the
+// signer followed by txs, without storing it. Each block is one second
+// younger than its parent, so a branch can be built ahead of time.
+func buildBlock(t testing.TB, prev *Block, signer *Wallet, txs ...*Transaction) *Block {
+	t.Helper()
+
+	key, err := signer.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbase := NewCoinbaseTX(signer.GetAddress(), fmt.Sprintf("block %d of %x", prev.Height+1, prev.Hash), InitialSubsidy)
+	block := NewBlock(append([]*Transaction{coinbase}, txs...), prev.Hash, prev.Height+1, nil)
+	block.Timestamp = prev.Timestamp + 1
+	MineBlock(block)
+	if err := SignBlock(block, key); err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// tipBlock returns the chain's current tip
+func tipBlock(t testing.TB, chain *Blockchain) *Block {
+	t.Helper()
+
+	block, err := chain.GetBlock(chain.LastHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &block
+}
+
+// newTestHost starts a loopback libp2p host, closed on cleanup
+func newTestHost(t *testing.T, opts ...libp2p.Option) host.Host {
+	t.Helper()
+
+	opts = append([]libp2p.Option{libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0")}, opts...)
+	h, err := libp2p.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// connectHosts dials b from a
+func connectHosts(t *testing.T, a, b host.Host) {
+	t.Helper()
+
+	if err := a.Connect(context.Background(), peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestServer wraps chain in an API-only server listening on a loopback
+// host gated by its ban list, with the same per-command limiters and
+// connection notifications as a node
+func newTestServer(t *testing.T, chain *Blockchain) *Server {
+	t.Helper()
+
+	s := NewAPIOnlyServer(chain)
+	s.Host = newTestHost(t, libp2p.ConnectionGater(s.Bans))
+	s.GetBlocksLimiter = NewIPRateLimiter(getBlocksRate, getBlocksBurst)
+	s.GetDataLimiter = NewIPRateLimiter(getDataRate, getDataBurst)
+	s.GetHeadersLimiter = NewIPRateLimiter(getHeadersRate, getHeadersBurst)
+	s.Host.SetStreamHandler(protocolID, s.HandleStream)
+	s.Host.Network().Notify(&network.NotifyBundle{
+		ConnectedF:    s.PeerConnected,
+		DisconnectedF: s.PeerDisconnected,
+	})
+	return s
+}
+
+// waitFor polls cond for up to five seconds, failing the test with what
+// if it never holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting: %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// loadValidatorKey returns w as a key loaded with --miner
+func loadValidatorKey(t *testing.T, w *Wallet) ValidatorKey {
+	t.Helper()
+
+	key, err := w.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ValidatorKey{Address: w.GetAddress(), PrivKey: &key, PubKeyHex: GetValidatorHex(*w)}
+}
+
+// addTestBlock stores a buildBlock child of the tip and applies it to the UTXO set
+func addTestBlock(t testing.TB, chain *Blockchain, signer *Wallet, txs ...*Transaction) *Block {
+	t.Helper()
+
+	block := buildBlock(t, tipBlock(t, chain), signer, txs...)
+	if !chain.AddBlock(block) {
+		t.Fatalf("block %d rejected", block.Height)
+	}
+	UTXOSet{chain}.Update(block)
+	return block
+}
+
+// spendTx signs a transaction of w spending output vout of prev into
+// outputs. What the outputs leave over is declared as the fee.
+func spendTx(t testing.TB, w *Wallet, prev *Transaction, vout int, outputs ...TxOutput) *Transaction {
+	t.Helper()
+
+	fee := prev.Vout[vout].Value
+	for _, out := range outputs {
+		fee -= out.Value
+	}
+	tx := &Transaction{
+		Vin:       []TxInput{{Txid: prev.ID, Vout: vout, PubKey: w.PublicKey}},
+		Vout:      outputs,
+		Timestamp: time.Now().Unix(),
+		Fee:       fee,
+	}
+
+	key, err := w.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Sign(key, map[string]Transaction{hex.EncodeToString(prev.ID): *prev})
+	tx.ID = tx.Hash() // The ID covers the signatures
+	return tx
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Light mode (node start --light): the node stores the genesis block and
+// then headers only. It follows the header chain with getheaders, and the
+// blocks and transactions the API is asked for are fetched from peers on
+// demand and checked against the stored headers.
+const (
+	// How long an API request waits for a peer to supply a block
+	lightFetchTimeout = 10 * time.Second
+	// A header batch that doesn't link to a known block forks below our tip:
+	// headers are requested again from this many blocks further back
+	lightReorgWindow = 100
+	// Sending a header that fails validation
+	badHeaderPenalty = 50
+)
+
+// ErrBlockUnavailable is returned by a light node when no peer supplied a
+// requested block before lightFetchTimeout
+var ErrBlockUnavailable = errors.New("block unavailable from peers")
+
+// lightKey marks a database written by a light node
+var lightKey = []byte("light")
+
+// IsLight reports whether the database holds headers only past genesis
+func (chain *Blockchain) IsLight() bool {
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(lightKey)
+		return err
+	})
+	return err == nil
+}
+
+// CheckLightMode reports whether the database can be used by a node started
+// with or without --light. A light database has no transactions to run a
+// full node on, and a full one only turns light while it holds nothing but
+// the genesis block.
+func (chain *Blockchain) CheckLightMode(light bool) error {
+	switch {
+	case !light && chain.IsLight():
+		return errors.New("the database holds headers only: start the node with --light, or sync a full node into a new data directory")
+	case light && !chain.IsLight() && chain.GetBestHeight() > 0:
+		return errors.New("--light needs a new database: this one already holds full blocks")
+	}
+	return nil
+}
+
+// MarkLight records that the database holds headers only. It is marked
+// pruned too, so the commands needing every block body refuse it.
+func (chain *Blockchain) MarkLight() error {
+	return chain.Database.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(prunedKey, []byte{1}); err != nil {
+			return err
+		}
+		return txn.Set(lightKey, []byte{1})
+	})
+}
+
+// AddHeader stores a header-only block (see HeaderOnly) on a light node. It
+// passes the checks VerifyHeaders makes against its parent, which must be
+// stored already. As with blocks, the highest header becomes the tip.
+func (chain *Blockchain) AddHeader(header *Block) error {
+	if !header.Pruned {
+		return errors.New("full block given instead of a header")
+	}
+	if chain.HasBlock(header.Hash) {
+		return nil
+	}
+	if len(header.PrevBlockHash) == 0 {
+		return errors.New("header without a parent (foreign genesis block)")
+	}
+
+	chain.Mux.Lock()
+	defer chain.Mux.Unlock()
+
+	parent, err := chain.LoadBlock(header.PrevBlockHash)
+	if err != nil {
+		return fmt.Errorf("parent %x: %w", header.PrevBlockHash, err)
+	}
+	revoked, err := chain.RevokedBy(parent)
+	if err != nil {
+		return err
+	}
+	if err := VerifyHeaders(parent, []*Block{header}, revoked); err != nil {
+		return err
+	}
+
+	var oldTip []byte
+	var extended bool
+	err = chain.Database.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(header.Hash, header.Serialize()); err != nil {
+			return err
+		}
+		if err := indexRevocations(txn, header); err != nil {
+			return err
+		}
+		oldTip, extended, err = chain.connectTip(txn, header)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("saving header: %w", err)
+	}
+	chain.rememberBlock(header.Hash)
+	chain.announceTip(header, oldTip, extended)
+	return nil
+}
+
+// GetTxBlock asks a peer for the block that includes TxID. Peers that don't
+// have it in full stay silent.
+type GetTxBlock struct {
+	AddrFrom string
+	TxID     []byte
+}
+
+func (s *Server) HandleGetTxBlock(request []byte, peerID peer.ID) {
+	var payload GetTxBlock
+	dec := gob.NewDecoder(bytes.NewReader(request))
+	if err := dec.Decode(&payload); err != nil {
+		log.Printf("⚠️ HandleGetTxBlock: gob decode error from %s: %v", ShortID(peerID.String()), err)
+		return
+	}
+	if len(payload.TxID) == 0 || !s.peerSupports(peerID, ProtocolLight) {
+		return
+	}
+
+	block, err := s.Blockchain.FindTransactionBlock(payload.TxID)
+	if err != nil || block.Pruned {
+		return
+	}
+	s.SendBlock(peerID, &block)
+}
+
+func (s *Server) SendGetTxBlock(peerID peer.ID, txID []byte) {
+	payload := GobEncode(GetTxBlock{s.Host.ID().String(), txID})
+	request := append(CommandToBytes("gettxblock"), payload...)
+	s.SendData(peerID, request)
+}
+
+func (s *Server) SendGetHeaders(peerID peer.ID, from int) {
+	payload := GobEncode(GetHeaders{s.Host.ID().String(), from})
+	request := append(CommandToBytes("getheaders"), payload...)
+	s.SendData(peerID, request)
+}
+
+// syncHeaders asks a peer for the headers above our tip
+func (s *Server) syncHeaders(peerID peer.ID) {
+	s.SendGetHeaders(peerID, s.Blockchain.GetBestHeight()+1)
+}
+
+// HandleHeaders extends a light node's header chain. A full batch means the
+// peer has more, so the next one is requested straight away.
+func (s *Server) HandleHeaders(request []byte, peerID peer.ID) {
+	if !s.Light {
+		return
+	}
+	var payload HeadersMsg
+	dec := gob.NewDecoder(bytes.NewReader(request))
+	if err := dec.Decode(&payload); err != nil {
+		log.Printf("⚠️ HandleHeaders: gob decode error from %s: %v", ShortID(peerID.String()), err)
+		return
+	}
+
+	added := 0
+	var last *Block
+	for i, data := range payload.Headers {
+		header, err := DeserializeBlockCanonical(data)
+		if err != nil {
+			fmt.Printf("⛔ [Headers] Undecodable header from %s: %v\n", ShortID(peerID.String()), err)
+			s.PenalizePeer(peerID, badHeaderPenalty)
+			return
+		}
+		last = header
+		if s.Blockchain.HasBlock(header.Hash) {
+			continue
+		}
+		if !s.Blockchain.HasBlock(header.PrevBlockHash) {
+			// The peer's branch forks below our tip
+			if i == 0 && header.Height > 1 {
+				s.SendGetHeaders(peerID, max(1, header.Height-lightReorgWindow))
+			}
+			return
+		}
+		if err := s.Blockchain.AddHeader(header); err != nil {
+			fmt.Printf("⛔ [Headers] Header %d from %s rejected: %v\n", header.Height, ShortID(peerID.String()), err)
+			s.PenalizePeer(peerID, badHeaderPenalty)
+			return
+		}
+		BroadcastBlock(s.BlockHub, header)
+		added++
+	}
+	if last == nil {
+		return
+	}
+
+	s.RecordPeerHeight(peerID, last.Height)
+	if added > 0 {
+		fmt.Printf("📥 [Headers] %d headers from %s, tip at height %d\n", added, ShortID(peerID.String()), s.Blockchain.GetBestHeight())
+	}
+	if len(payload.Headers) >= maxHeadersPerMsg {
+		s.SendGetHeaders(peerID, last.Height+1)
+	}
+}
+
+// handleLightBlock hands a block body a light node asked for to the API
+// requests waiting on it. It must match a stored header: the same hash and
+// a merkle root over transaction IDs that hash their content.
+func (s *Server) handleLightBlock(block *Block, peerID peer.ID) {
+	header, err := s.Blockchain.GetBlock(block.Hash)
+	if err != nil || !header.Pruned || block.Pruned {
+		return
+	}
+	for _, tx := range block.Transactions {
+		if !bytes.Equal(tx.ID, tx.Hash()) {
+			fmt.Printf("⛔ [Light] Block %x from %s: transaction ID %x does not match its content\n", block.Hash, ShortID(peerID.String()), tx.ID)
+			return
+		}
+	}
+
+	full := header
+	full.Pruned = false
+	full.PrunedRoot = nil
+	full.Transactions = block.Transactions
+	if !bytes.Equal(full.HashTransactions(), header.PrunedRoot) {
+		fmt.Printf("⛔ [Light] Block %x from %s: transactions don't match the header\n", block.Hash, ShortID(peerID.String()))
+		return
+	}
+	s.Fetcher.deliver(&full)
+}
+
+// BlockFetcher hands the block bodies a light node receives to the requests
+// waiting for them, by block hash or by the ID of a transaction they hold
+type BlockFetcher struct {
+	mux     sync.Mutex
+	waiting map[string][]chan *Block
+}
+
+func NewBlockFetcher() *BlockFetcher {
+	return &BlockFetcher{waiting: make(map[string][]chan *Block)}
+}
+
+func blockFetchKey(hash []byte) string { return "block:" + hex.EncodeToString(hash) }
+func txFetchKey(txID []byte) string    { return "tx:" + hex.EncodeToString(txID) }
+
+// wait registers interest in key. cancel must be called once done.
+func (f *BlockFetcher) wait(key string) (ch chan *Block, cancel func()) {
+	ch = make(chan *Block, 1)
+	f.mux.Lock()
+	f.waiting[key] = append(f.waiting[key], ch)
+	f.mux.Unlock()
+
+	return ch, func() {
+		f.mux.Lock()
+		defer f.mux.Unlock()
+		waiters := f.waiting[key]
+		for i, c := range waiters {
+			if c == ch {
+				waiters = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(waiters) == 0 {
+			delete(f.waiting, key)
+		} else {
+			f.waiting[key] = waiters
+		}
+	}
+}
+
+// deliver passes block to everyone waiting on it or on one of its
+// transactions. Waiters already served keep their first block.
+func (f *BlockFetcher) deliver(block *Block) {
+	keys := []string{blockFetchKey(block.Hash)}
+	for _, tx := range block.Transactions {
+		keys = append(keys, txFetchKey(tx.ID))
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for _, key := range keys {
+		for _, ch := range f.waiting[key] {
+			select {
+			case ch <- block:
+			default:
+			}
+		}
+	}
+}
+
+// askPeers sends a request to every peer that negotiated protocol v and
+// returns how many were asked
+func (s *Server) askPeers(v int, send func(peer.ID)) int {
+	asked := 0
+	for _, p := range s.Host.Network().Peers() {
+		if s.peerSupports(p, v) {
+			send(p)
+			asked++
+		}
+	}
+	return asked
+}
+
+// FetchBlock returns the block hash with its transactions. A light node
+// stores only its header, so the body is requested from every peer and the
+// first one matching the header is used.
+func (s *Server) FetchBlock(ctx context.Context, hash []byte) (*Block, error) {
+	block, err := s.Blockchain.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	if !block.Pruned {
+		return &block, nil
+	}
+
+	ch, cancel := s.Fetcher.wait(blockFetchKey(hash))
+	defer cancel()
+	if s.askPeers(MinProtocolVersion, func(p peer.ID) { s.SendGetData(p, "block", hash) }) == 0 {
+		return nil, fmt.Errorf("%w: no connected peers", ErrBlockUnavailable)
+	}
+	return awaitBlock(ctx, ch, func(*Block) bool { return true })
+}
+
+// FetchTxBlock returns the main-chain block that includes txID, from the
+// tx index if the block is stored in full and from peers otherwise. A light
+// node can't tell a transaction no peer knows from one that doesn't exist:
+// both end in ErrBlockUnavailable.
+func (s *Server) FetchTxBlock(ctx context.Context, txID []byte) (*Block, error) {
+	if block, err := s.Blockchain.FindTransactionBlock(txID); err == nil && !block.Pruned {
+		return &block, nil
+	}
+
+	ch, cancel := s.Fetcher.wait(txFetchKey(txID))
+	defer cancel()
+	if s.askPeers(ProtocolLight, func(p peer.ID) { s.SendGetTxBlock(p, txID) }) == 0 {
+		return nil, fmt.Errorf("%w: no connected peers speak protocol v%d", ErrBlockUnavailable, ProtocolLight)
+	}
+	// A peer on another branch may answer with a block we don't follow
+	return awaitBlock(ctx, ch, func(block *Block) bool {
+		hash, err := s.Blockchain.HashAtHeight(block.Height)
+		return err == nil && bytes.Equal(hash, block.Hash)
+	})
+}
+
+// awaitBlock returns the first block from ch that accept takes, waiting at
+// most lightFetchTimeout
+func awaitBlock(ctx context.Context, ch chan *Block, accept func(*Block) bool) (*Block, error) {
+	ctx, stop := context.WithTimeout(ctx, lightFetchTimeout)
+	defer stop()
+	for {
+		select {
+		case block := <-ch:
+			if accept(block) {
+				return block, nil
+			}
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrBlockUnavailable, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// newLightChain is newTestChain for a light node's database
+func newLightChain(t *testing.T) *Blockchain {
+	t.Helper()
+
+	chain := newTestChain(t)
+	if err := chain.MarkLight(); err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+func TestAddHeaderFollowsTheHeaderChain(t *testing.T) {
+	a, _ := newValidator(t)
+	b, _ := newValidator(t)
+	withValidators(t, 1, a, b)
+	full := newTestChain(t)
+	light := newLightChain(t)
+
+	var blocks []*Block
+	for i := 0; i < 3; i++ {
+		blocks = append(blocks, addTestBlock(t, full, a))
+	}
+
+	if err := light.AddHeader(blocks[1].HeaderOnly()); err == nil {
+		t.Fatal("header stored without its parent")
+	}
+	if err := light.AddHeader(blocks[0]); err == nil {
+		t.Fatal("full block stored as a header")
+	}
+	forged := blocks[0].HeaderOnly()
+	forged.Timestamp++
+	if err := light.AddHeader(forged); err == nil {
+		t.Fatal("header that doesn't hash to its hash stored")
+	}
+
+	for _, block := range blocks {
+		if err := light.AddHeader(block.HeaderOnly()); err != nil {
+			t.Fatalf("header %d: %v", block.Height, err)
+		}
+	}
+	if light.GetBestHeight() != 3 || !bytes.Equal(light.LastHash, blocks[2].Hash) {
+		t.Fatalf("tip at height %d, want the full chain's tip at 3", light.GetBestHeight())
+	}
+
+	// A longer branch forking at height 1 takes over, as with full blocks
+	side := []*Block{buildBlock(t, blocks[0], b)}
+	for i := 0; i < 2; i++ {
+		side = append(side, buildBlock(t, side[i], b))
+	}
+	for _, block := range side {
+		if err := light.AddHeader(block.HeaderOnly()); err != nil {
+			t.Fatalf("side header %d: %v", block.Height, err)
+		}
+	}
+	if hash, err := light.HashAtHeight(2); err != nil || !bytes.Equal(hash, side[0].Hash) || !bytes.Equal(light.LastHash, side[2].Hash) {
+		t.Fatal("main chain not switched to the longer header branch")
+	}
+}
+
+func TestCheckLightMode(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	full := newTestChain(t)
+	addTestBlock(t, full, w)
+	light := newLightChain(t)
+
+	if err := full.CheckLightMode(true); err == nil {
+		t.Fatal("--light accepted on a database holding full blocks")
+	}
+	if err := light.CheckLightMode(false); err == nil {
+		t.Fatal("full node accepted on a light database")
+	}
+	if err := (UTXOSet{light}).Reindex(); !errors.Is(err, ErrBlockPruned) {
+		t.Fatalf("reindex of a light database: got %v, want %v", err, ErrBlockPruned)
+	}
+}
+
+func TestHeadersFromReadsHeightIndex(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	chain := newTestChain(t)
+	var blocks []*Block
+	for i := 0; i < 6; i++ {
+		blocks = append(blocks, addTestBlock(t, chain, w))
+	}
+
+	// Blocks above the range aren't read: a walk from the tip would stop here
+	err := chain.Database.Update(func(txn *badger.Txn) error {
+		return txn.Set(blocks[3].Hash, []byte("not a block"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := chain.HeadersFrom(1, 2)
+	if len(headers) != 2 {
+		t.Fatalf("%d headers, want 2", len(headers))
+	}
+	for i, data := range headers {
+		header, err := DeserializeBlockCanonical(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !header.Pruned || !bytes.Equal(header.Hash, blocks[i].Hash) {
+			t.Fatalf("header %d is not the header of block %d", i, blocks[i].Height)
+		}
+	}
+	if headers := chain.HeadersFrom(6, 10); len(headers) != 1 {
+		t.Fatalf("%d headers from the tip, want 1", len(headers))
+	}
+	if headers := chain.HeadersFrom(7, 10); len(headers) != 0 {
+		t.Fatalf("%d headers above the tip", len(headers))
+	}
+}
+
+func TestLightBlockMustMatchHeader(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	full := newTestChain(t)
+	block := addTestBlock(t, full, w)
+	s := NewAPIOnlyServer(newLightChain(t))
+	if err := s.Blockchain.AddHeader(block.HeaderOnly()); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, cancel := s.Fetcher.wait(blockFetchKey(block.Hash))
+	defer cancel()
+
+	// Same IDs, different content
+	tampered := *block
+	coinbase := *block.Transactions[0]
+	coinbase.Vout = []TxOutput{*NewTxOutput(InitialSubsidy*2, w.GetAddress())}
+	tampered.Transactions = []*Transaction{&coinbase}
+	s.handleLightBlock(&tampered, "")
+	select {
+	case <-ch:
+		t.Fatal("block whose transactions don't hash to their IDs delivered")
+	default:
+	}
+
+	s.handleLightBlock(block, "")
+	select {
+	case got := <-ch:
+		if got.Pruned || len(got.Transactions) != 1 {
+			t.Fatal("delivered block has no body")
+		}
+	default:
+		t.Fatal("block matching its header not delivered")
+	}
+}
+
+func TestLightNodeSyncsHeadersAndFetchesBlocks(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	full := newTestServer(t, newTestChain(t))
+	mined := addTestBlock(t, full.Blockchain, w)
+	addTestBlock(t, full.Blockchain, w)
+	light := newTestServer(t, newLightChain(t))
+	light.Light = true
+	rs := &RestServer{P2P: light}
+
+	// Alone, the light node answers at once that no peer can help
+	lightTxID := hex.EncodeToString(mined.Transactions[0].ID)
+	start := time.Now()
+	rec := callAPI(rs.getTransaction, "GET", "/transaction/"+lightTxID, nil, map[string]string{"id": lightTxID})
+	if rec.Code != http.StatusServiceUnavailable || decodeError(t, rec) != CodeBlockUnavailable {
+		t.Fatalf("without peers: status %d %s, want 503 %s", rec.Code, rec.Body, CodeBlockUnavailable)
+	}
+	if time.Since(start) >= lightFetchTimeout {
+		t.Fatal("waited for peers while connected to none")
+	}
+
+	// The handshake starts a header sync, not an IBD
+	connectHosts(t, light.Host, full.Host)
+	light.SendVersion(full.Host.ID())
+	waitFor(t, "header sync", func() bool { return light.Blockchain.GetBestHeight() == 2 })
+	header, err := light.Blockchain.GetBlock(mined.Hash)
+	if err != nil || !header.Pruned {
+		t.Fatal("light node stored a full block")
+	}
+
+	// A new block is followed through its announcement
+	next := addTestBlock(t, full.Blockchain, w)
+	full.SendInv(light.Host.ID(), "block", [][]byte{next.Hash})
+	waitFor(t, "announced header", func() bool { return light.Blockchain.HasBlock(next.Hash) })
+
+	hash := hex.EncodeToString(mined.Hash)
+	rec = callAPI(rs.getBlock, "GET", "/blocks/"+hash, nil, map[string]string{"hash": hash})
+	var block JSONBlock
+	if err := json.Unmarshal(rec.Body.Bytes(), &block); err != nil || len(block.Transactions) != 1 || block.Pruned {
+		t.Fatalf("block not fetched from the peer: %s", rec.Body)
+	}
+
+	rec = callAPI(rs.getTransaction, "GET", "/transaction/"+lightTxID, nil, map[string]string{"id": lightTxID})
+	var tx JSONTransactionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &tx); err != nil || tx.ID != lightTxID || tx.BlockTime != mined.Timestamp {
+		t.Fatalf("transaction not fetched from the peer: %s", rec.Body)
+	}
+
+	// No peer has it: ErrBlockUnavailable once the wait is over
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := light.FetchTxBlock(ctx, bytes.Repeat([]byte{7}, 32)); !errors.Is(err, ErrBlockUnavailable) {
+		t.Fatalf("unknown transaction: got %v, want %v", err, ErrBlockUnavailable)
+	}
+
+	// What needs a UTXO set, tx index or mempool is refused
+	rec = callAPI(rs.fullNodeOnly(rs.getBalance), "GET", "/balance/x", nil, map[string]string{"address": w.GetAddress()})
+	if rec.Code != http.StatusServiceUnavailable || decodeError(t, rec) != CodeLightNode {
+		t.Fatalf("balance on a light node: status %d %s, want 503 %s", rec.Code, rec.Body, CodeLightNode)
+	}
+}
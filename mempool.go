@@ -0,0 +1,473 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Mempool size policy: once MaxMempoolBytes is exceeded the lowest fee-rate
+// transactions are evicted, and the eviction floor rises to the best rate
+// evicted so far. New transactions must pay strictly more than the floor.
+// The floor resets once the pool drains below half capacity.
+
+const (
+	DefaultMaxMempoolBytes = 10 * 1024 * 1024 // 10MB
+	MaxWaitingTxs          = 1000             // Time-locked transactions held back at once
+	MaxMempoolChainDepth   = 25               // Generations of unconfirmed parents a transaction may build on
+	DefaultMempoolExpiry   = 72 * time.Hour   // Unmined transactions are dropped after this long
+	mempoolExpiryCheck     = time.Minute
+	ReplacementTTL         = time.Hour // How long replaced txids are remembered
+	MaxReplacements        = 10000     // Replaced txids remembered at once
+)
+
+// Replacement records which conflicting transaction pushed a transaction out
+// of the mempool, so clients polling the old txid learn what superseded it
+type Replacement struct {
+	By string    // txID of the replacement
+	At time.Time // When the original was evicted
+}
+
+// FeeRate returns the fee rate of a mempool item in Photons per byte
+func (item MempoolItem) FeeRate() float64 {
+	if item.Size == 0 {
+		return 0
+	}
+	return float64(item.Fee) / float64(item.Size)
+}
+
+// AddToMempool admits tx under the size policy, evicting cheaper transactions
+// if needed. Caller must hold MempoolMux.
+func (s *Server) AddToMempool(tx Transaction, fee int64, receivedAt int64) error {
+	item := MempoolItem{Tx: tx, ReceivedAt: receivedAt, Fee: fee, Size: len(tx.Serialize())}
+	rate := item.FeeRate()
+
+	if s.MempoolFloor > 0 && rate <= s.MempoolFloor {
+		return fmt.Errorf("mempool full: fee rate %.2f Photons/byte is below the minimum of %.2f", rate, s.MempoolFloor)
+	}
+
+	if s.MaxMempoolBytes > 0 && item.Size > s.MaxMempoolBytes {
+		return fmt.Errorf("transaction (%d bytes) is larger than the whole mempool", item.Size)
+	}
+	if s.MaxMempoolBytes > 0 && s.MempoolBytes+item.Size > s.MaxMempoolBytes {
+		// Pick victims first: the newcomer must beat everything it would push out
+		ancestors, _ := mempoolAncestors(&tx, s.Mempool)
+		var victims []string
+		freed := 0
+		for _, id := range s.mempoolByFeeRate() {
+			if s.MempoolBytes-freed+item.Size <= s.MaxMempoolBytes {
+				break
+			}
+			if ancestors[id] {
+				return fmt.Errorf("mempool full: transaction would evict its own unconfirmed parent %s...", id[:8])
+			}
+			if victimRate := s.Mempool[id].FeeRate(); rate <= victimRate {
+				return fmt.Errorf("mempool full: fee rate %.2f Photons/byte is below the minimum of %.2f", rate, victimRate)
+			}
+			victims = append(victims, id)
+			freed += s.Mempool[id].Size
+		}
+
+		for _, id := range victims {
+			if _, ok := s.Mempool[id]; !ok {
+				continue // Already gone with an evicted parent
+			}
+			victimRate := s.Mempool[id].FeeRate()
+			s.EvictFromMempool(id)
+			if victimRate > s.MempoolFloor {
+				s.MempoolFloor = victimRate
+			}
+			fmt.Printf("🧹 [Mempool] Evicted %s... (fee rate %.2f) to make room\n", id[:8], victimRate)
+		}
+	}
+
+	s.Mempool[hex.EncodeToString(tx.ID)] = item
+	s.MempoolBytes += item.Size
+	return nil
+}
+
+// AddWaitingTx parks a time-locked transaction until PromoteWaitingTxs finds
+// it mature. A transaction spending an input already spent in the mempool or
+// the waiting pool is refused with ErrDoubleSpend (wrapped): the first seen
+// spend wins. Caller must hold MempoolMux.
+func (s *Server) AddWaitingTx(tx Transaction, fee int64, receivedAt int64) error {
+	txID := hex.EncodeToString(tx.ID)
+	if _, exists := s.WaitingTxs[txID]; exists {
+		return fmt.Errorf("transaction is already waiting")
+	}
+	if err := CheckMempoolConflict(&tx, s.Mempool); err != nil {
+		return err
+	}
+	if err := CheckMempoolConflict(&tx, s.WaitingTxs); err != nil {
+		return err
+	}
+	if len(s.WaitingTxs) >= MaxWaitingTxs {
+		return fmt.Errorf("too many time-locked transactions waiting (max %d)", MaxWaitingTxs)
+	}
+	s.WaitingTxs[txID] = MempoolItem{Tx: tx, ReceivedAt: receivedAt, Fee: fee, Size: len(tx.Serialize())}
+	return nil
+}
+
+// PromoteWaitingTxs moves time-locked transactions that may go in the next
+// block into the mempool and relays them. They are checked again first:
+// their inputs may have been spent while they waited. Caller must hold MempoolMux.
+func (s *Server) PromoteWaitingTxs() {
+	if len(s.WaitingTxs) == 0 {
+		return
+	}
+
+	nextHeight := s.Blockchain.GetBestHeight() + 1
+	for id, item := range s.WaitingTxs {
+		tx := item.Tx
+		if !tx.IsFinal(nextHeight) {
+			continue
+		}
+		delete(s.WaitingTxs, id)
+
+		if err := s.Blockchain.ValidateTransaction(&tx, s.Mempool); err != nil {
+			fmt.Printf("⚠️  [Mempool] Dropped time-locked TX %s...: %v\n", id[:8], err)
+			continue
+		}
+		// The fee was resolved, and any declared Fee checked, when it arrived
+		err := CheckMempoolConflict(&tx, s.Mempool)
+		if err == nil {
+			err = CheckMempoolChain(&tx, s.Mempool)
+		}
+		if err == nil {
+			err = s.AddToMempool(tx, item.Fee, item.ReceivedAt)
+		}
+		if err != nil {
+			fmt.Printf("⚠️  [Mempool] Dropped time-locked TX %s...: %v\n", id[:8], err)
+			continue
+		}
+
+		fmt.Printf("⏰ [Mempool] Time-locked TX %s... reached height %d\n", id[:8], tx.LockHeight)
+		BroadcastMempoolTx(s.MempoolHub, &tx)
+		if !s.IsAPIOnly() {
+			for _, p := range s.Host.Network().Peers() {
+				s.SendInv(p, "tx", [][]byte{tx.ID})
+			}
+		}
+	}
+}
+
+// RemoveFromMempool drops a transaction and updates the size accounting.
+// Caller must hold MempoolMux.
+func (s *Server) RemoveFromMempool(txID string) {
+	item, ok := s.Mempool[txID]
+	if !ok {
+		return
+	}
+	delete(s.Mempool, txID)
+	s.MempoolBytes -= item.Size
+
+	if s.MempoolBytes < s.MaxMempoolBytes/2 {
+		s.MempoolFloor = 0
+	}
+}
+
+// EvictFromMempool drops a transaction together with the mempool transactions
+// spending its outputs, which could never be mined without it. Use
+// RemoveFromMempool for mined transactions: their children stay valid.
+// Caller must hold MempoolMux.
+func (s *Server) EvictFromMempool(txID string) {
+	if _, ok := s.Mempool[txID]; !ok {
+		return
+	}
+	s.RemoveFromMempool(txID)
+
+	for id, item := range s.Mempool {
+		for _, vin := range item.Tx.Vin {
+			if hex.EncodeToString(vin.Txid) == txID {
+				fmt.Printf("🧹 [Mempool] Evicted %s... (spends evicted TX %s...)\n", id[:8], txID[:8])
+				s.EvictFromMempool(id)
+				break
+			}
+		}
+	}
+}
+
+// RecordReplacement remembers that txID was evicted in favor of the
+// conflicting transaction byID for ReplacementTTL. Expired records are
+// dropped first; past MaxReplacements the oldest one makes room.
+// Caller must hold MempoolMux.
+func (s *Server) RecordReplacement(txID, byID string, now time.Time) {
+	order := &s.replacementOrder
+	for {
+		oldest, ok := order.oldest()
+		if !ok || (now.Sub(oldest.at) <= ReplacementTTL && order.size < MaxReplacements) {
+			break
+		}
+		order.pop()
+		// A txid recorded again has a newer slot, which drops it instead
+		if r, ok := s.Replacements[oldest.txID]; ok && r.At.Equal(oldest.at) {
+			delete(s.Replacements, oldest.txID)
+		}
+	}
+	s.Replacements[txID] = Replacement{By: byID, At: now}
+	order.push(txID, now)
+}
+
+// replacementRing lists recorded replacements oldest first in at most
+// MaxReplacements slots, so expiry and eviction only look at its head
+type replacementRing struct {
+	slots []replacementSlot
+	head  int // Slot of the oldest record
+	size  int
+}
+
+type replacementSlot struct {
+	txID string
+	at   time.Time
+}
+
+func (r *replacementRing) oldest() (replacementSlot, bool) {
+	if r.size == 0 {
+		return replacementSlot{}, false
+	}
+	return r.slots[r.head], true
+}
+
+func (r *replacementRing) pop() {
+	r.slots[r.head] = replacementSlot{}
+	r.head = (r.head + 1) % len(r.slots)
+	r.size--
+}
+
+// push appends a record; the caller pops first when the ring is full
+func (r *replacementRing) push(txID string, at time.Time) {
+	if r.slots == nil {
+		r.slots = make([]replacementSlot, MaxReplacements)
+	}
+	r.slots[(r.head+r.size)%len(r.slots)] = replacementSlot{txID: txID, at: at}
+	r.size++
+}
+
+// ReplacedBy returns the txID that replaced txID within the last
+// ReplacementTTL, or "" if it wasn't replaced
+func (s *Server) ReplacedBy(txID string) string {
+	s.MempoolMux.Lock()
+	defer s.MempoolMux.Unlock()
+	r, ok := s.Replacements[txID]
+	if !ok || time.Since(r.At) > ReplacementTTL {
+		return ""
+	}
+	return r.By
+}
+
+// EvictConflicts drops the mempool transactions spending an outpoint that a
+// transaction of block also spends, with their descendants, and records the
+// block transaction as their replacement. Caller must hold MempoolMux.
+func (s *Server) EvictConflicts(block *Block) {
+	spentBy := make(map[string]string) // Outpoint -> txID of the block tx spending it
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, vin := range tx.Vin {
+			spentBy[fmt.Sprintf("%x-%d", vin.Txid, vin.Vout)] = hex.EncodeToString(tx.ID)
+		}
+	}
+	if len(spentBy) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for id, item := range s.Mempool {
+		for _, vin := range item.Tx.Vin {
+			byID, ok := spentBy[fmt.Sprintf("%x-%d", vin.Txid, vin.Vout)]
+			if !ok || byID == id {
+				continue
+			}
+			fmt.Printf("🧹 [Mempool] Evicted %s... (replaced by %s... in block %d)\n", id[:8], byID[:8], block.Height)
+			s.EvictFromMempool(id)
+			s.RecordReplacement(id, byID, now)
+			break
+		}
+	}
+}
+
+// CheckMempoolChain rejects tx if it builds on more than MaxMempoolChainDepth
+// generations of unconfirmed (mempool) transactions (ErrUnconfirmedInput)
+func CheckMempoolChain(tx *Transaction, mempool map[string]MempoolItem) error {
+	if _, depth := mempoolAncestors(tx, mempool); depth > MaxMempoolChainDepth {
+		return fmt.Errorf("%w: transaction is %d unconfirmed transactions deep (max %d), wait for a block", ErrUnconfirmedInput, depth, MaxMempoolChainDepth)
+	}
+	return nil
+}
+
+// mempoolAncestors returns the IDs of the mempool transactions tx builds on,
+// directly or not, and the length of the longest such chain (0 when every
+// input is confirmed)
+func mempoolAncestors(tx *Transaction, mempool map[string]MempoolItem) (map[string]bool, int) {
+	depths := make(map[string]int) // Ancestor ID -> its own chain depth
+
+	var walk func(tx *Transaction) int
+	walk = func(tx *Transaction) int {
+		depth := 0
+		for _, vin := range tx.Vin {
+			parentID := hex.EncodeToString(vin.Txid)
+			parent, ok := mempool[parentID]
+			if !ok {
+				continue // Confirmed
+			}
+			d, seen := depths[parentID]
+			if !seen {
+				d = walk(&parent.Tx)
+				depths[parentID] = d
+			}
+			if d+1 > depth {
+				depth = d + 1
+			}
+		}
+		return depth
+	}
+
+	depth := walk(tx)
+	ancestors := make(map[string]bool, len(depths))
+	for id := range depths {
+		ancestors[id] = true
+	}
+	return ancestors, depth
+}
+
+// mempoolDescendants returns the IDs of the mempool transactions spending
+// txID's outputs, directly or not: the ones EvictFromMempool would drop with it
+func mempoolDescendants(txID string, mempool map[string]MempoolItem) map[string]bool {
+	children := make(map[string][]string) // Parent ID -> mempool txs spending it
+	for id, item := range mempool {
+		for _, vin := range item.Tx.Vin {
+			parentID := hex.EncodeToString(vin.Txid)
+			if _, ok := mempool[parentID]; ok {
+				children[parentID] = append(children[parentID], id)
+			}
+		}
+	}
+
+	descendants := make(map[string]bool)
+	queue := []string{txID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, child := range children[id] {
+			if !descendants[child] {
+				descendants[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return descendants
+}
+
+// orderForBlock puts every transaction after the mempool parents it spends,
+// keeping the given order otherwise. UTXOSet.Update applies a block in order,
+// so a child listed first would leave its parent's output unspent.
+func orderForBlock(txs []*Transaction) []*Transaction {
+	byID := make(map[string]*Transaction, len(txs))
+	for _, tx := range txs {
+		byID[hex.EncodeToString(tx.ID)] = tx
+	}
+
+	ordered := make([]*Transaction, 0, len(txs))
+	placed := make(map[string]bool, len(txs))
+	var place func(tx *Transaction)
+	place = func(tx *Transaction) {
+		txID := hex.EncodeToString(tx.ID)
+		if placed[txID] {
+			return
+		}
+		placed[txID] = true
+		for _, vin := range tx.Vin {
+			if parent, ok := byID[hex.EncodeToString(vin.Txid)]; ok {
+				place(parent)
+			}
+		}
+		ordered = append(ordered, tx)
+	}
+
+	for _, tx := range txs {
+		place(tx)
+	}
+	return ordered
+}
+
+// withinBlockInputs returns the longest prefix of txs spending at most
+// MaxBlockInputs inputs. On an orderForBlock list every parent is in the
+// prefix with its children.
+func withinBlockInputs(txs []*Transaction) []*Transaction {
+	inputs := 0
+	for i, tx := range txs {
+		inputs += len(tx.Vin)
+		if inputs > MaxBlockInputs {
+			return txs[:i]
+		}
+	}
+	return txs
+}
+
+// MempoolStats summarises the pool for the API. Caller must hold MempoolMux.
+func (s *Server) MempoolStats() (count int, bytes int, floor float64, median float64) {
+	ids := s.mempoolByFeeRate()
+	if len(ids) > 0 {
+		median = s.Mempool[ids[len(ids)/2]].FeeRate()
+	}
+	return len(ids), s.MempoolBytes, s.MempoolFloor, median
+}
+
+// mempoolByPriority returns mempool txIDs in the order blocks take them:
+// highest fee rate first, then first received. Caller must hold MempoolMux.
+func (s *Server) mempoolByPriority() []string {
+	ids := s.mempoolByFeeRate()
+	sort.SliceStable(ids, func(i, j int) bool {
+		return s.Mempool[ids[i]].FeeRate() > s.Mempool[ids[j]].FeeRate()
+	})
+	return ids
+}
+
+// ExpireMempool evicts the transactions received more than maxAge before now,
+// with their descendants, and returns how many were dropped. The waiting pool
+// is left alone: time-locked transactions are expected to wait.
+// Caller must hold MempoolMux.
+func (s *Server) ExpireMempool(now time.Time, maxAge time.Duration) int {
+	cutoff := now.Add(-maxAge).Unix()
+	before := len(s.Mempool)
+	for _, id := range s.mempoolByFeeRate() {
+		if item, ok := s.Mempool[id]; ok && item.ReceivedAt < cutoff {
+			fmt.Printf("🧹 [Mempool] Expired %s... (unmined for %s)\n", id[:8], now.Sub(time.Unix(item.ReceivedAt, 0)).Round(time.Minute))
+			s.EvictFromMempool(id)
+		}
+	}
+	return before - len(s.Mempool)
+}
+
+// MempoolExpiryLoop drops transactions older than MempoolExpiry once a minute
+func (s *Server) MempoolExpiryLoop() {
+	ticker := time.NewTicker(mempoolExpiryCheck)
+	defer ticker.Stop()
+
+	for s.tick(ticker) {
+		s.MempoolMux.Lock()
+		s.ExpireMempool(time.Now(), s.MempoolExpiry)
+		s.MempoolMux.Unlock()
+	}
+}
+
+// mempoolByFeeRate returns mempool txIDs sorted by ascending fee rate (oldest first on ties)
+func (s *Server) mempoolByFeeRate() []string {
+	ids := make([]string, 0, len(s.Mempool))
+	for id := range s.Mempool {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := s.Mempool[ids[i]], s.Mempool[ids[j]]
+		if a.FeeRate() != b.FeeRate() {
+			return a.FeeRate() < b.FeeRate()
+		}
+		if a.ReceivedAt != b.ReceivedAt {
+			return a.ReceivedAt < b.ReceivedAt
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
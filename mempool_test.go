@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// lockTx re-signs tx (spending outputs of prev) so it can't be mined below height
+func lockTx(t *testing.T, w *Wallet, tx *Transaction, prev *Transaction, height int) *Transaction {
+	t.Helper()
+
+	key, err := w.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.LockHeight = height
+	tx.Sign(key, map[string]Transaction{hex.EncodeToString(prev.ID): *prev})
+	tx.ID = tx.Hash()
+	return tx
+}
+
+func TestWaitingPoolRejectsConflicts(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	s := NewAPIOnlyServer(newTestChain(t))
+	to, _ := NewWallet()
+
+	first := addTestBlock(t, s.Blockchain, w).Transactions[0]
+	second := addTestBlock(t, s.Blockchain, w).Transactions[0]
+	pay := func(prev *Transaction, value int64) *Transaction {
+		return spendTx(t, w, prev, 0, *NewTxOutput(value, to.GetAddress()))
+	}
+
+	// Two time-locked spends of the same output: the first one waits
+	waiting := lockTx(t, w, pay(first, InitialSubsidy/2), first, 10)
+	if err := s.AddWaitingTx(*waiting, waiting.Fee, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	rival := lockTx(t, w, pay(first, InitialSubsidy/4), first, 10)
+	if err := s.AddWaitingTx(*rival, rival.Fee, time.Now().Unix()); !errors.Is(err, ErrDoubleSpend) {
+		t.Fatalf("conflict with the waiting pool: got %v, want %v", err, ErrDoubleSpend)
+	}
+
+	// A time-locked spend of an output a mempool transaction already spends
+	pending := pay(second, InitialSubsidy/2)
+	if err := s.AddToMempool(*pending, pending.Fee, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	late := lockTx(t, w, pay(second, InitialSubsidy/4), second, 10)
+	if err := s.AddWaitingTx(*late, late.Fee, time.Now().Unix()); !errors.Is(err, ErrDoubleSpend) {
+		t.Fatalf("conflict with the mempool: got %v, want %v", err, ErrDoubleSpend)
+	}
+
+	if len(s.WaitingTxs) != 1 {
+		t.Fatalf("%d transactions waiting, want 1", len(s.WaitingTxs))
+	}
+}
+
+func TestTimeLockedTxDeferredThenIncluded(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	s := newTestServer(t, newTestChain(t))
+	s.EmptyBlocks = true
+	s.ValidatorKeys = []ValidatorKey{loadValidatorKey(t, w)}
+	to, _ := NewWallet()
+
+	funding := addTestBlock(t, s.Blockchain, w).Transactions[0]
+	lockHeight := s.Blockchain.GetBestHeight() + 3
+	tx := lockTx(t, w, spendTx(t, w, funding, 0, *NewTxOutput(InitialSubsidy/2, to.GetAddress())), funding, lockHeight)
+	txID := hex.EncodeToString(tx.ID)
+
+	s.MempoolMux.Lock()
+	err := s.AddWaitingTx(*tx, tx.Fee, time.Now().Unix())
+	s.MempoolMux.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for s.Blockchain.GetBestHeight() < lockHeight {
+		height := s.Blockchain.GetBestHeight()
+		s.AttemptMine()
+		if s.Blockchain.GetBestHeight() != height+1 {
+			t.Fatalf("no block forged on top of height %d", height)
+		}
+		tip := tipBlock(t, s.Blockchain)
+		included := false
+		for _, btx := range tip.Transactions {
+			included = included || hex.EncodeToString(btx.ID) == txID
+		}
+		if tip.Height < lockHeight && included {
+			t.Fatalf("mined at height %d, below its lock height %d", tip.Height, lockHeight)
+		}
+		if tip.Height == lockHeight && !included {
+			t.Fatalf("not mined at its lock height %d", lockHeight)
+		}
+	}
+
+	if _, ok := s.WaitingTxs[txID]; ok {
+		t.Fatal("still in the waiting pool after being mined")
+	}
+	if _, ok := s.Mempool[txID]; ok {
+		t.Fatal("still in the mempool after being mined")
+	}
+}
+
+func TestWithinBlockInputs(t *testing.T) {
+	withInputs := func(n int) *Transaction { return &Transaction{Vin: make([]TxInput, n)} }
+	txs := []*Transaction{withInputs(MaxBlockInputs - 2), withInputs(2), withInputs(1), withInputs(1)}
+
+	if kept := withinBlockInputs(txs); len(kept) != 2 {
+		t.Fatalf("kept %d transactions, want the 2 filling the block exactly", len(kept))
+	}
+	if kept := withinBlockInputs(txs[1:]); len(kept) != 3 {
+		t.Fatalf("kept %d of 3 transactions within the limit", len(kept))
+	}
+}
+
+func TestRecordReplacementEvictsOldest(t *testing.T) {
+	s := NewAPIOnlyServer(newTestChain(t))
+	start := time.Now()
+	id := func(i int) string { return fmt.Sprintf("tx%d", i) }
+
+	// One more record than fit: the oldest makes room for the newest
+	for i := 0; i <= MaxReplacements; i++ {
+		s.RecordReplacement(id(i), "by", start.Add(time.Duration(i)*time.Millisecond))
+	}
+	if len(s.Replacements) != MaxReplacements {
+		t.Fatalf("%d records kept, want %d", len(s.Replacements), MaxReplacements)
+	}
+	if _, ok := s.Replacements[id(0)]; ok {
+		t.Fatal("oldest record kept past MaxReplacements")
+	}
+	if _, ok := s.Replacements[id(MaxReplacements)]; !ok {
+		t.Fatal("newest record dropped")
+	}
+
+	// A txid recorded again keeps its newer record when its first slot drops
+	s.RecordReplacement(id(1), "again", start.Add(time.Minute))
+	if s.Replacements[id(1)].By != "again" || len(s.Replacements) != MaxReplacements {
+		t.Fatalf("re-recorded txid: %+v, %d records", s.Replacements[id(1)], len(s.Replacements))
+	}
+
+	// Past ReplacementTTL every earlier record expires
+	s.RecordReplacement("late", "by", start.Add(ReplacementTTL+2*time.Minute))
+	if len(s.Replacements) != 1 {
+		t.Fatalf("%d records after the TTL, want only the new one", len(s.Replacements))
+	}
+}
+
+// unconfirmedChain returns n transactions of w, each spending the single
+// output of the previous one, the first spending output 0 of funding
+func unconfirmedChain(t *testing.T, w *Wallet, funding *Transaction, n int) []*Transaction {
+	t.Helper()
+
+	chain := make([]*Transaction, 0, n)
+	prev := funding
+	for i := 0; i < n; i++ {
+		tx := spendTx(t, w, prev, 0, *NewTxOutput(prev.Vout[0].Value-1000, w.GetAddress()))
+		chain = append(chain, tx)
+		prev = tx
+	}
+	return chain
+}
+
+func TestHandleTxAdmitsUnconfirmedChain(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	funding := addTestBlock(t, chain, w).Transactions[0]
+	s := newTestServer(t, chain)
+
+	// Parent, child and grandchild arrive from a peer before any is mined
+	for _, tx := range unconfirmedChain(t, w, funding, 3) {
+		s.HandleTx(GobEncode(TxMsg{Transaction: tx.Serialize()}), "")
+		if _, ok := s.Mempool[hex.EncodeToString(tx.ID)]; !ok {
+			t.Fatalf("%x not admitted after its mempool parent", tx.ID)
+		}
+	}
+}
+
+func TestMempoolChainDepthLimit(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	funding := addTestBlock(t, chain, w).Transactions[0]
+	s := NewAPIOnlyServer(chain)
+
+	txs := unconfirmedChain(t, w, funding, MaxMempoolChainDepth+2)
+	for _, tx := range txs[:MaxMempoolChainDepth+1] {
+		if err := CheckMempoolChain(tx, s.Mempool); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddToMempool(*tx, tx.Fee, time.Now().Unix()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Building on MaxMempoolChainDepth+1 unconfirmed generations
+	deep := txs[MaxMempoolChainDepth+1]
+	err := CheckMempoolChain(deep, s.Mempool)
+	if !errors.Is(err, ErrUnconfirmedInput) {
+		t.Fatalf("got %v, want %v", err, ErrUnconfirmedInput)
+	}
+	if TxErrorCode(err) != "unconfirmed_input" || TxErrorExitCode(err) == 1 {
+		t.Fatalf("code %q, exit code %d: not told apart from an invalid transaction", TxErrorCode(err), TxErrorExitCode(err))
+	}
+}
@@ -17,9 +17,28 @@ type MerkleNode struct {
 	Data  []byte
 }
 
+// EmptyMerkleRoot is the root of a block with no transactions. It is zero
+// bytes long, so the header hash commits to no merkle data at all.
+var EmptyMerkleRoot = []byte{}
+
+// MerkleRoot returns the merkle root over txIDs. This is the only place the
+// header root is derived, so SetHash and HashTransactions always agree.
+// A single ID (the genesis coinbase) is paired with itself like any odd level.
+func MerkleRoot(txIDs [][]byte) []byte {
+	if len(txIDs) == 0 {
+		return EmptyMerkleRoot
+	}
+	return NewMerkleTree(txIDs).RootNode.Data
+}
+
+// NewMerkleTree builds the tree over data. With no data the tree has no root.
 func NewMerkleTree(data [][]byte) *MerkleTree {
 	var nodes []MerkleNode
 
+	if len(data) == 0 {
+		return &MerkleTree{}
+	}
+
 	if len(data)%2 != 0 {
 		data = append(data, data[len(data)-1])
 	}
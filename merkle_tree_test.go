@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+// pairHash hashes two merkle nodes into their parent
+func pairHash(left, right []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, left...), right...))
+	return sum[:]
+}
+
+func leafHash(id []byte) []byte {
+	sum := sha256.Sum256(id)
+	return sum[:]
+}
+
+func TestMerkleRootGenesis(t *testing.T) {
+	genesis := NewGenesisBlock()
+	if len(genesis.Transactions) != 1 {
+		t.Fatalf("genesis holds %d transactions, want its coinbase only", len(genesis.Transactions))
+	}
+
+	// A lone coinbase is paired with itself
+	leaf := leafHash(genesis.Transactions[0].ID)
+	if root := genesis.HashTransactions(); !bytes.Equal(root, pairHash(leaf, leaf)) {
+		t.Fatalf("genesis merkle root %x, want %x", root, pairHash(leaf, leaf))
+	}
+	if again := NewGenesisBlock(); !bytes.Equal(again.Hash, genesis.Hash) {
+		t.Fatalf("genesis hash changed between builds: %x and %x", genesis.Hash, again.Hash)
+	}
+}
+
+func TestMerkleRootEmptyBlock(t *testing.T) {
+	if root := MerkleRoot(nil); !bytes.Equal(root, EmptyMerkleRoot) {
+		t.Fatalf("empty root %x, want %x", root, EmptyMerkleRoot)
+	}
+
+	block := NewBlock(nil, make([]byte, 32), 1, nil)
+	if root := block.HashTransactions(); !bytes.Equal(root, EmptyMerkleRoot) {
+		t.Fatalf("empty block root %x, want %x", root, EmptyMerkleRoot)
+	}
+	rehashed := *block
+	rehashed.SetHash()
+	if !bytes.Equal(rehashed.Hash, block.Hash) {
+		t.Fatal("empty block hash is not stable")
+	}
+}
+
+func TestSetHashUsesMerkleRoot(t *testing.T) {
+	ids := [][]byte{{1}, {2}, {3}}
+	var txs []*Transaction
+	for _, id := range ids {
+		txs = append(txs, &Transaction{ID: id})
+	}
+	block := NewBlock(txs, make([]byte, 32), 1, nil)
+
+	// Odd levels repeat their last node
+	a, b, c := leafHash(ids[0]), leafHash(ids[1]), leafHash(ids[2])
+	want := pairHash(pairHash(a, b), pairHash(c, c))
+	if root := block.HashTransactions(); !bytes.Equal(root, want) {
+		t.Fatalf("merkle root %x, want %x", root, want)
+	}
+	if root := MerkleRoot(ids); !bytes.Equal(root, want) {
+		t.Fatalf("MerkleRoot %x, want %x", root, want)
+	}
+
+	header := bytes.Join([][]byte{block.PrevBlockHash, want, IntToHex(block.Timestamp), IntToHex(1), IntToHex(0)}, nil)
+	if sum := sha256.Sum256(header); !bytes.Equal(block.Hash, sum[:]) {
+		t.Fatal("SetHash does not commit to the merkle root")
+	}
+}
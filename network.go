@@ -7,15 +7,19 @@ import (
 	"encoding/binary"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
+	"net"
 	"os"
 	"sort"
 	"strings"
 	"time"
 
 	"sync"
+	"sync/atomic"
 
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/crypto"
@@ -23,7 +27,11 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+	quic "github.com/libp2p/go-libp2p/p2p/transport/quic"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -31,6 +39,76 @@ const (
 	discoveryNamespace = "sole_p2p"
 )
 
+// Wire protocol versions, advertised in the version handshake. A connection
+// uses the lower of the two sides' versions, and messages introduced above it
+// are neither sent nor answered (see peerSupports). Version 1 is every
+// message that predates negotiation: its nodes advertise 1 without checking.
+const (
+	ProtocolVersion     = 4 // Highest version this node speaks
+	MinProtocolVersion  = 1 // Peers advertising less are disconnected
+	ProtocolHeaders     = 2 // getheaders / headers
+	ProtocolRevocations = 3 // revocation
+	ProtocolLight       = 4 // gettxblock
+)
+
+const (
+	// Per-peer throttles for expensive sync requests. getblocks walks the whole
+	// chain, so it is limited tightly; getdata is requested once per missing
+	// block during IBD, so it gets a large burst.
+	getBlocksRate  = 0.2 // 1 every 5 seconds
+	getBlocksBurst = 3
+	getDataRate    = 100
+	getDataBurst   = 1000
+
+	// getheaders returns up to maxHeadersPerMsg headers per request, so a
+	// light client catching up sends a few of them back to back
+	getHeadersRate   = 2
+	getHeadersBurst  = 20
+	maxHeadersPerMsg = 2000
+
+	// Cap on all inbound messages per peer (--max-msg-rate), with a burst of
+	// msgBurstSeconds worth of them. The IBD sync peer is exempt: it answers
+	// our own getdata requests one block per message.
+	DefaultMaxMsgRate = 500
+	msgBurstSeconds   = 5
+
+	// Bootnode dialing: exponential backoff on startup, periodic re-dial afterwards
+	bootnodeMaxRetries        = 6
+	bootnodeBaseBackoff       = 2 * time.Second
+	bootnodeMaxBackoff        = 1 * time.Minute
+	bootnodeReconnectInterval = 30 * time.Second
+
+	// Dials time out after DefaultDialTimeout unless --dial-timeout says otherwise.
+	// mDNS peers failing with a timeout or no usable address (often NAT) are
+	// retried a few times; other failures are final.
+	DefaultDialTimeout   = 10 * time.Second
+	discoveryMaxRetries  = 3
+	discoveryBaseBackoff = 5 * time.Second
+
+	// Validators try to forge a block this often
+	MiningInterval = 10 * time.Second
+
+	// Pruning: bodies are discarded in batches once buried by the --prune depth
+	MinPruneKeepBlocks = 100
+	pruneInterval      = 100
+
+	// Recently mined txIDs remembered to skip re-fetching announced txs
+	seenTxFilterCapacity = 100000
+	seenTxFilterFPRate   = 0.01
+
+	// Stale tip watchdog
+	staleTipCheckInterval = 30 * time.Second
+	staleTipTimeout       = 5 * time.Minute
+
+	// Sync progress indicator refresh
+	syncProgressInterval = 3 * time.Second
+
+	// Misbehaviour scoring: peers reaching banScoreThreshold are disconnected
+	throttlePenalty   = 10
+	floodPenalty      = 50
+	banScoreThreshold = 100
+)
+
 var (
 	commandLength    = 12
 	DefaultBootnodes = []string{
@@ -39,8 +117,10 @@ var (
 )
 
 type MempoolItem struct {
-	Tx      Transaction
-	AddedAt int64
+	Tx         Transaction
+	ReceivedAt int64 // Unix time this node accepted it, kept across waiting -> mempool
+	Fee        int64 // Photons
+	Size       int   // Serialized bytes
 }
 
 type Server struct {
@@ -48,20 +128,78 @@ type Server struct {
 	Blockchain       *Blockchain
 	UTXOSet          *UTXOSet
 	MinerAddr        string
+	RewardAddress    string // Coinbase recipient; empty pays the signing validator's address
+	CoinbaseMessage  string
+	DustLimit        int64 // Min non-memo output value accepted into the mempool
+	MaxTxFee         int64 // Fee ceiling for API submissions (Photons)
 	ValidatorPrivKey *ecdsa.PrivateKey
+	ValidatorKeys    []ValidatorKey    // All loaded keys (MinerAddr/ValidatorPrivKey mirror the first)
 	KnownPeers       map[string]string // PeerID string -> Addr
+	PeerHeights      map[string]int    // PeerID string -> last reported best height
+	PeerConns        map[string]int    // PeerID string -> open connections
+	PeerCompress     map[string]bool   // PeerID string -> accepts compressed frames (from its handshake)
+	PeerProtocol     map[string]int    // PeerID string -> negotiated protocol version
 	KnownPeersMux    sync.RWMutex
+	Compression      bool // Advertise and send compressed payloads
+	DialTimeout      time.Duration
+	DialRetries      map[string]int // PeerID string -> failed attempts, while a discovered peer is being retried
+	DialRetriesMux   sync.Mutex
 	Mempool          map[string]MempoolItem
+	WaitingTxs       map[string]MempoolItem         // Time-locked txs until their LockHeight is next (guarded by MempoolMux)
+	Replacements     map[string]Replacement         // Evicted txID -> conflicting tx that replaced it (guarded by MempoolMux)
+	replacementOrder replacementRing                // Replacements oldest first, for expiry and eviction (guarded by MempoolMux)
+	Revocations      map[string]ValidatorRevocation // Complete revocations waiting for a block, by revoked key (guarded by MempoolMux)
 	MempoolMux       sync.Mutex
+	MempoolBytes     int           // Serialized size of all mempool transactions
+	MaxMempoolBytes  int           // Eviction threshold (0 = unbounded)
+	MempoolFloor     float64       // Min fee rate (Photons/byte) after evictions
+	MempoolExpiry    time.Duration // Drop transactions unmined for this long (0 = never)
+	PruneKeep        int           // Recent blocks kept in full; older bodies are discarded (0 = off)
+	EmptyBlocks      bool          // Forge coinbase-only blocks when the mempool is empty
+	Light            bool          // Headers only; the API fetches blocks from peers (see light.go)
+	Fetcher          *BlockFetcher // Block bodies a light node is waiting for
+
+	ValidatorStats *ValidatorStatsTracker
+	TxWatcher      *TxWatcher     // Confirmation waiters for /ws/tx/{id}
+	TipNotifier    *TipNotifier   // Wakes GET /blocks/tip?wait= long polls
+	Orphans        *OrphanTracker // Blocks received with an unknown parent, for /orphans
+	TxAcks         *TxAckTracker  // Peers that confirmed accepting our relayed txs
+
+	SeenTxs          *RollingBloomFilter // IDs of txs in recently connected blocks
+	SkippedTxFetches atomic.Int64        // tx invs dropped because the tx is already mined
+	Wire             WireStats           // P2P payload bytes, before and after compression
 
 	MempoolHub *EventHub
 	BlockHub   *EventHub
+	PeerHub    *EventHub // Peer connect/disconnect events for /ws/peers
 
 	SyncingFrom    peer.ID        // Peer we are currently syncing from
 	IsSyncing      bool           // True while IBD is in progress
 	BlockBuffer    map[int]*Block // Height → Block buffer for ordered application
 	ExpectedBlocks int            // Total blocks expected during IBD
+	syncWireStart  wireCounts     // Received bytes when the IBD started, for its savings summary
 	BlockBufferMux sync.Mutex
+
+	GetBlocksLimiter  *IPRateLimiter // Per-peer limiters keyed by PeerID string
+	GetDataLimiter    *IPRateLimiter
+	GetHeadersLimiter *IPRateLimiter
+	MessageLimiter    *IPRateLimiter // All inbound messages (nil = unlimited)
+	PeerScores        map[string]int // PeerID string -> misbehaviour score
+	PeerScoresMux     sync.Mutex
+	Bans              *BanList // Peers refused by the host until their ban expires
+
+	BlocksForged     int // Blocks forged by this node since startup
+	LastForgedHeight int
+	ForgeStatsMux    sync.Mutex
+
+	StartedAt time.Time // Process start, for uptime reporting
+
+	LastTipHeight  int       // Tip height last seen by the stale-tip watchdog
+	LastTipAdvance time.Time // When the tip last advanced
+	TipWatchMux    sync.Mutex
+
+	ctx  context.Context // Cancelled by Stop: ends the background loops and dial retries
+	stop context.CancelFunc
 }
 
 type discoveryNotifee struct {
@@ -78,28 +216,146 @@ func (n *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
 
 	// fmt.Printf("Peer discovered: %s\n", ShortID(pi.ID.String()))
 
-	err := n.h.Connect(context.Background(), pi)
-	if err != nil {
-		errMsg := err.Error()
-		// 2. Improve Error Handling
-		if errMsg == "dial to self attempted" {
-			// Ignore, expected behavior
+	// Retries sleep: don't hold up the mDNS notifications
+	go n.server.dialDiscoveredPeer(pi)
+}
+
+// dialDiscoveredPeer connects to a peer found on the LAN, retrying with a
+// doubling delay while it fails with a retryable error. Only one dial loop
+// runs per peer; rediscoveries during the retries are ignored.
+func (s *Server) dialDiscoveredPeer(pi peer.AddrInfo) {
+	id := pi.ID.String()
+	s.DialRetriesMux.Lock()
+	if _, dialing := s.DialRetries[id]; dialing {
+		s.DialRetriesMux.Unlock()
+		return
+	}
+	s.DialRetries[id] = 0
+	s.DialRetriesMux.Unlock()
+
+	defer func() {
+		s.DialRetriesMux.Lock()
+		delete(s.DialRetries, id)
+		s.DialRetriesMux.Unlock()
+	}()
+
+	backoff := discoveryBaseBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(s.ctx, s.DialTimeout)
+		err := s.Host.Connect(ctx, pi)
+		cancel()
+		if err == nil {
+			// Trigger Handshake immediately upon connection
+			s.SendVersion(pi.ID)
 			return
-		} else if contains(errMsg, "i/o timeout") || contains(errMsg, "no good addresses") {
-			// Debug level for network noise
-		} else if contains(errMsg, "unexpected handshake message") || contains(errMsg, "tls") {
-			fmt.Printf("⚠️  [P2P] TLS Error connecting to %s: %s\n", ShortID(pi.ID.String()), err)
-		} else {
-			fmt.Printf("⚠️  [P2P] Error connecting to %s: %s\n", ShortID(pi.ID.String()), err)
 		}
-	} else {
-		// Trigger Handshake immediately upon connection (only if running as Server)
-		if n.server != nil {
-			n.server.SendVersion(pi.ID)
+
+		if !retryableDialError(pi, err) || attempt > discoveryMaxRetries {
+			return
+		}
+		s.DialRetriesMux.Lock()
+		s.DialRetries[id] = attempt
+		s.DialRetriesMux.Unlock()
+
+		if !s.sleep(backoff) {
+			return
+		}
+		backoff *= 2
+		if s.Host.Network().Connectedness(pi.ID) == network.Connected {
+			return // It dialed us in the meantime
 		}
 	}
 }
 
+// retryableDialError logs a failed dial at the right level and reports
+// whether trying again later may succeed
+func retryableDialError(pi peer.AddrInfo, err error) bool {
+	errMsg := err.Error()
+	switch {
+	case errMsg == "dial to self attempted":
+		// Ignore, expected behavior
+		return false
+	case contains(errMsg, "i/o timeout") || contains(errMsg, "no good addresses") || errors.Is(err, context.DeadlineExceeded):
+		// Debug level for network noise: unreachable for now, often NAT
+		return true
+	case contains(errMsg, "unexpected handshake message") || contains(errMsg, "tls"):
+		fmt.Printf("⚠️  [P2P] TLS Error connecting to %s: %s\n", ShortID(pi.ID.String()), err)
+	default:
+		fmt.Printf("⚠️  [P2P] Error connecting to %s: %s\n", ShortID(pi.ID.String()), err)
+	}
+	return false
+}
+
+// PeerConnected logs a new peer. Extra connections to an already connected
+// peer are only counted. KnownPeers is filled by the version handshake.
+func (s *Server) PeerConnected(_ network.Network, conn network.Conn) {
+	peerID := conn.RemotePeer()
+
+	s.KnownPeersMux.Lock()
+	s.PeerConns[peerID.String()]++
+	first := s.PeerConns[peerID.String()] == 1
+	total := len(s.PeerConns)
+	s.KnownPeersMux.Unlock()
+	if !first {
+		return
+	}
+
+	addr := conn.RemoteMultiaddr().String()
+	direction := strings.ToLower(conn.Stat().Direction.String())
+	fmt.Printf("🔌 [P2P] Peer connected: %s (%s, %s)\n", ShortID(peerID.String()), direction, addr)
+	BroadcastPeerEvent(s.PeerHub, "connected", peerID.String(), addr, direction, total)
+}
+
+// PeerDisconnected drops the per-peer state once the last connection to a
+// peer closes. libp2p gives no close reason, so the log reports how long
+// the connection lasted instead.
+func (s *Server) PeerDisconnected(_ network.Network, conn network.Conn) {
+	peerID := conn.RemotePeer()
+
+	s.KnownPeersMux.Lock()
+	s.PeerConns[peerID.String()]--
+	last := s.PeerConns[peerID.String()] <= 0
+	if last {
+		delete(s.PeerConns, peerID.String())
+	}
+	total := len(s.PeerConns)
+	s.KnownPeersMux.Unlock()
+	if !last {
+		return
+	}
+	s.forgetPeer(peerID)
+
+	addr := conn.RemoteMultiaddr().String()
+	direction := strings.ToLower(conn.Stat().Direction.String())
+	uptime := time.Since(conn.Stat().Opened).Round(time.Second)
+	fmt.Printf("🔌 [P2P] Peer disconnected: %s (%s, connected for %s)\n", ShortID(peerID.String()), direction, uptime)
+	BroadcastPeerEvent(s.PeerHub, "disconnected", peerID.String(), addr, direction, total)
+}
+
+// forgetPeer drops everything learned from a peer's handshake, so a
+// reconnect performs a fresh one. An IBD from that peer is abandoned; the
+// next handshake or the stale-tip watchdog starts a new one. Its rate-limit
+// buckets are kept until idle (see IPRateLimiter.prune), so reconnecting
+// doesn't refill them.
+func (s *Server) forgetPeer(peerID peer.ID) {
+	s.KnownPeersMux.Lock()
+	delete(s.KnownPeers, peerID.String())
+	delete(s.PeerHeights, peerID.String())
+	delete(s.PeerCompress, peerID.String())
+	delete(s.PeerProtocol, peerID.String())
+	s.KnownPeersMux.Unlock()
+
+	s.BlockBufferMux.Lock()
+	if s.IsSyncing && s.SyncingFrom == peerID {
+		fmt.Printf("⚠️  [IBD] Sync peer %s disconnected, abandoning sync at %d/%d blocks\n", ShortID(peerID.String()), len(s.BlockBuffer), s.ExpectedBlocks)
+		s.IsSyncing = false
+		s.SyncingFrom = ""
+		s.BlockBuffer = make(map[int]*Block)
+		s.ExpectedBlocks = 0
+	}
+	s.BlockBufferMux.Unlock()
+}
+
 func contains(s, substr string) bool {
 	// Simple string check using bytes package
 	return bytes.Contains([]byte(s), []byte(substr))
@@ -113,16 +369,39 @@ func ShortID(id string) string {
 }
 
 type ServerConfig struct {
-	ListenHost string
-	Port       int
-	PublicIP   string
-	PublicDNS  string
-	Bootnodes  []string
-	MinerAddr  string
-	PrivKey    *ecdsa.PrivateKey
-	NodeKey    crypto.PrivKey // Identity Key
+	ListenHosts     []string // IPv4 and/or IPv6 addresses to bind
+	Transport       string   // tcp (default), quic or both
+	Port            int
+	PublicIP        string
+	PublicDNS       string
+	Bootnodes       []string
+	ValidatorKeys   []ValidatorKey
+	RewardAddress   string // Optional cold address receiving block rewards
+	CoinbaseMessage string
+	DustLimit       int64
+	MaxTxFee        int64
+	MaxMempoolBytes int
+	MempoolExpiry   time.Duration  // 0 = keep transactions until mined or evicted
+	PruneKeep       int            // Keep this many recent full blocks (0 = archive node)
+	EmptyBlocks     bool           // Keep forging on every tick, even with an empty mempool
+	Light           bool           // Store headers only and fetch blocks from peers on demand
+	DisableMDNS     bool           // Skip LAN discovery, peers come from bootnodes only
+	NoCompression   bool           // Don't offer gzip-compressed P2P payloads
+	DialTimeout     time.Duration  // Per connection attempt (0 = DefaultDialTimeout)
+	MaxMsgRate      float64        // Inbound messages per second per peer (0 = unlimited)
+	NodeKey         crypto.PrivKey // Identity Key
+}
+
+// ValidatorKey is a forging key loaded from the local wallet
+type ValidatorKey struct {
+	Address   string
+	PrivKey   *ecdsa.PrivateKey
+	PubKeyHex string
 }
 
+// NodeKeyFile holds the persistent P2P identity (it pins the PeerID)
+const NodeKeyFile = "node_key.dat"
+
 // LoadOrGenerateNodeKey manages persistent P2P identity
 func LoadOrGenerateNodeKey(keyFile string) (crypto.PrivKey, error) {
 	// Check if file exists
@@ -147,44 +426,209 @@ func LoadOrGenerateNodeKey(keyFile string) (crypto.PrivKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = os.WriteFile(keyFile, data, 0600)
+	err = writeFileAtomic(keyFile, data, 0600)
 	return priv, err
 }
 
+// RotateNodeKey replaces the P2P identity in keyFile with a fresh key. The
+// old key is kept as keyFile.<unix time>.bak so it can be restored.
+func RotateNodeKey(keyFile string) (oldID, newID peer.ID, backupFile string, err error) {
+	oldData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", "", "", err
+	}
+	oldKey, err := crypto.UnmarshalPrivateKey(oldData)
+	if err != nil {
+		return "", "", "", fmt.Errorf("current key is unreadable: %w", err)
+	}
+	if oldID, err = peer.IDFromPrivateKey(oldKey); err != nil {
+		return "", "", "", err
+	}
+
+	newKey, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+	if err != nil {
+		return "", "", "", err
+	}
+	newData, err := crypto.MarshalPrivateKey(newKey)
+	if err != nil {
+		return "", "", "", err
+	}
+	if newID, err = peer.IDFromPrivateKey(newKey); err != nil {
+		return "", "", "", err
+	}
+
+	// Back up first: if the swap fails, the old identity is still in place
+	backupFile = fmt.Sprintf("%s.%d.bak", keyFile, time.Now().Unix())
+	if err := writeFileAtomic(backupFile, oldData, 0600); err != nil {
+		return "", "", "", fmt.Errorf("backing up the current key: %w", err)
+	}
+	if err := writeFileAtomic(keyFile, newData, 0600); err != nil {
+		return "", "", "", err
+	}
+	return oldID, newID, backupFile, nil
+}
+
+// P2P transports selectable with --transport
+const (
+	TransportTCP  = "tcp"
+	TransportQUIC = "quic"
+	TransportBoth = "both"
+)
+
+// transportSuffixes returns the multiaddr suffixes (after the IP/DNS part)
+// for the selected transport.
+func transportSuffixes(transport string, port int) ([]string, error) {
+	tcpSuffix := fmt.Sprintf("/tcp/%d", port)
+	quicSuffix := fmt.Sprintf("/udp/%d/quic-v1", port)
+
+	switch transport {
+	case "", TransportTCP:
+		return []string{tcpSuffix}, nil
+	case TransportQUIC:
+		return []string{quicSuffix}, nil
+	case TransportBoth:
+		return []string{tcpSuffix, quicSuffix}, nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q (expected tcp, quic or both)", transport)
+	}
+}
+
+// transportOptions restricts libp2p to the selected transports
+func transportOptions(transport string) []libp2p.Option {
+	switch transport {
+	case TransportQUIC:
+		return []libp2p.Option{libp2p.Transport(quic.NewTransport)}
+	case TransportBoth:
+		return []libp2p.Option{libp2p.Transport(tcp.NewTCPTransport), libp2p.Transport(quic.NewTransport)}
+	default:
+		return []libp2p.Option{libp2p.Transport(tcp.NewTCPTransport)}
+	}
+}
+
+// PublicAnnounceAddrs builds the externally reachable multiaddrs from the
+// configured public DNS name and/or public IP, one per enabled transport.
+func PublicAnnounceAddrs(cfg ServerConfig) ([]multiaddr.Multiaddr, error) {
+	var addrs []multiaddr.Multiaddr
+
+	suffixes, err := transportSuffixes(cfg.Transport, cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, suffix := range suffixes {
+		if cfg.PublicDNS != "" {
+			ma, err := multiaddr.NewMultiaddr(fmt.Sprintf("/dns4/%s%s", cfg.PublicDNS, suffix))
+			if err != nil {
+				return nil, fmt.Errorf("public DNS %q: %w", cfg.PublicDNS, err)
+			}
+			addrs = append(addrs, ma)
+		}
+		if cfg.PublicIP != "" {
+			ma, err := ipMultiaddr(cfg.PublicIP, suffix)
+			if err != nil {
+				return nil, fmt.Errorf("public IP %q: %w", cfg.PublicIP, err)
+			}
+			addrs = append(addrs, ma)
+		}
+	}
+
+	return addrs, nil
+}
+
+// ipMultiaddr builds /ip4/<ip><suffix> or /ip6/<ip><suffix> for a literal IP
+func ipMultiaddr(ip string, suffix string) (multiaddr.Multiaddr, error) {
+	parsed := net.ParseIP(strings.Trim(ip, "[]"))
+	if parsed == nil {
+		return nil, fmt.Errorf("not an IP address")
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/%s%s", v4, suffix))
+	}
+	return multiaddr.NewMultiaddr(fmt.Sprintf("/ip6/%s%s", parsed, suffix))
+}
+
+// ListenMultiaddrs validates the listen hosts (e.g. "0.0.0.0", "::") and
+// converts them to multiaddrs for the selected transport, dropping duplicates.
+func ListenMultiaddrs(hosts []string, port int, transport string) ([]multiaddr.Multiaddr, error) {
+	suffixes, err := transportSuffixes(transport, port)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []multiaddr.Multiaddr
+	for _, host := range hosts {
+		for _, suffix := range suffixes {
+			ma, err := ipMultiaddr(host, suffix)
+			if err != nil {
+				return nil, fmt.Errorf("listen address %q: %w", host, err)
+			}
+			duplicate := false
+			for _, existing := range addrs {
+				if existing.Equal(ma) {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate {
+				addrs = append(addrs, ma)
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no listen address configured")
+	}
+	return addrs, nil
+}
+
+// announceAddrsFactory puts the public addresses first and keeps the
+// non-loopback listen addresses so LAN peers can still dial us directly.
+func announceAddrsFactory(public []multiaddr.Multiaddr) func([]multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	return func(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+		result := append([]multiaddr.Multiaddr{}, public...)
+		for _, addr := range addrs {
+			if manet.IsIPLoopback(addr) {
+				continue
+			}
+			duplicate := false
+			for _, existing := range result {
+				if existing.Equal(addr) {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate {
+				result = append(result, addr)
+			}
+		}
+		return result
+	}
+}
+
 // NewServer initializes the P2P server
 func NewServer(cfg ServerConfig) *Server {
 	// Use persistent identity
 	priv := cfg.NodeKey
 
-	listenAddr := fmt.Sprintf("/ip4/%s/tcp/%d", cfg.ListenHost, cfg.Port)
+	listenAddrs, err := ListenMultiaddrs(cfg.ListenHosts, cfg.Port, cfg.Transport)
+	if err != nil {
+		log.Fatalf("Fatal: Invalid listen address: %v", err)
+	}
+	bans := NewBanList()
 	opts := []libp2p.Option{
-		libp2p.ListenAddrStrings(listenAddr),
+		libp2p.ListenAddrs(listenAddrs...),
 		libp2p.Identity(priv),
+		libp2p.ConnectionGater(bans),
 		// Enable NAT traversal
 	}
+	opts = append(opts, transportOptions(cfg.Transport)...)
 
 	// Handle Public IP/DNS Announcement (NAT Traversal)
-	if cfg.PublicDNS != "" {
-		externalAddr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/dns4/%s/tcp/%d", cfg.PublicDNS, cfg.Port))
-		if err != nil {
-			log.Fatalf("Fatal: Invalid Public DNS Multiaddr: %v", err)
-		}
-		addrFactory := func(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
-			return []multiaddr.Multiaddr{externalAddr}
-		}
-		opts = append(opts, libp2p.AddrsFactory(addrFactory))
-		opts = append(opts, libp2p.ForceReachabilityPublic())
-	} else if cfg.PublicIP != "" {
-		externalAddr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d", cfg.PublicIP, cfg.Port))
-		if err != nil {
-			log.Fatalf("Fatal: Invalid Public IP Multiaddr: %v", err)
-		}
-
-		// Factory to force announcing ONLY the external address
-		addrFactory := func(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
-			return []multiaddr.Multiaddr{externalAddr}
-		}
-		opts = append(opts, libp2p.AddrsFactory(addrFactory))
+	announceAddrs, err := PublicAnnounceAddrs(cfg)
+	if err != nil {
+		log.Fatalf("Fatal: Invalid public announce address: %v", err)
+	}
+	if len(announceAddrs) > 0 {
+		opts = append(opts, libp2p.AddrsFactory(announceAddrsFactory(announceAddrs)))
 		opts = append(opts, libp2p.ForceReachabilityPublic())
 	}
 
@@ -193,6 +637,37 @@ func NewServer(cfg ServerConfig) *Server {
 		log.Fatalf("Fatal: Failed to start libp2p host: %v", err)
 	}
 
+	// Report what was actually bound (libp2p only fails if nothing binds)
+	bound := h.Network().ListenAddresses()
+	for _, want := range listenAddrs {
+		ok := false
+		for _, addr := range bound {
+			if addr.Equal(want) {
+				ok = true
+				break
+			}
+		}
+		if ok {
+			fmt.Printf("🔌 Listening on %s\n", want)
+		} else {
+			fmt.Printf("⚠️  Could not bind %s\n", want)
+		}
+	}
+
+	// Verify the public addresses are actually advertised
+	for _, announced := range announceAddrs {
+		found := false
+		for _, addr := range h.Addrs() {
+			if addr.Equal(announced) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fmt.Printf("⚠️  Public address %s is not being announced\n", announced)
+		}
+	}
+
 	// Using Default Bootnodes if needed
 	bootnodesToUse := cfg.Bootnodes
 	if len(bootnodesToUse) == 0 {
@@ -201,33 +676,138 @@ func NewServer(cfg ServerConfig) *Server {
 
 	chain := ContinueBlockchain("")
 	UTXOSet := &UTXOSet{chain}
+	if err := chain.CheckLightMode(cfg.Light); err != nil {
+		log.Fatalf("Fatal: %v", err)
+	}
+	if cfg.Light {
+		if err := chain.MarkLight(); err != nil {
+			log.Fatalf("Fatal: Marking the database light: %v", err)
+		}
+	}
 
 	mempoolHub := NewEventHub()
 	go mempoolHub.Run()
 	blockHub := NewEventHub()
 	go blockHub.Run()
+	peerHub := NewEventHub()
+	go peerHub.Run()
+
+	if err := chain.EnableBlockFilter(); err != nil {
+		fmt.Printf("⚠️  [Inv] Block filter disabled, every lookup hits the database: %v\n", err)
+	} else {
+		fmt.Printf("🧮 [Inv] Block filter loaded with %d block hashes\n", chain.KnownBlocks.Count())
+	}
+	seenTxs := NewRollingBloomFilter(seenTxFilterCapacity, seenTxFilterFPRate)
+
+	if cfg.PruneKeep > 0 {
+		if n, err := chain.PruneBlocks(cfg.PruneKeep); err != nil {
+			fmt.Printf("⚠️  [Prune] %v\n", err)
+		} else if n > 0 {
+			fmt.Printf("✂️  [Prune] Discarded the bodies of %d blocks (keeping the last %d)\n", n, cfg.PruneKeep)
+		}
+	}
+
+	validatorStats := NewValidatorStatsTracker()
+	validatorStats.Rebuild(chain)
+	txWatcher := NewTxWatcher()
+	tipNotifier := NewTipNotifier()
+	chain.OnTipExtended = func(block *Block) {
+		validatorStats.Record(block)
+		txWatcher.NotifyBlock(block)
+		tipNotifier.Notify()
+		for _, tx := range block.Transactions {
+			seenTxs.Add(tx.ID)
+		}
+		if cfg.PruneKeep > 0 && block.Height%pruneInterval == 0 {
+			if _, err := chain.PruneBlocks(cfg.PruneKeep); err != nil {
+				fmt.Printf("⚠️  [Prune] %v\n", err)
+			}
+		}
+	}
+
+	// Reorgs are published on the block stream so clients can drop orphaned blocks
+	chain.OnReorg = func(reorg ReorgInfo) {
+		validatorStats.Rebuild(chain)
+		BroadcastReorg(blockHub, reorg)
+		tipNotifier.Notify()
+	}
 
 	server := &Server{
-		Host:             h,
-		Blockchain:       chain,
-		UTXOSet:          UTXOSet,
-		MinerAddr:        cfg.MinerAddr,
-		ValidatorPrivKey: cfg.PrivKey,
-		KnownPeers:       make(map[string]string),
-		Mempool:          make(map[string]MempoolItem),
-		MempoolHub:       mempoolHub,
-		BlockHub:         blockHub,
-		BlockBuffer:      make(map[int]*Block),
+		Host:              h,
+		Blockchain:        chain,
+		UTXOSet:           UTXOSet,
+		RewardAddress:     cfg.RewardAddress,
+		CoinbaseMessage:   cfg.CoinbaseMessage,
+		DustLimit:         cfg.DustLimit,
+		MaxTxFee:          cfg.MaxTxFee,
+		MaxMempoolBytes:   cfg.MaxMempoolBytes,
+		MempoolExpiry:     cfg.MempoolExpiry,
+		PruneKeep:         cfg.PruneKeep,
+		EmptyBlocks:       cfg.EmptyBlocks,
+		Light:             cfg.Light,
+		Fetcher:           NewBlockFetcher(),
+		ValidatorKeys:     cfg.ValidatorKeys,
+		KnownPeers:        make(map[string]string),
+		PeerHeights:       make(map[string]int),
+		PeerConns:         make(map[string]int),
+		PeerCompress:      make(map[string]bool),
+		PeerProtocol:      make(map[string]int),
+		Compression:       !cfg.NoCompression,
+		DialTimeout:       cfg.DialTimeout,
+		DialRetries:       make(map[string]int),
+		Mempool:           make(map[string]MempoolItem),
+		WaitingTxs:        make(map[string]MempoolItem),
+		Replacements:      make(map[string]Replacement),
+		Revocations:       make(map[string]ValidatorRevocation),
+		MempoolHub:        mempoolHub,
+		BlockHub:          blockHub,
+		PeerHub:           peerHub,
+		BlockBuffer:       make(map[int]*Block),
+		LastTipAdvance:    time.Now(),
+		StartedAt:         time.Now(),
+		GetBlocksLimiter:  NewIPRateLimiter(getBlocksRate, getBlocksBurst),
+		GetDataLimiter:    NewIPRateLimiter(getDataRate, getDataBurst),
+		GetHeadersLimiter: NewIPRateLimiter(getHeadersRate, getHeadersBurst),
+		PeerScores:        make(map[string]int),
+		Bans:              bans,
+		ValidatorStats:    validatorStats,
+		TxWatcher:         txWatcher,
+		TipNotifier:       tipNotifier,
+		Orphans:           NewOrphanTracker(),
+		TxAcks:            NewTxAckTracker(),
+		SeenTxs:           seenTxs,
+	}
+	server.ctx, server.stop = context.WithCancel(context.Background())
+	if server.DialTimeout <= 0 {
+		server.DialTimeout = DefaultDialTimeout
+	}
+	if cfg.MaxMsgRate > 0 {
+		burst := max(int(cfg.MaxMsgRate*msgBurstSeconds), 1)
+		server.MessageLimiter = NewIPRateLimiter(rate.Limit(cfg.MaxMsgRate), burst)
+	}
+	if len(cfg.ValidatorKeys) > 0 {
+		server.MinerAddr = cfg.ValidatorKeys[0].Address
+		server.ValidatorPrivKey = cfg.ValidatorKeys[0].PrivKey
 	}
 
 	// Set Stream Handler
 	h.SetStreamHandler(protocolID, server.HandleStream)
 
+	// Log peer churn and forget disconnected peers
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF:    server.PeerConnected,
+		DisconnectedF: server.PeerDisconnected,
+	})
+
 	// Setup mDNS Discovery (Still useful for LAN)
-	notifee := &discoveryNotifee{h: h, server: server}
-	ser := mdns.NewMdnsService(h, discoveryNamespace, notifee)
-	if err := ser.Start(); err != nil {
-		log.Panic(err)
+	if !cfg.DisableMDNS {
+		notifee := &discoveryNotifee{h: h, server: server}
+		ser := mdns.NewMdnsService(h, discoveryNamespace, notifee)
+		if err := ser.Start(); err != nil {
+			log.Panic(err)
+		}
+	} else {
+		fmt.Println("ℹ️  mDNS discovery disabled: peers come from bootnodes only")
 	}
 
 	// Bootstrap (Internet Discovery)
@@ -235,78 +815,366 @@ func NewServer(cfg ServerConfig) *Server {
 		go server.Bootstrap(bootnodesToUse)
 	}
 
-	fmt.Println()
-	fmt.Println(ColorGreen + "──────────────────────────────────────────────────────────────────────" + ColorReset)
-	fmt.Printf(" ☀️  SOLE NODE STARTED (Port: "+ColorYellow+"%d"+ColorReset+")\n", cfg.Port)
-	fmt.Printf(" 🆔 Peer ID: "+ColorCyan+"%s"+ColorReset+"\n", h.ID().String())
-	fmt.Println(ColorGreen + "──────────────────────────────────────────────────────────────────────" + ColorReset)
-	fmt.Println()
-	fmt.Println(" 🔗 Listen Addresses:")
+	return server
+}
 
-	for _, addr := range h.Addrs() {
-		// Construct full multiaddr: /ip4/x.x.x.x/tcp/3000/p2p/Qm...
-		fullAddr := fmt.Sprintf("%s/p2p/%s", addr, h.ID().String())
+// NewAPIOnlyServer wraps an existing chain in a Server with no P2P host and
+// no validator key. Used by 'node serve-api' to back the REST API only.
+func NewAPIOnlyServer(chain *Blockchain) *Server {
+	mempoolHub := NewEventHub()
+	go mempoolHub.Run()
+	blockHub := NewEventHub()
+	go blockHub.Run()
+	peerHub := NewEventHub()
+	go peerHub.Run()
 
-		// Visual emphasis for public/LAN IPs
-		if strings.Contains(fullAddr, "/127.0.0.1/") {
-			fmt.Printf("   "+ColorYellow+"(Local)"+ColorReset+"  %s\n", fullAddr)
-		} else {
-			fmt.Printf("   "+ColorGreen+"👉(Public)"+ColorReset+" %s\n", fullAddr)
-		}
+	validatorStats := NewValidatorStatsTracker()
+	validatorStats.Rebuild(chain)
+
+	server := &Server{
+		Blockchain:   chain,
+		UTXOSet:      &UTXOSet{chain},
+		KnownPeers:   make(map[string]string),
+		PeerHeights:  make(map[string]int),
+		PeerConns:    make(map[string]int),
+		PeerCompress: make(map[string]bool),
+		PeerProtocol: make(map[string]int),
+		Mempool:      make(map[string]MempoolItem),
+		WaitingTxs:   make(map[string]MempoolItem),
+		Replacements: make(map[string]Replacement),
+		Revocations:  make(map[string]ValidatorRevocation),
+		MempoolHub:   mempoolHub,
+		BlockHub:     blockHub,
+		PeerHub:      peerHub,
+		BlockBuffer:  make(map[int]*Block),
+		PeerScores:   make(map[string]int),
+		Bans:         NewBanList(),
+
+		LastTipAdvance: time.Now(),
+		StartedAt:      time.Now(),
+		ValidatorStats: validatorStats,
+		TxWatcher:      NewTxWatcher(),
+		TipNotifier:    NewTipNotifier(),
+		Orphans:        NewOrphanTracker(),
+		TxAcks:         NewTxAckTracker(),
+		Fetcher:        NewBlockFetcher(),
 	}
+	server.ctx, server.stop = context.WithCancel(context.Background())
 	return server
 }
 
-// Bootstrap attempts to connect to seed nodes
-func (s *Server) Bootstrap(bootnodes []string) {
-	fmt.Printf("🔄 Bootstrapping: Connecting to %d seed nodes...\n", len(bootnodes))
+// IsAPIOnly reports whether the server runs without a P2P host
+func (s *Server) IsAPIOnly() bool {
+	return s.Host == nil
+}
+
+// Bootstrap attempts to connect to seed nodes, retrying unreachable ones with
+// exponential backoff, then keeps re-dialing any bootnode that drops.
+func (s *Server) Bootstrap(bootnodes []string) {
+	fmt.Printf("🔄 Bootstrapping: Connecting to %d seed nodes...\n", len(bootnodes))
+
+	var seeds []peer.AddrInfo
+	for _, addr := range bootnodes {
+		pi, err := ParseBootnode(addr)
+		if err != nil {
+			fmt.Printf("⚠️  Invalid bootnode %s: %s\n", addr, err)
+			continue
+		}
+
+		// Self-Dial Check
+		if pi.ID == s.Host.ID() {
+			fmt.Println("ℹ️  Skipping bootnode (Self-Dial detected)")
+			continue
+		}
+
+		seeds = append(seeds, *pi)
+	}
+
+	var wg sync.WaitGroup
+	var resultsMux sync.Mutex
+	var succeeded, failed []string
+	for _, pi := range seeds {
+		wg.Add(1)
+		go func(pi peer.AddrInfo) {
+			defer wg.Done()
+			ok := s.dialBootnodeWithBackoff(pi)
+
+			resultsMux.Lock()
+			if ok {
+				succeeded = append(succeeded, pi.ID.String())
+			} else {
+				failed = append(failed, pi.ID.String())
+			}
+			resultsMux.Unlock()
+		}(pi)
+	}
+	wg.Wait()
+
+	fmt.Printf("📋 Bootstrap summary: %d/%d bootnodes reachable\n", len(succeeded), len(bootnodes))
+	for _, id := range succeeded {
+		fmt.Printf("   ✅ %s\n", ShortID(id))
+	}
+	for _, id := range failed {
+		fmt.Printf("   ⛔ %s\n", ShortID(id))
+	}
+
+	if len(succeeded) > 0 {
+		fmt.Println("🚀 Bootstrap completed successfully.")
+	} else if len(bootnodes) > 0 {
+		fmt.Println("⚠️  Bootstrap failed: No bootnodes reachable.")
+	}
+
+	if len(seeds) > 0 && s.ctx.Err() == nil {
+		go s.bootnodeReconnectLoop(seeds)
+	}
+}
+
+// ParseBootnode converts a full bootnode multiaddr
+// (e.g. /ip4/1.2.3.4/tcp/3000/p2p/12D3KooW...) into a dialable AddrInfo.
+func ParseBootnode(addr string) (*peer.AddrInfo, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return nil, fmt.Errorf("empty address")
+	}
+
+	ma, err := multiaddr.NewMultiaddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ma.ValueForProtocol(multiaddr.P_P2P); err != nil {
+		return nil, fmt.Errorf("missing /p2p/<peer-id> suffix")
+	}
+
+	return peer.AddrInfoFromP2pAddr(ma)
+}
+
+// dialBootnode connects to a single bootnode and triggers the handshake
+func (s *Server) dialBootnode(pi peer.AddrInfo) error {
+	ctx, cancel := context.WithTimeout(s.ctx, s.DialTimeout)
+	defer cancel()
+
+	if err := s.Host.Connect(ctx, pi); err != nil {
+		return err
+	}
+	// Trigger sync immediately
+	s.SendVersion(pi.ID)
+	return nil
+}
+
+// dialBootnodeWithBackoff retries a bootnode up to bootnodeMaxRetries times,
+// doubling the delay between attempts (capped at bootnodeMaxBackoff).
+func (s *Server) dialBootnodeWithBackoff(pi peer.AddrInfo) bool {
+	backoff := bootnodeBaseBackoff
+
+	for attempt := 1; attempt <= bootnodeMaxRetries; attempt++ {
+		err := s.dialBootnode(pi)
+		if err == nil {
+			fmt.Printf("✅ Connected to bootnode: %s\n", ShortID(pi.ID.String()))
+			return true
+		}
+
+		if attempt == bootnodeMaxRetries {
+			fmt.Printf("⚠️  Failed to connect to bootnode %s after %d attempts: %s\n", ShortID(pi.ID.String()), attempt, err)
+			break
+		}
+
+		fmt.Printf("⚠️  Bootnode %s unreachable (attempt %d/%d), retrying in %s...\n", ShortID(pi.ID.String()), attempt, bootnodeMaxRetries, backoff)
+		if !s.sleep(backoff) {
+			break
+		}
+
+		backoff *= 2
+		if backoff > bootnodeMaxBackoff {
+			backoff = bootnodeMaxBackoff
+		}
+	}
+
+	return false
+}
+
+// bootnodeReconnectLoop periodically re-dials bootnodes that are not connected
+func (s *Server) bootnodeReconnectLoop(seeds []peer.AddrInfo) {
+	ticker := time.NewTicker(bootnodeReconnectInterval)
+	defer ticker.Stop()
+
+	for s.tick(ticker) {
+		for _, pi := range seeds {
+			if s.Host.Network().Connectedness(pi.ID) == network.Connected {
+				continue
+			}
+			if err := s.dialBootnode(pi); err == nil {
+				fmt.Printf("🔄 Reconnected to bootnode: %s\n", ShortID(pi.ID.String()))
+			}
+		}
+	}
+}
+
+// Start runs the P2P server loop, blocking until Stop
+func (s *Server) Start() {
+	fmt.Println("Waiting for connections...")
+
+	go s.StaleTipWatchdog()
+	go s.SyncProgressReporter()
+	if s.MempoolExpiry > 0 {
+		go s.MempoolExpiryLoop()
+	}
+
+	<-s.ctx.Done()
+}
+
+// Stop ends the background loops and pending dial retries. Called on
+// shutdown before the host and the database are closed.
+func (s *Server) Stop() {
+	s.stop()
+}
+
+// sleep waits for d and reports false if the server was stopped first
+func (s *Server) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// tick waits for the next tick and reports false if the server was stopped first
+func (s *Server) tick(ticker *time.Ticker) bool {
+	select {
+	case <-ticker.C:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// RecordPeerHeight remembers the highest best height a peer has reported
+func (s *Server) RecordPeerHeight(peerID peer.ID, height int) {
+	s.KnownPeersMux.Lock()
+	if height > s.PeerHeights[peerID.String()] {
+		s.PeerHeights[peerID.String()] = height
+	}
+	s.KnownPeersMux.Unlock()
+}
+
+// BestPeerHeight returns the connected peer reporting the highest best height
+func (s *Server) BestPeerHeight() (peer.ID, int) {
+	var bestPeer peer.ID
+	bestHeight := -1
+
+	s.KnownPeersMux.RLock()
+	defer s.KnownPeersMux.RUnlock()
+	for id, h := range s.PeerHeights {
+		pid, err := peer.Decode(id)
+		if err != nil || h <= bestHeight {
+			continue
+		}
+		if s.Host.Network().Connectedness(pid) == network.Connected {
+			bestPeer = pid
+			bestHeight = h
+		}
+	}
+	return bestPeer, bestHeight
+}
+
+// SyncProgressReporter prints a single updating "Syncing X/Y blocks" line
+// while IBD is running, and "✅ Synced" once we catch up with the best peer.
+func (s *Server) SyncProgressReporter() {
+	ticker := time.NewTicker(syncProgressInterval)
+	defer ticker.Stop()
+
+	reporting := false
+	for s.tick(ticker) {
+		s.BlockBufferMux.Lock()
+		syncing := s.IsSyncing
+		buffered := len(s.BlockBuffer)
+		expected := s.ExpectedBlocks
+		s.BlockBufferMux.Unlock()
+
+		height := s.Blockchain.GetBestHeight()
+		_, target := s.BestPeerHeight()
+
+		if !syncing {
+			if reporting && height >= target {
+				fmt.Printf("\r✅ Synced (height %d)%s\n", height, strings.Repeat(" ", 30))
+				reporting = false
+			}
+			continue
+		}
+
+		// Buffered IBD blocks are only applied at the end, so count them as progress
+		current := height + buffered
+		if height+expected > target {
+			target = height + expected
+		}
+		if target <= 0 {
+			continue
+		}
+		if current > target {
+			current = target
+		}
+
+		reporting = true
+		fmt.Printf("\r⏳ Syncing %d/%d blocks (%.1f%%)", current, target, float64(current)*100/float64(target))
+	}
+}
+
+// StaleTipWatchdog re-issues getblocks to the best known peer when the local
+// tip has not advanced for staleTipTimeout while a peer reports a higher height.
+func (s *Server) StaleTipWatchdog() {
+	ticker := time.NewTicker(staleTipCheckInterval)
+	defer ticker.Stop()
 
-	validNodes := 0
-	for _, addr := range bootnodes {
-		ma, err := multiaddr.NewMultiaddr(addr)
-		if err != nil {
-			fmt.Printf("⚠️  Invalid bootnode address %s: %s\n", addr, err)
-			continue
+	for s.tick(ticker) {
+		height := s.Blockchain.GetBestHeight()
+
+		s.TipWatchMux.Lock()
+		if height != s.LastTipHeight {
+			s.LastTipHeight = height
+			s.LastTipAdvance = time.Now()
 		}
+		stalledFor := time.Since(s.LastTipAdvance)
+		s.TipWatchMux.Unlock()
 
-		pi, err := peer.AddrInfoFromP2pAddr(ma)
-		if err != nil {
-			fmt.Printf("⚠️  Invalid bootnode info %s: %s\n", addr, err)
+		if stalledFor < staleTipTimeout {
 			continue
 		}
 
-		// Self-Dial Check
-		if pi.ID == s.Host.ID() {
-			fmt.Println("ℹ️  Skipping bootnode (Self-Dial detected)")
+		bestPeer, bestHeight := s.BestPeerHeight()
+		if bestPeer == "" || bestHeight <= height {
 			continue
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		err = s.Host.Connect(ctx, *pi)
-		cancel()
+		fmt.Printf("⚠️  [Sync] Tip stalled at height %d for %s (peer %s reports %d). Resyncing...\n",
+			height, stalledFor.Round(time.Second), ShortID(bestPeer.String()), bestHeight)
 
-		if err != nil {
-			fmt.Printf("⚠️  Failed to connect to bootnode %s: %s\n", ShortID(pi.ID.String()), err)
-		} else {
-			fmt.Printf("✅ Connected to bootnode: %s\n", ShortID(pi.ID.String()))
-			validNodes++
-			// Trigger sync immediately
-			s.SendVersion(pi.ID)
+		if s.Light {
+			s.TipWatchMux.Lock()
+			s.LastTipAdvance = time.Now()
+			s.TipWatchMux.Unlock()
+			s.syncHeaders(bestPeer)
+			continue
 		}
-	}
 
-	if validNodes > 0 {
-		fmt.Println("🚀 Bootstrap completed successfully.")
-	} else if len(bootnodes) > 0 {
-		fmt.Println("⚠️  Bootstrap failed: No bootnodes reachable.")
-	}
-}
+		s.BlockBufferMux.Lock()
+		s.IsSyncing = true
+		s.SyncingFrom = bestPeer
+		s.syncWireStart = s.Wire.received()
+		s.BlockBuffer = make(map[int]*Block)
+		s.ExpectedBlocks = 0
+		s.BlockBufferMux.Unlock()
 
-// Start runs the P2P server loop (blocking)
-func (s *Server) Start() {
-	fmt.Println("Waiting for connections...")
+		// Give the resync a full timeout window before trying again
+		s.TipWatchMux.Lock()
+		s.LastTipAdvance = time.Now()
+		s.TipWatchMux.Unlock()
 
-	select {} // block forever
+		s.SendGetBlocks(bestPeer)
+	}
 }
 
 func (s *Server) HandleStream(stream network.Stream) {
@@ -318,6 +1186,14 @@ func (s *Server) HandleStream(stream network.Stream) {
 func (s *Server) ReadData(stream network.Stream, peerID peer.ID) {
 	defer stream.Close()
 
+	// Every message comes on its own stream, so a flood is a stream flood
+	if s.MessageLimiter != nil && !s.isSyncPeer(peerID) && !s.MessageLimiter.GetLimiter(peerID.String()).Allow() {
+		fmt.Printf("⛔ [P2P] Disconnecting %s: more than %.0f messages/s\n", ShortID(peerID.String()), float64(s.MessageLimiter.r))
+		s.PenalizePeer(peerID, floodPenalty)
+		s.Host.Network().ClosePeer(peerID)
+		return
+	}
+
 	// Read 4-byte length prefix (big-endian)
 	lenBuf := make([]byte, 4)
 	_, err := io.ReadFull(stream, lenBuf)
@@ -329,7 +1205,7 @@ func (s *Server) ReadData(stream network.Stream, peerID peer.ID) {
 	}
 
 	payloadLen := binary.BigEndian.Uint32(lenBuf)
-	if payloadLen == 0 || payloadLen > 8*1024*1024 { // 8MB safety cap
+	if payloadLen == 0 || payloadLen > maxP2PPayload {
 		log.Printf("⚠️ Invalid payload length from %s: %d bytes. Dropping.", ShortID(peerID.String()), payloadLen)
 		return
 	}
@@ -342,6 +1218,15 @@ func (s *Server) ReadData(stream network.Stream, peerID peer.ID) {
 		return
 	}
 
+	s.Wire.RecvWire.Add(int64(payloadLen))
+	if payload[0] == compressedFrame {
+		if payload, err = decompressPayload(payload); err != nil {
+			log.Printf("⚠️ Invalid compressed payload from %s: %v. Dropping.", ShortID(peerID.String()), err)
+			return
+		}
+	}
+	s.Wire.RecvRaw.Add(int64(len(payload)))
+
 	if len(payload) < commandLength {
 		return
 	}
@@ -349,6 +1234,22 @@ func (s *Server) ReadData(stream network.Stream, peerID peer.ID) {
 	command := BytesToCommand(payload[:commandLength])
 	content := payload[commandLength:]
 
+	// Expensive requests (full chain walk / block serialization) are throttled per peer
+	var limiter *IPRateLimiter
+	switch command {
+	case "getblocks":
+		limiter = s.GetBlocksLimiter
+	case "getdata", "gettxblock":
+		limiter = s.GetDataLimiter
+	case "getheaders":
+		limiter = s.GetHeadersLimiter
+	}
+	if limiter != nil && !limiter.GetLimiter(peerID.String()).Allow() {
+		log.Printf("⚠️ [P2P] Throttled '%s' from %s (rate limit exceeded)", command, ShortID(peerID.String()))
+		s.PenalizePeer(peerID, throttlePenalty)
+		return
+	}
+
 	switch command {
 	case "version":
 		s.HandleVersion(content, peerID)
@@ -358,20 +1259,58 @@ func (s *Server) ReadData(stream network.Stream, peerID peer.ID) {
 		s.HandleGetBlocks(content, peerID)
 	case "getdata":
 		s.HandleGetData(content, peerID)
+	case "getheaders":
+		s.HandleGetHeaders(content, peerID)
+	case "headers":
+		s.HandleHeaders(content, peerID)
+	case "gettxblock":
+		s.HandleGetTxBlock(content, peerID)
 	case "block":
 		s.HandleBlock(content, peerID)
 	case "tx":
 		s.HandleTx(content, peerID)
+	case "txack":
+		s.HandleTxAck(content, peerID)
+	case "revocation":
+		s.HandleRevocation(content, peerID)
 	default:
 		fmt.Println("Unknown command")
 	}
 }
 
+// isSyncPeer reports whether peerID is serving the current IBD
+func (s *Server) isSyncPeer(peerID peer.ID) bool {
+	s.BlockBufferMux.Lock()
+	defer s.BlockBufferMux.Unlock()
+	return s.IsSyncing && s.SyncingFrom == peerID
+}
+
+// PenalizePeer increases a peer's misbehaviour score. Scores survive
+// disconnects; once one reaches banScoreThreshold the peer is disconnected
+// and banned for BanDuration, after which it starts again from 0.
+func (s *Server) PenalizePeer(peerID peer.ID, penalty int) {
+	s.PeerScoresMux.Lock()
+	s.PeerScores[peerID.String()] += penalty
+	score := s.PeerScores[peerID.String()]
+	if score >= banScoreThreshold {
+		delete(s.PeerScores, peerID.String()) // The ban takes over
+	}
+	s.PeerScoresMux.Unlock()
+
+	if score >= banScoreThreshold {
+		fmt.Printf("⛔ [P2P] Banning %s for %s: misbehaviour score %d\n", ShortID(peerID.String()), BanDuration, score)
+		s.Bans.Ban(peerID, time.Now(), BanDuration)
+		s.Host.Network().ClosePeer(peerID)
+	}
+}
+
 // Helper structs for messages
 type Version struct {
-	Version    int
-	BestHeight int
-	AddrFrom   string
+	Version     int
+	BestHeight  int
+	AddrFrom    string
+	Compression bool // Sender accepts compressed frames (older peers leave it false)
+	Light       bool // Sender stores headers only and serves no blocks (node start --light)
 }
 
 type Inv struct {
@@ -396,6 +1335,13 @@ type TxMsg struct {
 	Transaction []byte
 }
 
+// TxAck tells the peer that sent us a transaction that we accepted it
+// (mempool or time-locked waiting pool)
+type TxAck struct {
+	AddrFrom string
+	TxID     []byte
+}
+
 func (s *Server) HandleVersion(request []byte, peerID peer.ID) {
 	var payload Version
 	dec := gob.NewDecoder(bytes.NewReader(request))
@@ -404,6 +1350,23 @@ func (s *Server) HandleVersion(request []byte, peerID peer.ID) {
 		return
 	}
 
+	if payload.Version < MinProtocolVersion {
+		fmt.Printf("⛔ [P2P] Disconnecting %s: protocol version %d is below the minimum %d\n", ShortID(peerID.String()), payload.Version, MinProtocolVersion)
+		s.Host.Network().ClosePeer(peerID)
+		return
+	}
+	negotiated := min(payload.Version, ProtocolVersion)
+
+	// A light peer can't serve the blocks up to its height
+	if !payload.Light {
+		s.RecordPeerHeight(peerID, payload.BestHeight)
+	}
+
+	s.KnownPeersMux.Lock()
+	s.PeerCompress[peerID.String()] = payload.Compression
+	s.PeerProtocol[peerID.String()] = negotiated
+	s.KnownPeersMux.Unlock()
+
 	// Duplicate Handshake Check
 	s.KnownPeersMux.RLock()
 	_, ok := s.KnownPeers[peerID.String()]
@@ -412,7 +1375,7 @@ func (s *Server) HandleVersion(request []byte, peerID peer.ID) {
 		return
 	}
 
-	fmt.Printf("🤝 [Handshake] Connected to: %s (Remote) | Version: %d | BestHeight: %d\n", ShortID(peerID.String()), payload.Version, payload.BestHeight)
+	fmt.Printf("🤝 [Handshake] Connected to: %s (Remote) | Protocol: v%d (using v%d) | BestHeight: %d\n", ShortID(peerID.String()), payload.Version, negotiated, payload.BestHeight)
 
 	s.KnownPeersMux.Lock()
 	s.KnownPeers[peerID.String()] = payload.AddrFrom
@@ -421,19 +1384,32 @@ func (s *Server) HandleVersion(request []byte, peerID peer.ID) {
 	myBestHeight := s.Blockchain.GetBestHeight()
 	foreignerBestHeight := payload.BestHeight
 
-	if myBestHeight < foreignerBestHeight {
+	if s.Light && !payload.Light && myBestHeight < foreignerBestHeight {
+		fmt.Printf("📥 [Headers] Syncing headers from %s (local: %d, remote: %d)\n", ShortID(peerID.String()), myBestHeight, foreignerBestHeight)
+		s.syncHeaders(peerID)
+	} else if !s.Light && !payload.Light && myBestHeight < foreignerBestHeight {
 		// Initialize IBD state
 		s.BlockBufferMux.Lock()
 		s.IsSyncing = true
 		s.SyncingFrom = peerID
+		s.syncWireStart = s.Wire.received()
 		s.BlockBuffer = make(map[int]*Block)
 		s.BlockBufferMux.Unlock()
 
 		fmt.Printf("📦 [IBD] Starting sync from %s (local: %d, remote: %d)\n", ShortID(peerID.String()), myBestHeight, foreignerBestHeight)
 		s.SendGetBlocks(peerID)
-	} else if myBestHeight > foreignerBestHeight {
-		s.SendVersion(peerID)
 	}
+	// Answer every new handshake, so the dialing side learns our protocol
+	// version (and height) too. Its reply is a duplicate and ends here.
+	s.SendVersion(peerID)
+}
+
+// peerSupports reports whether the protocol negotiated with a peer includes
+// the messages of version v. A peer without a handshake supports none.
+func (s *Server) peerSupports(peerID peer.ID, v int) bool {
+	s.KnownPeersMux.RLock()
+	defer s.KnownPeersMux.RUnlock()
+	return s.PeerProtocol[peerID.String()] >= v
 }
 
 func (s *Server) HandleInv(request []byte, peerID peer.ID) {
@@ -444,11 +1420,21 @@ func (s *Server) HandleInv(request []byte, peerID peer.ID) {
 		return
 	}
 
+	if s.Light {
+		// New blocks are followed by their headers; there is no mempool
+		for _, blockHash := range payload.Items {
+			if payload.Type == "block" && !s.Blockchain.HasBlock(blockHash) {
+				s.syncHeaders(peerID)
+				break
+			}
+		}
+		return
+	}
+
 	if payload.Type == "block" {
 		var needed [][]byte
 		for _, blockHash := range payload.Items {
-			_, err := s.Blockchain.GetBlock(blockHash)
-			if err != nil {
+			if !s.Blockchain.HasBlock(blockHash) {
 				needed = append(needed, blockHash)
 			}
 		}
@@ -477,6 +1463,12 @@ func (s *Server) HandleInv(request []byte, peerID peer.ID) {
 			s.MempoolMux.Lock()
 			exists := s.Mempool[txID].Tx.ID != nil
 			s.MempoolMux.Unlock()
+
+			// A filter hit is confirmed against the tx index before skipping
+			if !exists && s.SeenTxs != nil && s.SeenTxs.Test(payload.Items[0]) && s.Blockchain.HasTransaction(payload.Items[0]) {
+				s.SkippedTxFetches.Add(1)
+				exists = true
+			}
 			if !exists {
 				s.SendGetData(peerID, "tx", payload.Items[0])
 			}
@@ -485,6 +1477,9 @@ func (s *Server) HandleInv(request []byte, peerID peer.ID) {
 }
 
 func (s *Server) HandleGetBlocks(request []byte, peerID peer.ID) {
+	if s.Light {
+		return // Its inventory would point at blocks it can't serve
+	}
 	hashes := s.Blockchain.GetBlockHashes()
 	s.SendInv(peerID, "block", hashes)
 }
@@ -502,11 +1497,19 @@ func (s *Server) HandleGetData(request []byte, peerID peer.ID) {
 
 	if payload.Type == "block" {
 		fmt.Printf("📦 [P2P] Data Request (Block) | Hash: %x | Peer: %s\n", payload.ID[:4], ShortID(peerID.String()))
+		if !s.Blockchain.MayHaveBlock(payload.ID) {
+			fmt.Printf("⚠️  Object (Block) not found for Hash: %x\n", payload.ID)
+			return
+		}
 		block, err := s.Blockchain.GetBlock(payload.ID)
 		if err != nil {
 			fmt.Printf("⚠️  Object (Block) not found for Hash: %x\n", payload.ID)
 			return
 		}
+		if block.Pruned {
+			fmt.Printf("⚠️  Object (Block) unavailable, body pruned: %x\n", payload.ID)
+			return
+		}
 		s.SendBlock(peerID, &block)
 	}
 
@@ -543,6 +1546,14 @@ func (s *Server) HandleBlock(request []byte, peerID peer.ID) {
 		return
 	}
 
+	if s.Light {
+		s.handleLightBlock(block, peerID)
+		return
+	}
+
+	// A peer serving a block has at least that height
+	s.RecordPeerHeight(peerID, block.Height)
+
 	s.BlockBufferMux.Lock()
 	isSyncing := s.IsSyncing
 	s.BlockBufferMux.Unlock()
@@ -555,8 +1566,6 @@ func (s *Server) HandleBlock(request []byte, peerID peer.ID) {
 		expected := s.ExpectedBlocks
 		s.BlockBufferMux.Unlock()
 
-		fmt.Printf("📦 [IBD] Buffered block %d (hash: %x) [%d/%d]\n", block.Height, block.Hash[:4], buffered, expected)
-
 		// Check if we have all expected blocks
 		if buffered >= expected && expected > 0 {
 			s.applyBufferedBlocks()
@@ -565,28 +1574,50 @@ func (s *Server) HandleBlock(request []byte, peerID peer.ID) {
 		// === NORMAL MODE: Apply single block immediately ===
 		fmt.Printf("Received new block! Hash: %x Height: %d\n", block.Hash, block.Height)
 
-		// Validate UTXOs (Double-spend check) before processing the block
-		if !s.UTXOSet.ValidateBlockTransactions(block) {
-			fmt.Printf("⛔ Block %x rejected: Contains double-spends or invalid inputs.\n", block.Hash)
-			return
+		if len(block.PrevBlockHash) > 0 && !s.Blockchain.HasBlock(block.PrevBlockHash) {
+			// Only a block a validator signed may take a slot in the tracker
+			if err := ValidateOrphanHeader(block); err != nil {
+				fmt.Printf("⛔ Orphan block %x dropped: %v\n", block.Hash, err)
+				return
+			}
+			s.Orphans.Record(block, peerID.String())
 		}
-
-		if s.Blockchain.AddBlock(block) {
-			s.UTXOSet.Update(block)
-			fmt.Printf("✅ Block added %x and UTXO set updated.\n", block.Hash)
-			BroadcastBlock(s.BlockHub, block)
-		} else {
-			fmt.Printf("Block discarded or duplicate: %x\n", block.Hash)
+		if err := s.AcceptBlock(block); err != nil {
+			fmt.Printf("⛔ Block %x: %v\n", block.Hash, err)
 		}
+	}
+}
 
-		// Clean mempool
-		s.MempoolMux.Lock()
-		for _, tx := range block.Transactions {
-			txID := hex.EncodeToString(tx.ID)
-			delete(s.Mempool, txID)
-		}
-		s.MempoolMux.Unlock()
+// AcceptBlock validates a single block against the tip, stores it, updates
+// the UTXO set and drops its transactions from the mempool.
+func (s *Server) AcceptBlock(block *Block) error {
+	// Validate UTXOs (Double-spend check) before processing the block
+	if !s.UTXOSet.ValidateBlockTransactions(block) {
+		return errors.New("rejected: contains double-spends or invalid inputs")
 	}
+
+	added := s.Blockchain.AddBlock(block)
+	if added {
+		s.UTXOSet.Update(block)
+		fmt.Printf("✅ Block added %x and UTXO set updated.\n", block.Hash)
+		BroadcastBlock(s.BlockHub, block)
+	}
+
+	// Clean mempool
+	s.MempoolMux.Lock()
+	for _, tx := range block.Transactions {
+		s.RemoveFromMempool(hex.EncodeToString(tx.ID))
+	}
+	if added {
+		s.EvictConflicts(block)
+	}
+	s.PromoteWaitingTxs()
+	s.MempoolMux.Unlock()
+
+	if !added {
+		return errors.New("discarded: duplicate or failed validation")
+	}
+	return nil
 }
 
 // applyBufferedBlocks sorts buffered blocks by height and applies them chronologically,
@@ -612,6 +1643,7 @@ func (s *Server) applyBufferedBlocks() {
 		len(heights), heights[0], heights[len(heights)-1])
 
 	applied := 0
+	var failed error
 	// Cumulative cache: accumulates verified TXs across blocks so
 	// cross-block dependencies within this IBD batch resolve in-memory.
 	ibdTxCache := make(map[string]Transaction)
@@ -622,12 +1654,35 @@ func (s *Server) applyBufferedBlocks() {
 			log.Printf("⚠️ [IBD] Nil block encountered at height %d, skipping...", h)
 			continue
 		}
+		tipBefore := s.Blockchain.LastHash
+
+		// A pruned chain can't be reindexed, so a branch switch must be undone
+		// block by block: refuse one that needs a pruned block
+		if s.PruneKeep > 0 && !bytes.Equal(block.PrevBlockHash, tipBefore) && block.Height > s.Blockchain.GetBestHeight() {
+			if err := s.checkBranchSwitch(tipBefore, block.PrevBlockHash); err != nil {
+				fmt.Printf("⛔ [IBD] Block %x at height %d not applied: %v\n", block.Hash, block.Height, err)
+				continue
+			}
+		}
+
 		if s.Blockchain.AddBlock(block, ibdTxCache) {
 			applied++
+
+			// Apply UTXO changes block by block as well
+			if s.PruneKeep > 0 {
+				if bytes.Equal(block.PrevBlockHash, tipBefore) {
+					s.UTXOSet.Update(block)
+				} else if !bytes.Equal(s.Blockchain.LastHash, tipBefore) {
+					if err := s.switchUTXOBranch(tipBefore, s.Blockchain.LastHash); err != nil {
+						failed = err
+						break
+					}
+				}
+			}
 		}
 	}
 
-	fmt.Printf("✅ [IBD] Sync complete. Applied %d blocks.\n", applied)
+	fmt.Printf("✅ [IBD] Sync complete. Applied %d blocks, %s.\n", applied, s.Wire.compressionSummary(s.syncWireStart))
 
 	// Broadcast the tip block to WebSocket clients
 	if len(heights) > 0 {
@@ -636,18 +1691,88 @@ func (s *Server) applyBufferedBlocks() {
 		}
 	}
 
+	if s.PruneKeep > 0 {
+		if failed != nil {
+			fmt.Printf("⛔ [IBD] Switching the UTXO set to the new branch failed: %v. Run 'chain reset' and resync.\n", failed)
+		}
+		return
+	}
+
 	// Full UTXO reindex from the now-complete chain
 	fmt.Println("🔄 [IBD] Rebuilding UTXO set (Reindex)...")
-	s.UTXOSet.Reindex()
+	if err := s.UTXOSet.Reindex(); err != nil {
+		fmt.Printf("⛔ [IBD] UTXO Reindex failed: %v\n", err)
+		return
+	}
 	fmt.Println("✅ [IBD] UTXO Reindex complete.")
 }
 
+// checkBranchSwitch reports whether the UTXO set can move from oldTip's
+// branch to the branch ending at newParent: every block of the old branch
+// above the fork point must still have its body and undo record.
+func (s *Server) checkBranchSwitch(oldTip, newParent []byte) error {
+	reorg, err := s.Blockchain.FindReorg(oldTip, newParent)
+	if err != nil {
+		return err
+	}
+	for _, hash := range reorg.Disconnected {
+		block, err := s.Blockchain.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		if block.Pruned {
+			return fmt.Errorf("%w: the branch forks below block %x, which this node has pruned", ErrBlockPruned, block.Hash)
+		}
+	}
+	return nil
+}
+
+// switchUTXOBranch moves the UTXO set from oldTip's branch to newTip's after
+// AddBlock switched the tip: the old branch is rolled back to the fork point,
+// then the new branch applied from there. This is how a pruned node, which
+// can't reindex, follows a reorg (see checkBranchSwitch).
+func (s *Server) switchUTXOBranch(oldTip, newTip []byte) error {
+	reorg, err := s.Blockchain.FindReorg(oldTip, newTip)
+	if err != nil {
+		return err
+	}
+	for _, hash := range reorg.Disconnected {
+		block, err := s.Blockchain.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		if err := s.UTXOSet.Rollback(&block); err != nil {
+			return err
+		}
+	}
+
+	var connect []*Block
+	for hash := newTip; ; {
+		block, err := s.Blockchain.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		if block.Height <= reorg.CommonAncestorHeight {
+			break
+		}
+		connect = append(connect, &block)
+		hash = block.PrevBlockHash
+	}
+	for i := len(connect) - 1; i >= 0; i-- {
+		s.UTXOSet.Update(connect[i])
+	}
+	return nil
+}
+
 func (s *Server) HandleTx(request []byte, peerID peer.ID) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("⚡ Panic in HandleTx: %v", r)
 		}
 	}()
+	if s.Light {
+		return // No UTXO set to validate it against
+	}
 
 	var payload TxMsg
 	dec := gob.NewDecoder(bytes.NewReader(request))
@@ -657,8 +1782,17 @@ func (s *Server) HandleTx(request []byte, peerID peer.ID) {
 	}
 
 	txData := payload.Transaction
+	if len(txData) > MaxTxSize {
+		fmt.Printf("⚠️  [HandleTx] Rejected TX from %s: payload too large (%d bytes)\n", ShortID(peerID.String()), len(txData))
+		return
+	}
 	tx := DeserializeTransaction(txData)
 
+	if err := tx.ValidateSize(); err != nil {
+		fmt.Printf("⚠️  [HandleTx] Rejected TX %x: %s\n", tx.ID, err)
+		return
+	}
+
 	s.MempoolMux.Lock()
 	defer s.MempoolMux.Unlock()
 
@@ -676,46 +1810,156 @@ func (s *Server) HandleTx(request []byte, peerID peer.ID) {
 		fmt.Printf("⚠️  [HandleTx] Rejected TX %x: Negative fee (%d)\n", tx.ID, fee)
 		return
 	}
+	if err := tx.CheckDeclaredFee(fee); err != nil {
+		fmt.Printf("⚠️  [HandleTx] Rejected TX %x: %s\n", tx.ID, err)
+		return
+	}
+	if err := tx.CheckDust(s.DustLimit); err != nil {
+		fmt.Printf("⚠️  [HandleTx] Rejected TX %x: %s\n", tx.ID, err)
+		return
+	}
+
+	if !tx.IsFinal(s.Blockchain.GetBestHeight() + 1) {
+		if err := s.AddWaitingTx(tx, fee, time.Now().Unix()); err != nil {
+			fmt.Printf("⚠️  [HandleTx] Rejected TX %x: %s\n", tx.ID, err)
+			return
+		}
+		fmt.Printf("⏳ Time-locked Transaction %x waits for height %d\n", tx.ID, tx.LockHeight)
+		go s.SendTxAck(peerID, tx.ID)
+		return
+	}
 
 	// Check for mempool double-spend: reject if any input is already consumed
+	if err := CheckMempoolConflict(&tx, s.Mempool); err != nil {
+		fmt.Printf("⚠️  [HandleTx] Rejected TX %x: %s\n", tx.ID, err)
+		return
+	}
+	if err := CheckMempoolChain(&tx, s.Mempool); err != nil {
+		fmt.Printf("⚠️  [HandleTx] Rejected TX %x: %s\n", tx.ID, err)
+		return
+	}
+
+	if err := s.AddToMempool(tx, fee, time.Now().Unix()); err != nil {
+		fmt.Printf("⚠️  [HandleTx] Rejected TX %x: %s\n", tx.ID, err)
+		return
+	}
+	fmt.Printf("New Transaction in Mempool: %x (Fee: %d)\n", tx.ID, fee)
+	BroadcastMempoolTx(s.MempoolHub, &tx)
+	go s.SendTxAck(peerID, tx.ID)
+
+	peers := s.Host.Network().Peers()
+	for _, p := range peers {
+		if p != peerID {
+			s.SendInv(p, "tx", [][]byte{tx.ID})
+		}
+	}
+}
+
+// HandleTxAck records a peer's acknowledgment of a transaction we hold.
+// Acks for transactions we don't know are ignored.
+func (s *Server) HandleTxAck(request []byte, peerID peer.ID) {
+	var payload TxAck
+	dec := gob.NewDecoder(bytes.NewReader(request))
+	if err := dec.Decode(&payload); err != nil {
+		log.Printf("⚠️ HandleTxAck: gob decode error from %s: %v", ShortID(peerID.String()), err)
+		return
+	}
+
+	txID := hex.EncodeToString(payload.TxID)
+	s.MempoolMux.Lock()
+	_, pending := s.Mempool[txID]
+	_, waiting := s.WaitingTxs[txID]
+	s.MempoolMux.Unlock()
+
+	if pending || waiting {
+		s.TxAcks.Record(payload.TxID, peerID.String())
+	}
+}
+
+// CheckMempoolConflict returns ErrDoubleSpend (wrapped) if another mempool
+// transaction already spends one of tx's inputs. Caller must hold the mempool lock.
+func CheckMempoolConflict(tx *Transaction, mempool map[string]MempoolItem) error {
+	txID := hex.EncodeToString(tx.ID)
 	for _, vin := range tx.Vin {
 		inputKey := hex.EncodeToString(vin.Txid) + ":" + fmt.Sprintf("%d", vin.Vout)
-		for existingID, existing := range s.Mempool {
+		for existingID, existing := range mempool {
 			if existingID == txID {
 				continue
 			}
 			for _, evin := range existing.Tx.Vin {
 				existingKey := hex.EncodeToString(evin.Txid) + ":" + fmt.Sprintf("%d", evin.Vout)
 				if inputKey == existingKey {
-					fmt.Printf("⚠️  [HandleTx] Rejected TX %x: double-spend attempt against mempool TX %s\n", tx.ID, existingID)
-					return
+					return fmt.Errorf("%w: input %s already used by mempool TX %s", ErrDoubleSpend, inputKey, existingID)
 				}
 			}
 		}
 	}
+	return nil
+}
 
-	fmt.Printf("New Transaction in Mempool: %x (Fee: %d)\n", tx.ID, fee)
-	s.Mempool[txID] = MempoolItem{Tx: tx, AddedAt: time.Now().Unix()}
-	BroadcastMempoolTx(s.MempoolHub, &tx)
+// StartMiningLoop forges on every MiningInterval tick until ctx is cancelled.
+// It returns once any block in progress is stored.
+func (s *Server) StartMiningLoop(ctx context.Context) {
+	if s.MinerAddr == "" {
+		return
+	}
+	fmt.Printf("⛏️  Mining Loop started (Interval: %s)\n", MiningInterval)
+	ticker := time.NewTicker(MiningInterval)
+	defer ticker.Stop()
 
-	peers := s.Host.Network().Peers()
-	for _, p := range peers {
-		if p != peerID {
-			s.SendInv(p, "tx", [][]byte{tx.ID})
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.AttemptMine()
 		}
 	}
 }
 
-func (s *Server) StartMiningLoop() {
-	if s.MinerAddr == "" {
-		return
+// ForgingKey returns the loaded key of the round-robin proposer scheduled
+// for height. A revoked proposer's turn passes to the next validator in the
+// schedule that isn't revoked. nil means it isn't this node's turn to forge.
+func (s *Server) ForgingKey(height int, revoked map[string]int) *ValidatorKey {
+	scheduled := ScheduledValidator(height)
+	for i := 1; i < len(AuthorizedValidators); i++ {
+		if _, gone := revoked[scheduled]; !gone {
+			break
+		}
+		scheduled = ScheduledValidator(height + i)
+	}
+	for i := range s.ValidatorKeys {
+		key := &s.ValidatorKeys[i]
+		if _, gone := revoked[key.PubKeyHex]; key.PubKeyHex == scheduled && !gone {
+			return key
+		}
 	}
-	fmt.Println("⛏️  Mining Loop started (Interval: 10s)")
-	ticker := time.NewTicker(10 * time.Second)
+	return nil
+}
+
+// AttestingKeys returns the other loaded keys, not revoked, that co-sign a
+// block signed by key, as many as BlockQuorum needs. A node can only reach
+// the quorum on its own if it holds that many validator keys.
+func (s *Server) AttestingKeys(key *ValidatorKey, revoked map[string]int) []ecdsa.PrivateKey {
+	var attesters []ecdsa.PrivateKey
+	for i := range s.ValidatorKeys {
+		if len(attesters) >= BlockQuorum-1 {
+			break
+		}
+		if _, gone := revoked[s.ValidatorKeys[i].PubKeyHex]; s.ValidatorKeys[i].PubKeyHex != key.PubKeyHex && !gone {
+			attesters = append(attesters, *s.ValidatorKeys[i].PrivKey)
+		}
+	}
+	return attesters
+}
 
-	for range ticker.C {
-		s.AttemptMine()
+// RewardAddressFor returns the address paid by the coinbase of a block signed
+// with key: the configured reward address, else the validator's own address.
+func (s *Server) RewardAddressFor(key *ValidatorKey) string {
+	if s.RewardAddress != "" {
+		return s.RewardAddress
 	}
+	return key.Address
 }
 
 func (s *Server) AttemptMine() {
@@ -726,18 +1970,41 @@ func (s *Server) AttemptMine() {
 		}
 	}()
 
-	if s.MinerAddr == "" || s.ValidatorPrivKey == nil {
+	if len(s.ValidatorKeys) == 0 {
+		return
+	}
+
+	// Only the scheduled proposer forges
+	nextHeight := s.Blockchain.GetBestHeight() + 1
+	revoked, err := s.Blockchain.Revoked()
+	if err != nil {
+		fmt.Printf("⛔ [Forge] Validator revocations can't be read: %v\n", err)
+		return
+	}
+	key := s.ForgingKey(nextHeight, revoked)
+	if key == nil {
 		return
 	}
+	attesters := s.AttestingKeys(key, revoked)
+	if 1+len(attesters) < BlockQuorum {
+		return // Not enough keys to reach the quorum (warned at startup)
+	}
 
 	s.MempoolMux.Lock()
 	defer s.MempoolMux.Unlock()
 
-	if len(s.Mempool) == 0 {
+	s.PromoteWaitingTxs()
+	revocations := s.revocationsForBlock()
+	hasTxs := len(s.Mempool) > 0
+	// A revocation is urgent: it gets a block even with nothing else to include
+	forgeEmpty := s.EmptyBlocks || len(revocations) > 0
+	if !hasTxs && !forgeEmpty {
 		return
 	}
 
-	fmt.Println("Forging new block with mempool transactions...")
+	if hasTxs {
+		fmt.Println("Forging new block with mempool transactions...")
+	}
 
 	type txWithFee struct {
 		tx  *Transaction
@@ -747,43 +2014,73 @@ func (s *Server) AttemptMine() {
 	var validTxs []txWithFee
 	var totalFees int64
 
-	for id := range s.Mempool {
-		item := s.Mempool[id]
-		tx := item.Tx
-		if s.Blockchain.VerifyTransactionWithMempool(&tx, s.Mempool) {
-			fee, err := s.UTXOSet.CalculateFee(&tx, s.Mempool)
-			if err == nil && fee >= 0 {
-				validTxs = append(validTxs, txWithFee{tx: &tx, fee: fee})
-			} else {
-				// Invalid fee (or dependencies missing)
-				delete(s.Mempool, id)
+	// A mempool tx whose lock height is out of reach again (the chain got
+	// shorter) goes back to the waiting pool on its own
+	for id, item := range s.Mempool {
+		if !item.Tx.IsFinal(nextHeight) {
+			s.RemoveFromMempool(id)
+			s.WaitingTxs[id] = item
+		}
+	}
+	// Its descendants stay in the mempool but sit out until it is promoted
+	blocked := make(map[string]bool)
+	for id, item := range s.Mempool {
+		for _, vin := range item.Tx.Vin {
+			if _, waiting := s.WaitingTxs[hex.EncodeToString(vin.Txid)]; waiting {
+				blocked[id] = true
+				maps.Copy(blocked, mempoolDescendants(id, s.Mempool))
+				break
 			}
+		}
+	}
+
+	parents := make(PrevTxCache) // Mempool txs often spend outputs of the same few transactions
+	// Best fee rate first, first received on ties. orderForBlock later moves
+	// parents ahead of their children.
+	for _, id := range s.mempoolByPriority() {
+		item, ok := s.Mempool[id]
+		if !ok || blocked[id] {
+			continue // Evicted along with an invalid parent, or waiting on one
+		}
+		tx := item.Tx
+		if s.Blockchain.VerifyTransactionWithMempool(&tx, s.Mempool, parents) {
+			// The fee was resolved, and any declared Fee checked, on admission
+			validTxs = append(validTxs, txWithFee{tx: &tx, fee: item.Fee})
 		} else {
-			delete(s.Mempool, id) // Clear invalid tx
+			s.EvictFromMempool(id) // Clear invalid tx
+		}
+	}
+
+	// Evictions take descendants with them, which may already have been picked
+	inMempool := func(list []txWithFee) []txWithFee {
+		var kept []txWithFee
+		for _, twf := range list {
+			if _, ok := s.Mempool[hex.EncodeToString(twf.tx.ID)]; ok {
+				kept = append(kept, twf)
+			}
 		}
+		return kept
 	}
+	validTxs = inMempool(validTxs)
 
-	if len(validTxs) == 0 {
+	if len(validTxs) == 0 && hasTxs {
 		fmt.Println("All transactions in mempool are invalid.")
+	}
+	// With --empty-blocks the chain keeps moving: the block is just the coinbase
+	if len(validTxs) == 0 && !forgeEmpty {
 		return
 	}
 
-	sort.Slice(validTxs, func(i, j int) bool {
-		return validTxs[i].fee > validTxs[j].fee
-	})
-
 	var txs []*Transaction
 	for _, twf := range validTxs {
 		txs = append(txs, twf.tx)
 		totalFees += twf.fee
 	}
 
-	bestHeight := s.Blockchain.GetBestHeight()
-	nextHeight := bestHeight + 1
 	subsidy := s.Blockchain.GetBlockSubsidy(nextHeight)
 
 	totalReward := subsidy + totalFees
-	cbTx := NewCoinbaseTX(s.MinerAddr, "", totalReward)
+	cbTx := NewCoinbaseTX(s.RewardAddressFor(key), s.CoinbaseMessage, totalReward)
 
 	// Detect and evict conflicting transactions instead of wiping the entire mempool
 	prospectiveBlock := &Block{Transactions: append([]*Transaction{cbTx}, txs...)}
@@ -802,7 +2099,8 @@ func (s *Server) AttemptMine() {
 				key := hex.EncodeToString(vin.Txid) + ":" + fmt.Sprintf("%d", vin.Vout)
 				if claimer, exists := spentInputs[key]; exists {
 					fmt.Printf("  ↳ Evicted TX %s (conflicts with %s on input %s)\n", tid, claimer, key)
-					delete(s.Mempool, tid)
+					s.EvictFromMempool(tid)
+					s.RecordReplacement(tid, claimer, time.Now()) // The claimer pays a better fee rate
 					conflict = true
 					break
 				}
@@ -813,33 +2111,67 @@ func (s *Server) AttemptMine() {
 					spentInputs[key] = tid
 				}
 				cleanTxs = append(cleanTxs, twf)
-				totalFees += twf.fee
 			}
 		}
+		cleanTxs = inMempool(cleanTxs)
+		for _, twf := range cleanTxs {
+			totalFees += twf.fee
+		}
 
 		if len(cleanTxs) == 0 {
 			fmt.Println("No valid transactions remain after conflict eviction.")
-			return
+			if !forgeEmpty {
+				return
+			}
 		}
 
 		// Rebuild the block with clean transactions
 		totalReward = subsidy + totalFees
-		cbTx = NewCoinbaseTX(s.MinerAddr, "", totalReward)
-		txs = []*Transaction{cbTx}
+		cbTx = NewCoinbaseTX(s.RewardAddressFor(key), s.CoinbaseMessage, totalReward)
+		txs = nil
 		for _, twf := range cleanTxs {
 			txs = append(txs, twf.tx)
 		}
-	} else {
-		txs = append([]*Transaction{cbTx}, txs...) // Coinbase first
 	}
+	ordered := orderForBlock(txs) // Parents before children
+	if kept := withinBlockInputs(ordered); len(kept) < len(ordered) {
+		fmt.Printf("ℹ️  [Forge] %d transaction(s) left for the next block (max %d inputs per block)\n", len(ordered)-len(kept), MaxBlockInputs)
+		fees := make(map[string]int64, len(validTxs))
+		for _, twf := range validTxs {
+			fees[hex.EncodeToString(twf.tx.ID)] = twf.fee
+		}
+		ordered = kept
+		totalFees = 0
+		for _, tx := range ordered {
+			totalFees += fees[hex.EncodeToString(tx.ID)]
+		}
+		totalReward = subsidy + totalFees
+		cbTx = NewCoinbaseTX(s.RewardAddressFor(key), s.CoinbaseMessage, totalReward)
+	}
+	txs = append([]*Transaction{cbTx}, ordered...) // Coinbase first
 
-	newBlock := s.Blockchain.ForgeBlock(txs, *s.ValidatorPrivKey)
+	newBlock, err := s.Blockchain.ForgeBlock(txs, revocations, *key.PrivKey, attesters...)
+	if err != nil {
+		fmt.Printf("⛔ [Forge] Block not stored: %v\n", err)
+		return
+	}
+	for _, r := range newBlock.Revocations {
+		delete(s.Revocations, r.PubKey)
+		fmt.Printf("⛔ Validator %.16s... revoked from height %d\n", r.PubKey, newBlock.Height+1)
+	}
 	s.UTXOSet.Update(newBlock)
+
+	s.ForgeStatsMux.Lock()
+	s.BlocksForged++
+	s.LastForgedHeight = newBlock.Height
+	s.ForgeStatsMux.Unlock()
 	BroadcastBlock(s.BlockHub, newBlock)
 
-	s.Mempool = make(map[string]MempoolItem)
+	for _, tx := range newBlock.Transactions {
+		s.RemoveFromMempool(hex.EncodeToString(tx.ID))
+	}
 
-	fmt.Printf("New block forged: %x (Reward: %d | Sub: %d + Fee: %d)\n", newBlock.Hash, totalReward, subsidy, totalFees)
+	fmt.Printf("New block forged: %x by %s (Reward: %d | Sub: %d + Fee: %d)\n", newBlock.Hash, key.Address, totalReward, subsidy, totalFees)
 
 	peers := s.Host.Network().Peers()
 	for _, p := range peers {
@@ -851,13 +2183,13 @@ func (s *Server) AttemptMine() {
 
 func (s *Server) SendVersion(peerID peer.ID) {
 	bestHeight := s.Blockchain.GetBestHeight()
-	payload := GobEncode(Version{1, bestHeight, s.Host.ID().String()})
+	payload := GobEncode(Version{Version: ProtocolVersion, BestHeight: bestHeight, AddrFrom: s.Host.ID().String(), Compression: s.Compression, Light: s.Light})
 	request := append(CommandToBytes("version"), payload...)
 	s.SendData(peerID, request)
 }
 
 func (s *Server) SendGetBlocks(peerID peer.ID) {
-	payload := GobEncode(Version{1, 0, s.Host.ID().String()})
+	payload := GobEncode(Version{Version: ProtocolVersion, BestHeight: 0, AddrFrom: s.Host.ID().String(), Compression: s.Compression})
 	request := append(CommandToBytes("getblocks"), payload...)
 	s.SendData(peerID, request)
 }
@@ -876,7 +2208,8 @@ func (s *Server) SendGetData(peerID peer.ID, kind string, id []byte) {
 }
 
 func (s *Server) SendBlock(peerID peer.ID, block *Block) {
-	data := BlockMsg{s.Host.ID().String(), block.Serialize()}
+	// Gob until every peer decodes the canonical format (DeserializeBlock reads both)
+	data := BlockMsg{s.Host.ID().String(), block.SerializeGob()}
 	payload := GobEncode(data)
 	request := append(CommandToBytes("block"), payload...)
 	s.SendData(peerID, request)
@@ -889,6 +2222,17 @@ func (s *Server) SendTx(peerID peer.ID, tx *Transaction) {
 	s.SendData(peerID, request)
 }
 
+// SendTxAck acknowledges txID to the peer that sent it. Nothing is sent if
+// that peer has disconnected in the meantime.
+func (s *Server) SendTxAck(peerID peer.ID, txID []byte) {
+	if s.Host.Network().Connectedness(peerID) != network.Connected {
+		return
+	}
+	payload := GobEncode(TxAck{s.Host.ID().String(), txID})
+	request := append(CommandToBytes("txack"), payload...)
+	s.SendData(peerID, request)
+}
+
 func (s *Server) SendData(peerID peer.ID, data []byte) {
 	stream, err := s.Host.NewStream(context.Background(), peerID, protocolID)
 	if err != nil {
@@ -896,6 +2240,15 @@ func (s *Server) SendData(peerID peer.ID, data []byte) {
 	}
 	defer stream.Close()
 
+	// Only peers that advertised compression get compressed frames
+	s.Wire.SentRaw.Add(int64(len(data)))
+	if s.Compression && s.peerAcceptsCompression(peerID.String()) {
+		if frame, ok := compressPayload(data); ok {
+			data = frame
+		}
+	}
+	s.Wire.SentWire.Add(int64(len(data)))
+
 	// Write 4-byte big-endian length prefix
 	lenBuf := make([]byte, 4)
 	binary.BigEndian.PutUint32(lenBuf, uint32(len(data)))
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestStopCancelsBootnodeRetries(t *testing.T) {
+	s := newTestServer(t, newTestChain(t))
+	s.DialTimeout = time.Second
+
+	// A bootnode that went away: every dial fails and is retried with backoff
+	gone := newTestHost(t)
+	pi := peer.AddrInfo{ID: gone.ID(), Addrs: gone.Addrs()}
+	gone.Close()
+
+	done := make(chan bool)
+	go func() { done <- s.dialBootnodeWithBackoff(pi) }()
+
+	time.Sleep(200 * time.Millisecond)
+	s.Stop()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("dial to a closed host succeeded")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("bootnode retries kept running after Stop")
+	}
+}
+
+func TestStopEndsBackgroundLoops(t *testing.T) {
+	s := newTestServer(t, newTestChain(t))
+
+	done := make(chan struct{})
+	go func() {
+		s.Start()
+		close(done)
+	}()
+
+	s.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after Stop")
+	}
+	if s.sleep(time.Hour) {
+		t.Fatal("sleep waited on a stopped server")
+	}
+}
+
+func TestForgingKeyFollowsSchedule(t *testing.T) {
+	a, _ := newValidator(t)
+	b, _ := newValidator(t)
+	withValidators(t, 1, a, b)
+
+	s := NewAPIOnlyServer(newTestChain(t))
+	s.ValidatorKeys = []ValidatorKey{loadValidatorKey(t, b)}
+	if key := s.ForgingKey(0, nil); key != nil {
+		t.Fatalf("forging with %s in a's turn", key.Address)
+	}
+	if key := s.ForgingKey(1, nil); key == nil || key.Address != b.GetAddress() {
+		t.Fatalf("b's turn: got %v", key)
+	}
+
+	s.ValidatorKeys = append(s.ValidatorKeys, loadValidatorKey(t, a))
+	if key := s.ForgingKey(2, nil); key == nil || key.Address != a.GetAddress() {
+		t.Fatalf("a's turn with both keys loaded: got %v", key)
+	}
+}
+
+func TestAttemptMineWaitsForItsTurn(t *testing.T) {
+	a, _ := newValidator(t)
+	b, _ := newValidator(t)
+	withValidators(t, 1, a, b)
+
+	s := newTestServer(t, newTestChain(t))
+	s.EmptyBlocks = true
+	s.ValidatorKeys = []ValidatorKey{loadValidatorKey(t, a)}
+
+	// Height 1 is b's
+	s.AttemptMine()
+	if height := s.Blockchain.GetBestHeight(); height != 0 {
+		t.Fatalf("forged height %d out of turn", height)
+	}
+
+	s.ValidatorKeys = append(s.ValidatorKeys, loadValidatorKey(t, b))
+	s.AttemptMine()
+	if height := s.Blockchain.GetBestHeight(); height != 1 {
+		t.Fatalf("tip at %d after forging in turn, want 1", height)
+	}
+	tip := tipBlock(t, s.Blockchain)
+	if !bytes.Equal(tip.Validator, b.PublicKey[1:]) && !bytes.Equal(tip.Validator, b.PublicKey) {
+		t.Fatalf("block signed by %x, want b", tip.Validator)
+	}
+}
+
+func TestReconnectRepeatsHandshake(t *testing.T) {
+	s := newTestServer(t, newTestChain(t))
+	remote := newTestHost(t)
+	id := remote.ID().String()
+	known := func() bool {
+		s.KnownPeersMux.RLock()
+		defer s.KnownPeersMux.RUnlock()
+		_, ok := s.KnownPeers[id]
+		return ok
+	}
+	handshake := func() {
+		connectHosts(t, remote, s.Host)
+		version := append(CommandToBytes("version"), GobEncode(Version{Version: ProtocolVersion, AddrFrom: id})...)
+		if err := sendP2PFrame(context.Background(), remote, s.Host.ID(), version); err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, "handshake", known)
+	}
+
+	handshake()
+	throttled := s.GetBlocksLimiter.GetLimiter(id)
+	throttled.AllowN(time.Now(), getBlocksBurst)
+
+	remote.Network().ClosePeer(s.Host.ID())
+	waitFor(t, "peer forgotten", func() bool { return !known() })
+
+	handshake()
+	if s.GetBlocksLimiter.GetLimiter(id) != throttled {
+		t.Fatal("reconnecting refilled the peer's getblocks bucket")
+	}
+}
+
+// syncBranch buffers blocks as an IBD from a peer would and applies them
+func syncBranch(s *Server, blocks ...*Block) {
+	s.BlockBufferMux.Lock()
+	s.IsSyncing = true
+	for _, block := range blocks {
+		s.BlockBuffer[block.Height] = block
+	}
+	s.BlockBufferMux.Unlock()
+	s.applyBufferedBlocks()
+}
+
+// forkFromGenesis builds a branch of n blocks on the chain's genesis block
+func forkFromGenesis(t *testing.T, chain *Blockchain, signer *Wallet, n int) []*Block {
+	t.Helper()
+
+	genesis, err := chain.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branch := []*Block{buildBlock(t, &genesis, signer)}
+	for len(branch) < n {
+		branch = append(branch, buildBlock(t, branch[len(branch)-1], signer))
+	}
+	return branch
+}
+
+func TestPrunedSyncSwitchesBranch(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	other, _ := newValidator(t)
+	withValidators(t, 1, w, other)
+	to, _ := NewWallet()
+
+	funding := addTestBlock(t, chain, w).Transactions[0]
+	addTestBlock(t, chain, w, spendTx(t, w, funding, 0, *NewTxOutput(DefaultDustLimit, to.GetAddress())))
+
+	s := newTestServer(t, chain)
+	s.PruneKeep = MinPruneKeepBlocks
+	branch := forkFromGenesis(t, chain, other, 3)
+	syncBranch(s, branch...)
+
+	if !bytes.Equal(chain.LastHash, branch[2].Hash) {
+		t.Fatal("the sync did not switch to the longer branch")
+	}
+	audit, err := s.UTXOSet.Audit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !audit.Clean() {
+		t.Fatalf("UTXO set doesn't match the new branch: %+v", audit.Issues)
+	}
+}
+
+func TestPrunedSyncRefusesForkBelowPrunedBlocks(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	other, _ := newValidator(t)
+	withValidators(t, 1, w, other)
+
+	addTestBlock(t, chain, w)
+	tip := addTestBlock(t, chain, w)
+	if _, err := chain.PruneBlocks(1); err != nil {
+		t.Fatal(err)
+	}
+	before := utxoSnapshot(t, chain)
+
+	s := newTestServer(t, chain)
+	s.PruneKeep = 1
+	syncBranch(s, forkFromGenesis(t, chain, other, 3)...)
+
+	if !bytes.Equal(chain.LastHash, tip.Hash) {
+		t.Fatal("switched to a branch forking below a pruned block")
+	}
+	if after := utxoSnapshot(t, chain); !maps.Equal(after, before) {
+		t.Fatal("the refused branch changed the UTXO set")
+	}
+}
+
+func TestAttemptMineNeedsQuorumKeys(t *testing.T) {
+	a, _ := newValidator(t)
+	b, _ := newValidator(t)
+	withValidators(t, 2, a, b)
+
+	s := newTestServer(t, newTestChain(t))
+	s.EmptyBlocks = true
+
+	// Whichever is scheduled, one key can't reach a quorum of two
+	for _, w := range []*Wallet{a, b} {
+		s.ValidatorKeys = []ValidatorKey{loadValidatorKey(t, w)}
+		s.AttemptMine()
+	}
+	if height := s.Blockchain.GetBestHeight(); height != 0 {
+		t.Fatalf("forged up to height %d without the quorum", height)
+	}
+
+	s.ValidatorKeys = []ValidatorKey{loadValidatorKey(t, a), loadValidatorKey(t, b)}
+	s.AttemptMine()
+	if height := s.Blockchain.GetBestHeight(); height != 1 {
+		t.Fatalf("tip at %d with both keys loaded, want 1", height)
+	}
+	if !VerifyBlockSignature(tipBlock(t, s.Blockchain)) {
+		t.Fatal("forged block fails the quorum check")
+	}
+}
+
+// blockHas reports whether block holds tx
+func blockHas(block *Block, tx *Transaction) bool {
+	for _, btx := range block.Transactions {
+		if bytes.Equal(btx.ID, tx.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAttemptMineDefersOnlyImmatureTx(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	s := newTestServer(t, newTestChain(t))
+	s.EmptyBlocks = true
+	s.ValidatorKeys = []ValidatorKey{loadValidatorKey(t, w)}
+	to, _ := NewWallet()
+
+	first := addTestBlock(t, s.Blockchain, w).Transactions[0]
+	second := addTestBlock(t, s.Blockchain, w).Transactions[0]
+	lockHeight := s.Blockchain.GetBestHeight() + 3
+
+	// As after a reorg: a mempool tx whose lock height is out of reach, with
+	// a child, next to an unrelated spend
+	locked := lockTx(t, w, spendTx(t, w, first, 0, *NewTxOutput(InitialSubsidy/2, w.GetAddress())), first, lockHeight)
+	child := spendTx(t, w, locked, 0, *NewTxOutput(InitialSubsidy/4, to.GetAddress()))
+	other := spendTx(t, w, second, 0, *NewTxOutput(InitialSubsidy/2, to.GetAddress()))
+	s.MempoolMux.Lock()
+	for _, tx := range []*Transaction{locked, child, other} {
+		if err := s.AddToMempool(*tx, tx.Fee, time.Now().Unix()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.MempoolMux.Unlock()
+
+	s.AttemptMine()
+	tip := tipBlock(t, s.Blockchain)
+	if !blockHas(tip, other) || blockHas(tip, locked) || blockHas(tip, child) {
+		t.Fatalf("block at height %d holds the wrong transactions", tip.Height)
+	}
+	if _, ok := s.WaitingTxs[hex.EncodeToString(locked.ID)]; !ok {
+		t.Fatal("immature transaction not moved to the waiting pool")
+	}
+	if _, ok := s.Mempool[hex.EncodeToString(child.ID)]; !ok {
+		t.Fatal("child of the immature transaction dropped from the mempool")
+	}
+
+	for s.Blockchain.GetBestHeight() < lockHeight {
+		s.AttemptMine()
+	}
+	tip = tipBlock(t, s.Blockchain)
+	if tip.Height != lockHeight || !blockHas(tip, locked) || !blockHas(tip, child) {
+		t.Fatalf("parent and child not mined together at their lock height %d", lockHeight)
+	}
+	if len(s.Mempool) != 0 || len(s.WaitingTxs) != 0 {
+		t.Fatalf("%d pending and %d waiting after mining everything", len(s.Mempool), len(s.WaitingTxs))
+	}
+}
+
+func TestAttemptMineCollectsRecordedFees(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	s := newTestServer(t, newTestChain(t))
+	s.ValidatorKeys = []ValidatorKey{loadValidatorKey(t, w)}
+
+	funding := addTestBlock(t, s.Blockchain, w).Transactions[0]
+	tx := spendTx(t, w, funding, 0, *NewTxOutput(InitialSubsidy-1500, w.GetAddress()))
+	s.MempoolMux.Lock()
+	if err := s.AddToMempool(*tx, tx.Fee, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	s.MempoolMux.Unlock()
+
+	s.AttemptMine()
+	tip := tipBlock(t, s.Blockchain)
+	if !blockHas(tip, tx) {
+		t.Fatal("transaction not mined")
+	}
+	if reward := tip.Transactions[0].Vout[0].Value; reward != s.Blockchain.GetBlockSubsidy(tip.Height)+1500 {
+		t.Fatalf("coinbase pays %d, want the subsidy plus 1500", reward)
+	}
+}
+
+func TestEmptyBlocksKeepTipMoving(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	s := newTestServer(t, newTestChain(t))
+	s.ValidatorKeys = []ValidatorKey{loadValidatorKey(t, w)}
+
+	s.AttemptMine()
+	if height := s.Blockchain.GetBestHeight(); height != 0 {
+		t.Fatalf("forged up to %d with an empty mempool and no --empty-blocks", height)
+	}
+
+	s.EmptyBlocks = true
+	for range 3 {
+		s.AttemptMine()
+	}
+	if height := s.Blockchain.GetBestHeight(); height != 3 {
+		t.Fatalf("tip at %d after three empty rounds, want 3", height)
+	}
+
+	// Just the coinbase, still paid, and valid like any other block
+	for height := 1; height <= 3; height++ {
+		block, err := s.Blockchain.GetBlockByHeight(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent, err := s.Blockchain.GetBlockByHeight(height - 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(block.Transactions) != 1 || block.Transactions[0].Vout[0].Value != s.Blockchain.GetBlockSubsidy(height) {
+			t.Fatalf("block %d: %d transactions, want the coinbase paying the subsidy", height, len(block.Transactions))
+		}
+		if err := ValidateBlockHeader(&block, &parent); err != nil {
+			t.Fatalf("block %d: %v", height, err)
+		}
+		if !VerifyBlockSignature(&block) || !s.Blockchain.VerifyBlockTransactions(&block) {
+			t.Fatalf("block %d fails validation", height)
+		}
+	}
+}
+
+func TestHandleBlockRecordsOnlySignedOrphans(t *testing.T) {
+	w, _ := newValidator(t)
+	stranger, _ := newValidator(t)
+	withValidators(t, 1, w)
+	s := newTestServer(t, newTestChain(t))
+	send := func(block *Block) {
+		s.HandleBlock(GobEncode(BlockMsg{"peer", block.SerializeGob()}), s.Host.ID())
+	}
+
+	// Children of a block this node never received
+	branch := forkFromGenesis(t, s.Blockchain, w, 2)
+	unauthorized := buildBlock(t, branch[0], stranger)
+	forged := buildBlock(t, branch[0], w)
+	forged.Timestamp++ // Content no longer matches the signed hash
+
+	send(unauthorized)
+	send(forged)
+	if orphans := s.Orphans.Snapshot(s.Blockchain); len(orphans) != 0 {
+		t.Fatalf("unsigned junk recorded as orphans: %+v", orphans)
+	}
+
+	send(branch[1])
+	orphans := s.Orphans.Snapshot(s.Blockchain)
+	if len(orphans) != 1 || orphans[0].Hash != hex.EncodeToString(branch[1].Hash) {
+		t.Fatalf("orphans %+v, want block %x", orphans, branch[1].Hash)
+	}
+}
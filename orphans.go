@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	MaxTrackedOrphans = 100       // Oldest entries are dropped beyond this
+	OrphanTTL         = time.Hour // Entries are forgotten after this long
+)
+
+// OrphanBlock is a block received outside of sync whose parent we don't have.
+// AddBlock rejects such blocks; the tracker only remembers them so operators
+// can see which parent never arrived.
+type OrphanBlock struct {
+	Hash      string
+	PrevHash  string
+	Height    int
+	Peer      string // Peer that sent it first
+	FirstSeen time.Time
+	Seen      int // Times received
+}
+
+// OrphanTracker records orphan blocks until their parent is stored, they
+// expire, or newer orphans push them out
+type OrphanTracker struct {
+	orphans map[string]*OrphanBlock
+	mux     sync.Mutex
+}
+
+func NewOrphanTracker() *OrphanTracker {
+	return &OrphanTracker{orphans: make(map[string]*OrphanBlock)}
+}
+
+// Record notes that block arrived from peer without a known parent
+func (ot *OrphanTracker) Record(block *Block, peer string) {
+	hash := hex.EncodeToString(block.Hash)
+
+	ot.mux.Lock()
+	defer ot.mux.Unlock()
+
+	if o, ok := ot.orphans[hash]; ok {
+		o.Seen++
+		return
+	}
+	if len(ot.orphans) >= MaxTrackedOrphans {
+		var oldest *OrphanBlock
+		for _, o := range ot.orphans {
+			if oldest == nil || o.FirstSeen.Before(oldest.FirstSeen) {
+				oldest = o
+			}
+		}
+		delete(ot.orphans, oldest.Hash)
+	}
+	ot.orphans[hash] = &OrphanBlock{
+		Hash:      hash,
+		PrevHash:  hex.EncodeToString(block.PrevBlockHash),
+		Height:    block.Height,
+		Peer:      peer,
+		FirstSeen: time.Now(),
+		Seen:      1,
+	}
+}
+
+// Snapshot returns the orphans still waiting, oldest first. Entries whose
+// parent or own block has since been stored, or that expired, are dropped.
+func (ot *OrphanTracker) Snapshot(chain *Blockchain) []OrphanBlock {
+	ot.mux.Lock()
+	defer ot.mux.Unlock()
+
+	var waiting []OrphanBlock
+	for hash, o := range ot.orphans {
+		prev, _ := hex.DecodeString(o.PrevHash)
+		own, _ := hex.DecodeString(hash)
+		if time.Since(o.FirstSeen) > OrphanTTL || chain.HasBlock(prev) || chain.HasBlock(own) {
+			delete(ot.orphans, hash)
+			continue
+		}
+		waiting = append(waiting, *o)
+	}
+
+	sort.Slice(waiting, func(i, j int) bool {
+		return waiting[i].FirstSeen.Before(waiting[j].FirstSeen)
+	})
+	return waiting
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/spf13/viper"
+)
+
+// presetKey records in the database the preset a chain was initialized with
+var presetKey = []byte("preset")
+
+//go:embed presets/*.yaml
+var presetFiles embed.FS
+
+// GenesisPreset is a named lab network that chain init --preset starts:
+// its validators, quorum, block reward and premine allocations. These are
+// consensus rules, so the preset is stored in the database and applied again
+// every time the chain is opened. Without a preset the compiled mainnet
+// rules apply.
+type GenesisPreset struct {
+	Name        string
+	Description string
+	NetworkID   string
+	Validators  []string // Empty keeps the compiled AuthorizedValidators
+	Quorum      int
+	BlockReward int64 // Photons
+	Allocations []GenesisAllocation
+}
+
+// ActivePreset is the preset applied to this process ("" on mainnet)
+var ActivePreset string
+
+// GenesisPresets returns the embedded presets, sorted by name
+func GenesisPresets() ([]*GenesisPreset, error) {
+	entries, err := presetFiles.ReadDir("presets")
+	if err != nil {
+		return nil, err
+	}
+
+	var presets []*GenesisPreset
+	for _, e := range entries {
+		p, err := loadPreset(strings.TrimSuffix(e.Name(), ".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		presets = append(presets, p)
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].Name < presets[j].Name })
+	return presets, nil
+}
+
+// FindGenesisPreset returns the embedded preset called name
+func FindGenesisPreset(name string) (*GenesisPreset, error) {
+	if _, err := presetFiles.Open(path.Join("presets", name+".yaml")); err != nil {
+		return nil, fmt.Errorf("unknown preset %q (see 'chain presets')", name)
+	}
+	return loadPreset(name)
+}
+
+func loadPreset(name string) (*GenesisPreset, error) {
+	data, err := presetFiles.ReadFile(path.Join("presets", name+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("preset %s: %w", name, err)
+	}
+	var allocations []struct {
+		Address string  `mapstructure:"address"`
+		Amount  float64 `mapstructure:"amount"` // SOLE
+	}
+	if err := v.UnmarshalKey("allocations", &allocations); err != nil {
+		return nil, fmt.Errorf("preset %s: allocations: %w", name, err)
+	}
+
+	p := &GenesisPreset{
+		Name:        name,
+		Description: v.GetString("description"),
+		NetworkID:   v.GetString("network_id"),
+		Validators:  v.GetStringSlice("validators"),
+		Quorum:      v.GetInt("quorum"),
+		BlockReward: int64(math.Round(v.GetFloat64("block_reward") * 100000000)),
+	}
+	for _, a := range allocations {
+		p.Allocations = append(p.Allocations, GenesisAllocation{
+			Address: strings.TrimSpace(a.Address),
+			Amount:  int64(math.Round(a.Amount * 100000000)),
+		})
+	}
+	return p, nil
+}
+
+// ValidatorSet returns the validators the preset authorizes
+func (p *GenesisPreset) ValidatorSet() []string {
+	if len(p.Validators) == 0 {
+		return AuthorizedValidators
+	}
+	return p.Validators
+}
+
+// Validate checks the preset's parameters before a chain is built on them
+func (p *GenesisPreset) Validate() error {
+	if p.NetworkID == "" || p.NetworkID == MainnetNetworkID {
+		return fmt.Errorf("preset %s: network_id must be set and differ from %s", p.Name, MainnetNetworkID)
+	}
+
+	seen := make(map[string]bool)
+	for i, key := range p.Validators {
+		if err := validateValidatorKey(key); err != nil {
+			return fmt.Errorf("preset %s: validator %d: %w", p.Name, i+1, err)
+		}
+		if seen[key] {
+			return fmt.Errorf("preset %s: validator %d is listed twice", p.Name, i+1)
+		}
+		seen[key] = true
+	}
+	if n := len(p.ValidatorSet()); p.Quorum < 1 || p.Quorum > n {
+		return fmt.Errorf("preset %s: quorum must be between 1 and %d (the number of validators), got %d", p.Name, n, p.Quorum)
+	}
+	if p.BlockReward < 0 || p.BlockReward > MaxBlockReward {
+		return fmt.Errorf("preset %s: block_reward must be between 0 and %d SOLE", p.Name, MaxBlockReward/100000000)
+	}
+	if len(p.Allocations) == 0 {
+		return fmt.Errorf("preset %s: allocations can't be empty", p.Name)
+	}
+	if err := ValidateGenesisAllocations(p.Allocations); err != nil {
+		return fmt.Errorf("preset %s: %w", p.Name, err)
+	}
+	return nil
+}
+
+// validateValidatorKey checks an uncompressed P-256 public key in hex, the
+// format of AuthorizedValidators
+func validateValidatorKey(key string) error {
+	raw, err := hex.DecodeString(key)
+	if err != nil || len(raw) != 65 || raw[0] != 0x04 {
+		return errors.New("must be a 65-byte uncompressed public key in hex (04...)")
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), raw)
+	if x == nil || y == nil {
+		return errors.New("is not a point on the P-256 curve")
+	}
+	return nil
+}
+
+// Apply makes the preset's rules the ones this process runs with. Consensus
+// settings from the config file or flags that disagree are overridden: every
+// node of the network must use the preset's.
+func (p *GenesisPreset) Apply() {
+	if viper.IsSet("consensus.quorum") && viper.GetInt("consensus.quorum") != p.Quorum {
+		fmt.Printf("⚠️  consensus.quorum ignored: preset %s requires %d\n", p.Name, p.Quorum)
+	}
+	if viper.IsSet("consensus.block_reward") &&
+		int64(math.Round(viper.GetFloat64("consensus.block_reward")*100000000)) != p.BlockReward {
+		fmt.Printf("⚠️  consensus.block_reward ignored: preset %s pays %.8f SOLE\n", p.Name, float64(p.BlockReward)/100000000)
+	}
+
+	AuthorizedValidators = p.ValidatorSet()
+	BlockQuorum = p.Quorum
+	BlockReward = p.BlockReward
+	GenesisAllocations = p.Allocations
+	NetworkID = p.NetworkID
+	ActivePreset = p.Name
+}
+
+// LoadPreset applies the preset the chain was initialized with, if any. It
+// must run before anything checks validators (e.g. Revoked).
+func (chain *Blockchain) LoadPreset() error {
+	var name string
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(presetKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		v, err := item.ValueCopy(nil)
+		name = string(v)
+		return err
+	})
+	if err != nil || name == "" || name == ActivePreset {
+		return err
+	}
+
+	p, err := FindGenesisPreset(name)
+	if err != nil {
+		return fmt.Errorf("chain was initialized with preset %q, which this build doesn't have: %w", name, err)
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	p.Apply()
+	return nil
+}
+
+// applyStoredPreset applies the preset of the local chain, if there is one,
+// for commands that check validators before opening it or without opening
+// it at all (node start, chain sign-block, chain revoke-validator)
+func applyStoredPreset() error {
+	if !DBExists() {
+		return nil
+	}
+	db, err := badger.Open(getBadgerOptions(dbPath, true))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	chain := Blockchain{Database: db}
+	return chain.LoadPreset()
+}
+
+// applyStoredPresetOrWarn is applyStoredPreset for the offline signing
+// commands: when the chain is locked by a running node they go on with the
+// compiled validators
+func applyStoredPresetOrWarn() {
+	if err := applyStoredPreset(); err != nil {
+		fmt.Printf("⚠️  Could not read the local chain's preset (%v): checking against the compiled validators.\n", err)
+	}
+}
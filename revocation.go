@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// revokedPrefix keys the revoked public keys of each stored block that
+	// carries revocations, by block hash
+	revokedPrefix = "revoked-"
+
+	// MaxBlockRevocations bounds the revocations one block carries
+	MaxBlockRevocations = 16
+)
+
+// ValidatorRevocation removes a compromised key from AuthorizedValidators
+// without a new release. It is valid once a strict majority of the other
+// validators signed it (see RevocationQuorum), and takes effect when a block
+// includes it: from the next height on, blocks signed by the key are
+// rejected. Blocks below that height, including the key's, stay valid.
+type ValidatorRevocation struct {
+	PubKey     string // Standard (65 bytes) hex key being revoked
+	Signatures []BlockAttestation
+}
+
+// RevokedValidator is a revocation included in the main chain, as listed by
+// GET /validators
+type RevokedValidator struct {
+	PubKey string `json:"pubkey"`
+	Height int    `json:"height"` // First height whose blocks it may not sign
+}
+
+// blockRevocations is what the revokedPrefix index stores for a block
+type blockRevocations struct {
+	Height  int
+	PubKeys []string
+}
+
+// RevocationQuorum is how many validators must sign the revocation of
+// pubKey: a strict majority of the authorized ones that are neither pubKey
+// nor already revoked
+func RevocationQuorum(pubKey string, revoked map[string]int) int {
+	others := 0
+	for _, v := range AuthorizedValidators {
+		if _, gone := revoked[v]; v != pubKey && !gone {
+			others++
+		}
+	}
+	return others/2 + 1
+}
+
+// Digest is what every validator signs. The domain prefix keeps a block
+// signature from ever passing as a revocation signature.
+func (r *ValidatorRevocation) Digest() []byte {
+	hash := sha256.Sum256([]byte("SOLE validator revocation:" + r.PubKey))
+	return hash[:]
+}
+
+// Sign adds privKey's signature to the revocation
+func (r *ValidatorRevocation) Sign(privKey ecdsa.PrivateKey) error {
+	signer := ValidatorPubKeyHex(&privKey)
+	if signer == r.PubKey {
+		return errors.New("the revoked key cannot sign its own revocation")
+	}
+	for _, sig := range r.Signatures {
+		if ValidatorPubKeyFromBlock(sig.Validator) == signer {
+			return errors.New("this validator already signed the revocation")
+		}
+	}
+
+	rs, ss, err := ecdsa.Sign(rand.Reader, &privKey, r.Digest())
+	if err != nil {
+		return err
+	}
+	r.Signatures = append(r.Signatures, BlockAttestation{
+		Validator: append(privKey.PublicKey.X.FillBytes(make([]byte, 32)),
+			privKey.PublicKey.Y.FillBytes(make([]byte, 32))...),
+		Signature: GetSignatureBytes(rs, ss),
+	})
+	return nil
+}
+
+// Verify checks every signature against the keys revoked so far and returns
+// the number of distinct validators that signed. The key must be authorized
+// and not revoked yet; the signers must be neither that key nor revoked.
+func (r *ValidatorRevocation) Verify(revoked map[string]int) (int, error) {
+	if !IsAuthorizedValidator(r.PubKey) {
+		return 0, fmt.Errorf("%.16s... is not an authorized validator", r.PubKey)
+	}
+	if _, gone := revoked[r.PubKey]; gone {
+		return 0, fmt.Errorf("%.16s... is already revoked", r.PubKey)
+	}
+	if len(r.Signatures) > MaxBlockAttestations {
+		return 0, fmt.Errorf("%d signatures, at most %d allowed", len(r.Signatures), MaxBlockAttestations)
+	}
+
+	digest := r.Digest()
+	signers := make(map[string]bool)
+	for i, sig := range r.Signatures {
+		signer, ok := verifyValidatorSignature(sig.Validator, sig.Signature, digest)
+		if !ok {
+			return 0, fmt.Errorf("signature %d is invalid", i+1)
+		}
+		if signer == r.PubKey {
+			return 0, errors.New("signed by the revoked key itself")
+		}
+		if _, gone := revoked[signer]; gone {
+			return 0, fmt.Errorf("signature %d is by a revoked key", i+1)
+		}
+		signers[signer] = true
+	}
+	return len(signers), nil
+}
+
+// Complete returns an error unless the revocation verifies and enough
+// validators signed it
+func (r *ValidatorRevocation) Complete(revoked map[string]int) error {
+	signers, err := r.Verify(revoked)
+	if err != nil {
+		return err
+	}
+	if quorum := RevocationQuorum(r.PubKey, revoked); signers < quorum {
+		return fmt.Errorf("not enough signatures: %d of %d validators", signers, quorum)
+	}
+	return nil
+}
+
+func (r *ValidatorRevocation) Serialize() []byte {
+	return GobEncode(r)
+}
+
+func DeserializeRevocation(data []byte) (*ValidatorRevocation, error) {
+	var r ValidatorRevocation
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid revocation: %w", err)
+	}
+	return &r, nil
+}
+
+// encodeRevocations is the canonical encoding of a block's revocations, in
+// the style of SerializeCanonical: count | count × (pubKey | sigCount |
+// sigCount × (validator | signature)). The block hash commits to it.
+func encodeRevocations(revocations []ValidatorRevocation) []byte {
+	var encoded bytes.Buffer
+	writeBytes := func(data []byte) {
+		binary.Write(&encoded, binary.BigEndian, int64(len(data)))
+		encoded.Write(data)
+	}
+
+	binary.Write(&encoded, binary.BigEndian, int64(len(revocations)))
+	for _, r := range revocations {
+		writeBytes([]byte(r.PubKey))
+		binary.Write(&encoded, binary.BigEndian, int64(len(r.Signatures)))
+		for _, sig := range r.Signatures {
+			writeBytes(sig.Validator)
+			writeBytes(sig.Signature)
+		}
+	}
+	return encoded.Bytes()
+}
+
+// CheckRevocations applies the consensus rules on revocations to block,
+// given the keys revoked by its ancestors: no signer of the block may be
+// revoked, and each revocation it carries must be complete and revoke a new
+// key. The block's revocations are then added to revoked, from the next
+// height on.
+func CheckRevocations(block *Block, revoked map[string]int) error {
+	signers := []string{ValidatorPubKeyFromBlock(block.Validator)}
+	for _, a := range block.Attestations {
+		signers = append(signers, ValidatorPubKeyFromBlock(a.Validator))
+	}
+	for _, signer := range signers {
+		if from, gone := revoked[signer]; gone {
+			return fmt.Errorf("signed by %.16s..., revoked from height %d", signer, from)
+		}
+	}
+
+	if len(block.Revocations) > MaxBlockRevocations {
+		return fmt.Errorf("%d revocations, at most %d allowed", len(block.Revocations), MaxBlockRevocations)
+	}
+	for _, r := range block.Revocations {
+		// Checked against the keys revoked before this block only, as a
+		// forger assembling it sees them
+		if err := r.Complete(revoked); err != nil {
+			return fmt.Errorf("revocation of %.16s...: %w", r.PubKey, err)
+		}
+	}
+	for _, r := range block.Revocations {
+		if _, dup := revoked[r.PubKey]; dup {
+			return fmt.Errorf("%.16s... revoked twice", r.PubKey)
+		}
+		revoked[r.PubKey] = block.Height + 1
+	}
+	return nil
+}
+
+// indexRevocations records the keys block revokes, if any, so the revoked
+// set of any branch can be derived without reading every block
+func indexRevocations(txn *badger.Txn, block *Block) error {
+	if len(block.Revocations) == 0 {
+		return nil
+	}
+	entry := blockRevocations{Height: block.Height}
+	for _, r := range block.Revocations {
+		entry.PubKeys = append(entry.PubKeys, r.PubKey)
+	}
+	return txn.Set(append([]byte(revokedPrefix), block.Hash...), GobEncode(entry))
+}
+
+// RevokedBy returns the keys revoked by tip and its ancestors, each with the
+// first height it may no longer sign
+func (chain *Blockchain) RevokedBy(tip *Block) (map[string]int, error) {
+	type indexed struct {
+		hash []byte
+		blockRevocations
+	}
+	var entries []indexed
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(revokedPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var e indexed
+			e.hash = bytes.TrimPrefix(it.Item().KeyCopy(nil), []byte(revokedPrefix))
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&e.blockRevocations); err != nil {
+				return fmt.Errorf("revocations of block %x: %w", e.hash, err)
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := make(map[string]int)
+	for _, e := range entries {
+		if e.Height > tip.Height || !chain.onBranch(e.hash, e.Height, tip) {
+			continue
+		}
+		for _, pubKey := range e.PubKeys {
+			revoked[pubKey] = e.Height + 1
+		}
+	}
+	return revoked, nil
+}
+
+// onBranch reports whether the block hash at height is tip or one of its
+// ancestors. Tip's branch is walked down to the main chain, where the
+// height index answers.
+func (chain *Blockchain) onBranch(hash []byte, height int, tip *Block) bool {
+	block := tip
+	for block.Height >= height {
+		if bytes.Equal(block.Hash, hash) {
+			return true
+		}
+		if main, err := chain.HashAtHeight(block.Height); err == nil && bytes.Equal(main, block.Hash) {
+			main, err := chain.HashAtHeight(height)
+			return err == nil && bytes.Equal(main, hash)
+		}
+		if block.IsGenesis() {
+			return false
+		}
+		parent, err := chain.GetBlock(block.PrevBlockHash)
+		if err != nil {
+			return false
+		}
+		block = &parent
+	}
+	return false
+}
+
+// Revoked returns the keys revoked on the main chain: those that may not sign
+// the next block
+func (chain *Blockchain) Revoked() (map[string]int, error) {
+	tip, err := chain.GetBlock(chain.LastHash)
+	if err != nil {
+		return nil, err
+	}
+	return chain.RevokedBy(&tip)
+}
+
+// RevokedValidators lists the revocations included in the main chain, lowest
+// height first
+func (chain *Blockchain) RevokedValidators() []RevokedValidator {
+	revoked, err := chain.Revoked()
+	if err != nil {
+		fmt.Printf("⚠️  Validator revocations can't be read: %v\n", err)
+	}
+	list := make([]RevokedValidator, 0, len(revoked))
+	for pubKey, height := range revoked {
+		list = append(list, RevokedValidator{PubKey: pubKey, Height: height})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Height != list[j].Height {
+			return list[i].Height < list[j].Height
+		}
+		return list[i].PubKey < list[j].PubKey
+	})
+	return list
+}
+
+// AddRevocation queues a complete revocation for the next block this node
+// forges. It reports false if the key is already revoked or queued.
+// Caller must hold MempoolMux.
+func (s *Server) AddRevocation(r *ValidatorRevocation) (bool, error) {
+	revoked, err := s.Blockchain.Revoked()
+	if err != nil {
+		return false, err
+	}
+	if _, gone := revoked[r.PubKey]; gone {
+		return false, nil
+	}
+	if err := r.Complete(revoked); err != nil {
+		return false, err
+	}
+	if _, queued := s.Revocations[r.PubKey]; queued {
+		return false, nil
+	}
+	s.Revocations[r.PubKey] = *r
+	return true, nil
+}
+
+// revocationsForBlock returns the queued revocations still valid on top of
+// the main chain, in key order, and drops the others (already included,
+// or no longer complete). Caller must hold MempoolMux.
+func (s *Server) revocationsForBlock() []ValidatorRevocation {
+	if len(s.Revocations) == 0 {
+		return nil
+	}
+	revoked, err := s.Blockchain.Revoked()
+	if err != nil {
+		fmt.Printf("⚠️  [Forge] Validator revocations can't be read: %v\n", err)
+		return nil
+	}
+
+	var included []ValidatorRevocation
+	for pubKey, r := range s.Revocations {
+		if err := r.Complete(revoked); err != nil {
+			delete(s.Revocations, pubKey)
+			continue
+		}
+		included = append(included, r)
+	}
+	sort.Slice(included, func(i, j int) bool { return included[i].PubKey < included[j].PubKey })
+	if len(included) > MaxBlockRevocations {
+		included = included[:MaxBlockRevocations]
+	}
+	return included
+}
+
+// RevocationMsg relays a complete revocation to the validators that will
+// include it in a block
+type RevocationMsg struct {
+	AddrFrom   string
+	Revocation []byte
+}
+
+func (s *Server) SendRevocation(peerID peer.ID, r *ValidatorRevocation) {
+	payload := GobEncode(RevocationMsg{s.Host.ID().String(), r.Serialize()})
+	request := append(CommandToBytes("revocation"), payload...)
+	s.SendData(peerID, request)
+}
+
+// RelayRevocation sends r to every connected peer speaking protocol v3,
+// except the one it came from
+func (s *Server) RelayRevocation(r *ValidatorRevocation, except peer.ID) {
+	for _, p := range s.Host.Network().Peers() {
+		if p != except && s.peerSupports(p, ProtocolRevocations) {
+			s.SendRevocation(p, r)
+		}
+	}
+}
+
+// HandleRevocation queues a revocation relayed by a peer and passes it on
+// the first time it is seen
+func (s *Server) HandleRevocation(request []byte, peerID peer.ID) {
+	var payload RevocationMsg
+	if err := gob.NewDecoder(bytes.NewReader(request)).Decode(&payload); err != nil {
+		log.Printf("⚠️ HandleRevocation: gob decode error from %s: %v", ShortID(peerID.String()), err)
+		return
+	}
+	r, err := DeserializeRevocation(payload.Revocation)
+	if err != nil {
+		log.Printf("⚠️ HandleRevocation: %v from %s", err, ShortID(peerID.String()))
+		return
+	}
+
+	s.MempoolMux.Lock()
+	added, err := s.AddRevocation(r)
+	s.MempoolMux.Unlock()
+	if err != nil {
+		fmt.Printf("⚠️  [HandleRevocation] Rejected revocation of %.16s... from %s: %v\n", r.PubKey, ShortID(peerID.String()), err)
+		return
+	}
+	if added {
+		fmt.Printf("⛔ Revocation of validator %.16s... queued for the next block\n", r.PubKey)
+		s.RelayRevocation(r, peerID)
+	}
+}
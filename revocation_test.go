@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// revokingBlock is buildBlock for a block that carries revocations
+func revokingBlock(t *testing.T, prev *Block, signer *Wallet, revocations ...ValidatorRevocation) *Block {
+	t.Helper()
+
+	key, err := signer.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbase := NewCoinbaseTX(signer.GetAddress(), "revocation", InitialSubsidy)
+	block := NewBlock([]*Transaction{coinbase}, prev.Hash, prev.Height+1, nil)
+	block.Timestamp = prev.Timestamp + 1
+	block.Revocations = revocations
+	MineBlock(block)
+	if err := SignBlock(block, key); err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// revocationOf returns the revocation of revoked signed by signers
+func revocationOf(t *testing.T, revoked *Wallet, signers ...*Wallet) ValidatorRevocation {
+	t.Helper()
+
+	r := ValidatorRevocation{PubKey: GetValidatorHex(*revoked)}
+	for _, w := range signers {
+		key, err := w.GetPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func TestRevocationNeedsQuorum(t *testing.T) {
+	a, _ := newValidator(t)
+	b, _ := newValidator(t)
+	c, _ := newValidator(t)
+	withValidators(t, 1, a, b, c)
+	s := NewAPIOnlyServer(newTestChain(t))
+
+	// 2 of the 2 other validators must sign
+	partial := revocationOf(t, c, a)
+	if err := partial.Complete(map[string]int{}); err == nil {
+		t.Fatal("revocation signed by 1 of 2 validators is complete")
+	}
+	if _, err := s.AddRevocation(&partial); err == nil {
+		t.Fatal("revocation without quorum queued")
+	}
+	if s.Blockchain.AddBlock(revokingBlock(t, tipBlock(t, s.Blockchain), a, partial)) {
+		t.Fatal("block carrying a revocation without quorum accepted")
+	}
+
+	// Once the revoked key is left out, the quorum is 1 of 1
+	full := revocationOf(t, c, a, b)
+	if err := full.Complete(map[string]int{}); err != nil {
+		t.Fatalf("revocation signed by 2 of 2 validators: %v", err)
+	}
+	if err := full.Complete(map[string]int{GetValidatorHex(*b): 1}); err == nil {
+		t.Fatal("revocation signed by a revoked key is complete")
+	}
+	if added, err := s.AddRevocation(&full); err != nil || !added {
+		t.Fatalf("complete revocation not queued: %v", err)
+	}
+	if added, _ := s.AddRevocation(&full); added {
+		t.Fatal("revocation queued twice")
+	}
+}
+
+func TestRevokedKeyRejectedAfterInclusion(t *testing.T) {
+	a, _ := newValidator(t)
+	b, _ := newValidator(t)
+	c, _ := newValidator(t)
+	withValidators(t, 1, a, b, c)
+	chain := newTestChain(t)
+	cKey := GetValidatorHex(*c)
+
+	signedByC := addTestBlock(t, chain, c)
+	revoking := revokingBlock(t, signedByC, a, revocationOf(t, c, a, b))
+	if !chain.AddBlock(revoking) {
+		t.Fatal("block carrying a complete revocation rejected")
+	}
+	revoked, err := chain.Revoked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from, ok := revoked[cKey]; !ok || from != revoking.Height+1 {
+		t.Fatalf("revoked set %v, want %.16s... from height %d", revoked, cKey, revoking.Height+1)
+	}
+
+	// The key's block below the revocation stays on the chain
+	if !chain.HasBlock(signedByC.Hash) {
+		t.Fatal("block signed before the revocation dropped")
+	}
+	if chain.AddBlock(buildBlock(t, revoking, c)) {
+		t.Fatal("block signed by the revoked key accepted")
+	}
+
+	// A branch that doesn't include the revocation still accepts the key
+	sibling := buildBlock(t, signedByC, b)
+	if !chain.AddBlock(sibling) {
+		t.Fatal("side branch block rejected")
+	}
+	if !chain.AddBlock(buildBlock(t, sibling, c)) {
+		t.Fatal("key rejected on a branch without its revocation")
+	}
+	if revoked, err := chain.Revoked(); err != nil || len(revoked) != 0 {
+		t.Fatalf("revoked set %v after switching to the branch without the revocation (%v)", revoked, err)
+	}
+}
+
+func TestAttemptMineIncludesRevocation(t *testing.T) {
+	a, _ := newValidator(t)
+	b, _ := newValidator(t)
+	c, _ := newValidator(t)
+	withValidators(t, 1, c, a, b) // a forges height 1
+	s := newTestServer(t, newTestChain(t))
+	s.ValidatorKeys = []ValidatorKey{loadValidatorKey(t, a)}
+
+	r := revocationOf(t, c, a, b)
+	s.MempoolMux.Lock()
+	_, err := s.AddRevocation(&r)
+	s.MempoolMux.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No transactions and no --empty-blocks: the revocation alone gets a block
+	s.AttemptMine()
+	tip := tipBlock(t, s.Blockchain)
+	if tip.Height != 1 || len(tip.Revocations) != 1 || tip.Revocations[0].PubKey != r.PubKey {
+		t.Fatalf("tip at height %d carries %d revocation(s), want the pending one at height 1", tip.Height, len(tip.Revocations))
+	}
+	if len(s.Revocations) != 0 {
+		t.Fatalf("%d revocation(s) still pending after being included", len(s.Revocations))
+	}
+
+	// Height 3 is c's turn: it passes to a, next in the schedule
+	revoked, err := s.Blockchain.Revoked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key := s.ForgingKey(3, revoked); key == nil || key.Address != a.GetAddress() {
+		t.Fatal("revoked proposer's turn not passed to the next validator")
+	}
+}
+
+func TestBlockRevocationsCanonicalRoundTrip(t *testing.T) {
+	a, _ := newValidator(t)
+	b, _ := newValidator(t)
+	c, _ := newValidator(t)
+	withValidators(t, 1, a, b, c)
+	chain := newTestChain(t)
+
+	block := revokingBlock(t, tipBlock(t, chain), a, revocationOf(t, c, a, b))
+	decoded, err := DeserializeBlockCanonical(block.SerializeCanonical())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.HeaderHash(), block.Hash) {
+		t.Fatal("decoded block doesn't hash to the original")
+	}
+	if len(decoded.Revocations) != 1 || len(decoded.Revocations[0].Signatures) != 2 {
+		t.Fatalf("decoded %d revocation(s), want 1 with 2 signatures", len(decoded.Revocations))
+	}
+
+	// The hash commits to the revocations
+	decoded.Revocations = nil
+	if bytes.Equal(decoded.HeaderHash(), block.Hash) {
+		t.Fatal("block hash doesn't cover its revocations")
+	}
+}
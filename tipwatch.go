@@ -0,0 +1,32 @@
+package main
+
+import "sync"
+
+// TipNotifier wakes long-poll requests waiting for a new tip. Every change
+// closes the current channel and replaces it, so any number of waiters can
+// share one notification without registering.
+type TipNotifier struct {
+	changed chan struct{}
+	mux     sync.Mutex
+}
+
+func NewTipNotifier() *TipNotifier {
+	return &TipNotifier{changed: make(chan struct{})}
+}
+
+// Changed returns a channel closed at the next tip change. Take it before
+// reading the tip, or a block landing in between is missed until the next one.
+func (tn *TipNotifier) Changed() <-chan struct{} {
+	tn.mux.Lock()
+	defer tn.mux.Unlock()
+	return tn.changed
+}
+
+// Notify wakes every waiter. Called when a block extends the tip and after
+// a reorg.
+func (tn *TipNotifier) Notify() {
+	tn.mux.Lock()
+	close(tn.changed)
+	tn.changed = make(chan struct{})
+	tn.mux.Unlock()
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
@@ -13,7 +12,6 @@ import (
 	"io"
 	"log"
 	"math/big"
-	"os"
 	"time"
 )
 
@@ -21,6 +19,17 @@ const (
 	MaxTxInputs  = 10000
 	MaxTxOutputs = 10000
 	MaxFieldLen  = 1 << 20 // 1MB per field
+
+	MaxCoinbaseMessageLen = 100 // Miner-supplied coinbase data (bytes)
+
+	// Admission limits (mempool and blocks)
+	MaxTxSize        = 100 * 1024 // 100KB serialized
+	MaxTxInputCount  = 1000
+	MaxTxOutputCount = 1000
+
+	// Relay policy defaults (overridable via node config)
+	DefaultDustLimit = 546           // Outputs below this many Photons are dust (memo outputs excepted)
+	DefaultMaxTxFee  = 1 * 100000000 // 1 SOLE: higher fees are treated as a mistake
 )
 
 type TxOutput struct {
@@ -87,10 +96,32 @@ func (in *TxInput) UsesKey(pubKeyHash []byte) bool {
 }
 
 type Transaction struct {
-	ID        []byte
-	Vin       []TxInput
-	Vout      []TxOutput
-	Timestamp int64
+	ID         []byte
+	Vin        []TxInput
+	Vout       []TxOutput
+	Timestamp  int64
+	LockHeight int   // Can't be mined below this height (0 = no lock)
+	Fee        int64 // Declared fee in Photons (0 = implicit, inputs − outputs)
+}
+
+// CheckDeclaredFee rejects a declared Fee that differs from the actual
+// inputs − outputs. Transactions without a declared fee always pass.
+//
+// A declared Fee is signed with the transaction, so anyone can read what it
+// pays without resolving the value of every spent output. Nodes still resolve
+// the inputs once, at admission and in blocks, and call this: the declaration
+// saves later lookups but is never trusted on its own. Leaving it 0 keeps the
+// old implicit fee, so older transactions (and their IDs) stay valid.
+func (tx Transaction) CheckDeclaredFee(actual int64) error {
+	if tx.Fee != 0 && tx.Fee != actual {
+		return fmt.Errorf("%w: declares %d Photons, pays %d", ErrFeeMismatch, tx.Fee, actual)
+	}
+	return nil
+}
+
+// IsFinal reports whether tx may be included in a block at height
+func (tx Transaction) IsFinal(height int) bool {
+	return tx.LockHeight <= height
 }
 
 func (tx Transaction) Serialize() []byte {
@@ -119,9 +150,42 @@ func (tx Transaction) Serialize() []byte {
 	// Timestamp
 	binary.Write(&encoded, binary.BigEndian, tx.Timestamp)
 
+	// Lock height and declared fee, only when set: older transactions keep their
+	// encoding. A fee needs the lock height slot before it, written as 0 if unlocked.
+	if tx.LockHeight > 0 || tx.Fee > 0 {
+		binary.Write(&encoded, binary.BigEndian, int64(tx.LockHeight))
+	}
+	if tx.Fee > 0 {
+		binary.Write(&encoded, binary.BigEndian, tx.Fee)
+	}
+
 	return encoded.Bytes()
 }
 
+// ValidateSize enforces the admission limits on serialized size and input/output counts
+func (tx Transaction) ValidateSize() error {
+	if len(tx.Vin) > MaxTxInputCount {
+		return fmt.Errorf("too many inputs (%d, max %d)", len(tx.Vin), MaxTxInputCount)
+	}
+	if len(tx.Vout) > MaxTxOutputCount {
+		return fmt.Errorf("too many outputs (%d, max %d)", len(tx.Vout), MaxTxOutputCount)
+	}
+	if size := len(tx.Serialize()); size > MaxTxSize {
+		return fmt.Errorf("transaction too large (%d bytes, max %d)", size, MaxTxSize)
+	}
+	return nil
+}
+
+// CheckDust rejects non-zero outputs below dustLimit. Zero-value outputs are memos.
+func (tx Transaction) CheckDust(dustLimit int64) error {
+	for i, out := range tx.Vout {
+		if out.Value > 0 && out.Value < dustLimit {
+			return fmt.Errorf("%w: output %d is %d Photons (min %d)", ErrDustOutput, i, out.Value, dustLimit)
+		}
+	}
+	return nil
+}
+
 func DeserializeTransaction(data []byte) Transaction {
 	var tx Transaction
 	reader := bytes.NewReader(data)
@@ -223,6 +287,24 @@ func DeserializeTransaction(data []byte) Transaction {
 		binary.Read(reader, binary.BigEndian, &tx.Timestamp)
 	}
 
+	// Optional lock height
+	if reader.Len() >= 8 {
+		var lockHeight int64
+		binary.Read(reader, binary.BigEndian, &lockHeight)
+		if lockHeight > 0 {
+			tx.LockHeight = int(lockHeight)
+		}
+	}
+
+	// Optional declared fee
+	if reader.Len() >= 8 {
+		var fee int64
+		binary.Read(reader, binary.BigEndian, &fee)
+		if fee > 0 {
+			tx.Fee = fee
+		}
+	}
+
 	// Recalculate Hash (ID)
 	tx.ID = tx.Hash()
 	return tx
@@ -259,27 +341,82 @@ func (tx Transaction) SerializeForHash() []byte {
 	// Timestamp
 	binary.Write(&encoded, binary.BigEndian, tx.Timestamp)
 
+	// Lock height and declared fee (covered by the ID and the signatures)
+	if tx.LockHeight > 0 || tx.Fee > 0 {
+		binary.Write(&encoded, binary.BigEndian, int64(tx.LockHeight))
+	}
+	if tx.Fee > 0 {
+		binary.Write(&encoded, binary.BigEndian, tx.Fee)
+	}
+
 	return encoded.Bytes()
 }
 
 func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+	lockFor := func(vin TxInput) ([]byte, error) {
+		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+		if prevTx.ID == nil {
+			return nil, fmt.Errorf("skipped signing: previous transaction %x not found in context", vin.Txid) // Cannot sign if input tx is missing
+		}
+		if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return nil, fmt.Errorf("skipped signing: output %x:%d does not exist", vin.Txid, vin.Vout)
+		}
+		return prevTx.Vout[vin.Vout].PubKeyHash, nil
+	}
+	if err := tx.signInputs(func(TxInput) (ecdsa.PrivateKey, bool) { return privKey, true }, lockFor); err != nil {
+		fmt.Printf("⚠️  [Sign] %v\n", err)
+	}
+}
+
+// OutpointKey identifies output vout of transaction txID in SignWithKeys
+func OutpointKey(txID []byte, vout int) string {
+	return fmt.Sprintf("%x:%d", txID, vout)
+}
+
+// SignWithKeys signs each input with the key of the wallet that owns it, so a
+// transaction can spend outputs of several addresses. keys is indexed by the
+// hex-encoded public key carried in TxInput.PubKey. spent holds the outputs
+// the inputs spend, by OutpointKey: a wallet has them from the node's UTXO
+// list, even when the node no longer stores the previous transactions (--prune).
+func (tx *Transaction) SignWithKeys(keys map[string]ecdsa.PrivateKey, spent map[string]TxOutput) error {
+	return tx.signInputs(func(vin TxInput) (ecdsa.PrivateKey, bool) {
+		key, ok := keys[hex.EncodeToString(vin.PubKey)]
+		return key, ok
+	}, func(vin TxInput) ([]byte, error) {
+		out, ok := spent[OutpointKey(vin.Txid, vin.Vout)]
+		if !ok {
+			return nil, fmt.Errorf("spent output %s not provided", OutpointKey(vin.Txid, vin.Vout))
+		}
+		return out.PubKeyHash, nil
+	})
+}
+
+// signInputs signs every input with the key from keyFor. lockFor returns the
+// public key hash locking the output an input spends, which the signature covers.
+func (tx *Transaction) signInputs(keyFor func(TxInput) (ecdsa.PrivateKey, bool), lockFor func(TxInput) ([]byte, error)) error {
 	if tx.IsCoinbase() {
-		return
+		return nil
 	}
 
-	for _, vin := range tx.Vin {
-		if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-			fmt.Printf("⚠️  [Sign] Skipped input: Previous transaction %x not found in context.\n", vin.Txid)
-			return // Cannot sign if input tx is missing
+	locks := make([][]byte, len(tx.Vin))
+	for inID, vin := range tx.Vin {
+		lock, err := lockFor(vin)
+		if err != nil {
+			return err
 		}
+		locks[inID] = lock
 	}
 
 	txCopy := tx.TrimmedCopy()
 
-	for inID, vin := range txCopy.Vin {
-		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+	for inID := range txCopy.Vin {
+		privKey, ok := keyFor(tx.Vin[inID])
+		if !ok {
+			return fmt.Errorf("no key for input %d (public key %x)", inID, tx.Vin[inID].PubKey)
+		}
+
 		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
+		txCopy.Vin[inID].PubKey = locks[inID]
 		txCopy.ID = txCopy.Hash()
 		txCopy.Vin[inID].PubKey = nil
 
@@ -295,22 +432,31 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transac
 
 		tx.Vin[inID].Signature = signature
 	}
+	return nil
 }
 
 func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+	if err := tx.CheckSignatures(prevTXs); err != nil {
+		fmt.Printf("⛔ ERROR: %s\n", err)
+		return false
+	}
+	return true
+}
+
+// CheckSignatures verifies every input against its previous output.
+// Returns ErrUnknownInput or ErrInvalidSignature (wrapped) on failure.
+func (tx *Transaction) CheckSignatures(prevTXs map[string]Transaction) error {
 	if tx.IsCoinbase() {
-		return true
+		return nil
 	}
 
-	for _, vin := range tx.Vin {
-		if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-			fmt.Printf("ERROR: Previous transaction is not correct\n")
-			return false
+	for inID := range tx.Vin {
+		if _, err := tx.prevOutput(prevTXs, inID); err != nil {
+			return err
 		}
 	}
 
 	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
 
 	for inID, vin := range tx.Vin {
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
@@ -319,47 +465,59 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 		txCopy.ID = txCopy.Hash()
 		txCopy.Vin[inID].PubKey = nil
 
-		// 1. Strict Key Check: ANSI X9.62 Uncompressed (65 bytes, 0x04 prefix)
-		if len(vin.PubKey) != 65 {
-			fmt.Printf("⛔ ERROR: Input %d: Invalid Public Key length: %d (Expected 65)\n", inID, len(vin.PubKey))
-			return false
-		}
-		if vin.PubKey[0] != 0x04 {
-			fmt.Printf("⛔ ERROR: Input %d: Invalid Public Key prefix: 0x%x (Expected 0x04)\n", inID, vin.PubKey[0])
-			return false
+		// 1. Strict Key Check: uncompressed (65 bytes), raw (64 bytes) or compressed (33 bytes)
+		pubKey, err := ParsePubKey(vin.PubKey)
+		if err != nil {
+			return fmt.Errorf("%w: input %d: %v", ErrInvalidSignature, inID, err)
 		}
 
 		// Verify ownership: Check if the input signer's key hashes to the output's PubKeyHash
 		signerHash := HashPubKey(vin.PubKey)
 		if !bytes.Equal(signerHash, prevTx.Vout[vin.Vout].PubKeyHash) {
-			fmt.Printf("⛔ ERROR: Input %d: Public Key hash does not match Output's PubKeyHash\n", inID)
-			return false
+			return fmt.Errorf("%w: input %d: public key hash does not match the output's PubKeyHash", ErrInvalidSignature, inID)
 		}
 
 		r := big.Int{}
 		s := big.Int{}
 		if len(vin.Signature) != 64 {
-			fmt.Printf("⛔ ERROR: Input %d: Invalid Signature length: %d\n", inID, len(vin.Signature))
-			return false
+			return fmt.Errorf("%w: input %d: invalid signature length %d", ErrInvalidSignature, inID, len(vin.Signature))
 		}
 		r.SetBytes(vin.Signature[:32])
 		s.SetBytes(vin.Signature[32:])
 
-		x := big.Int{}
-		y := big.Int{}
-		// We already checked len(vin.PubKey) == 65 above.
-		// Uncompressed format: 0x04 + 32 bytes X + 32 bytes Y
-		x.SetBytes(vin.PubKey[1:33])
-		y.SetBytes(vin.PubKey[33:])
-
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-		if !ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) {
-			fmt.Printf("⛔ ERROR: Input %d: ECDSA Signature Verification failed\n", inID)
-			return false
+		if !ecdsa.Verify(pubKey, txCopy.ID, &r, &s) {
+			return fmt.Errorf("%w: input %d: ECDSA verification failed", ErrInvalidSignature, inID)
 		}
 	}
 
-	return true
+	return nil
+}
+
+// prevOutput returns the output input inID spends, looked up in prevTXs.
+// A missing transaction or output index is ErrUnknownInput (wrapped).
+func (tx *Transaction) prevOutput(prevTXs map[string]Transaction, inID int) (TxOutput, error) {
+	vin := tx.Vin[inID]
+	prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+	if prevTx.ID == nil {
+		return TxOutput{}, fmt.Errorf("%w: input %d: previous transaction %x not found", ErrUnknownInput, inID, vin.Txid)
+	}
+	if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+		return TxOutput{}, fmt.Errorf("%w: input %d: output %d does not exist in %x", ErrUnknownInput, inID, vin.Vout, vin.Txid)
+	}
+	return prevTx.Vout[vin.Vout], nil
+}
+
+// InputTotal sums the values of the outputs tx spends, looked up in prevTXs
+func (tx *Transaction) InputTotal(prevTXs map[string]Transaction) (int64, error) {
+	var total int64
+	for inID := range tx.Vin {
+		out, err := tx.prevOutput(prevTXs, inID)
+		if err != nil {
+			return 0, err
+		}
+		total += out.Value
+	}
+	return total, nil
 }
 
 func (tx *Transaction) TrimmedCopy() Transaction {
@@ -374,15 +532,25 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 		outputs = append(outputs, TxOutput{vout.Value, vout.PubKeyHash})
 	}
 
-	txCopy := Transaction{tx.ID, inputs, outputs, tx.Timestamp}
+	txCopy := Transaction{tx.ID, inputs, outputs, tx.Timestamp, tx.LockHeight, tx.Fee}
 
 	return txCopy
 }
 
+// ValidateCoinbaseMessage checks a --coinbase-message value
+func ValidateCoinbaseMessage(msg string) error {
+	if len(msg) > MaxCoinbaseMessageLen {
+		return fmt.Errorf("coinbase message too long (%d bytes, max %d)", len(msg), MaxCoinbaseMessageLen)
+	}
+	return nil
+}
+
 func (tx Transaction) IsCoinbase() bool {
 	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
+// NewCoinbaseTX pays amount to to. data is stored as is: a miner-supplied
+// message is checked by ValidateCoinbaseMessage when the node starts.
 func NewCoinbaseTX(to, data string, amount int64) *Transaction {
 	if data == "" {
 		data = fmt.Sprintf("Reward to '%s'", to)
@@ -390,24 +558,36 @@ func NewCoinbaseTX(to, data string, amount int64) *Transaction {
 
 	txin := TxInput{[]byte{}, -1, nil, []byte(data)}
 	txout := NewTxOutput(amount, to)
-	tx := Transaction{nil, []TxInput{txin}, []TxOutput{*txout}, time.Now().Unix()}
+	tx := Transaction{nil, []TxInput{txin}, []TxOutput{*txout}, time.Now().Unix(), 0, 0}
 	tx.ID = tx.Hash()
 
 	return &tx
 }
 
-func NewUTXOTransaction(from, to string, amount int64, fee int64, memo string, utxoSet *UTXOSet) *Transaction {
+// NewUTXOTransaction builds and signs a transaction from the local wallet.
+// Change goes to the change address, or back to from when change is empty;
+// change below dustLimit is left to the fee.
+// Returns ErrInsufficientFunds (wrapped) when the balance does not cover amount + fee.
+func NewUTXOTransaction(from, to, change string, amount int64, fee int64, memo string, dustLimit int64, utxoSet *UTXOSet) (*Transaction, error) {
 	var inputs []TxInput
 	var outputs []TxOutput
 
 	wallets, err := CreateWallets()
 	if err != nil {
-		log.Panic(err)
+		return nil, err
 	}
 	wallet := wallets.GetWalletRef(from)
 	if wallet == nil {
-		fmt.Printf("⛔ ERRORE: Wallet non trovato per l'indirizzo mittente %s. Assicurati di avere il file wallet.dat corretto.\n", from)
-		os.Exit(1)
+		return nil, fmt.Errorf("wallet not found for sender address %s", from)
+	}
+	if change == "" {
+		change = from
+	}
+	if !ValidateAddress(change) {
+		return nil, fmt.Errorf("invalid change address %s", change)
+	}
+	if wallets.GetWalletRef(change) == nil {
+		return nil, fmt.Errorf("wallet not found for change address %s", change)
 	}
 	pubKeyHash := HashPubKey(wallet.PublicKey)
 
@@ -417,15 +597,13 @@ func NewUTXOTransaction(from, to string, amount int64, fee int64, memo string, u
 	acc, validOutputs := utxoSet.FindSpendableOutputs(pubKeyHash, totalRequired)
 
 	if acc < totalRequired {
-		fmt.Printf("⛔ ERRORE: Fondi insufficienti. Disponibili: %d, Richiesti: %d (Importo: %d + Fee: %d)\n", acc, totalRequired, amount, fee)
-		os.Exit(1)
-		// return nil // unreachable
+		return nil, fmt.Errorf("%w: available %d, required %d (amount %d + fee %d)", ErrInsufficientFunds, acc, totalRequired, amount, fee)
 	}
 
 	for txid, outs := range validOutputs {
 		txID, err := hex.DecodeString(txid)
 		if err != nil {
-			log.Panic(err)
+			return nil, err
 		}
 
 		for _, out := range outs {
@@ -445,19 +623,23 @@ func NewUTXOTransaction(from, to string, amount int64, fee int64, memo string, u
 	// The primary destination output
 	outputs = append(outputs, *NewTxOutput(amount, to))
 
-	// The change output (returned to sender)
-	if acc > totalRequired {
-		outputs = append(outputs, *NewTxOutput(acc-totalRequired, from))
+	// The change output
+	if acc-totalRequired > 0 && acc-totalRequired >= dustLimit {
+		outputs = append(outputs, *NewTxOutput(acc-totalRequired, change))
+	}
+
+	var outputTotal int64
+	for _, out := range outputs {
+		outputTotal += out.Value
 	}
 
-	tx := Transaction{nil, inputs, outputs, time.Now().Unix()}
+	tx := Transaction{nil, inputs, outputs, time.Now().Unix(), 0, acc - outputTotal}
 	tx.ID = tx.Hash()
 	privKey, err := wallet.GetPrivateKey()
 	if err != nil {
-		fmt.Printf("⛔ ERROR: Failed to get private key for %s: %v\n", from, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to get private key for %s: %w", from, err)
 	}
 	utxoSet.Blockchain.SignTransaction(&tx, privKey)
 
-	return &tx
+	return &tx, nil
 }
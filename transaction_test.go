@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoinbaseMessageLimit(t *testing.T) {
+	w, _ := NewWallet()
+
+	longest := strings.Repeat("a", MaxCoinbaseMessageLen)
+	if err := ValidateCoinbaseMessage(longest); err != nil {
+		t.Fatalf("%d-byte message rejected: %v", len(longest), err)
+	}
+	if err := ValidateCoinbaseMessage(longest + "a"); err == nil {
+		t.Fatal("over-long message accepted")
+	}
+
+	tx := NewCoinbaseTX(w.GetAddress(), longest, InitialSubsidy)
+	if got := string(tx.Vin[0].PubKey); got != longest {
+		t.Fatalf("coinbase data stored as %q", got)
+	}
+}
+
+// oversizedTx spends one made-up output with a public key big enough to
+// push the serialized transaction past MaxTxSize
+func oversizedTx(to string) *Transaction {
+	tx := &Transaction{
+		Vin:       []TxInput{{Txid: make([]byte, 32), Vout: 0, PubKey: make([]byte, MaxTxSize)}},
+		Vout:      []TxOutput{*NewTxOutput(DefaultDustLimit, to)},
+		Timestamp: time.Now().Unix(),
+	}
+	tx.ID = tx.Hash()
+	return tx
+}
+
+func TestValidateSize(t *testing.T) {
+	w, _ := NewWallet()
+	out := *NewTxOutput(DefaultDustLimit, w.GetAddress())
+
+	small := Transaction{Vin: []TxInput{{Txid: make([]byte, 32)}}, Vout: []TxOutput{out}}
+	if err := small.ValidateSize(); err != nil {
+		t.Fatalf("small transaction rejected: %v", err)
+	}
+
+	manyInputs := Transaction{Vin: make([]TxInput, MaxTxInputCount+1), Vout: []TxOutput{out}}
+	if err := manyInputs.ValidateSize(); err == nil || !strings.Contains(err.Error(), "too many inputs") {
+		t.Fatalf("%d inputs: %v", len(manyInputs.Vin), err)
+	}
+
+	manyOutputs := Transaction{Vin: small.Vin, Vout: make([]TxOutput, MaxTxOutputCount+1)}
+	if err := manyOutputs.ValidateSize(); err == nil || !strings.Contains(err.Error(), "too many outputs") {
+		t.Fatalf("%d outputs: %v", len(manyOutputs.Vout), err)
+	}
+
+	if err := oversizedTx(w.GetAddress()).ValidateSize(); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("oversized transaction: %v", err)
+	}
+}
+
+func TestSignWithKeysFromSpentOutputs(t *testing.T) {
+	w, _ := NewWallet()
+	key, err := w.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := NewCoinbaseTX(w.GetAddress(), "", InitialSubsidy)
+
+	tx := &Transaction{
+		Vin:       []TxInput{{Txid: prev.ID, Vout: 0, PubKey: w.PublicKey}},
+		Vout:      []TxOutput{*NewTxOutput(InitialSubsidy, w.GetAddress())},
+		Timestamp: time.Now().Unix(),
+	}
+	keys := map[string]ecdsa.PrivateKey{hex.EncodeToString(w.PublicKey): key}
+	if err := tx.SignWithKeys(keys, map[string]TxOutput{}); err == nil {
+		t.Fatal("signed without the spent output")
+	}
+
+	// The output as listed by /utxos is enough, no previous transaction needed
+	spent := map[string]TxOutput{OutpointKey(prev.ID, 0): *NewTxOutput(InitialSubsidy, w.GetAddress())}
+	if err := tx.SignWithKeys(keys, spent); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CheckSignatures(map[string]Transaction{hex.EncodeToString(prev.ID): *prev}); err != nil {
+		t.Fatalf("signature doesn't verify against the previous transaction: %v", err)
+	}
+}
+
+func TestInputTotalOutputIndex(t *testing.T) {
+	w, _ := NewWallet()
+	prev := NewCoinbaseTX(w.GetAddress(), "", InitialSubsidy)
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): *prev}
+
+	for _, vout := range []int{0, 1, -1} {
+		tx := &Transaction{Vin: []TxInput{{Txid: prev.ID, Vout: vout, PubKey: w.PublicKey}}}
+		total, err := tx.InputTotal(prevTXs)
+		switch {
+		case vout == 0 && (err != nil || total != InitialSubsidy):
+			t.Errorf("output 0: got %d, %v", total, err)
+		case vout != 0 && !errors.Is(err, ErrUnknownInput):
+			t.Errorf("output %d: got %v, want %v", vout, err, ErrUnknownInput)
+		}
+	}
+}
+
+func TestCalculateFeeOutputIndex(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+
+	funding := addTestBlock(t, chain, w).Transactions[0]
+	pending := spendTx(t, w, funding, 0, *NewTxOutput(InitialSubsidy/2, w.GetAddress()))
+	mempool := map[string]MempoolItem{hex.EncodeToString(pending.ID): {Tx: *pending}}
+
+	// A spent parent is found through the tx index, an unconfirmed one in the mempool
+	for _, prev := range []*Transaction{funding, pending} {
+		tx := &Transaction{Vin: []TxInput{{Txid: prev.ID, Vout: len(prev.Vout)}}}
+		if _, err := (UTXOSet{chain}).CalculateFee(tx, mempool); !errors.Is(err, ErrUnknownInput) {
+			t.Errorf("output %d of %x: got %v, want %v", len(prev.Vout), prev.ID[:4], err, ErrUnknownInput)
+		}
+	}
+}
+
+func TestNewUTXOTransactionChangeFollowsDustLimit(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	addTestBlock(t, chain, w)
+
+	ws := &Wallets{Wallets: map[string]*Wallet{w.GetAddress(): w}}
+	changeAddr, _ := ws.AddWallet(false)
+	if err := ws.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+	to, _ := NewWallet()
+	const fee, change = 1000, 600 // Change above the default dust limit
+	amount := int64(InitialSubsidy - fee - change)
+
+	// A node relaying with a higher --dust-limit: the change goes to the fee
+	tx, err := NewUTXOTransaction(w.GetAddress(), to.GetAddress(), changeAddr, amount, fee, "", 1000, &UTXOSet{chain})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.Vout) != 1 || tx.Fee != fee+change {
+		t.Fatalf("%d outputs, fee %d: want the change of %d left to the fee", len(tx.Vout), tx.Fee, change)
+	}
+
+	tx, err = NewUTXOTransaction(w.GetAddress(), to.GetAddress(), changeAddr, amount, fee, "", DefaultDustLimit, &UTXOSet{chain})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changeHash, _ := ExtractPubKeyHash(changeAddr)
+	if len(tx.Vout) != 2 || tx.Vout[1].Value != change || !tx.Vout[1].IsLockedWithKey(changeHash) || tx.Fee != fee {
+		t.Fatalf("%d outputs, fee %d: want %d Photons of change to --change", len(tx.Vout), tx.Fee, change)
+	}
+}
+
+func TestCompressedAndRawKeysSpend(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	funding := addTestBlock(t, chain, w).Transactions[0]
+
+	compressed, _ := NewWallet()
+	compressed.Compress()
+	raw, _ := NewWallet()
+	raw.PublicKey = raw.PublicKey[1:] // X || Y without the 0x04 prefix
+	pay := spendTx(t, w, funding, 0, *NewTxOutput(InitialSubsidy/2, compressed.GetAddress()), *NewTxOutput(InitialSubsidy/4, raw.GetAddress()))
+	addTestBlock(t, chain, w, pay)
+
+	fromCompressed := spendTx(t, compressed, pay, 0, *NewTxOutput(InitialSubsidy/2-1000, w.GetAddress()))
+	fromRaw := spendTx(t, raw, pay, 1, *NewTxOutput(InitialSubsidy/4-1000, w.GetAddress()))
+	prevTXs := map[string]Transaction{hex.EncodeToString(pay.ID): *pay}
+	for name, tx := range map[string]*Transaction{"compressed": fromCompressed, "raw": fromRaw} {
+		if err := tx.CheckSignatures(prevTXs); err != nil {
+			t.Fatalf("%s key: %v", name, err)
+		}
+	}
+	if len(fromCompressed.Vin[0].PubKey) != 33 {
+		t.Fatalf("compressed wallet signed with a %d-byte key", len(fromCompressed.Vin[0].PubKey))
+	}
+	block := buildBlock(t, tipBlock(t, chain), w, fromCompressed, fromRaw)
+	if !chain.AddBlock(block) {
+		t.Fatal("block spending compressed and raw key outputs rejected")
+	}
+}
+
+func TestParsePubKeyRejectsBadCompressedKeys(t *testing.T) {
+	w, _ := NewWallet()
+	w.Compress()
+
+	badPrefix := append([]byte{0x05}, w.PublicKey[1:]...)
+	if _, err := ParsePubKey(badPrefix); err == nil {
+		t.Fatal("33-byte key with prefix 0x05 accepted")
+	}
+
+	// An X with no point on P-256: x³ - 3x + b is not a square
+	offCurve := make([]byte, 33)
+	offCurve[0] = 0x02
+	for x := byte(1); ; x++ {
+		offCurve[32] = x
+		if px, _ := elliptic.UnmarshalCompressed(elliptic.P256(), offCurve); px == nil {
+			break
+		}
+	}
+	if _, err := ParsePubKey(offCurve); err == nil {
+		t.Fatal("compressed key with X off the curve accepted")
+	}
+}
+
+func TestCompressedKeyMustHashToOutput(t *testing.T) {
+	w, _ := NewWallet()
+	prev := NewCoinbaseTX(w.GetAddress(), "", InitialSubsidy)
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): *prev}
+
+	// Same key pair, compressed bytes: a valid signature, but not the
+	// key the uncompressed address commits to
+	signer := *w
+	signer.Compress()
+	tx := spendTx(t, &signer, prev, 0, *NewTxOutput(InitialSubsidy-1000, w.GetAddress()))
+	if err := tx.CheckSignatures(prevTXs); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidSignature)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	TxAckTTL         = time.Hour // Acknowledgments are forgotten after this long
+	MaxTrackedTxAcks = 10000     // Transactions with recorded acknowledgments
+)
+
+// TxAckPeer is one peer that accepted a transaction we relayed to it
+type TxAckPeer struct {
+	Peer string
+	At   time.Time
+}
+
+// TxAckTracker counts the peers that confirmed (with a txack message) that a
+// transaction reached their mempool. Only transactions this node holds are
+// tracked, so peers cannot fill it with arbitrary IDs.
+type TxAckTracker struct {
+	acks  map[string]map[string]time.Time // txid -> peer -> ack time
+	first map[string]time.Time            // txid -> first ack, for expiry
+	mux   sync.Mutex
+}
+
+func NewTxAckTracker() *TxAckTracker {
+	return &TxAckTracker{
+		acks:  make(map[string]map[string]time.Time),
+		first: make(map[string]time.Time),
+	}
+}
+
+// Record notes that peer accepted txID. Repeated acks from a peer count once.
+func (t *TxAckTracker) Record(txID []byte, peer string) {
+	id := hex.EncodeToString(txID)
+	now := time.Now()
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.acks[id] == nil {
+		t.expire(now)
+		if len(t.acks) >= MaxTrackedTxAcks {
+			return
+		}
+		t.acks[id] = make(map[string]time.Time)
+		t.first[id] = now
+	}
+	if _, ok := t.acks[id][peer]; !ok {
+		t.acks[id][peer] = now
+	}
+}
+
+// Peers returns the peers that acknowledged txID, earliest first
+func (t *TxAckTracker) Peers(txID string) []TxAckPeer {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	peers := make([]TxAckPeer, 0, len(t.acks[txID]))
+	for peer, at := range t.acks[txID] {
+		peers = append(peers, TxAckPeer{Peer: peer, At: at})
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].At.Before(peers[j].At) })
+	return peers
+}
+
+// expire drops transactions first acknowledged more than TxAckTTL ago.
+// Caller must hold t.mux.
+func (t *TxAckTracker) expire(now time.Time) {
+	for id, at := range t.first {
+		if now.Sub(at) > TxAckTTL {
+			delete(t.first, id)
+			delete(t.acks, id)
+		}
+	}
+}
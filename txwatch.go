@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/hex"
+	"sync"
+)
+
+// TxConfirmation reports the block a watched transaction was mined in
+type TxConfirmation struct {
+	TxID        string
+	BlockHash   string
+	BlockHeight int
+}
+
+// TxWatcher is a registry of waiters keyed by txid. Blocks that extend the
+// tip are fed to NotifyBlock, which wakes every waiter of the included txs.
+type TxWatcher struct {
+	waiters map[string][]chan TxConfirmation
+	mux     sync.Mutex
+}
+
+func NewTxWatcher() *TxWatcher {
+	return &TxWatcher{waiters: make(map[string][]chan TxConfirmation)}
+}
+
+// Watch registers interest in txID. The returned channel receives exactly one
+// confirmation; call Cancel if you stop waiting before it arrives.
+func (tw *TxWatcher) Watch(txID string) chan TxConfirmation {
+	ch := make(chan TxConfirmation, 1)
+	tw.mux.Lock()
+	tw.waiters[txID] = append(tw.waiters[txID], ch)
+	tw.mux.Unlock()
+	return ch
+}
+
+// Cancel removes a waiter registered with Watch
+func (tw *TxWatcher) Cancel(txID string, ch chan TxConfirmation) {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+
+	waiters := tw.waiters[txID]
+	for i, w := range waiters {
+		if w == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(tw.waiters, txID)
+	} else {
+		tw.waiters[txID] = waiters
+	}
+}
+
+// NotifyBlock wakes the waiters of every transaction in block
+func (tw *TxWatcher) NotifyBlock(block *Block) {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+
+	if len(tw.waiters) == 0 {
+		return
+	}
+
+	blockHash := hex.EncodeToString(block.Hash)
+	for _, tx := range block.Transactions {
+		txID := hex.EncodeToString(tx.ID)
+		for _, ch := range tw.waiters[txID] {
+			ch <- TxConfirmation{TxID: txID, BlockHash: blockHash, BlockHeight: block.Height}
+		}
+		delete(tw.waiters, txID)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "node_key.dat")
+
+	if err := writeFileAtomic(path, []byte("first"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(content, []byte("second")) {
+		t.Fatalf("content %q (%v), want \"second\"", content, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("permissions %o, want 600", perm)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("%d files left in the directory, want only the target", len(entries))
+	}
+}
+
+func TestWriteFileAtomicFailureLeavesTarget(t *testing.T) {
+	dir := t.TempDir()
+
+	// The final rename fails: the target is a non-empty directory
+	path := filepath.Join(dir, "mempool.dat")
+	if err := os.Mkdir(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "keep"), []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("partial"), 0600); err == nil {
+		t.Fatal("write over a directory succeeded")
+	}
+
+	if content, err := os.ReadFile(filepath.Join(path, "keep")); err != nil || string(content) != "data" {
+		t.Fatalf("existing data touched: %q, %v", content, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("%d entries left in the directory, the temp file wasn't removed", len(entries))
+	}
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/fatih/color"
 )
 
@@ -33,3 +37,27 @@ func PrintNetwork(format string, a ...interface{}) {
 	c := color.New(color.FgBlue)
 	c.Printf("🌐 "+format+"\n", a...)
 }
+
+// PrintSummaryBox prints label/value rows inside a box. A row with an empty
+// label continues the value of the row above.
+func PrintSummaryBox(title string, rows [][2]string) {
+	border := color.New(color.FgCyan)
+	label := color.New(color.Bold)
+
+	labelWidth, valueWidth := 0, utf8.RuneCountInString(title)
+	for _, row := range rows {
+		labelWidth = max(labelWidth, utf8.RuneCountInString(row[0]))
+		valueWidth = max(valueWidth, utf8.RuneCountInString(row[1]))
+	}
+	inner := labelWidth + 2 + valueWidth
+
+	titleText := " " + title + " "
+	border.Printf("╭─%s%s╮\n", titleText, strings.Repeat("─", inner+1-utf8.RuneCountInString(titleText)))
+	for _, row := range rows {
+		border.Print("│ ")
+		label.Print(row[0] + strings.Repeat(" ", labelWidth-utf8.RuneCountInString(row[0])))
+		fmt.Print("  " + row[1] + strings.Repeat(" ", valueWidth-utf8.RuneCountInString(row[1])))
+		border.Println(" │")
+	}
+	border.Printf("╰%s╯\n", strings.Repeat("─", inner+2))
+}
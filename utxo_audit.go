@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+const (
+	MaxAuditIssues    = 100             // Discrepancies listed in a report (all are counted)
+	utxoAuditRecheck  = 5 * time.Second // Wait before confirming drift found by the node
+	MinUTXOAuditEvery = time.Minute     // Shortest --audit-utxo interval
+)
+
+// ErrAuditPruned is returned when a block body needed to recompute the UTXO
+// set has been pruned
+var ErrAuditPruned = errors.New("cannot audit the UTXO set of a pruned chain")
+
+// UTXOAuditIssue is one outpoint on which the stored UTXO set and the chain
+// disagree. Missing: unspent on chain, absent from the set. Extra: in the set,
+// spent or never created on chain. Mismatch: present in both, different output.
+type UTXOAuditIssue struct {
+	Kind        string `json:"kind"`
+	Outpoint    string `json:"outpoint"` // <txid>:<vout>
+	ChainValue  int64  `json:"chain_value,omitempty"`
+	StoredValue int64  `json:"stored_value,omitempty"`
+}
+
+// UTXOAudit is the result of UTXOSet.Audit
+type UTXOAudit struct {
+	Height      int              `json:"height"`
+	TipHash     string           `json:"tip_hash"`
+	Blocks      int              `json:"blocks"`
+	ChainUTXOs  int              `json:"chain_utxos"`
+	StoredUTXOs int              `json:"stored_utxos"`
+	Missing     int              `json:"missing"`
+	Extra       int              `json:"extra"`
+	Mismatched  int              `json:"mismatched"`
+	Issues      []UTXOAuditIssue `json:"issues"` // First MaxAuditIssues, sorted by outpoint
+}
+
+// Clean reports whether the stored set matched the chain
+func (a UTXOAudit) Clean() bool {
+	return a.Missing+a.Extra+a.Mismatched == 0
+}
+
+// Audit recomputes the UTXO set from the blocks, the way Reindex does, and
+// diffs it against the stored utxo- entries. Nothing is written. Everything is
+// read inside one Badger transaction, so a running node can keep adding blocks
+// while the audit sees a consistent snapshot. The recomputed set is held in
+// memory.
+func (u UTXOSet) Audit() (UTXOAudit, error) {
+	var report UTXOAudit
+
+	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("lh"))
+		if err != nil {
+			return fmt.Errorf("reading the tip: %w", err)
+		}
+		hash, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		report.TipHash = hex.EncodeToString(hash)
+
+		// Outpoint ("<txid>-<vout>", as in the utxo- keys) -> unspent output
+		expected := make(map[string]TxOutput)
+		spent := make(map[string]bool)
+
+		for len(hash) > 0 {
+			item, err := txn.Get(hash)
+			if err != nil {
+				return fmt.Errorf("reading block %x: %w", hash, err)
+			}
+			data, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			block := DeserializeBlock(data)
+			if block == nil {
+				return fmt.Errorf("block %x cannot be decoded", hash)
+			}
+			if block.Pruned {
+				return fmt.Errorf("%w (block %d)", ErrAuditPruned, block.Height)
+			}
+			if report.Blocks == 0 {
+				report.Height = block.Height
+			}
+
+			for i := len(block.Transactions) - 1; i >= 0; i-- {
+				tx := block.Transactions[i]
+				txID := hex.EncodeToString(tx.ID)
+
+				for outIdx, out := range tx.Vout {
+					outpoint := fmt.Sprintf("%s-%d", txID, outIdx)
+					if spent[outpoint] {
+						delete(spent, outpoint)
+						continue
+					}
+					if out.IsOPReturn() {
+						continue
+					}
+					expected[outpoint] = out
+				}
+
+				if !tx.IsCoinbase() {
+					for _, in := range tx.Vin {
+						spent[fmt.Sprintf("%x-%d", in.Txid, in.Vout)] = true
+					}
+				}
+			}
+
+			report.Blocks++
+			hash = block.PrevBlockHash
+		}
+		report.ChainUTXOs = len(expected)
+
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(utxoPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			outpoint := strings.TrimPrefix(string(it.Item().Key()), utxoPrefix)
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			stored := DeserializeUTXO(v)
+			report.StoredUTXOs++
+
+			want, ok := expected[outpoint]
+			switch {
+			case !ok:
+				report.Extra++
+				report.add(UTXOAuditIssue{Kind: "extra", Outpoint: outpoint, StoredValue: stored.Value})
+			case want.Value != stored.Value || !bytes.Equal(want.PubKeyHash, stored.PubKeyHash):
+				report.Mismatched++
+				report.add(UTXOAuditIssue{Kind: "mismatch", Outpoint: outpoint, ChainValue: want.Value, StoredValue: stored.Value})
+			}
+			delete(expected, outpoint)
+		}
+
+		for outpoint, out := range expected {
+			report.Missing++
+			report.add(UTXOAuditIssue{Kind: "missing", Outpoint: outpoint, ChainValue: out.Value})
+		}
+		return nil
+	})
+	if err != nil {
+		return UTXOAudit{}, err
+	}
+
+	for i := range report.Issues {
+		report.Issues[i].Outpoint = auditOutpoint(report.Issues[i].Outpoint)
+	}
+	sort.Slice(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Outpoint < report.Issues[j].Outpoint
+	})
+	if report.Issues == nil {
+		report.Issues = []UTXOAuditIssue{}
+	}
+	return report, nil
+}
+
+func (a *UTXOAudit) add(issue UTXOAuditIssue) {
+	if len(a.Issues) < MaxAuditIssues {
+		a.Issues = append(a.Issues, issue)
+	}
+}
+
+// auditOutpoint turns a utxo- key suffix ("<txid>-<vout>") into "<txid>:<vout>"
+func auditOutpoint(key string) string {
+	if i := strings.LastIndex(key, "-"); i >= 0 {
+		return key[:i] + ":" + key[i+1:]
+	}
+	return key
+}
+
+// UTXOAuditLoop audits the UTXO set every interval and logs any drift. The
+// set is updated right after each block is stored, so a snapshot can fall
+// between the two: drift is only reported if a second audit shortly after
+// still finds it. Audits are skipped while syncing.
+func (s *Server) UTXOAuditLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for s.tick(ticker) {
+		s.BlockBufferMux.Lock()
+		syncing := s.IsSyncing
+		s.BlockBufferMux.Unlock()
+		if syncing {
+			continue
+		}
+
+		report, err := s.UTXOSet.Audit()
+		if err == nil && !report.Clean() {
+			if !s.sleep(utxoAuditRecheck) {
+				return
+			}
+			report, err = s.UTXOSet.Audit()
+		}
+		if err != nil {
+			fmt.Printf("⚠️  [UTXO audit] %v\n", err)
+			continue
+		}
+		if report.Clean() {
+			fmt.Printf("✅ [UTXO audit] %d UTXOs match the chain at height %d\n", report.StoredUTXOs, report.Height)
+			continue
+		}
+		fmt.Printf("⛔ [UTXO audit] Drift at height %d: %d missing, %d extra, %d mismatched. Stop the node and run 'chain reindex'.\n",
+			report.Height, report.Missing, report.Extra, report.Mismatched)
+	}
+}
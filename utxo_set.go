@@ -14,54 +14,188 @@ import (
 
 const utxoPrefix = "utxo-"
 
+// undoPrefix keys the outputs each block spent ("undo-" + block hash), which
+// Rollback restores. Pruning a block drops its record with its body.
+const undoPrefix = "undo-"
+
+func undoKey(blockHash []byte) []byte {
+	return append([]byte(undoPrefix), blockHash...)
+}
+
+// addrPrefix indexes the UTXO set by owner: "utxoaddr-" + pubkey hash (hex)
+// + "-" + outpoint, holding the same serialized output as the "utxo-" entry.
+// It lists one address's outputs without scanning the whole set.
+const addrPrefix = "utxoaddr-"
+
+// addrKey is the address index entry of outpoint ("<txid>-<vout>")
+func addrKey(pubKeyHash []byte, outpoint string) []byte {
+	return []byte(fmt.Sprintf("%s%x-%s", addrPrefix, pubKeyHash, outpoint))
+}
+
+// SpentOutput is an output a block removed from the UTXO set
+type SpentOutput struct {
+	TxID   []byte
+	Vout   int
+	Output TxOutput
+}
+
 type UTXOSet struct {
 	Blockchain *Blockchain
 }
 
-func (u UTXOSet) Reindex() {
+// reindexProgressEvery controls how often Reindex logs progress (in blocks)
+const reindexProgressEvery = 1000
+
+// Reindex rebuilds the UTXO set from the chain. It walks blocks from the tip
+// back to Genesis, so every spend is seen before the output it consumes, and
+// streams unspent outputs to Badger through a WriteBatch (flushed in bounded
+// batches) instead of building the whole set in memory. A pruned chain is
+// refused before anything is changed (ErrBlockPruned).
+func (u UTXOSet) Reindex() error {
 	db := u.Blockchain.Database
-	bucketName := []byte(utxoPrefix)
 
-	err := db.Update(func(txn *badger.Txn) error {
-		err := db.DropPrefix(bucketName)
-		return err
-	})
-	if err != nil {
-		log.Fatalf("Fatal: Failed to clear UTXO set prefix: %v", err)
+	// Checked before anything is dropped: the set could not be rebuilt
+	if u.Blockchain.IsPruned() {
+		return fmt.Errorf("%w: the UTXO set of a pruned chain can't be rebuilt", ErrBlockPruned)
+	}
+
+	if err := db.DropPrefix([]byte(utxoPrefix), []byte(addrPrefix)); err != nil {
+		return fmt.Errorf("clearing the UTXO set: %w", err)
 	}
 
-	UTXO := u.Blockchain.FindUTXO()
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+
+	// Outpoints spent by blocks above the current one. Entries are dropped as
+	// soon as the creating transaction is reached, keeping the map small.
+	spent := make(map[string]bool)
+	blocks, written := 0, 0
+
+	iter := u.Blockchain.Iterator()
+	for {
+		block := iter.Next()
+
+		// Reverse order handles spends of outputs created earlier in the same block
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			tx := block.Transactions[i]
+			txID := hex.EncodeToString(tx.ID)
 
-	err = db.Update(func(txn *badger.Txn) error {
-		for txId, outs := range UTXO {
-			for outIdx, out := range outs.Outputs {
+			for outIdx, out := range tx.Vout {
+				outpoint := fmt.Sprintf("%s-%d", txID, outIdx)
+				if spent[outpoint] {
+					delete(spent, outpoint)
+					continue
+				}
 				if out.IsOPReturn() {
 					continue
 				}
-				key := fmt.Sprintf("%s%s-%d", utxoPrefix, txId, outIdx)
-				err := txn.Set([]byte(key), SerializeUTXO(out))
-				if err != nil {
-					return err
+				if err := wb.Set([]byte(utxoPrefix+outpoint), SerializeUTXO(out)); err != nil {
+					return fmt.Errorf("rebuilding the UTXO set: %w", err)
+				}
+				if err := wb.Set(addrKey(out.PubKeyHash, outpoint), SerializeUTXO(out)); err != nil {
+					return fmt.Errorf("rebuilding the UTXO set: %w", err)
 				}
+				written++
 			}
+
+			if !tx.IsCoinbase() {
+				for _, in := range tx.Vin {
+					spent[fmt.Sprintf("%x-%d", in.Txid, in.Vout)] = true
+				}
+			}
+		}
+
+		blocks++
+		if blocks%reindexProgressEvery == 0 {
+			fmt.Printf("🔄 [Reindex] %d blocks scanned (height %d), %d UTXOs written\n", blocks, block.Height, written)
+		}
+
+		if block.IsGenesis() {
+			break
+		}
+	}
+
+	if err := wb.Flush(); err != nil {
+		return fmt.Errorf("rebuilding the UTXO set: %w", err)
+	}
+	if blocks >= reindexProgressEvery {
+		fmt.Printf("🔄 [Reindex] Done: %d blocks, %d UTXOs\n", blocks, written)
+	}
+	return nil
+}
+
+// GetUTXO looks up a single outpoint in the UTXO set.
+// Returns badger.ErrKeyNotFound if it is spent or never existed.
+func (u UTXOSet) GetUTXO(txID []byte, vout int) (TxOutput, error) {
+	var out TxOutput
+	key := fmt.Sprintf("%s%x-%d", utxoPrefix, txID, vout)
+
+	err := u.Blockchain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
 		}
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		out = DeserializeUTXO(v)
 		return nil
 	})
-	if err != nil {
-		log.Fatalf("Fatal: Failed to rebuild UTXO set: %v", err)
-	}
+	return out, err
 }
 
+// FindTxOutputs returns the unspent outputs of a transaction, by output index
+func (u UTXOSet) FindTxOutputs(txID []byte) map[int]TxOutput {
+	outputs := make(map[int]TxOutput)
+	prefix := []byte(fmt.Sprintf("%s%x-", utxoPrefix, txID))
+
+	u.Blockchain.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			outIdx, err := strconv.Atoi(string(item.Key()[len(prefix):]))
+			if err != nil {
+				continue
+			}
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			outputs[outIdx] = DeserializeUTXO(v)
+		}
+		return nil
+	})
+	return outputs
+}
+
+// Update applies a block to the UTXO set. The outputs it spends are kept in
+// the block's undo record for Rollback.
 func (u UTXOSet) Update(block *Block) {
 	db := u.Blockchain.Database
 
 	err := db.Update(func(txn *badger.Txn) error {
+		var spent []SpentOutput
 		for _, tx := range block.Transactions {
 			if !tx.IsCoinbase() {
 				for _, vin := range tx.Vin {
 					txID := hex.EncodeToString(vin.Txid)
 					key := fmt.Sprintf("%s%s-%d", utxoPrefix, txID, vin.Vout)
 
+					if item, err := txn.Get([]byte(key)); err == nil {
+						data, err := item.ValueCopy(nil)
+						if err != nil {
+							return err
+						}
+						out := DeserializeUTXO(data)
+						spent = append(spent, SpentOutput{TxID: vin.Txid, Vout: vin.Vout, Output: out})
+						if err := txn.Delete(addrKey(out.PubKeyHash, fmt.Sprintf("%s-%d", txID, vin.Vout))); err != nil {
+							return err
+						}
+					}
+
 					// Delete spent output
 					err := txn.Delete([]byte(key))
 					if err == badger.ErrKeyNotFound {
@@ -77,22 +211,119 @@ func (u UTXOSet) Update(block *Block) {
 				if out.IsOPReturn() {
 					continue
 				}
-				txID := hex.EncodeToString(tx.ID)
-				key := fmt.Sprintf("%s%s-%d", utxoPrefix, txID, outIdx)
+				outpoint := fmt.Sprintf("%s-%d", hex.EncodeToString(tx.ID), outIdx)
 
-				err := txn.Set([]byte(key), SerializeUTXO(out))
+				err := txn.Set([]byte(utxoPrefix+outpoint), SerializeUTXO(out))
 				if err != nil {
 					return err
 				}
+				if err := txn.Set(addrKey(out.PubKeyHash, outpoint), SerializeUTXO(out)); err != nil {
+					return err
+				}
 			}
 		}
-		return nil
+		if len(spent) == 0 {
+			return nil
+		}
+		return txn.Set(undoKey(block.Hash), GobEncode(spent))
 	})
 	if err != nil {
 		log.Panic(err)
 	}
 }
 
+// Rollback reverses Update for a block being disconnected: outputs the block
+// created are removed and the outputs its inputs spent are restored. Spent
+// outputs come from the block's undo record. Blocks applied before undo
+// records existed fall back to the block itself and the chain's tx index,
+// which needs their ancestors' bodies. Transactions are undone in reverse
+// order, which also handles outputs created and spent in the same block.
+func (u UTXOSet) Rollback(block *Block) error {
+	db := u.Blockchain.Database
+
+	blockTxs := make(map[string]*Transaction, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		blockTxs[hex.EncodeToString(tx.ID)] = tx
+	}
+
+	return db.Update(func(txn *badger.Txn) error {
+		undo := make(map[string]TxOutput)
+		if item, err := txn.Get(undoKey(block.Hash)); err == nil {
+			data, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var spent []SpentOutput
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&spent); err != nil {
+				return fmt.Errorf("undo record of %x: %w", block.Hash, err)
+			}
+			for _, s := range spent {
+				undo[OutpointKey(s.TxID, s.Vout)] = s.Output
+			}
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			tx := block.Transactions[i]
+			txID := hex.EncodeToString(tx.ID)
+
+			// Remove created outputs
+			for outIdx, out := range tx.Vout {
+				if out.IsOPReturn() {
+					continue
+				}
+				outpoint := fmt.Sprintf("%s-%d", txID, outIdx)
+				if err := txn.Delete([]byte(utxoPrefix + outpoint)); err != nil {
+					return err
+				}
+				if err := txn.Delete(addrKey(out.PubKeyHash, outpoint)); err != nil {
+					return err
+				}
+			}
+
+			if tx.IsCoinbase() {
+				continue
+			}
+
+			// Restore spent outputs
+			for _, vin := range tx.Vin {
+				prevID := hex.EncodeToString(vin.Txid)
+				outpoint := fmt.Sprintf("%s-%d", prevID, vin.Vout)
+				restore := func(out TxOutput) error {
+					if err := txn.Set([]byte(utxoPrefix+outpoint), SerializeUTXO(out)); err != nil {
+						return err
+					}
+					return txn.Set(addrKey(out.PubKeyHash, outpoint), SerializeUTXO(out))
+				}
+				if out, ok := undo[OutpointKey(vin.Txid, vin.Vout)]; ok {
+					if err := restore(out); err != nil {
+						return err
+					}
+					continue
+				}
+
+				prevTx, ok := blockTxs[prevID]
+				if !ok {
+					found, err := u.Blockchain.FindTransaction(vin.Txid)
+					if err != nil {
+						return fmt.Errorf("%w: rollback of %x: spent transaction %s not found", ErrUnknownInput, block.Hash, prevID)
+					}
+					prevTx = &found
+				}
+				if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+					return fmt.Errorf("%w: rollback of %x: output %s:%d does not exist", ErrUnknownInput, block.Hash, prevID, vin.Vout)
+				}
+
+				if err := restore(prevTx.Vout[vin.Vout]); err != nil {
+					return err
+				}
+			}
+		}
+		return txn.Delete(undoKey(block.Hash))
+	})
+}
+
 func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int64) (int64, map[string][]int) {
 	unspentOutputs := make(map[string][]int)
 	accumulated := int64(0)
@@ -173,45 +404,152 @@ type UTXO struct {
 	Output TxOutput
 }
 
+// FindAllUTXOs returns the outputs locked to pubKeyHash in outpoint key order,
+// read from the address index. A set opened read-only before the index was
+// built is scanned in full instead.
 func (u UTXOSet) FindAllUTXOs(pubKeyHash []byte) []UTXO {
 	var UTXOs []UTXO
 	db := u.Blockchain.Database
 
 	err := db.View(func(txn *badger.Txn) error {
+		indexed, err := addressIndexed(txn)
+		if err != nil {
+			return err
+		}
+		if !indexed {
+			UTXOs, err = scanUTXOs(txn, pubKeyHash)
+			return err
+		}
+
+		prefix := []byte(fmt.Sprintf("%s%x-", addrPrefix, pubKeyHash))
 		opts := badger.DefaultIteratorOptions
-		opts.Prefix = []byte(utxoPrefix)
+		opts.Prefix = prefix
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
 		for it.Rewind(); it.Valid(); it.Next() {
 			item := it.Item()
-			k := string(item.Key())
+			txID, outIdx, ok := strings.Cut(string(item.Key()[len(prefix):]), "-")
+			if !ok {
+				continue
+			}
+			vout, err := strconv.Atoi(outIdx)
+			if err != nil {
+				continue
+			}
 			v, err := item.ValueCopy(nil)
 			if err != nil {
 				return err
 			}
+			UTXOs = append(UTXOs, UTXO{txID, vout, DeserializeUTXO(v)})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
 
-			// Key format: utxo-<txID>-<outIdx>
-			parts := strings.Split(k, "-")
-			if len(parts) < 3 {
-				continue
-			}
-			txID := parts[1]
-			outIdx, _ := strconv.Atoi(parts[2])
+	return UTXOs
+}
 
-			out := DeserializeUTXO(v)
+// scanUTXOs finds the outputs locked to pubKeyHash by reading the whole set
+func scanUTXOs(txn *badger.Txn, pubKeyHash []byte) ([]UTXO, error) {
+	var UTXOs []UTXO
 
-			if out.IsLockedWithKey(pubKeyHash) {
-				UTXOs = append(UTXOs, UTXO{txID, outIdx, out})
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(utxoPrefix)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		k := string(item.Key())
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// Key format: utxo-<txID>-<outIdx>
+		parts := strings.Split(k, "-")
+		if len(parts) < 3 {
+			continue
+		}
+		txID := parts[1]
+		outIdx, _ := strconv.Atoi(parts[2])
+
+		out := DeserializeUTXO(v)
+
+		if out.IsLockedWithKey(pubKeyHash) {
+			UTXOs = append(UTXOs, UTXO{txID, outIdx, out})
+		}
+	}
+	return UTXOs, nil
+}
+
+// addressIndexed reports whether the address index covers the UTXO set,
+// judged by the entry of its first output. An empty set counts as indexed.
+func addressIndexed(txn *badger.Txn) (bool, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(utxoPrefix)
+	opts.PrefetchSize = 1
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Rewind()
+	if !it.Valid() {
+		return true, nil
+	}
+	v, err := it.Item().ValueCopy(nil)
+	if err != nil {
+		return false, err
+	}
+	outpoint := strings.TrimPrefix(string(it.Item().Key()), utxoPrefix)
+	_, err = txn.Get(addrKey(DeserializeUTXO(v).PubKeyHash, outpoint))
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ensureAddressIndex builds the address index of UTXO sets written before it
+// existed, from the set itself: no block is read.
+func (u UTXOSet) ensureAddressIndex() error {
+	db := u.Blockchain.Database
+
+	var indexed bool
+	if err := db.View(func(txn *badger.Txn) (err error) {
+		indexed, err = addressIndexed(txn)
+		return err
+	}); err != nil || indexed {
+		return err
+	}
+
+	fmt.Println("🔄 Indexing the UTXO set by address...")
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(utxoPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			outpoint := strings.TrimPrefix(string(it.Item().Key()), utxoPrefix)
+			if err := wb.Set(addrKey(DeserializeUTXO(v).PubKeyHash, outpoint), v); err != nil {
+				return err
 			}
 		}
 		return nil
 	})
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
-
-	return UTXOs
+	return wb.Flush()
 }
 
 func (u UTXOSet) CountTransactions() int {
@@ -236,6 +574,34 @@ func (u UTXOSet) CountTransactions() int {
 	return counter
 }
 
+// Totals returns the number of unspent outputs and their summed value, i.e.
+// the circulating supply. It decodes every UTXO: callers should cache it.
+func (u UTXOSet) Totals() (count int, value int64) {
+	db := u.Blockchain.Database
+
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(utxoPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			count++
+			value += DeserializeUTXO(v).Value
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return count, value
+}
+
 // Helper functions for serialization since we are storing individual TxOutputs
 func SerializeUTXO(out TxOutput) []byte {
 	var buff bytes.Buffer
@@ -352,6 +718,11 @@ func (u UTXOSet) ValidateBlockTransactions(block *Block) bool {
 				valid = false
 				return nil
 			}
+			if err := tx.CheckDeclaredFee(fee); err != nil {
+				fmt.Printf("⛔ [UTXOSet] Invalid transaction %x: %s\n", tx.ID, err)
+				valid = false
+				return nil
+			}
 			totalFees += fee
 		}
 
@@ -411,20 +782,22 @@ func (u UTXOSet) CalculateFee(tx *Transaction, mempool ...map[string]MempoolItem
 				// Check mempool for unconfirmed parent transaction
 				if mp != nil {
 					if mempoolItem, exists := mp[txID]; exists {
-						if int(vin.Vout) < len(mempoolItem.Tx.Vout) {
-							inputTotal += mempoolItem.Tx.Vout[vin.Vout].Value
-							continue
+						if vin.Vout < 0 || vin.Vout >= len(mempoolItem.Tx.Vout) {
+							return fmt.Errorf("%w: output %d does not exist in mempool tx %s", ErrUnknownInput, vin.Vout, txID)
 						}
+						inputTotal += mempoolItem.Tx.Vout[vin.Vout].Value
+						continue
 					}
 				}
-				// Fallback to blockchain DB search
-				prevTx, err := u.Blockchain.FindTransaction(vin.Txid)
+				// Fallback to the tx index (spent or pruned parents)
+				prevTx, err := u.Blockchain.FindParentTransaction(vin.Txid)
 				if err != nil {
-					return fmt.Errorf("input tx %s not found in DB or Mempool", txID)
+					return fmt.Errorf("%w: input tx %s not found in DB or Mempool", ErrUnknownInput, txID)
 				}
-				if int(vin.Vout) < len(prevTx.Vout) {
-					inputTotal += prevTx.Vout[vin.Vout].Value
+				if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+					return fmt.Errorf("%w: output %d does not exist in tx %s", ErrUnknownInput, vin.Vout, txID)
 				}
+				inputTotal += prevTx.Vout[vin.Vout].Value
 				continue
 			} else if err != nil {
 				return err
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// utxoSnapshot returns every UTXO entry of the chain, keyed by its database key
+func utxoSnapshot(t *testing.T, chain *Blockchain) map[string]string {
+	t.Helper()
+
+	entries := make(map[string]string)
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(utxoPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			entries[string(it.Item().KeyCopy(nil))] = string(value)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestRollbackUndoesUpdate(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	to, _ := NewWallet()
+
+	funding := addTestBlock(t, chain, w).Transactions[0]
+	before := utxoSnapshot(t, chain)
+
+	// The second spend consumes an output created earlier in the same block
+	first := spendTx(t, w, funding, 0, *NewTxOutput(InitialSubsidy/2, w.GetAddress()), *NewTxOutput(DefaultDustLimit, to.GetAddress()))
+	second := spendTx(t, w, first, 0, *NewTxOutput(DefaultDustLimit, to.GetAddress()))
+	block := buildBlock(t, tipBlock(t, chain), w, first, second)
+
+	utxos := UTXOSet{chain}
+	utxos.Update(block)
+	if maps.Equal(utxoSnapshot(t, chain), before) {
+		t.Fatal("Update left the UTXO set unchanged")
+	}
+	if err := utxos.Rollback(block); err != nil {
+		t.Fatal(err)
+	}
+	if after := utxoSnapshot(t, chain); !maps.Equal(after, before) {
+		t.Fatalf("UTXO set after rollback:\n got %v\nwant %v", after, before)
+	}
+}
+
+func TestRollbackUnknownInput(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+
+	// The funding block is never stored, so its outputs can't be restored
+	funding := buildBlock(t, tipBlock(t, chain), w)
+	spend := spendTx(t, w, funding.Transactions[0], 0, *NewTxOutput(DefaultDustLimit, w.GetAddress()))
+	block := buildBlock(t, funding, w, spend)
+
+	before := utxoSnapshot(t, chain)
+	if err := (UTXOSet{chain}).Rollback(block); !errors.Is(err, ErrUnknownInput) {
+		t.Fatalf("got %v, want %v", err, ErrUnknownInput)
+	}
+	if after := utxoSnapshot(t, chain); !maps.Equal(after, before) {
+		t.Fatal("a failed rollback changed the UTXO set")
+	}
+}
+
+func TestReindexRefusesPrunedChain(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	for range 3 {
+		addTestBlock(t, chain, w)
+	}
+	if _, err := chain.PruneBlocks(1); err != nil {
+		t.Fatal(err)
+	}
+
+	before := utxoSnapshot(t, chain)
+	if err := (UTXOSet{chain}).Reindex(); !errors.Is(err, ErrBlockPruned) {
+		t.Fatalf("got %v, want %v", err, ErrBlockPruned)
+	}
+	if after := utxoSnapshot(t, chain); !maps.Equal(after, before) {
+		t.Fatal("a refused reindex changed the UTXO set")
+	}
+}
+
+func TestRollbackUsesUndoRecord(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	to, _ := NewWallet()
+
+	funding := addTestBlock(t, chain, w).Transactions[0]
+	before := utxoSnapshot(t, chain)
+	addTestBlock(t, chain, w, spendTx(t, w, funding, 0, *NewTxOutput(DefaultDustLimit, to.GetAddress())))
+
+	// The funding transaction is gone from the database with its block body
+	if _, err := chain.PruneBlocks(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.FindTransaction(funding.ID); !errors.Is(err, ErrBlockPruned) {
+		t.Fatalf("funding transaction still readable (%v)", err)
+	}
+
+	if _, err := chain.RollbackTip(); err != nil {
+		t.Fatal(err)
+	}
+	if after := utxoSnapshot(t, chain); !maps.Equal(after, before) {
+		t.Fatalf("UTXO set after rollback:\n got %v\nwant %v", after, before)
+	}
+}
+
+// The mainnet premine sits under the fixed genesis coinbase ID. Its key isn't
+// available to tests, so the spend is followed up to the signature check.
+func TestGenesisPremineSpendable(t *testing.T) {
+	chain := newTestChain(t)
+	utxos := UTXOSet{chain}
+	premine := int64(GenesisReward * 100000000)
+	fixedID := []byte("SOLE_GENESIS_TX_ID")
+
+	adminHash, err := ExtractPubKeyHash(GenesisAdminAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, outputs := utxos.FindSpendableOutputs(adminHash, premine)
+	if found != premine {
+		t.Fatalf("spendable premine %d, want %d", found, premine)
+	}
+	var ids [][]byte
+	for key := range outputs {
+		id, err := hex.DecodeString(key)
+		if err != nil {
+			t.Fatalf("UTXO key %q doesn't decode: %v", key, err)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 1 || !bytes.Equal(ids[0], fixedID) {
+		t.Fatalf("premine outputs under %q, want the fixed ID", ids)
+	}
+
+	if out, err := utxos.GetUTXO(fixedID, 0); err != nil || out.Value != premine {
+		t.Fatalf("GetUTXO: %d, %v", out.Value, err)
+	}
+	if _, err := chain.FindTransactionWithMempool(fixedID, nil); err != nil {
+		t.Fatalf("FindTransactionWithMempool: %v", err)
+	}
+
+	w, key := newValidator(t)
+	spend := &Transaction{
+		Vin:  []TxInput{{Txid: fixedID, Vout: 0, PubKey: w.PublicKey}},
+		Vout: []TxOutput{*NewTxOutput(premine-1000, w.GetAddress())},
+	}
+	if fee, err := utxos.CalculateFee(spend); err != nil || fee != 1000 {
+		t.Fatalf("CalculateFee: %d, %v", fee, err)
+	}
+	prev, err := chain.FindTransaction(fixedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend.Sign(key, map[string]Transaction{hex.EncodeToString(fixedID): prev})
+	// Every input resolves: only the signer is wrong
+	if err := spend.CheckSignatures(map[string]Transaction{hex.EncodeToString(fixedID): prev}); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("spend signed by a stranger: got %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestGenesisAllocationSpentInBlock(t *testing.T) {
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	premine := int64(GenesisReward * 100000000)
+	defer func(saved []GenesisAllocation) { GenesisAllocations = saved }(GenesisAllocations)
+	GenesisAllocations = []GenesisAllocation{{w.GetAddress(), premine}}
+
+	chain := newTestChain(t)
+	genesis, err := chain.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, _ := NewWallet()
+	spend := spendTx(t, w, genesis.Transactions[0], 0, *NewTxOutput(premine/2, to.GetAddress()), *NewTxOutput(premine/2-1000, w.GetAddress()))
+	addTestBlock(t, chain, w, spend)
+
+	toHash, _ := ExtractPubKeyHash(to.GetAddress())
+	if found, _ := (UTXOSet{chain}).FindSpendableOutputs(toHash, premine); found != premine/2 {
+		t.Fatalf("recipient holds %d, want %d", found, premine/2)
+	}
+}
+
+// checkAddressIndex fails unless the address index holds exactly the UTXO set
+func checkAddressIndex(t *testing.T, chain *Blockchain) {
+	t.Helper()
+
+	set := utxoSnapshot(t, chain)
+	indexed := 0
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		for key, value := range set {
+			outpoint := key[len(utxoPrefix):]
+			item, err := txn.Get(addrKey(DeserializeUTXO([]byte(value)).PubKeyHash, outpoint))
+			if err != nil {
+				return fmt.Errorf("%s not indexed: %w", outpoint, err)
+			}
+			if got, err := item.ValueCopy(nil); err != nil || string(got) != value {
+				return fmt.Errorf("%s indexed with another output", outpoint)
+			}
+		}
+
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(addrPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			indexed++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexed != len(set) {
+		t.Fatalf("%d address index entries for %d UTXOs", indexed, len(set))
+	}
+}
+
+func TestAddressIndexFollowsUTXOSet(t *testing.T) {
+	chain := newTestChain(t)
+	w, _ := newValidator(t)
+	withValidators(t, 1, w)
+	to, _ := NewWallet()
+	checkAddressIndex(t, chain)
+
+	funding := addTestBlock(t, chain, w).Transactions[0]
+	first := spendTx(t, w, funding, 0, *NewTxOutput(InitialSubsidy/2, w.GetAddress()), *NewTxOutput(DefaultDustLimit, to.GetAddress()))
+	second := spendTx(t, w, first, 0, *NewTxOutput(DefaultDustLimit, to.GetAddress()))
+	block := buildBlock(t, tipBlock(t, chain), w, first, second)
+
+	utxos := UTXOSet{chain}
+	utxos.Update(block)
+	checkAddressIndex(t, chain)
+	toHash, _ := ExtractPubKeyHash(to.GetAddress())
+	if found := utxos.FindAllUTXOs(toHash); len(found) != 2 {
+		t.Fatalf("%d outputs found for the receiver, want 2", len(found))
+	}
+
+	if err := utxos.Rollback(block); err != nil {
+		t.Fatal(err)
+	}
+	checkAddressIndex(t, chain)
+	if found := utxos.FindAllUTXOs(toHash); len(found) != 0 {
+		t.Fatalf("%d outputs found for the receiver after rollback", len(found))
+	}
+
+	// A database from before the index: lookups scan, startup backfills
+	if err := chain.Database.DropPrefix([]byte(addrPrefix)); err != nil {
+		t.Fatal(err)
+	}
+	wHash, _ := ExtractPubKeyHash(w.GetAddress())
+	if found := utxos.FindAllUTXOs(wHash); len(found) != 1 {
+		t.Fatalf("unindexed lookup found %d outputs, want 1", len(found))
+	}
+	if err := utxos.ensureAddressIndex(); err != nil {
+		t.Fatal(err)
+	}
+	checkAddressIndex(t, chain)
+
+	if err := utxos.Reindex(); err != nil {
+		t.Fatal(err)
+	}
+	checkAddressIndex(t, chain)
+}
@@ -0,0 +1,19 @@
+package main
+
+import "fmt"
+
+// Build metadata, injected at release time with:
+//
+//	go build -ldflags "-X main.BuildVersion=v3.1.0 -X main.BuildCommit=$(git rev-parse --short HEAD) -X main.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// (Not named Version: that is the P2P handshake message type.)
+var (
+	BuildVersion = "dev"
+	BuildCommit  = "dev"
+	BuildDate    = "dev"
+)
+
+// VersionString returns a one-line description of this build
+func VersionString() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", BuildVersion, BuildCommit, BuildDate)
+}
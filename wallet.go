@@ -8,6 +8,7 @@ import (
 	"crypto/x509"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"log"
 	"math/big"
 	"strings"
@@ -120,6 +121,57 @@ func (w Wallet) GetPrivateKey() (ecdsa.PrivateKey, error) {
 	return *key, nil
 }
 
+// Verify checks that the private key matches the public key (compressed or
+// not) and that the public key derives to address
+func (w Wallet) Verify(address string) bool {
+	privKey, err := w.GetPrivateKey()
+	if err != nil {
+		return false
+	}
+	pubKey, err := ParsePubKey(w.PublicKey)
+	if err != nil || pubKey.X.Cmp(privKey.PublicKey.X) != 0 || pubKey.Y.Cmp(privKey.PublicKey.Y) != 0 {
+		return false
+	}
+	return w.GetAddress() == address
+}
+
+// Compress switches the wallet to its 33-byte compressed public key
+// (0x02/0x03 + X). The address changes too, since it hashes the key bytes.
+func (w *Wallet) Compress() {
+	if len(w.PublicKey) != 65 {
+		return
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), w.PublicKey)
+	if x == nil {
+		return
+	}
+	w.PublicKey = elliptic.MarshalCompressed(elliptic.P256(), x, y)
+}
+
+// ParsePubKey decodes a P-256 public key in either ANSI X9.62 form,
+// uncompressed (65 bytes, 0x04 prefix) or compressed (33 bytes, 0x02/0x03
+// prefix), or as raw X || Y (64 bytes), which validator keys also accept.
+func ParsePubKey(pubKey []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+	var x, y *big.Int
+
+	switch {
+	case len(pubKey) == 65 && pubKey[0] == 0x04:
+		x, y = elliptic.Unmarshal(curve, pubKey)
+	case len(pubKey) == 64:
+		x, y = elliptic.Unmarshal(curve, append([]byte{0x04}, pubKey...))
+	case len(pubKey) == 33 && (pubKey[0] == 0x02 || pubKey[0] == 0x03):
+		x, y = elliptic.UnmarshalCompressed(curve, pubKey)
+	default:
+		return nil, fmt.Errorf("invalid public key (%d bytes, expected 65 uncompressed, 64 raw or 33 compressed)", len(pubKey))
+	}
+
+	if x == nil {
+		return nil, errors.New("public key is not a point on P-256")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
 func HashPubKey(pubKey []byte) []byte {
 	publicSHA256 := sha256.Sum256(pubKey)
 
@@ -135,12 +187,19 @@ func HashPubKey(pubKey []byte) []byte {
 
 
 
+// addressLength is the decoded size of an address: version + RIPEMD160 hash + checksum
+const addressLength = 1 + 20 + 4
+
 func ValidateAddress(address string) bool {
 	pubKeyHash, err := Base58Decode([]byte(address))
 	if err != nil {
 		return false
 	}
-	if len(pubKeyHash) < 4 {
+	if len(pubKeyHash) != addressLength {
+		return false
+	}
+	// Reject non-canonical encodings (e.g. a missing or extra leading '1')
+	if string(Base58Encode(pubKeyHash)) != address {
 		return false
 	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-4:]
@@ -2,18 +2,78 @@ package main
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
+	"sort"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	walletFile       = "wallet.dat"
+	walletBackupFile = walletFile + ".bak" // Previous wallet.dat, rotated on every save
+)
+
+// Encrypted wallet backups: backupMagic | salt | nonce | AES-256-GCM(wallet.dat content),
+// keyed with scrypt. Plain backups are a copy of wallet.dat.
+const (
+	backupMagic    = "SOLEWBK1"
+	backupSaltLen  = 16
+	backupScryptN  = 1 << 15
+	backupScryptR  = 8
+	backupScryptP  = 1
+	backupKeyBytes = 32
+)
+
+var (
+	// ErrBackupEncrypted is returned when an encrypted backup is read without a passphrase
+	ErrBackupEncrypted = errors.New("wallet backup is encrypted, a passphrase is required")
+	// ErrBackupPassphrase is returned when the passphrase doesn't decrypt the backup
+	ErrBackupPassphrase = errors.New("wrong passphrase or damaged wallet backup")
+)
+
+// wallet.dat formats. Gob is the original one and stays the default for new
+// files; once a file is JSON it is kept JSON (see Wallets.saveFormat).
+const (
+	WalletFormatGob   = "gob"
+	WalletFormatJSON  = "json"
+	walletJSONVersion = 1
 )
 
-const walletFile = "wallet.dat"
+// WalletFormat is the format wallet.dat is written in (--wallet-format).
+// Empty keeps the format the file was read in.
+var WalletFormat string
+
+// walletFileJSON is wallet.dat in the JSON format: keys as hex, private keys
+// as the raw 32-byte scalar that 'wallet import --privkey' takes
+type walletFileJSON struct {
+	Version int               `json:"version"`
+	Wallets []walletEntryJSON `json:"wallets"`
+}
+
+type walletEntryJSON struct {
+	Address    string `json:"address"`
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"` // Compressed (33 bytes) or not (65)
+}
+
+// ErrWalletCorrupt is returned (wrapped) when wallet.dat exists but cannot be decoded.
+// A missing file is reported with an os.IsNotExist error instead.
+var ErrWalletCorrupt = errors.New("wallet file is corrupt")
 
 type Wallets struct {
 	Wallets map[string]*Wallet
+
+	format string // Format wallet.dat was read in, "" if it didn't exist (not encoded)
 }
 
 func CreateWallets() (*Wallets, error) {
@@ -25,8 +85,13 @@ func CreateWallets() (*Wallets, error) {
 	return &wallets, err
 }
 
-func (ws *Wallets) AddWallet() (string, string) {
+// AddWallet generates a new wallet. With compressed set the wallet uses its
+// 33-byte public key, which gives a different address than the same key uncompressed.
+func (ws *Wallets) AddWallet(compressed bool) (string, string) {
 	wallet, mnemonic := NewWallet()
+	if compressed {
+		wallet.Compress()
+	}
 	address := fmt.Sprintf("%s", wallet.GetAddress())
 
 	ws.Wallets[address] = wallet
@@ -34,11 +99,14 @@ func (ws *Wallets) AddWallet() (string, string) {
 	return address, mnemonic
 }
 
-func (ws *Wallets) RecoverWallet(mnemonic string) (string, error) {
+func (ws *Wallets) RecoverWallet(mnemonic string, compressed bool) (string, error) {
 	wallet, err := MakeWalletFromMnemonic(mnemonic)
 	if err != nil {
 		return "", err
 	}
+	if compressed {
+		wallet.Compress()
+	}
 
 	address := fmt.Sprintf("%s", wallet.GetAddress())
 	ws.Wallets[address] = wallet
@@ -46,11 +114,14 @@ func (ws *Wallets) RecoverWallet(mnemonic string) (string, error) {
 	return address, nil
 }
 
-func (ws *Wallets) ImportWallet(privKeyHex string) (string, error) {
+func (ws *Wallets) ImportWallet(privKeyHex string, compressed bool) (string, error) {
 	wallet, err := MakeWalletFromPrivKeyHex(privKeyHex)
 	if err != nil {
 		return "", err
 	}
+	if compressed {
+		wallet.Compress()
+	}
 
 	address := fmt.Sprintf("%s", wallet.GetAddress())
 	ws.Wallets[address] = wallet
@@ -75,12 +146,15 @@ func (ws *Wallets) GetWalletRef(address string) *Wallet {
 	return ws.Wallets[address]
 }
 
+// GetAddresses returns the wallet addresses sorted lexicographically,
+// so listings are stable across runs.
 func (ws *Wallets) GetAddresses() []string {
 	var addresses []string
 
 	for address := range ws.Wallets {
 		addresses = append(addresses, address)
 	}
+	sort.Strings(addresses)
 
 	return addresses
 }
@@ -92,34 +166,224 @@ func (ws *Wallets) LoadFromFile() error {
 
 	fileContent, err := ioutil.ReadFile(walletFile)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
-	var wallets Wallets
-	gob.Register(elliptic.P256())
-	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-	err = decoder.Decode(&wallets)
+	wallets, err := decodeWallets(fileContent, walletFile)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	ws.Wallets = wallets.Wallets
+	ws.format = wallets.format
+
+	return nil
+}
+
+// saveFormat is the format the wallets are written in: --wallet-format if
+// given, else the one they were read in, else gob
+func (ws *Wallets) saveFormat() string {
+	switch {
+	case WalletFormat != "":
+		return WalletFormat
+	case ws.format != "":
+		return ws.format
+	default:
+		return WalletFormatGob
+	}
+}
+
+// SaveToFile persists the wallets crash-safely: the current wallet.dat is kept
+// as wallet.dat.bak, then the new content atomically replaces wallet.dat.
+func (ws *Wallets) SaveToFile() error {
+	content, err := encodeWallets(ws)
+	if err != nil {
+		return err
+	}
 
+	// Rotate the previous wallet into the backup slot
+	if previous, err := ioutil.ReadFile(walletFile); err == nil {
+		if err := writeFileAtomic(walletBackupFile, previous, 0600); err != nil {
+			return fmt.Errorf("backing up %s: %w", walletFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := writeFileAtomic(walletFile, content, 0600); err != nil {
+		return err
+	}
+	ws.format = ws.saveFormat()
 	return nil
 }
 
-func (ws *Wallets) SaveToFile() {
+// encodeWallets encodes wallets in the wallet.dat format given by saveFormat
+func encodeWallets(ws *Wallets) ([]byte, error) {
+	if ws.saveFormat() == WalletFormatJSON {
+		file := walletFileJSON{Version: walletJSONVersion, Wallets: []walletEntryJSON{}}
+		for _, address := range ws.GetAddresses() {
+			wallet := ws.Wallets[address]
+			privKey, err := wallet.GetPrivateKey()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", address, err)
+			}
+			file.Wallets = append(file.Wallets, walletEntryJSON{
+				Address:    address,
+				PrivateKey: hex.EncodeToString(privKey.D.FillBytes(make([]byte, 32))),
+				PublicKey:  hex.EncodeToString(wallet.PublicKey),
+			})
+		}
+		return json.MarshalIndent(file, "", "  ")
+	}
+
 	var content bytes.Buffer
+	gob.Register(elliptic.P256())
+	if err := gob.NewEncoder(&content).Encode(ws); err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
+
+// decodeWallets reads wallet.dat content in either format: JSON files start
+// with '{', which a gob stream never does. name is only used in errors.
+func decodeWallets(content []byte, name string) (*Wallets, error) {
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '{' {
+		var file walletFileJSON
+		if err := json.Unmarshal(trimmed, &file); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrWalletCorrupt, name, err)
+		}
+		if file.Version != walletJSONVersion {
+			return nil, fmt.Errorf("%w: %s: unsupported version %d", ErrWalletCorrupt, name, file.Version)
+		}
+
+		wallets := &Wallets{Wallets: make(map[string]*Wallet), format: WalletFormatJSON}
+		for _, entry := range file.Wallets {
+			wallet, err := MakeWalletFromPrivKeyHex(entry.PrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: private key of %s: %v", ErrWalletCorrupt, name, entry.Address, err)
+			}
+			if wallet.PublicKey, err = hex.DecodeString(entry.PublicKey); err != nil || !wallet.Verify(entry.Address) {
+				return nil, fmt.Errorf("%w: %s: keys don't match address %s", ErrWalletCorrupt, name, entry.Address)
+			}
+			wallets.Wallets[entry.Address] = wallet
+		}
+		return wallets, nil
+	}
 
+	var wallets Wallets
 	gob.Register(elliptic.P256())
-	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(ws)
+	if err := gob.NewDecoder(bytes.NewReader(content)).Decode(&wallets); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrWalletCorrupt, name, err)
+	}
+	if wallets.Wallets == nil {
+		return nil, fmt.Errorf("%w: %s: no wallets decoded", ErrWalletCorrupt, name)
+	}
+	wallets.format = WalletFormatGob
+	return &wallets, nil
+}
+
+// backupCipher derives the AES-GCM cipher of a backup from its passphrase and salt
+func backupCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, backupScryptN, backupScryptR, backupScryptP, backupKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ExportBackup writes every wallet to path, encrypted when passphrase is set.
+// An unencrypted backup has the wallet.dat format (gob or JSON, as saved). Existing files are not
+// overwritten.
+func (ws *Wallets) ExportBackup(path, passphrase string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	content, err := encodeWallets(ws)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0600)
+	if passphrase != "" {
+		salt := make([]byte, backupSaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		gcm, err := backupCipher(passphrase, salt)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+
+		sealed := append([]byte(backupMagic), salt...)
+		sealed = append(sealed, nonce...)
+		content = gcm.Seal(sealed, nonce, content, []byte(backupMagic))
+	}
+
+	return writeFileAtomic(path, content, 0600)
+}
+
+// ReadWalletBackup loads a backup written by ExportBackup, or any wallet.dat
+func ReadWalletBackup(path, passphrase string) (*Wallets, error) {
+	content, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Panic(err)
+		return nil, err
+	}
+
+	if bytes.HasPrefix(content, []byte(backupMagic)) {
+		if passphrase == "" {
+			return nil, ErrBackupEncrypted
+		}
+		rest := content[len(backupMagic):]
+		if len(rest) < backupSaltLen {
+			return nil, ErrBackupPassphrase
+		}
+		gcm, err := backupCipher(passphrase, rest[:backupSaltLen])
+		if err != nil {
+			return nil, err
+		}
+		rest = rest[backupSaltLen:]
+		if len(rest) < gcm.NonceSize() {
+			return nil, ErrBackupPassphrase
+		}
+		content, err = gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], []byte(backupMagic))
+		if err != nil {
+			return nil, ErrBackupPassphrase
+		}
+	}
+
+	return decodeWallets(content, path)
+}
+
+// MergeResult lists what Merge did with each address of the imported wallets
+type MergeResult struct {
+	Added   []string
+	Skipped []string // Already in the local wallet
+	Invalid []string // Key doesn't match the public key or the address
+}
+
+// Merge adds the wallets of other that are not present yet. Each one must
+// hold a valid private key whose public key derives to its address.
+func (ws *Wallets) Merge(other *Wallets) MergeResult {
+	var result MergeResult
+	for _, address := range other.GetAddresses() {
+		wallet := other.Wallets[address]
+		switch {
+		case wallet == nil || !wallet.Verify(address):
+			result.Invalid = append(result.Invalid, address)
+		case ws.Wallets[address] != nil:
+			result.Skipped = append(result.Skipped, address)
+		default:
+			ws.Wallets[address] = wallet
+			result.Added = append(result.Added, address)
+		}
 	}
+	return result
 }
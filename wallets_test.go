@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// savedWallets creates n wallets and saves them to wallet.dat
+func savedWallets(t *testing.T, n int) *Wallets {
+	t.Helper()
+
+	ws, err := CreateWallets()
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		ws.AddWallet(false)
+	}
+	if err := ws.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestLoadWalletsMissingVsCorrupt(t *testing.T) {
+	chdirTemp(t)
+
+	if _, err := CreateWallets(); !os.IsNotExist(err) {
+		t.Fatalf("missing wallet.dat: %v", err)
+	}
+
+	savedWallets(t, 2)
+	content, err := os.ReadFile(walletFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(walletFile, content[:len(content)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateWallets(); !errors.Is(err, ErrWalletCorrupt) {
+		t.Fatalf("truncated wallet.dat: %v", err)
+	}
+}
+
+func TestSaveWalletsKeepsBackup(t *testing.T) {
+	chdirTemp(t)
+
+	first := savedWallets(t, 1)
+	second := savedWallets(t, 1)
+
+	backup, err := ReadWalletBackup(walletBackupFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backup.Wallets) != len(first.Wallets) {
+		t.Fatalf("backup holds %d wallets, want the previous %d", len(backup.Wallets), len(first.Wallets))
+	}
+	current, err := CreateWallets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current.Wallets) != len(second.Wallets) {
+		t.Fatalf("wallet.dat holds %d wallets, want %d", len(current.Wallets), len(second.Wallets))
+	}
+}
+
+func TestInterruptedWalletSaveKeepsPrevious(t *testing.T) {
+	chdirTemp(t)
+
+	saved := savedWallets(t, 2)
+
+	// The backup rotation can't complete: the save must stop before wallet.dat
+	if err := os.Mkdir(walletBackupFile, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(walletBackupFile, "blocker"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	saved.AddWallet(false)
+	if err := saved.SaveToFile(); err == nil {
+		t.Fatal("save succeeded without its backup")
+	}
+
+	current, err := CreateWallets()
+	if err != nil {
+		t.Fatalf("wallet.dat damaged by the failed save: %v", err)
+	}
+	if len(current.Wallets) != 2 {
+		t.Fatalf("wallet.dat holds %d wallets, want the previous 2", len(current.Wallets))
+	}
+}
+
+func TestGetAddressesSorted(t *testing.T) {
+	ws := &Wallets{Wallets: make(map[string]*Wallet)}
+	for i := 0; i < 20; i++ {
+		ws.AddWallet(i%2 == 0)
+	}
+
+	first := ws.GetAddresses()
+	if !sort.StringsAreSorted(first) {
+		t.Fatalf("addresses not sorted: %v", first)
+	}
+	for i := 0; i < 5; i++ {
+		if again := ws.GetAddresses(); !slices.Equal(again, first) {
+			t.Fatalf("order changed between calls: %v then %v", first, again)
+		}
+	}
+}
+
+// withWalletFormat sets --wallet-format for the test
+func withWalletFormat(t *testing.T, format string) {
+	t.Helper()
+
+	previous := WalletFormat
+	WalletFormat = format
+	t.Cleanup(func() { WalletFormat = previous })
+}
+
+func TestWalletsRoundTripBothFormats(t *testing.T) {
+	ws := &Wallets{Wallets: make(map[string]*Wallet)}
+	for i := 0; i < 3; i++ {
+		ws.AddWallet(i == 0)
+	}
+
+	for _, format := range []string{WalletFormatGob, WalletFormatJSON} {
+		withWalletFormat(t, format)
+		content, err := encodeWallets(ws)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		decoded, err := decodeWallets(content, walletFile)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if decoded.format != format {
+			t.Fatalf("%s file read as %s", format, decoded.format)
+		}
+		if !slices.Equal(decoded.GetAddresses(), ws.GetAddresses()) {
+			t.Fatalf("%s: addresses %v, want %v", format, decoded.GetAddresses(), ws.GetAddresses())
+		}
+		for address, wallet := range ws.Wallets {
+			got := decoded.Wallets[address]
+			if !bytes.Equal(got.PublicKey, wallet.PublicKey) || !bytes.Equal(got.PrivateKey, wallet.PrivateKey) {
+				t.Fatalf("%s: keys of %s changed in the round trip", format, address)
+			}
+		}
+	}
+}
+
+func TestLoadWalletsDoesNotConvert(t *testing.T) {
+	chdirTemp(t)
+
+	savedWallets(t, 2)
+	before, err := os.ReadFile(walletFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A read-only command leaves the gob file as it is
+	withWalletFormat(t, WalletFormatJSON)
+	wallets := loadWallets(false)
+	if after, err := os.ReadFile(walletFile); err != nil || !bytes.Equal(after, before) {
+		t.Fatal("wallet.dat rewritten by a command that only reads it")
+	}
+
+	// The next save converts it
+	if err := wallets.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+	converted, err := CreateWallets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted.format != WalletFormatJSON || len(converted.Wallets) != 2 {
+		t.Fatalf("saved as %s with %d wallets, want json with 2", converted.format, len(converted.Wallets))
+	}
+}
@@ -125,12 +125,46 @@ type WsBlockEvent struct {
 	Transactions []WsBlockTxSummary `json:"transactions"`
 }
 
+type WsReorgEvent struct {
+	Event                string   `json:"event"`
+	OldTip               string   `json:"old_tip"`
+	NewTip               string   `json:"new_tip"`
+	CommonAncestorHeight int      `json:"common_ancestor_height"`
+	Depth                int      `json:"depth"`
+	DisconnectedBlocks   []string `json:"disconnected_blocks"`
+}
+
+type WsPeerEvent struct {
+	Event      string `json:"event"`  // Always "peer"
+	Action     string `json:"action"` // "connected" or "disconnected"
+	PeerID     string `json:"peer_id"`
+	Addr       string `json:"addr"`
+	Direction  string `json:"direction"` // "inbound" or "outbound"
+	TotalPeers int    `json:"total_peers"`
+}
+
+type WsTxConfirmedEvent struct {
+	Event       string `json:"event"`
+	TxID        string `json:"txid"`
+	BlockHash   string `json:"block_hash"`
+	BlockHeight int    `json:"block_height"`
+}
+
+func NewWsTxConfirmedEvent(c TxConfirmation) WsTxConfirmedEvent {
+	return WsTxConfirmedEvent{
+		Event:       "tx_confirmed",
+		TxID:        c.TxID,
+		BlockHash:   c.BlockHash,
+		BlockHeight: c.BlockHeight,
+	}
+}
+
 func BroadcastMempoolTx(hub *EventHub, tx *Transaction) {
 	if hub == nil {
 		return
 	}
 
-	var inputs []WsInput
+	inputs := make([]WsInput, 0, len(tx.Vin))
 	if tx.IsCoinbase() {
 		inputs = append(inputs, WsInput{Address: "COINBASE"})
 	} else {
@@ -141,7 +175,7 @@ func BroadcastMempoolTx(hub *EventHub, tx *Transaction) {
 		}
 	}
 
-	var outputs []WsOutput
+	outputs := make([]WsOutput, 0, len(tx.Vout))
 	var memo string
 
 	for _, vout := range tx.Vout {
@@ -181,20 +215,20 @@ func BroadcastBlock(hub *EventHub, block *Block) {
 		return
 	}
 
-	var txSummaries []WsBlockTxSummary
+	txSummaries := make([]WsBlockTxSummary, 0, len(block.Transactions))
 	for _, tx := range block.Transactions {
 		if tx.IsCoinbase() {
 			continue
 		}
 
-		var inputs []WsInput
+		inputs := make([]WsInput, 0, len(tx.Vin))
 		for _, vin := range tx.Vin {
 			inputs = append(inputs, WsInput{
 				Address: AddressFromPubKeyHash(HashPubKey(vin.PubKey)),
 			})
 		}
 
-		var outputs []WsOutput
+		outputs := make([]WsOutput, 0, len(tx.Vout))
 		for _, vout := range tx.Vout {
 			if vout.IsOPReturn() {
 				continue
@@ -231,3 +265,58 @@ func BroadcastBlock(hub *EventHub, block *Block) {
 	default:
 	}
 }
+
+func BroadcastReorg(hub *EventHub, reorg ReorgInfo) {
+	if hub == nil {
+		return
+	}
+
+	disconnected := make([]string, 0, len(reorg.Disconnected))
+	for _, hash := range reorg.Disconnected {
+		disconnected = append(disconnected, hex.EncodeToString(hash))
+	}
+
+	evt := WsReorgEvent{
+		Event:                "reorg",
+		OldTip:               hex.EncodeToString(reorg.OldTip),
+		NewTip:               hex.EncodeToString(reorg.NewTip),
+		CommonAncestorHeight: reorg.CommonAncestorHeight,
+		Depth:                reorg.Depth,
+		DisconnectedBlocks:   disconnected,
+	}
+
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return
+	}
+
+	select {
+	case hub.Broadcast <- payload:
+	default:
+	}
+}
+
+func BroadcastPeerEvent(hub *EventHub, action, peerID, addr, direction string, totalPeers int) {
+	if hub == nil {
+		return
+	}
+
+	evt := WsPeerEvent{
+		Event:      "peer",
+		Action:     action,
+		PeerID:     peerID,
+		Addr:       addr,
+		Direction:  direction,
+		TotalPeers: totalPeers,
+	}
+
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return
+	}
+
+	select {
+	case hub.Broadcast <- payload:
+	default:
+	}
+}